@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"twitter-clone-backend/internal/adapters/auth"
+	"twitter-clone-backend/internal/config"
+	"twitter-clone-backend/internal/ports"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// newAuthUseCase initializes token authentication as selected by AUTH_MODE.
+// It returns nil in header mode, where the X-User-ID header is trusted
+func newAuthUseCase(cfg *config.Config, repo storage, hasher ports.PasswordHasher, logger ports.Logger) (*usecases.AuthUseCase, error) {
+	switch cfg.AuthMode {
+	case "header":
+		logger.Warn("AUTH_MODE=header trusts the X-User-ID header, use it only for local development")
+		return nil, nil
+	case "jwt":
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.AuthMode)
+	}
+
+	tokens, err := newTokenService(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revocations are kept by the storage adapter, so they survive restarts
+	// and are shared between instances
+	return usecases.NewAuthUseCase(tokens, repo, repo, hasher, repo, logger, usecases.AuthConfig{
+		AccessTokenTTL:        cfg.AccessTokenTTL,
+		RefreshTokenTTL:       cfg.RefreshTokenTTL,
+		MaxLoginAttempts:      cfg.LoginMaxAttempts,
+		MaxLoginAttemptsPerIP: cfg.LoginMaxPerIP,
+		LoginThrottleWindow:   cfg.LoginWindow,
+	}), nil
+}
+
+// newTokenService creates the JWT signer selected by JWT_ALGORITHM
+func newTokenService(cfg *config.Config, logger ports.Logger) (*auth.JWTService, error) {
+	switch cfg.JWTAlgorithm {
+	case auth.AlgorithmHS256:
+		secret := []byte(cfg.JWTSecret)
+		if len(secret) == 0 {
+			// Works out of the box, but tokens do not survive restarts
+			secret = make([]byte, auth.MinHMACSecretLength)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+			logger.Warn("JWT_SECRET not set, using a random secret: tokens are invalidated on restart")
+		}
+		return auth.NewHMACService(secret, cfg.JWTIssuer)
+	case auth.AlgorithmEdDSA:
+		data, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_PRIVATE_KEY_FILE: %w", err)
+		}
+		privateKey, err := auth.ParseEd25519PrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewEd25519Service(privateKey, cfg.JWTIssuer)
+	default:
+		return nil, fmt.Errorf("unknown JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+}
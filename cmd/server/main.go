@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"twitter-clone-backend/internal/adapters/auth"
+	"twitter-clone-backend/internal/adapters/cache"
+	"twitter-clone-backend/internal/adapters/events"
 	httpAdapters "twitter-clone-backend/internal/adapters/http"
 	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/adapters/webhooks"
 	"twitter-clone-backend/internal/config"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
 	"twitter-clone-backend/internal/usecases"
 	"twitter-clone-backend/pkg/logger"
 )
@@ -19,21 +30,152 @@ func main() {
 	appLogger.Info("Starting Twitter Clone Backend", "port", cfg.Port, "storage", cfg.StorageType)
 
 	// Initialize repositories
-	repo := memory.NewRepositories()
+	repo, closeStorage, err := openStorage(cfg, appLogger)
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	// Initialize timeline cache (optional)
+	var timelineCache ports.CacheService
+	if cfg.EnableCache {
+		switch cfg.CacheType {
+		case "redis":
+			redisCache, err := cache.NewRedisCache(cfg.RedisURI, cfg.CacheTTL)
+			if err != nil {
+				log.Fatal("Failed to configure redis cache:", err)
+			}
+			// Redis may start after the app (docker-compose), so only warn here
+			if err := redisCache.Ping(context.Background()); err != nil {
+				appLogger.Warn("redis not reachable yet, cache misses until it is", "error", err)
+			}
+			timelineCache = redisCache
+		case "memory":
+			timelineCache = cache.NewMemoryCache(cfg.CacheMaxEntries, cfg.CacheTTL)
+		default:
+			log.Fatal("Unknown cache type: ", cfg.CacheType)
+		}
+		appLogger.Info("Timeline cache enabled", "type", cfg.CacheType, "ttl", cfg.CacheTTL)
+	}
+
+	// Initialize precomputed home timelines (fan-out on write)
+	var useCaseOpts []usecases.Option
+	var homeTimelines *usecases.HomeTimelines
+	if cfg.EnableFanout {
+		timelineStore := memory.NewTimelineStore(cfg.HomeTimelineSize)
+		homeTimelines = usecases.NewHomeTimelines(timelineStore, repo, repo, timelineCache, appLogger, usecases.HomeTimelinesConfig{
+			Workers:            cfg.FanoutWorkers,
+			CelebrityThreshold: cfg.CelebrityThreshold,
+		})
+		useCaseOpts = append(useCaseOpts, usecases.WithHomeTimelines(homeTimelines))
+		appLogger.Info("Home timeline fan-out enabled", "workers", cfg.FanoutWorkers, "size", cfg.HomeTimelineSize, "celebrityThreshold", cfg.CelebrityThreshold)
+	}
+
+	// Initialize the domain event bus and its subscribers
+	eventBus := events.NewBus(appLogger, events.BusConfig{
+		QueueSize:    cfg.EventQueueSize,
+		MaxAttempts:  cfg.EventMaxAttempts,
+		RetryBackoff: cfg.EventRetryBackoff,
+	})
+	if homeTimelines != nil {
+		eventBus.Subscribe("home-timelines", homeTimelines.HandleEvent,
+			domain.EventTweetCreated, domain.EventTweetDeleted, domain.EventUserFollowed, domain.EventUserUnfollowed)
+	}
+	if timelineCache != nil {
+		invalidator := usecases.NewTimelineCacheInvalidator(repo, timelineCache, appLogger, useCaseOpts...)
+		eventBus.Subscribe("timeline-cache", invalidator.HandleEvent,
+			domain.EventTweetCreated, domain.EventTweetDeleted, domain.EventUserFollowed, domain.EventUserUnfollowed)
+	}
+	notificationUseCase := usecases.NewNotificationUseCase(repo, appLogger)
+	eventBus.Subscribe("notifications", notificationUseCase.HandleEvent,
+		domain.EventTweetCreated, domain.EventTweetLiked, domain.EventUserFollowed)
+	webhookSender := webhooks.NewHTTPSender(cfg.WebhookTimeout, cfg.WebhookAllowPrivate)
+	webhookUseCase := usecases.NewWebhookUseCase(repo, webhookSender, appLogger, usecases.WebhookConfig{
+		Workers:             cfg.WebhookWorkers,
+		MaxAttempts:         cfg.WebhookMaxAttempts,
+		RetryBackoff:        cfg.WebhookBackoff,
+		DisableAfter:        cfg.WebhookDisableAfter,
+		AllowPrivateTargets: cfg.WebhookAllowPrivate,
+	})
+	eventBus.Subscribe("webhooks", webhookUseCase.HandleEvent,
+		domain.EventTweetCreated, domain.EventTweetDeleted, domain.EventUserFollowed, domain.EventUserUnfollowed)
+	timelineStreams := usecases.NewTimelineStreams(repo, appLogger, usecases.TimelineStreamsConfig{
+		BufferSize: cfg.StreamBufferSize,
+		Heartbeat:  cfg.StreamHeartbeat,
+	})
+	eventBus.Subscribe("timeline-streams", timelineStreams.HandleEvent, domain.EventTweetCreated)
+
+	// Relay the events stored in the outbox with each change to the bus
+	outboxRelay := usecases.NewOutboxRelay(repo, eventBus, appLogger, usecases.OutboxRelayConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		RetryBackoff: cfg.OutboxRetryBackoff,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+	})
+	outboxRelay.Start()
 
 	// Initialize use cases
-	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger)
-	followUseCase := usecases.NewFollowUseCase(repo, repo, nil, appLogger)
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, timelineCache, appLogger, useCaseOpts...)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, appLogger)
+
+	// Initialize authentication (JWT, or trusted X-User-ID header for development)
+	passwordHasher := auth.NewArgon2Hasher(auth.DefaultArgon2Params)
+	authUseCase, err := newAuthUseCase(cfg, repo, passwordHasher, appLogger)
+	if err != nil {
+		log.Fatal("Failed to initialize authentication:", err)
+	}
+	handlerOpts := []httpAdapters.Option{
+		httpAdapters.WithAPIKeys(usecases.NewAPIKeyUseCase(repo, appLogger)),
+		httpAdapters.WithLikes(usecases.NewLikeUseCase(repo, repo, repo, appLogger, useCaseOpts...)),
+		httpAdapters.WithNotifications(notificationUseCase),
+		httpAdapters.WithWebhooks(webhookUseCase),
+		httpAdapters.WithTimelineStreams(timelineStreams),
+	}
+	var userOpts []usecases.Option
+	if authUseCase != nil {
+		handlerOpts = append(handlerOpts, httpAdapters.WithAuth(authUseCase))
+		userOpts = append(userOpts, usecases.WithCredentials(repo, passwordHasher))
+		appLogger.Info("Token authentication enabled", "algorithm", cfg.JWTAlgorithm)
+	}
+	userUseCase := usecases.NewUserUseCase(repo, appLogger, userOpts...)
 
 	// Initialize HTTP handlers
-	handlers := httpAdapters.NewHandlers(tweetUseCase, followUseCase)
+	handlers := httpAdapters.NewHandlers(tweetUseCase, followUseCase, userUseCase, appLogger, handlerOpts...)
 
 	// Configure routes
 	router := httpAdapters.SetupRoutes(handlers)
 
 	// Start server
-	appLogger.Info("Server starting", "port", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	// Open timeline streams never end on their own
+	server.RegisterOnShutdown(timelineStreams.Close)
+	go func() {
+		appLogger.Info("Server starting", "port", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Wait for a termination signal and shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	appLogger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		appLogger.Error("failed to shut down server", err)
+	}
+	if err := outboxRelay.Stop(shutdownCtx); err != nil {
+		appLogger.Error("failed to stop outbox relay", err)
+	}
+	if err := eventBus.Close(shutdownCtx); err != nil {
+		appLogger.Error("failed to deliver pending events", err)
+	}
+	if err := webhookUseCase.Close(shutdownCtx); err != nil {
+		appLogger.Error("failed to deliver pending webhooks", err)
+	}
+	if err := closeStorage(); err != nil {
+		appLogger.Error("failed to close storage", err)
 	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"twitter-clone-backend/internal/adapters/file"
+	"twitter-clone-backend/internal/adapters/memory"
+	mongoAdapters "twitter-clone-backend/internal/adapters/mongo"
+	sqlAdapters "twitter-clone-backend/internal/adapters/sql"
+	"twitter-clone-backend/internal/config"
+	"twitter-clone-backend/internal/ports"
+
+	// SQL drivers selectable with SQL_DRIVER
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// storage groups the repositories provided by a storage adapter
+type storage interface {
+	ports.TweetRepository
+	ports.FollowRepository
+	ports.LikeRepository
+	ports.NotificationRepository
+	ports.UserRepository
+	ports.CredentialRepository
+	ports.APIKeyRepository
+	ports.WebhookRepository
+	ports.OutboxRepository
+	ports.RevocationList
+}
+
+// openStorage initializes the storage adapter selected by STORAGE_TYPE.
+// The returned function releases its resources on shutdown
+func openStorage(cfg *config.Config, logger ports.Logger) (storage, func() error, error) {
+	switch cfg.StorageType {
+	case "memory":
+		return memory.NewRepositories(), func() error { return nil }, nil
+	case "file":
+		repo, err := file.Open(file.Options{
+			Dir:              cfg.DataDir,
+			FsyncPolicy:      file.FsyncPolicy(cfg.FsyncPolicy),
+			FsyncInterval:    cfg.FsyncInterval,
+			SnapshotInterval: cfg.SnapshotInterval,
+			Logger:           logger,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	case "sql":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		repo, err := sqlAdapters.Open(ctx, cfg.SQLDriver, cfg.SQLDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	case "mongo", "mongodb":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		repo, err := mongoAdapters.Open(ctx, cfg.MongoURI, cfg.MongoDatabase)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, repo.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage type %q", cfg.StorageType)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// clockSkew is the tolerance when checking token times
+const clockSkew = 30 * time.Second
+
+// MinHMACSecretLength is the minimum HS256 secret size in bytes
+const MinHMACSecretLength = 32
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// jwtClaims is the JSON representation of domain.TokenClaims
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	ID        string `json:"jti"`
+	Use       string `json:"token_use"`
+	Family    string `json:"fam,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// JWTService implements ports.TokenService with compact JWS tokens.
+// Only the configured algorithm is accepted when verifying, so a token
+// can not choose how it is checked (e.g. "none" or HS256 with a public key)
+type JWTService struct {
+	algorithm  string
+	issuer     string
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// NewHMACService creates a service signing with HMAC-SHA256
+func NewHMACService(secret []byte, issuer string) (*JWTService, error) {
+	if len(secret) < MinHMACSecretLength {
+		return nil, errors.New("HMAC secret must have at least 32 bytes")
+	}
+
+	return &JWTService{
+		algorithm: AlgorithmHS256,
+		issuer:    issuer,
+		secret:    secret,
+	}, nil
+}
+
+// NewEd25519Service creates a service signing with an Ed25519 key
+func NewEd25519Service(privateKey ed25519.PrivateKey, issuer string) (*JWTService, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Ed25519 private key")
+	}
+
+	return &JWTService{
+		algorithm:  AlgorithmEdDSA,
+		issuer:     issuer,
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 Ed25519 private key
+// (as generated by `openssl genpkey -algorithm ed25519`)
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("PEM block is not an Ed25519 private key")
+	}
+	return privateKey, nil
+}
+
+// Algorithm returns the signing algorithm
+func (s *JWTService) Algorithm() string {
+	return s.algorithm
+}
+
+// Sign returns a signed token with the given claims
+func (s *JWTService) Sign(claims domain.TokenClaims) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: s.algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(jwtClaims{
+		Issuer:    s.issuer,
+		Subject:   claims.Subject,
+		ID:        claims.ID,
+		Use:       claims.Use,
+		Family:    claims.Family,
+		IssuedAt:  claims.IssuedAt.Unix(),
+		ExpiresAt: claims.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	return signingInput + "." + encodeSegment(s.signature([]byte(signingInput))), nil
+}
+
+// Verify checks the signature, issuer and expiration of a token and
+// returns its claims. Any failure is reported as domain.ErrInvalidToken
+func (s *JWTService) Verify(token string) (*domain.TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, domain.ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Algorithm != s.algorithm {
+		return nil, domain.ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !s.verifySignature([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	now := time.Now()
+	if claims.Issuer != s.issuer || claims.Subject == "" ||
+		!now.Before(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) ||
+		now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return &domain.TokenClaims{
+		ID:        claims.ID,
+		Subject:   claims.Subject,
+		Use:       claims.Use,
+		Family:    claims.Family,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
+
+func (s *JWTService) signature(signingInput []byte) []byte {
+	if s.algorithm == AlgorithmEdDSA {
+		return ed25519.Sign(s.privateKey, signingInput)
+	}
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(signingInput)
+	return mac.Sum(nil)
+}
+
+func (s *JWTService) verifySignature(signingInput, signature []byte) bool {
+	if s.algorithm == AlgorithmEdDSA {
+		return ed25519.Verify(s.publicKey, signingInput, signature)
+	}
+	return hmac.Equal(s.signature(signingInput), signature)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
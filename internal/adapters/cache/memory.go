@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// Default cache settings (README: timeline TTL of 1 hour, LRU eviction)
+const (
+	DefaultMaxEntries = 10000
+	DefaultTTL        = time.Hour
+)
+
+// Stats contains cache usage counters
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// entry is a cached timeline with its expiration time
+type entry struct {
+	userID    string
+	tweets    []*domain.Tweet
+	expiresAt time.Time
+}
+
+// MemoryCache implements ports.CacheService with an in-process LRU cache
+// bounded by number of entries, where every entry expires after its TTL
+type MemoryCache struct {
+	maxEntries int
+	ttl        time.Duration
+	items      map[string]*list.Element
+	order      *list.List // front = most recently used
+	stats      Stats
+	now        func() time.Time
+	mu         sync.Mutex
+}
+
+// NewMemoryCache creates a new in-memory timeline cache
+func NewMemoryCache(maxEntries int, ttl time.Duration) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// GetTimeline returns the cached timeline of a user, or nil if it is not cached
+func (c *MemoryCache) GetTimeline(ctx context.Context, userID string) ([]*domain.Tweet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.items[userID]
+	if !exists {
+		c.stats.Misses++
+		return nil, nil
+	}
+
+	cached := element.Value.(*entry)
+	if !c.now().Before(cached.expiresAt) {
+		c.removeElement(element)
+		c.stats.Misses++
+		return nil, nil
+	}
+
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+
+	// Return a copy so callers cannot modify the cached slice
+	tweets := make([]*domain.Tweet, len(cached.tweets))
+	copy(tweets, cached.tweets)
+	return tweets, nil
+}
+
+// SetTimeline caches the timeline of a user using the default TTL
+func (c *MemoryCache) SetTimeline(ctx context.Context, userID string, tweets []*domain.Tweet) error {
+	return c.SetTimelineWithTTL(ctx, userID, tweets, c.ttl)
+}
+
+// SetTimelineWithTTL caches the timeline of a user with a specific TTL
+func (c *MemoryCache) SetTimelineWithTTL(ctx context.Context, userID string, tweets []*domain.Tweet, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	stored := make([]*domain.Tweet, len(tweets))
+	copy(stored, tweets)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+
+	if element, exists := c.items[userID]; exists {
+		cached := element.Value.(*entry)
+		cached.tweets = stored
+		cached.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.items[userID] = c.order.PushFront(&entry{
+		userID:    userID,
+		tweets:    stored,
+		expiresAt: expiresAt,
+	})
+
+	// Evict least recently used entries when over capacity
+	for c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+
+	return nil
+}
+
+// InvalidateTimeline removes the cached timeline of a user
+func (c *MemoryCache) InvalidateTimeline(ctx context.Context, userID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.items[userID]; exists {
+		c.removeElement(element)
+	}
+
+	return nil
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *MemoryCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// removeElement removes an element from the list and the index (caller must hold the lock)
+func (c *MemoryCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry).userID)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// timelineKeyPrefix namespaces timeline keys in Redis
+const timelineKeyPrefix = "timeline:"
+
+// RedisCache implements ports.CacheService on top of Redis. Timelines are
+// stored as serialized lists with a TTL, so they are shared across replicas
+// and survive application restarts
+type RedisCache struct {
+	client *redisClient
+	ttl    time.Duration
+}
+
+// NewRedisCache creates a Redis-backed timeline cache from a REDIS_URI
+func NewRedisCache(uri string, ttl time.Duration) (*RedisCache, error) {
+	opts, err := ParseRedisURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &RedisCache{
+		client: newRedisClient(opts),
+		ttl:    ttl,
+	}, nil
+}
+
+// Ping verifies that the server is reachable
+func (c *RedisCache) Ping(ctx context.Context) error {
+	_, err := c.client.Do(ctx, "PING")
+	return err
+}
+
+// Close releases the pooled connections
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+// GetTimeline returns the cached timeline of a user, or nil if it is not cached
+func (c *RedisCache) GetTimeline(ctx context.Context, userID string) ([]*domain.Tweet, error) {
+	reply, err := c.client.Do(ctx, "GET", timelineKeyPrefix+userID)
+	if err == errNilReply {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := reply.(string)
+	tweets := []*domain.Tweet{}
+	if err := json.Unmarshal([]byte(payload), &tweets); err != nil {
+		return nil, err
+	}
+
+	return tweets, nil
+}
+
+// SetTimeline caches the timeline of a user using the default TTL
+func (c *RedisCache) SetTimeline(ctx context.Context, userID string, tweets []*domain.Tweet) error {
+	return c.SetTimelineWithTTL(ctx, userID, tweets, c.ttl)
+}
+
+// SetTimelineWithTTL caches the timeline of a user with a specific TTL
+func (c *RedisCache) SetTimelineWithTTL(ctx context.Context, userID string, tweets []*domain.Tweet, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if tweets == nil {
+		tweets = []*domain.Tweet{}
+	}
+
+	payload, err := json.Marshal(tweets)
+	if err != nil {
+		return err
+	}
+
+	// Millisecond precision so sub-second TTLs are honored
+	ttlMillis := ttl.Milliseconds()
+	if ttlMillis <= 0 {
+		ttlMillis = 1
+	}
+
+	_, err = c.client.Do(ctx, "SET", timelineKeyPrefix+userID, string(payload), "PX", strconv.FormatInt(ttlMillis, 10))
+	return err
+}
+
+// InvalidateTimeline removes the cached timeline of a user
+func (c *RedisCache) InvalidateTimeline(ctx context.Context, userID string) error {
+	_, err := c.client.Do(ctx, "DEL", timelineKeyPrefix+userID)
+	return err
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default connection settings for the Redis client
+const (
+	defaultRedisPort    = "6379"
+	defaultDialTimeout  = 5 * time.Second
+	defaultIOTimeout    = 3 * time.Second
+	defaultMaxIdleConns = 10
+)
+
+// errNilReply is returned when Redis answers with a null bulk string
+var errNilReply = errors.New("redis: nil reply")
+
+// redisError is an error reply sent by the server (e.g. "-ERR unknown command")
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisOptions contains the connection settings parsed from a REDIS_URI
+type RedisOptions struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+}
+
+// ParseRedisURI parses URIs like redis://[[user]:password@]host[:port][/db]
+// and rediss:// for TLS connections
+func ParseRedisURI(uri string) (*RedisOptions, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URI: %w", err)
+	}
+
+	opts := &RedisOptions{}
+	switch parsed.Scheme {
+	case "redis":
+	case "rediss":
+		opts.TLS = true
+	default:
+		return nil, fmt.Errorf("invalid redis URI scheme %q", parsed.Scheme)
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return nil, errors.New("invalid redis URI: missing host")
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = defaultRedisPort
+	}
+	opts.Addr = net.JoinHostPort(host, port)
+
+	if parsed.User != nil {
+		opts.Username = parsed.User.Username()
+		opts.Password, _ = parsed.User.Password()
+	}
+
+	if db := strings.Trim(parsed.Path, "/"); db != "" {
+		opts.DB, err = strconv.Atoi(db)
+		if err != nil || opts.DB < 0 {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+
+	return opts, nil
+}
+
+// redisClient is a minimal RESP2 client with a pool of idle connections
+type redisClient struct {
+	opts *RedisOptions
+	idle []*redisConn
+	mu   sync.Mutex
+}
+
+// redisConn is a single connection to the server
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// newRedisClient creates a client; connections are opened lazily
+func newRedisClient(opts *RedisOptions) *redisClient {
+	return &redisClient{opts: opts}
+}
+
+// Do sends a command and returns its reply. Replies are decoded as
+// string (simple and bulk strings), int64, []interface{} or errNilReply
+func (c *redisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(defaultIOTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.conn.SetDeadline(deadline)
+
+	reply, err := conn.roundTrip(args)
+	if err != nil {
+		var replyErr redisError
+		if errors.As(err, &replyErr) || err == errNilReply {
+			// The connection is still in a consistent state
+			c.put(conn)
+			return nil, err
+		}
+		conn.conn.Close()
+		return nil, err
+	}
+
+	c.put(conn)
+	return reply, nil
+}
+
+// Close closes all idle connections
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, conn := range c.idle {
+		conn.conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// get returns an idle connection or dials a new one
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	return c.dial(ctx)
+}
+
+// put returns a healthy connection to the pool
+func (c *redisClient) put(conn *redisConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.idle) >= defaultMaxIdleConns {
+		conn.conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// dial opens a connection and runs the AUTH/SELECT handshake
+func (c *redisClient) dial(ctx context.Context) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout}
+
+	var netConn net.Conn
+	var err error
+	if c.opts.TLS {
+		host, _, _ := net.SplitHostPort(c.opts.Addr)
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+		netConn, err = tlsDialer.DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{
+		conn:   netConn,
+		reader: bufio.NewReader(netConn),
+		writer: bufio.NewWriter(netConn),
+	}
+	netConn.SetDeadline(time.Now().Add(defaultIOTimeout))
+
+	if c.opts.Password != "" {
+		args := []string{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			args = []string{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if _, err := conn.roundTrip(args); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if c.opts.DB != 0 {
+		if _, err := conn.roundTrip([]string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// roundTrip writes a command as a RESP array of bulk strings and reads the reply
+func (c *redisConn) roundTrip(args []string) (interface{}, error) {
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.reader)
+}
+
+// readReply decodes a single RESP2 reply
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if size < 0 {
+			return nil, errNilReply
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line[1:])
+		}
+		if count < 0 {
+			return nil, errNilReply
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := readReply(reader)
+			if err != nil && err != errNilReply {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
+
+// readLine reads a CRLF terminated line without the terminator
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
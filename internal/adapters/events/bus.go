@@ -0,0 +1,332 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// Default bus settings
+const (
+	DefaultQueueSize    = 1024
+	DefaultMaxAttempts  = 5
+	DefaultRetryBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff   = 10 * time.Second
+	DefaultDedupeWindow = 10000
+)
+
+var (
+	// ErrQueueFull is returned when a subscriber's queue has no room for
+	// an event, which is dropped for that subscriber
+	ErrQueueFull = errors.New("event queue is full")
+
+	// ErrBusClosed is returned when publishing to a closed bus
+	ErrBusClosed = errors.New("event bus is closed")
+)
+
+// BusConfig contains the delivery settings of the bus
+type BusConfig struct {
+	// QueueSize is the number of events a subscriber can have pending
+	QueueSize int
+	// MaxAttempts is how many times a failing handler gets an event
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry, doubled on every
+	// retry up to MaxBackoff
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// DedupeWindow is how many handled event IDs a subscriber remembers to
+	// skip events delivered again
+	DedupeWindow int
+}
+
+// Stats contains event delivery counters
+type Stats struct {
+	Delivered  uint64 `json:"delivered"`
+	Retried    uint64 `json:"retried"`
+	Failed     uint64 `json:"failed"`     // handler kept failing after every attempt
+	Dropped    uint64 `json:"dropped"`    // subscriber queue was full
+	Duplicates uint64 `json:"duplicates"` // already handled by the subscriber
+	Pending    int    `json:"pending"`
+}
+
+// delivery is an event queued for a subscriber
+type delivery struct {
+	ctx   context.Context
+	event domain.Event
+}
+
+// subscriber receives the events of some types (all if none) in the
+// order they were published, from a queue of its own
+type subscriber struct {
+	name    string
+	handler ports.EventHandler
+	types   map[string]bool
+	queue   chan delivery
+	handled *dedupeWindow // only used by the worker
+}
+
+// dedupeWindow remembers the IDs of the last handled events
+type dedupeWindow struct {
+	ids   map[string]bool
+	order []string // ring buffer, oldest at next
+	next  int
+}
+
+func newDedupeWindow(size int) *dedupeWindow {
+	return &dedupeWindow{ids: make(map[string]bool, size), order: make([]string, 0, size)}
+}
+
+func (w *dedupeWindow) contains(id string) bool {
+	return w.ids[id]
+}
+
+// add remembers id, forgetting the oldest one if the window is full
+func (w *dedupeWindow) add(id string) {
+	if w.ids[id] {
+		return
+	}
+	if len(w.order) < cap(w.order) {
+		w.order = append(w.order, id)
+	} else {
+		delete(w.ids, w.order[w.next])
+		w.order[w.next] = id
+		w.next = (w.next + 1) % len(w.order)
+	}
+	w.ids[id] = true
+}
+
+// Bus implements ports.EventPublisher with in-process asynchronous
+// delivery. Every subscriber has a bounded queue and a worker, so a slow or
+// failing subscriber never delays the others nor the publisher: failures
+// are retried with exponential backoff, panics are recovered, and events
+// that do not fit in a full queue are dropped. An event published again
+// (same ID) is skipped by the subscribers that already handled it, so
+// at-least-once publishers do not cause duplicate side effects. The
+// window is kept in memory only, so after a restart handlers get events
+// again and must be idempotent. Events a subscriber kept failing are
+// reported by Flush, so the publisher can publish them again later
+type Bus struct {
+	config      BusConfig
+	logger      ports.Logger
+	subscribers []*subscriber
+	stats       Stats
+	failed      map[string]bool // IDs of the failed events, reset by Flush
+	idle        chan struct{}   // closed when no event is pending
+	closed      bool
+	workers     sync.WaitGroup
+	mu          sync.Mutex
+}
+
+// NewBus creates a new event bus without subscribers
+func NewBus(logger ports.Logger, config BusConfig) *Bus {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+	if config.MaxBackoff < config.RetryBackoff {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.DedupeWindow <= 0 {
+		config.DedupeWindow = DefaultDedupeWindow
+	}
+
+	idle := make(chan struct{})
+	close(idle)
+	return &Bus{config: config, logger: logger, failed: make(map[string]bool), idle: idle}
+}
+
+// Subscribe delivers the events of the given types (all if none) to
+// handler, and starts the subscriber's worker. name identifies the
+// subscriber in logs
+func (b *Bus) Subscribe(name string, handler ports.EventHandler, eventTypes ...string) {
+	s := &subscriber{
+		name:    name,
+		handler: handler,
+		types:   make(map[string]bool, len(eventTypes)),
+		queue:   make(chan delivery, b.config.QueueSize),
+		handled: newDedupeWindow(b.config.DedupeWindow),
+	}
+	for _, eventType := range eventTypes {
+		s.types[eventType] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.subscribers = append(b.subscribers, s)
+	b.workers.Add(1)
+	go b.worker(s)
+}
+
+// Publish queues an event for its subscribers without waiting for them.
+// It fails with ErrQueueFull if the queue of any subscriber is full, the
+// others still get the event
+func (b *Bus) Publish(ctx context.Context, event domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	// Handlers run after the request that published the event is over
+	d := delivery{ctx: context.WithoutCancel(ctx), event: event}
+
+	var err error
+	for _, s := range b.subscribers {
+		if len(s.types) > 0 && !s.types[event.EventType()] {
+			continue
+		}
+
+		select {
+		case s.queue <- d:
+			if b.stats.Pending == 0 {
+				b.idle = make(chan struct{})
+			}
+			b.stats.Pending++
+		default:
+			b.stats.Dropped++
+			b.logger.Warn("event dropped, subscriber queue is full", "subscriber", s.name, "type", event.EventType(), "eventID", event.EventID())
+			err = ErrQueueFull
+		}
+	}
+	return err
+}
+
+// Flush waits until every queued event has been handled, and returns the
+// IDs of the events that some subscriber failed to handle since the
+// previous Flush
+func (b *Bus) Flush(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	idle := b.idle
+	b.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failed := make([]string, 0, len(b.failed))
+	for id := range b.failed {
+		failed = append(failed, id)
+	}
+	b.failed = make(map[string]bool)
+	return failed, nil
+}
+
+// Close stops accepting events and waits until the queued ones are handled
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subscribers {
+			close(s.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the delivery counters
+func (b *Bus) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// worker delivers the events queued for a subscriber, one at a time
+func (b *Bus) worker(s *subscriber) {
+	defer b.workers.Done()
+
+	for d := range s.queue {
+		id := d.event.EventID()
+		duplicate := s.handled.contains(id)
+		delivered := false
+		if !duplicate {
+			// Failed events are not remembered, they can be delivered again
+			if delivered = b.deliver(s, d); delivered {
+				s.handled.add(id)
+			}
+		}
+
+		b.mu.Lock()
+		switch {
+		case duplicate:
+			b.stats.Duplicates++
+		case delivered:
+			b.stats.Delivered++
+		default:
+			b.stats.Failed++
+			b.failed[id] = true
+		}
+		b.stats.Pending--
+		if b.stats.Pending == 0 {
+			close(b.idle)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// deliver hands an event to a subscriber, retrying with exponential
+// backoff while it fails, and reports whether it was handled
+func (b *Bus) deliver(s *subscriber, d delivery) bool {
+	backoff := b.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.handle(d)
+		if err == nil {
+			return true
+		}
+
+		if attempt == b.config.MaxAttempts {
+			b.logger.Error("event handler failed, giving up", err, "subscriber", s.name, "type", d.event.EventType(), "eventID", d.event.EventID(), "attempts", attempt)
+			return false
+		}
+		b.logger.Warn("event handler failed, retrying", "error", err, "subscriber", s.name, "type", d.event.EventType(), "eventID", d.event.EventID(), "attempt", attempt)
+
+		b.mu.Lock()
+		b.stats.Retried++
+		b.mu.Unlock()
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > b.config.MaxBackoff {
+			backoff = b.config.MaxBackoff
+		}
+	}
+}
+
+// handle runs the handler of the subscriber, turning a panic into an error
+// so it does not bring down the worker
+func (s *subscriber) handle(d delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("event handler panicked: %v", r)
+		}
+	}()
+	return s.handler(d.ctx, d.event)
+}
@@ -0,0 +1,847 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// File names inside the data directory
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// FsyncPolicy defines when the WAL is flushed to stable storage
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs after every write (no acknowledged write is lost)
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs periodically (up to one interval of writes may be lost)
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system
+	FsyncNever FsyncPolicy = "never"
+)
+
+// Options configures the file storage engine
+type Options struct {
+	Dir              string
+	FsyncPolicy      FsyncPolicy
+	FsyncInterval    time.Duration
+	SnapshotInterval time.Duration
+	Logger           ports.Logger
+}
+
+// snapshot is the on-disk representation of a snapshot
+type snapshot struct {
+	Seq   uint64        `json:"seq"` // last WAL record included
+	State *memory.State `json:"state"`
+}
+
+// Repositories implements the repositories with durable file storage.
+// Reads are served from an in-memory copy; every mutation is appended to a
+// write-ahead log before being applied, and snapshots are taken periodically
+// so the log does not grow forever. On startup the last snapshot is loaded
+// and the log is replayed on top of it
+type Repositories struct {
+	*memory.Repositories
+
+	opts    Options
+	wal     *os.File
+	walSize int64
+	seq     uint64 // last WAL record written
+	snapSeq uint64 // last WAL record included in the snapshot
+	dirty   bool   // written but not yet synced
+	writeMu sync.Mutex
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens (or creates) the storage in opts.Dir and recovers its state
+func Open(opts Options) (*Repositories, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("data directory is required")
+	}
+	switch opts.FsyncPolicy {
+	case "":
+		opts.FsyncPolicy = FsyncAlways
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", opts.FsyncPolicy)
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = time.Second
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	repo := &Repositories{
+		Repositories: memory.NewRepositories(),
+		opts:         opts,
+		done:         make(chan struct{}),
+	}
+
+	if err := repo.recover(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(repo.path(walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := wal.Stat()
+	if err != nil {
+		wal.Close()
+		return nil, err
+	}
+	repo.wal, repo.walSize = wal, info.Size()
+
+	if opts.FsyncPolicy == FsyncInterval {
+		repo.startWorker(opts.FsyncInterval, repo.syncIfDirty)
+	}
+	if opts.SnapshotInterval > 0 {
+		repo.startWorker(opts.SnapshotInterval, func() {
+			if err := repo.Snapshot(); err != nil {
+				repo.warn("failed to take snapshot", "error", err)
+			}
+		})
+	}
+
+	return repo, nil
+}
+
+// Close takes a final snapshot and closes the log
+func (r *Repositories) Close() error {
+	close(r.done)
+	r.wg.Wait()
+
+	snapshotErr := r.Snapshot()
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+	if err := r.wal.Close(); err != nil {
+		return err
+	}
+	return snapshotErr
+}
+
+// Snapshot writes the current state to disk and truncates the log
+func (r *Repositories) Snapshot() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if r.seq == r.snapSeq {
+		return nil
+	}
+
+	data, err := json.Marshal(&snapshot{Seq: r.seq, State: r.Export()})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename, so a crash never leaves a partial snapshot
+	tmpPath := r.path(snapshotFileName + ".tmp")
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, r.path(snapshotFileName)); err != nil {
+		return err
+	}
+	if err := syncDir(r.opts.Dir); err != nil {
+		return err
+	}
+
+	// Records up to seq are in the snapshot; replay skips them even if the
+	// process dies before the truncate below
+	if err := r.wal.Truncate(0); err != nil {
+		return err
+	}
+	if err := r.wal.Sync(); err != nil {
+		return err
+	}
+
+	r.snapSeq = r.seq
+	r.walSize = 0
+	r.dirty = false
+	return nil
+}
+
+// TweetRepository methods
+
+func (r *Repositories) Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	return r.logChange(&walRecord{Op: opCreateTweet, Tweet: tweet}, events)
+}
+
+func (r *Repositories) CreateRetweet(ctx context.Context, retweet *domain.Tweet, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// All writes hold writeMu, so check + write is atomic
+	if _, err := r.Repositories.GetRetweet(ctx, retweet.UserID, retweet.ReferencedTweetID); err == nil {
+		return domain.ErrAlreadyRetweeted
+	}
+
+	// A retweet is replayed as any other tweet
+	return r.logChange(&walRecord{Op: opCreateTweet, Tweet: retweet}, events)
+}
+
+func (r *Repositories) Delete(ctx context.Context, id string, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	return r.logChange(&walRecord{Op: opDeleteTweet, ID: id}, events)
+}
+
+// FollowRepository methods
+
+func (r *Repositories) Follow(ctx context.Context, followerID, followeeID string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opFollow, FollowerID: followerID, FolloweeID: followeeID}); err != nil {
+		return err
+	}
+	return r.Repositories.Follow(ctx, followerID, followeeID)
+}
+
+func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// All writes hold writeMu, so check + write is atomic
+	following, err := r.Repositories.IsFollowing(ctx, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if following {
+		return domain.ErrAlreadyFollowing
+	}
+
+	return r.logChange(&walRecord{Op: opFollow, FollowerID: followerID, FolloweeID: followeeID}, events)
+}
+
+func (r *Repositories) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opUnfollow, FollowerID: followerID, FolloweeID: followeeID}); err != nil {
+		return err
+	}
+	return r.Repositories.Unfollow(ctx, followerID, followeeID)
+}
+
+func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	following, err := r.Repositories.IsFollowing(ctx, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if !following {
+		return domain.ErrNotFollowing
+	}
+
+	return r.logChange(&walRecord{Op: opUnfollow, FollowerID: followerID, FolloweeID: followeeID}, events)
+}
+
+func (r *Repositories) Block(ctx context.Context, block *domain.Block) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// All writes hold writeMu, so check + write is atomic
+	blocked, err := r.Repositories.IsBlocked(ctx, block.BlockerID, block.BlockedID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return domain.ErrAlreadyBlocked
+	}
+
+	return r.logChange(&walRecord{Op: opBlock, Block: block}, nil)
+}
+
+func (r *Repositories) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	blocked, err := r.Repositories.IsBlocked(ctx, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	if !blocked {
+		return domain.ErrNotBlocked
+	}
+
+	return r.logChange(&walRecord{Op: opUnblock, Block: &domain.Block{BlockerID: blockerID, BlockedID: blockedID}}, nil)
+}
+
+// LikeRepository methods
+
+func (r *Repositories) LikeIfNotExists(ctx context.Context, like *domain.Like, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// All writes hold writeMu, so check + write is atomic
+	liked, err := r.Repositories.GetLikedTweetIDs(ctx, like.UserID, []string{like.TweetID})
+	if err != nil {
+		return err
+	}
+	if liked[like.TweetID] {
+		return domain.ErrAlreadyLiked
+	}
+
+	return r.logChange(&walRecord{Op: opLike, Like: like}, events)
+}
+
+func (r *Repositories) UnlikeIfExists(ctx context.Context, userID, tweetID string, events ...domain.Event) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	liked, err := r.Repositories.GetLikedTweetIDs(ctx, userID, []string{tweetID})
+	if err != nil {
+		return err
+	}
+	if !liked[tweetID] {
+		return domain.ErrNotLiked
+	}
+
+	return r.logChange(&walRecord{Op: opUnlike, UserID: userID, ID: tweetID}, events)
+}
+
+// NotificationRepository methods
+
+func (r *Repositories) Notify(ctx context.Context, event *domain.Notification) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opNotify, Notification: event}); err != nil {
+		return err
+	}
+	return r.Repositories.Notify(ctx, event)
+}
+
+func (r *Repositories) MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// Without a position every notification is marked
+	record := &walRecord{Op: opMarkRead, UserID: userID}
+	if upTo != nil {
+		record.Time = &upTo.CreatedAt
+		record.ID = upTo.TweetID
+	}
+	if err := r.appendRecord(record); err != nil {
+		return err
+	}
+	return r.Repositories.MarkRead(ctx, userID, upTo)
+}
+
+// OutboxRepository methods
+
+func (r *Repositories) MarkRelayed(ctx context.Context, ids []string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opMarkRelayed, IDs: ids}); err != nil {
+		return err
+	}
+	return r.Repositories.MarkRelayed(ctx, ids)
+}
+
+func (r *Repositories) ScheduleRetry(ctx context.Context, id string, at time.Time) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opScheduleRetry, ID: id, Time: &at}); err != nil {
+		return err
+	}
+	return r.Repositories.ScheduleRetry(ctx, id, at)
+}
+
+// UserRepository methods
+
+func (r *Repositories) CreateUser(ctx context.Context, user *domain.User) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	existing, err := r.Repositories.GetUserByUsername(ctx, user.Username)
+	if err == nil && existing.ID != user.ID {
+		return domain.ErrUsernameTaken
+	}
+	if err != nil && err != domain.ErrUserNotFound {
+		return err
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opCreateUser, User: user}); err != nil {
+		return err
+	}
+	return r.Repositories.CreateUser(ctx, user)
+}
+
+func (r *Repositories) UpdateUser(ctx context.Context, user *domain.User) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetUserByID(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opUpdateUser, User: user}); err != nil {
+		return err
+	}
+	return r.Repositories.UpdateUser(ctx, user)
+}
+
+// CredentialRepository methods
+
+func (r *Repositories) SetCredentials(ctx context.Context, credentials *domain.Credentials) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opSetCredentials, Credentials: credentials}); err != nil {
+		return err
+	}
+	return r.Repositories.SetCredentials(ctx, credentials)
+}
+
+func (r *Repositories) DeleteCredentials(ctx context.Context, userID string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetCredentials(ctx, userID); err == domain.ErrNoCredentials {
+		return nil
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opDeleteCredentials, ID: userID}); err != nil {
+		return err
+	}
+	return r.Repositories.DeleteCredentials(ctx, userID)
+}
+
+// APIKeyRepository methods
+
+func (r *Repositories) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opCreateAPIKey, APIKey: key}); err != nil {
+		return err
+	}
+	return r.Repositories.CreateAPIKey(ctx, key)
+}
+
+func (r *Repositories) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	key, err := r.Repositories.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.UserID != userID {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opDeleteAPIKey, ID: id, UserID: userID}); err != nil {
+		return err
+	}
+	return r.Repositories.DeleteAPIKey(ctx, userID, id)
+}
+
+func (r *Repositories) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetAPIKey(ctx, id); err == domain.ErrAPIKeyNotFound {
+		return nil
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opTouchAPIKey, ID: id, Time: &usedAt}); err != nil {
+		return err
+	}
+	return r.Repositories.TouchAPIKey(ctx, id, usedAt)
+}
+
+// WebhookRepository methods
+
+func (r *Repositories) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opCreateWebhook, Webhook: webhook}); err != nil {
+		return err
+	}
+	return r.Repositories.CreateWebhook(ctx, webhook)
+}
+
+func (r *Repositories) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetWebhook(ctx, webhook.ID); err != nil {
+		return err
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opUpdateWebhook, Webhook: webhook}); err != nil {
+		return err
+	}
+	return r.Repositories.UpdateWebhook(ctx, webhook)
+}
+
+func (r *Repositories) DeleteWebhook(ctx context.Context, userID, id string) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	webhook, err := r.Repositories.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if webhook.UserID != userID {
+		return domain.ErrWebhookNotFound
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opDeleteWebhook, ID: id, UserID: userID}); err != nil {
+		return err
+	}
+	return r.Repositories.DeleteWebhook(ctx, userID, id)
+}
+
+func (r *Repositories) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, err := r.Repositories.GetWebhook(ctx, delivery.WebhookID); err == domain.ErrWebhookNotFound {
+		return nil
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opAddDelivery, Delivery: delivery}); err != nil {
+		return err
+	}
+	return r.Repositories.AddWebhookDelivery(ctx, delivery)
+}
+
+// RevocationList methods
+
+func (r *Repositories) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opRevokeToken, ID: tokenID, Time: &expiresAt}); err != nil {
+		return err
+	}
+	return r.Repositories.Revoke(ctx, tokenID, expiresAt)
+}
+
+func (r *Repositories) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	// All writes hold writeMu, so check + write is atomic
+	if revoked, err := r.Repositories.IsRevoked(ctx, tokenID); err != nil || revoked {
+		return false, err
+	}
+
+	if err := r.appendRecord(&walRecord{Op: opRevokeToken, ID: tokenID, Time: &expiresAt}); err != nil {
+		return false, err
+	}
+	return r.Repositories.Consume(ctx, tokenID, expiresAt)
+}
+
+func (r *Repositories) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if err := r.appendRecord(&walRecord{Op: opRevokeUser, UserID: userID, Time: &issuedBefore}); err != nil {
+		return err
+	}
+	return r.Repositories.RevokeUser(ctx, userID, issuedBefore)
+}
+
+// recover loads the last snapshot and replays the log on top of it
+func (r *Repositories) recover() error {
+	data, err := os.ReadFile(r.path(snapshotFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		r.Import(snap.State)
+		r.seq, r.snapSeq = snap.Seq, snap.Seq
+	}
+
+	replayed := 0
+	truncated, err := readWAL(r.path(walFileName), func(record *walRecord) error {
+		if record.Seq <= r.snapSeq {
+			return nil
+		}
+		if err := r.apply(record); err != nil {
+			return err
+		}
+		r.seq = record.Seq
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay WAL: %w", err)
+	}
+
+	if truncated {
+		r.warn("discarded incomplete WAL record during recovery", "dir", r.opts.Dir)
+	}
+	if r.opts.Logger != nil {
+		r.opts.Logger.Info("file storage recovered", "dir", r.opts.Dir, "snapshotSeq", r.snapSeq, "replayedRecords", replayed)
+	}
+	return nil
+}
+
+// apply applies a replayed record to the in-memory state
+func (r *Repositories) apply(record *walRecord) error {
+	ctx := context.Background()
+
+	// The events logged with a change are stored with it
+	r.Repositories.AppendOutbox(record.Outbox)
+
+	switch record.Op {
+	case opCreateTweet:
+		return r.Repositories.Create(ctx, record.Tweet)
+	case opDeleteTweet:
+		if err := r.Repositories.Delete(ctx, record.ID); err != nil && err != domain.ErrTweetNotFound {
+			return err
+		}
+		return nil
+	case opFollow:
+		return r.Repositories.Follow(ctx, record.FollowerID, record.FolloweeID)
+	case opUnfollow:
+		return r.Repositories.Unfollow(ctx, record.FollowerID, record.FolloweeID)
+	case opBlock:
+		if err := r.Repositories.Block(ctx, record.Block); err != nil && err != domain.ErrAlreadyBlocked {
+			return err
+		}
+		return nil
+	case opUnblock:
+		if err := r.Repositories.Unblock(ctx, record.Block.BlockerID, record.Block.BlockedID); err != nil && err != domain.ErrNotBlocked {
+			return err
+		}
+		return nil
+	case opLike:
+		if err := r.Repositories.LikeIfNotExists(ctx, record.Like); err != nil && err != domain.ErrAlreadyLiked {
+			return err
+		}
+		return nil
+	case opUnlike:
+		if err := r.Repositories.UnlikeIfExists(ctx, record.UserID, record.ID); err != nil && err != domain.ErrNotLiked {
+			return err
+		}
+		return nil
+	case opNotify:
+		return r.Repositories.Notify(ctx, record.Notification)
+	case opMarkRead:
+		var upTo *domain.Cursor
+		if record.Time != nil {
+			upTo = &domain.Cursor{CreatedAt: *record.Time, TweetID: record.ID}
+		}
+		return r.Repositories.MarkRead(ctx, record.UserID, upTo)
+	case opMarkRelayed:
+		return r.Repositories.MarkRelayed(ctx, record.IDs)
+	case opScheduleRetry:
+		if record.Time == nil {
+			return fmt.Errorf("missing time in %q record", record.Op)
+		}
+		return r.Repositories.ScheduleRetry(ctx, record.ID, *record.Time)
+	case opCreateUser:
+		return r.Repositories.CreateUser(ctx, record.User)
+	case opUpdateUser:
+		return r.Repositories.UpdateUser(ctx, record.User)
+	case opSetCredentials:
+		return r.Repositories.SetCredentials(ctx, record.Credentials)
+	case opDeleteCredentials:
+		return r.Repositories.DeleteCredentials(ctx, record.ID)
+	case opCreateAPIKey:
+		return r.Repositories.CreateAPIKey(ctx, record.APIKey)
+	case opDeleteAPIKey:
+		if err := r.Repositories.DeleteAPIKey(ctx, record.UserID, record.ID); err != nil && err != domain.ErrAPIKeyNotFound {
+			return err
+		}
+		return nil
+	case opTouchAPIKey:
+		if record.Time == nil {
+			return fmt.Errorf("missing time in %q record", record.Op)
+		}
+		return r.Repositories.TouchAPIKey(ctx, record.ID, *record.Time)
+	case opCreateWebhook:
+		return r.Repositories.CreateWebhook(ctx, record.Webhook)
+	case opUpdateWebhook:
+		if err := r.Repositories.UpdateWebhook(ctx, record.Webhook); err != nil && err != domain.ErrWebhookNotFound {
+			return err
+		}
+		return nil
+	case opDeleteWebhook:
+		if err := r.Repositories.DeleteWebhook(ctx, record.UserID, record.ID); err != nil && err != domain.ErrWebhookNotFound {
+			return err
+		}
+		return nil
+	case opAddDelivery:
+		return r.Repositories.AddWebhookDelivery(ctx, record.Delivery)
+	case opRevokeToken:
+		if record.Time == nil {
+			return fmt.Errorf("missing time in %q record", record.Op)
+		}
+		return r.Repositories.Revoke(ctx, record.ID, *record.Time)
+	case opRevokeUser:
+		if record.Time == nil {
+			return fmt.Errorf("missing time in %q record", record.Op)
+		}
+		return r.Repositories.RevokeUser(ctx, record.UserID, *record.Time)
+	default:
+		return fmt.Errorf("unknown WAL operation %q", record.Op)
+	}
+}
+
+// logChange appends a record of a change with the outbox entries of its
+// events, and applies it, so both are written or lost together (caller
+// must hold writeMu)
+func (r *Repositories) logChange(record *walRecord, events []domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
+	record.Outbox = entries
+	if err := r.appendRecord(record); err != nil {
+		return err
+	}
+	return r.apply(record)
+}
+
+// appendRecord writes a record to the log honoring the fsync policy (caller must hold writeMu)
+func (r *Repositories) appendRecord(record *walRecord) error {
+	record.Seq = r.seq + 1
+
+	line, err := encodeRecord(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.wal.Write(line); err != nil {
+		r.rollback()
+		return fmt.Errorf("failed to write WAL: %w", err)
+	}
+
+	switch r.opts.FsyncPolicy {
+	case FsyncAlways:
+		if err := r.wal.Sync(); err != nil {
+			r.rollback()
+			return fmt.Errorf("failed to sync WAL: %w", err)
+		}
+	case FsyncInterval:
+		r.dirty = true
+	}
+
+	r.seq = record.Seq
+	r.walSize += int64(len(line))
+	return nil
+}
+
+// rollback drops a partially written record so it is not replayed later
+func (r *Repositories) rollback() {
+	if err := r.wal.Truncate(r.walSize); err != nil {
+		r.warn("failed to roll back WAL write", "error", err)
+	}
+}
+
+// syncIfDirty flushes pending writes for the interval policy
+func (r *Repositories) syncIfDirty() {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if !r.dirty {
+		return
+	}
+	if err := r.wal.Sync(); err != nil {
+		r.warn("failed to sync WAL", "error", err)
+		return
+	}
+	r.dirty = false
+}
+
+// startWorker runs fn every interval until Close
+func (r *Repositories) startWorker(interval time.Duration, fn func()) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Repositories) path(name string) string {
+	return filepath.Join(r.opts.Dir, name)
+}
+
+func (r *Repositories) warn(msg string, args ...interface{}) {
+	if r.opts.Logger != nil {
+		r.opts.Logger.Warn(msg, args...)
+	}
+}
+
+// writeFileSync writes a file and flushes it to stable storage
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes directory entries so a rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
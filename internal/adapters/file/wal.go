@@ -0,0 +1,139 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// WAL operations
+const (
+	opCreateTweet       = "create_tweet"
+	opDeleteTweet       = "delete_tweet"
+	opFollow            = "follow"
+	opUnfollow          = "unfollow"
+	opBlock             = "block"
+	opUnblock           = "unblock"
+	opLike              = "like"
+	opUnlike            = "unlike"
+	opNotify            = "notify"
+	opMarkRead          = "mark_read"
+	opMarkRelayed       = "mark_relayed"
+	opScheduleRetry     = "schedule_retry"
+	opCreateUser        = "create_user"
+	opUpdateUser        = "update_user"
+	opSetCredentials    = "set_credentials"
+	opDeleteCredentials = "delete_credentials"
+	opCreateAPIKey      = "create_api_key"
+	opDeleteAPIKey      = "delete_api_key"
+	opTouchAPIKey       = "touch_api_key"
+	opCreateWebhook     = "create_webhook"
+	opUpdateWebhook     = "update_webhook"
+	opDeleteWebhook     = "delete_webhook"
+	opAddDelivery       = "add_webhook_delivery"
+	opRevokeToken       = "revoke_token"
+	opRevokeUser        = "revoke_user"
+)
+
+// walRecord is a single mutation appended to the write-ahead log
+type walRecord struct {
+	Seq          uint64                  `json:"seq"`
+	Op           string                  `json:"op"`
+	Tweet        *domain.Tweet           `json:"tweet,omitempty"`
+	Like         *domain.Like            `json:"like,omitempty"`
+	Block        *domain.Block           `json:"block,omitempty"`
+	Notification *domain.Notification    `json:"notification,omitempty"`
+	User         *domain.User            `json:"user,omitempty"`
+	Credentials  *domain.Credentials     `json:"credentials,omitempty"`
+	APIKey       *domain.APIKey          `json:"api_key,omitempty"`
+	Webhook      *domain.Webhook         `json:"webhook,omitempty"`
+	Delivery     *domain.WebhookDelivery `json:"webhook_delivery,omitempty"`
+	ID           string                  `json:"id,omitempty"`
+	UserID       string                  `json:"user_id,omitempty"`
+	Time         *time.Time              `json:"time,omitempty"`
+	FollowerID   string                  `json:"follower_id,omitempty"`
+	FolloweeID   string                  `json:"followee_id,omitempty"`
+	IDs          []string                `json:"ids,omitempty"`
+	Outbox       []*domain.OutboxEntry   `json:"outbox,omitempty"` // events written with the change
+}
+
+// errCorruptRecord signals a torn or corrupted record, normally the tail of
+// the log after a crash in the middle of a write
+var errCorruptRecord = errors.New("corrupt WAL record")
+
+// encodeRecord encodes a record as "<crc32 hex> <json>\n"
+func encodeRecord(record *walRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+	return []byte(line), nil
+}
+
+// decodeRecord decodes a line produced by encodeRecord (without the newline)
+func decodeRecord(line string) (*walRecord, error) {
+	checksumHex, payload, found := strings.Cut(line, " ")
+	if !found || len(checksumHex) != 8 {
+		return nil, errCorruptRecord
+	}
+
+	sum, err := strconv.ParseUint(checksumHex, 16, 32)
+	if err != nil || crc32.ChecksumIEEE([]byte(payload)) != uint32(sum) {
+		return nil, errCorruptRecord
+	}
+
+	var record walRecord
+	if err := json.Unmarshal([]byte(payload), &record); err != nil {
+		return nil, errCorruptRecord
+	}
+	return &record, nil
+}
+
+// readWAL reads all valid records of the log. If a corrupt record is found
+// the log is truncated right before it, discarding the partial write
+func readWAL(path string, apply func(*walRecord) error) (truncated bool, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr == io.EOF && line == "" {
+			return false, nil
+		}
+		if readErr != nil && readErr != io.EOF {
+			return false, readErr
+		}
+
+		// A line without newline is an incomplete write
+		record, err := decodeRecord(strings.TrimSuffix(line, "\n"))
+		if readErr == io.EOF || err != nil {
+			if err := f.Truncate(offset); err != nil {
+				return false, fmt.Errorf("failed to truncate WAL: %w", err)
+			}
+			return true, f.Sync()
+		}
+
+		if err := apply(record); err != nil {
+			return false, err
+		}
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"twitter-clone-backend/internal/domain"
+)
+
+// API key request/response structures
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+// CreatedAPIKeyResponse includes the plain key, which is only shown once
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type APIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+// newAPIKeyResponse converts an API key into its response (never the hash)
+func newAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	response := APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if !key.LastUsedAt.IsZero() {
+		response.LastUsedAt = key.LastUsedAt.Format("2006-01-02T15:04:05Z")
+	}
+	return response
+}
+
+// CreateAPIKey creates an API key for the authenticated user
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.apiKeyUseCase == nil {
+		writeError(w, http.StatusNotFound, "API keys are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	key, plain, err := h.apiKeyUseCase.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, CreatedAPIKeyResponse{APIKeyResponse: newAPIKeyResponse(key), Key: plain})
+}
+
+// ListAPIKeys lists the API keys of the authenticated user
+func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if h.apiKeyUseCase == nil {
+		writeError(w, http.StatusNotFound, "API keys are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyUseCase.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	response := APIKeysResponse{APIKeys: make([]APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.APIKeys = append(response.APIKeys, newAPIKeyResponse(key))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// RevokeAPIKey deletes an API key of the authenticated user
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.apiKeyUseCase == nil {
+		writeError(w, http.StatusNotFound, "API keys are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract keyID from path (format: /users/me/api-keys/{keyID})
+	keyID := strings.TrimPrefix(r.URL.Path, "/users/me/api-keys/")
+	if keyID == "" || strings.Contains(keyID, "/") {
+		writeError(w, http.StatusBadRequest, "keyID parameter is required")
+		return
+	}
+
+	if err := h.apiKeyUseCase.RevokeAPIKey(r.Context(), userID, keyID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully revoked API key"})
+}
@@ -0,0 +1,324 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// contextKey is the type of the request context keys of this package
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	apiKeyKey
+	scopeGrantedKey
+)
+
+// Option configures optional features of the handlers
+type Option func(*Handlers)
+
+// WithAuth authenticates requests with bearer access tokens. Without it the
+// X-User-ID header is trusted as is, which is only meant for local development
+func WithAuth(authUseCase *usecases.AuthUseCase) Option {
+	return func(h *Handlers) {
+		h.authUseCase = authUseCase
+	}
+}
+
+// WithAPIKeys lets users create scoped API keys and authenticate with them
+func WithAPIKeys(apiKeyUseCase *usecases.APIKeyUseCase) Option {
+	return func(h *Handlers) {
+		h.apiKeyUseCase = apiKeyUseCase
+	}
+}
+
+// Auth request/response structures
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutRequest optionally names the refresh token of the session, so it
+// is revoked together with the access token
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
+
+// newTokenResponse converts a token pair into its response
+func newTokenResponse(tokens *domain.TokenPair) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(tokens.AccessExpiresAt).Seconds()),
+	}
+}
+
+// withUserID returns a copy of ctx carrying the authenticated user ID
+func withUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// userIDFromContext returns the authenticated user ID, if any
+func userIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+// withAPIKey returns a copy of ctx carrying the API key of the request
+func withAPIKey(ctx context.Context, key *domain.APIKey) context.Context {
+	return context.WithValue(withUserID(ctx, key.UserID), apiKeyKey, key)
+}
+
+// apiKeyFromContext returns the API key the request was authenticated with, if any
+func apiKeyFromContext(ctx context.Context) (*domain.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey).(*domain.APIKey)
+	return key, ok
+}
+
+// authenticatedUserID returns the authenticated user ID, or writes a 401
+// response if the request is anonymous. API keys are only accepted by the
+// handlers wrapped with requireScope (403 otherwise), so a key can never
+// manage the account or mint other keys
+func authenticatedUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeDomainError(w, domain.ErrUnauthenticated)
+		return "", false
+	}
+
+	if _, isAPIKey := apiKeyFromContext(r.Context()); isAPIKey {
+		if granted, _ := r.Context().Value(scopeGrantedKey).(bool); !granted {
+			writeDomainError(w, domain.ErrInsufficientScope)
+			return "", false
+		}
+	}
+	return userID, true
+}
+
+// requireScope lets requests authenticated with an API key reach a handler
+// only if the key grants scope. Other requests are not affected
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := apiKeyFromContext(r.Context()); ok {
+			if !key.HasScope(scope) {
+				writeDomainError(w, domain.ErrInsufficientScope)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), scopeGrantedKey, true))
+		}
+		next(w, r)
+	}
+}
+
+// bearerToken returns the token of a Bearer Authorization header.
+// present is false when the request has no Authorization header
+func bearerToken(r *http.Request) (token string, present, ok bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false, false
+	}
+	token, ok = strings.CutPrefix(header, "Bearer ")
+	return token, true, ok
+}
+
+// clientIP returns the address of the client. Forwarding headers are not
+// trusted, since any client can set them
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authenticate is a middleware that puts the identity of the caller in the
+// request context. Anonymous requests go through (handlers decide whether
+// they need a user), but invalid credentials are rejected
+func (h *Handlers) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, _, ok := bearerToken(r); ok && h.apiKeyUseCase != nil && domain.IsAPIKey(token) {
+			key, err := h.apiKeyUseCase.Authenticate(r.Context(), token)
+			if err != nil {
+				if err == domain.ErrInvalidToken {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				h.writeUseCaseError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withAPIKey(r.Context(), key)))
+			return
+		}
+
+		if h.authUseCase == nil {
+			if userID := r.Header.Get("X-User-ID"); userID != "" {
+				r = r.WithContext(withUserID(r.Context(), userID))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, present, ok := bearerToken(r)
+		if !present {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			writeError(w, http.StatusUnauthorized, "Authorization header must use the Bearer scheme")
+			return
+		}
+
+		userID, err := h.authUseCase.Authenticate(r.Context(), token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeDomainError(w, domain.ErrInvalidToken)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withUserID(r.Context(), userID)))
+	})
+}
+
+// Login exchanges a username and password for a pair of tokens
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	if h.authUseCase == nil {
+		writeError(w, http.StatusNotFound, "token authentication is disabled")
+		return
+	}
+
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "username and password are required")
+		return
+	}
+
+	user, tokens, err := h.authUseCase.Login(r.Context(), req.Username, req.Password, clientIP(r))
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AuthenticatedUserResponse{
+		UserResponse:  newUserResponse(user),
+		TokenResponse: newTokenResponse(tokens),
+	})
+}
+
+// Logout revokes the access token of the request and, if given, the
+// refresh token of the same session
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if h.authUseCase == nil {
+		writeError(w, http.StatusNotFound, "token authentication is disabled")
+		return
+	}
+
+	if _, isAPIKey := apiKeyFromContext(r.Context()); isAPIKey {
+		writeDomainError(w, domain.ErrInsufficientScope)
+		return
+	}
+
+	// The middleware has already verified the token
+	accessToken, _, ok := bearerToken(r)
+	if !ok {
+		writeDomainError(w, domain.ErrUnauthenticated)
+		return
+	}
+
+	// The body is optional
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if err := h.authUseCase.Logout(r.Context(), accessToken, req.RefreshToken); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully logged out"})
+}
+
+// ChangePassword replaces the password of the authenticated user. Other
+// sessions are logged out, the response carries new tokens for this one
+func (h *Handlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if h.authUseCase == nil {
+		writeError(w, http.StatusNotFound, "token authentication is disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	tokens, err := h.authUseCase.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
+
+// RefreshToken exchanges a refresh token for a new pair of tokens
+func (h *Handlers) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if h.authUseCase == nil {
+		writeError(w, http.StatusNotFound, "token authentication is disabled")
+		return
+	}
+
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	tokens, err := h.authUseCase.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
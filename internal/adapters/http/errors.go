@@ -0,0 +1,141 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"twitter-clone-backend/internal/domain"
+)
+
+// ProblemResponse is an RFC 7807 problem details body. Code is a stable,
+// machine-readable identifier of the error; Detail is meant for humans
+type ProblemResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// problemMapping translates a domain error into a status and code
+type problemMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// problemMappings are the domain errors clients can act on. Malformed
+// requests are 400 and well-formed but invalid ones 422. Any other error
+// is a 500 and its text is never sent to the client
+var problemMappings = []problemMapping{
+	{domain.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+
+	{domain.ErrInvalidUserID, http.StatusUnprocessableEntity, "invalid_user_id"},
+	{domain.ErrEmptyContent, http.StatusUnprocessableEntity, "empty_content"},
+	{domain.ErrContentTooLong, http.StatusUnprocessableEntity, "content_too_long"},
+	{domain.ErrInvalidContent, http.StatusUnprocessableEntity, "invalid_content"},
+	{domain.ErrInvalidUsername, http.StatusUnprocessableEntity, "invalid_username"},
+	{domain.ErrProfileTooLong, http.StatusUnprocessableEntity, "profile_too_long"},
+	{domain.ErrInvalidAvatarURL, http.StatusUnprocessableEntity, "invalid_avatar_url"},
+	{domain.ErrInvalidPassword, http.StatusUnprocessableEntity, "invalid_password"},
+	{domain.ErrInvalidAPIKeyName, http.StatusUnprocessableEntity, "invalid_api_key_name"},
+	{domain.ErrInvalidScope, http.StatusUnprocessableEntity, "invalid_scope"},
+	{domain.ErrCannotFollowSelf, http.StatusUnprocessableEntity, "cannot_follow_self"},
+	{domain.ErrCannotBlockSelf, http.StatusUnprocessableEntity, "cannot_block_self"},
+	{domain.ErrParentNotFound, http.StatusUnprocessableEntity, "parent_not_found"},
+	{domain.ErrQuotedNotFound, http.StatusUnprocessableEntity, "quoted_not_found"},
+	{domain.ErrCannotReshare, http.StatusUnprocessableEntity, "cannot_reshare"},
+	{domain.ErrInvalidWebhookURL, http.StatusUnprocessableEntity, "invalid_webhook_url"},
+	{domain.ErrWebhookURLNotPublic, http.StatusUnprocessableEntity, "webhook_url_not_public"},
+	{domain.ErrInvalidWebhookEvents, http.StatusUnprocessableEntity, "invalid_webhook_events"},
+	{domain.ErrInvalidWebhookSecret, http.StatusUnprocessableEntity, "invalid_webhook_secret"},
+	{domain.ErrTooManyWebhooks, http.StatusUnprocessableEntity, "too_many_webhooks"},
+
+	{domain.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+	{domain.ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+	{domain.ErrInvalidLogin, http.StatusUnauthorized, "invalid_login"},
+
+	{domain.ErrNotTweetAuthor, http.StatusForbidden, "not_tweet_author"},
+	{domain.ErrWrongPassword, http.StatusForbidden, "wrong_password"},
+	{domain.ErrInsufficientScope, http.StatusForbidden, "insufficient_scope"},
+	{domain.ErrBlocked, http.StatusForbidden, "blocked"},
+
+	{domain.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{domain.ErrTweetNotFound, http.StatusNotFound, "tweet_not_found"},
+	{domain.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+	{domain.ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found"},
+	{domain.ErrNotFollowing, http.StatusNotFound, "not_following"},
+	{domain.ErrNotBlocked, http.StatusNotFound, "not_blocked"},
+	{domain.ErrNotRetweeted, http.StatusNotFound, "not_retweeted"},
+
+	{domain.ErrAlreadyFollowing, http.StatusConflict, "already_following"},
+	{domain.ErrAlreadyBlocked, http.StatusConflict, "already_blocked"},
+	{domain.ErrUsernameTaken, http.StatusConflict, "username_taken"},
+	{domain.ErrAlreadyRetweeted, http.StatusConflict, "already_retweeted"},
+
+	{domain.ErrTooManyAttempts, http.StatusTooManyRequests, "too_many_attempts"},
+}
+
+// statusCodes are the codes of the errors detected by the handlers
+// themselves (invalid JSON, missing parameters, ...)
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// writeProblem writes an RFC 7807 problem response
+func writeProblem(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ProblemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+}
+
+// writeError writes a problem response for an invalid request
+func writeError(w http.ResponseWriter, status int, message string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "error"
+	}
+	writeProblem(w, status, code, message)
+}
+
+// writeDomainError writes the problem response of a domain error, or a
+// generic 500 for any other error
+func writeDomainError(w http.ResponseWriter, err error) {
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.err) {
+			writeProblem(w, mapping.status, mapping.code, mapping.err.Error())
+			return
+		}
+	}
+	writeProblem(w, http.StatusInternalServerError, "internal_error", "an internal error occurred")
+}
+
+// isDomainError reports whether err is translated to a client error
+func isDomainError(err error) bool {
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.err) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeUseCaseError writes the response for an error of a use case.
+// Internal errors are logged, since their text is hidden from the client
+func (h *Handlers) writeUseCaseError(w http.ResponseWriter, r *http.Request, err error) {
+	if !isDomainError(err) {
+		h.logger.Error("request failed", err, "method", r.Method, "path", r.URL.Path)
+	}
+	writeDomainError(w, err)
+}
@@ -4,41 +4,97 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
 	"twitter-clone-backend/internal/usecases"
 )
 
 // Handlers contains HTTP handlers
 type Handlers struct {
-	tweetUseCase  *usecases.TweetUseCase
-	followUseCase *usecases.FollowUseCase
+	tweetUseCase    *usecases.TweetUseCase
+	followUseCase   *usecases.FollowUseCase
+	userUseCase     *usecases.UserUseCase
+	authUseCase     *usecases.AuthUseCase
+	apiKeyUseCase   *usecases.APIKeyUseCase
+	webhookUseCase  *usecases.WebhookUseCase
+	likeUseCase     *usecases.LikeUseCase
+	notifications   *usecases.NotificationUseCase
+	timelineStreams *usecases.TimelineStreams
+	logger          ports.Logger
 }
 
 // NewHandlers creates a new instance of handlers
-func NewHandlers(tweetUseCase *usecases.TweetUseCase, followUseCase *usecases.FollowUseCase) *Handlers {
-	return &Handlers{
+func NewHandlers(tweetUseCase *usecases.TweetUseCase, followUseCase *usecases.FollowUseCase, userUseCase *usecases.UserUseCase, logger ports.Logger, opts ...Option) *Handlers {
+	h := &Handlers{
 		tweetUseCase:  tweetUseCase,
 		followUseCase: followUseCase,
+		userUseCase:   userUseCase,
+		logger:        logger,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // Tweet request/response structures
 type CreateTweetRequest struct {
-	Content string `json:"content"`
+	Content          string `json:"content"`
+	InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+	QuotedTweetID    string `json:"quoted_tweet_id"`
 }
 
+// TweetResponse is a tweet with its counts. Retweets and quotes embed the
+// tweet they reshare as ReferencedTweet, unless it was deleted. LikedByMe
+// tells whether the caller liked the tweet
 type TweetResponse struct {
-	ID        string `json:"id"`
-	UserID    string `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
+	ID                string           `json:"id"`
+	UserID            string           `json:"user_id"`
+	Kind              string           `json:"kind"`
+	Content           string           `json:"content"`
+	InReplyToTweetID  string           `json:"in_reply_to_tweet_id,omitempty"`
+	ConversationID    string           `json:"conversation_id"`
+	ReferencedTweetID string           `json:"referenced_tweet_id,omitempty"`
+	ReferencedTweet   *TweetResponse   `json:"referenced_tweet,omitempty"`
+	Entities          *domain.Entities `json:"entities,omitempty"`
+	ReplyCount        int              `json:"reply_count"`
+	RetweetCount      int              `json:"retweet_count"`
+	QuoteCount        int              `json:"quote_count"`
+	LikeCount         int              `json:"like_count"`
+	LikedByMe         bool             `json:"liked_by_me"`
+	CreatedAt         string           `json:"created_at"`
+}
+
+// TweetPageResponse is a page of a list of tweets. NextCursor (sent as
+// max_id) continues with older tweets and is empty on the last page;
+// NewestCursor (sent as since_id) polls for tweets newer than the page
+type TweetPageResponse struct {
+	Tweets       []TweetResponse `json:"tweets"`
+	NextCursor   string          `json:"next_cursor,omitempty"`
+	NewestCursor string          `json:"newest_cursor,omitempty"`
+}
+
+// ThreadTweetResponse is a tweet of a conversation with its replies
+type ThreadTweetResponse struct {
+	TweetResponse
+	Replies []ThreadTweetResponse `json:"replies"`
+}
+
+// ThreadResponse is a whole conversation as a tree, each level in
+// chronological order. Replies to deleted tweets are roots next to the
+// first tweet
+type ThreadResponse struct {
+	ConversationID string                `json:"conversation_id"`
+	Tweets         []ThreadTweetResponse `json:"tweets"`
 }
 
 type FollowRequest struct {
 	FolloweeID string `json:"followee_id"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+type BlockRequest struct {
+	BlockedID string `json:"blocked_id"`
 }
 
 type MessageResponse struct {
@@ -60,11 +116,6 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// writeError writes a JSON error response
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, ErrorResponse{Error: message})
-}
-
 // extractUserIDFromPath extracts userID from paths like /users/{userID}/timeline, /users/{userID}/tweets, etc.
 func extractUserIDFromPath(path, suffix string) string {
 	prefix := "/users/"
@@ -80,11 +131,222 @@ func extractUserIDFromPath(path, suffix string) string {
 	return path[len(prefix):endIndex]
 }
 
-// CreateTweet handles the creation of a new tweet
+// extractTweetIDFromPath extracts tweetID from paths like /tweets/{tweetID}
+func extractTweetIDFromPath(path string) string {
+	return extractTweetIDFromSubPath(path, "")
+}
+
+// extractTweetIDFromSubPath extracts tweetID from paths like /tweets/{tweetID}/replies
+func extractTweetIDFromSubPath(path, suffix string) string {
+	tweetID := strings.TrimSuffix(strings.TrimPrefix(path, "/tweets/"), suffix)
+	if strings.Contains(tweetID, "/") {
+		return ""
+	}
+	return tweetID
+}
+
+// parsePageQuery reads the limit, max_id and since_id query parameters
+func parsePageQuery(r *http.Request) (domain.PageQuery, error) {
+	query := r.URL.Query()
+	page := domain.PageQuery{Limit: 50} // default value
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			page.Limit = parsedLimit
+		}
+	}
+
+	if token := query.Get("max_id"); token != "" {
+		cursor, err := domain.DecodeCursor(token)
+		if err != nil {
+			return page, err
+		}
+		page.MaxID = &cursor
+	}
+
+	if token := query.Get("since_id"); token != "" {
+		cursor, err := domain.DecodeCursor(token)
+		if err != nil {
+			return page, err
+		}
+		page.SinceID = &cursor
+	}
+
+	return page, nil
+}
+
+// newTweetResponse converts a tweet and its counts into its response
+func newTweetResponse(tweet *domain.Tweet, counts domain.TweetCounts) TweetResponse {
+	return TweetResponse{
+		ID:                tweet.ID,
+		UserID:            tweet.UserID,
+		Kind:              tweet.TweetKind(),
+		Content:           tweet.Content,
+		InReplyToTweetID:  tweet.InReplyToTweetID,
+		ConversationID:    tweet.Conversation(),
+		ReferencedTweetID: tweet.ReferencedTweetID,
+		Entities:          tweet.Entities,
+		ReplyCount:        counts.Replies,
+		RetweetCount:      counts.Retweets,
+		QuoteCount:        counts.Quotes,
+		LikeCount:         counts.Likes,
+		CreatedAt:         tweet.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// newTweetPageResponse builds the response envelope of a page of tweets
+// from their responses
+func newTweetPageResponse(tweets []*domain.Tweet, responses []TweetResponse, page domain.PageQuery) TweetPageResponse {
+	positions := make([]domain.Cursor, len(tweets))
+	for i, tweet := range tweets {
+		positions[i] = domain.CursorOf(tweet)
+	}
+	return newPageResponse(responses, positions, page)
+}
+
+// newPageResponse builds the response envelope of a page from the responses
+// and the position of each item of the page, which may have more items
+// than responses (e.g. likes of deleted tweets)
+func newPageResponse(responses []TweetResponse, positions []domain.Cursor, page domain.PageQuery) TweetPageResponse {
+	response := TweetPageResponse{Tweets: responses}
+	response.NextCursor, response.NewestCursor = pageCursors(positions, page)
+	return response
+}
+
+// pageCursors returns the cursors of a page from the positions of its
+// items, in order
+func pageCursors(positions []domain.Cursor, page domain.PageQuery) (next, newest string) {
+	if len(positions) == 0 {
+		// Nothing new: keep polling from the same position
+		if page.SinceID != nil {
+			newest = page.SinceID.Encode()
+		}
+		return "", newest
+	}
+
+	// A short page is the last one (same limit bounds as the use case)
+	limit := page.Limit
+	if limit <= 0 || limit > domain.MaxTimelineLimit {
+		limit = domain.MaxTimelineLimit
+	}
+
+	newest = positions[0].Encode()
+	if len(positions) >= limit {
+		next = positions[len(positions)-1].Encode()
+	}
+	return next, newest
+}
+
+// newThreadTweetResponses converts the nodes of a thread into their responses
+func newThreadTweetResponses(nodes []*domain.ThreadNode, counts map[string]domain.TweetCounts, liked map[string]bool) []ThreadTweetResponse {
+	responses := make([]ThreadTweetResponse, 0, len(nodes))
+	for _, node := range nodes {
+		response := ThreadTweetResponse{
+			TweetResponse: newTweetResponse(node.Tweet, counts[node.Tweet.ID]),
+			Replies:       newThreadTweetResponses(node.Replies, counts, liked),
+		}
+		response.LikedByMe = liked[node.Tweet.ID]
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// threadTweets lists the tweets of a thread
+func threadTweets(nodes []*domain.ThreadNode) []*domain.Tweet {
+	var tweets []*domain.Tweet
+	for _, node := range nodes {
+		tweets = append(tweets, node.Tweet)
+		tweets = append(tweets, threadTweets(node.Replies)...)
+	}
+	return tweets
+}
+
+// tweetResponses converts tweets into their responses, with the counts of
+// both the tweets and the ones they reshare
+func (h *Handlers) tweetResponses(r *http.Request, tweets []*domain.Tweet) ([]TweetResponse, error) {
+	referenced, err := h.tweetUseCase.GetReferencedTweets(r.Context(), tweets)
+	if err != nil {
+		return nil, err
+	}
+
+	counted := append([]*domain.Tweet{}, tweets...)
+	for _, tweet := range referenced {
+		counted = append(counted, tweet)
+	}
+	counts, liked, err := h.getCounts(r, counted)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]TweetResponse, 0, len(tweets))
+	for _, tweet := range tweets {
+		response := newTweetResponse(tweet, counts[tweet.ID])
+		response.LikedByMe = liked[tweet.ID]
+		if original, ok := referenced[tweet.ReferencedTweetID]; ok {
+			embedded := newTweetResponse(original, counts[original.ID])
+			embedded.LikedByMe = liked[original.ID]
+			response.ReferencedTweet = &embedded
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// getCounts counts the interactions with tweets, likes included, and
+// returns the ones liked by the caller
+func (h *Handlers) getCounts(r *http.Request, tweets []*domain.Tweet) (map[string]domain.TweetCounts, map[string]bool, error) {
+	counts, err := h.tweetUseCase.GetCounts(r.Context(), tweets)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.likeUseCase == nil || len(tweets) == 0 {
+		return counts, nil, nil
+	}
+
+	ids := make([]string, len(tweets))
+	for i, tweet := range tweets {
+		ids[i] = tweet.ID
+	}
+	viewerID, _ := userIDFromContext(r.Context())
+	likes, liked, err := h.likeUseCase.GetLikeInfo(r.Context(), viewerID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for id, count := range counts {
+		count.Likes = likes[id]
+		counts[id] = count
+	}
+	return counts, liked, nil
+}
+
+// writeTweet writes a single tweet with its counts
+func (h *Handlers) writeTweet(w http.ResponseWriter, r *http.Request, status int, tweet *domain.Tweet) {
+	responses, err := h.tweetResponses(r, []*domain.Tweet{tweet})
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, status, responses[0])
+}
+
+// writeTweetPage writes a page of tweets with their counts
+func (h *Handlers) writeTweetPage(w http.ResponseWriter, r *http.Request, tweets []*domain.Tweet, page domain.PageQuery) {
+	responses, err := h.tweetResponses(r, tweets)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTweetPageResponse(tweets, responses, page))
+}
+
+// CreateTweet handles the creation of a new tweet, of a reply when
+// in_reply_to_tweet_id is set, or of a quote when quoted_tweet_id is set
 func (h *Handlers) CreateTweet(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		writeError(w, http.StatusBadRequest, "X-User-ID header is required")
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
 		return
 	}
 
@@ -94,33 +356,168 @@ func (h *Handlers) CreateTweet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Content == "" {
-		writeError(w, http.StatusBadRequest, "Content is required")
+	if req.InReplyToTweetID != "" && req.QuotedTweetID != "" {
+		writeError(w, http.StatusBadRequest, "a tweet can not both reply to and quote a tweet")
+		return
+	}
+
+	// Content rules live in the domain (ErrEmptyContent, ErrContentTooLong)
+	var tweet *domain.Tweet
+	var err error
+	switch {
+	case req.InReplyToTweetID != "":
+		tweet, err = h.tweetUseCase.CreateReply(r.Context(), userID, req.InReplyToTweetID, req.Content)
+	case req.QuotedTweetID != "":
+		tweet, err = h.tweetUseCase.CreateQuote(r.Context(), userID, req.QuotedTweetID, req.Content)
+	default:
+		tweet, err = h.tweetUseCase.CreateTweet(r.Context(), userID, req.Content)
+	}
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweet(w, r, http.StatusCreated, tweet)
+}
+
+// GetTweet gets a tweet by its ID
+func (h *Handlers) GetTweet(w http.ResponseWriter, r *http.Request) {
+	// Extract tweetID from path (format: /tweets/{tweetID})
+	tweetID := extractTweetIDFromPath(r.URL.Path)
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	tweet, err := h.tweetUseCase.GetTweet(r.Context(), tweetID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweet(w, r, http.StatusOK, tweet)
+}
+
+// GetReplies gets a page of the direct replies to a tweet
+func (h *Handlers) GetReplies(w http.ResponseWriter, r *http.Request) {
+	// Extract tweetID from path (format: /tweets/{tweetID}/replies)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/replies")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	page, err := parsePageQuery(r)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	tweets, err := h.tweetUseCase.GetReplies(r.Context(), tweetID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweetPage(w, r, tweets, page)
+}
+
+// GetThread gets the whole conversation a tweet belongs to
+func (h *Handlers) GetThread(w http.ResponseWriter, r *http.Request) {
+	// Extract tweetID from path (format: /tweets/{tweetID}/thread)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/thread")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	conversationID, thread, err := h.tweetUseCase.GetThread(r.Context(), tweetID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	counts, liked, err := h.getCounts(r, threadTweets(thread))
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ThreadResponse{
+		ConversationID: conversationID,
+		Tweets:         newThreadTweetResponses(thread, counts, liked),
+	})
+}
+
+// Retweet reshares a tweet as the authenticated user
+func (h *Handlers) Retweet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
 		return
 	}
 
-	if len(req.Content) > 280 {
-		writeError(w, http.StatusBadRequest, "Content exceeds 280 characters")
+	// Extract tweetID from path (format: /tweets/{tweetID}/retweet)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/retweet")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
 		return
 	}
 
-	tweet, err := h.tweetUseCase.CreateTweet(r.Context(), userID, req.Content)
+	retweet, err := h.tweetUseCase.Retweet(r.Context(), userID, tweetID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweet(w, r, http.StatusCreated, retweet)
+}
+
+// UndoRetweet removes the authenticated user's retweet of a tweet
+func (h *Handlers) UndoRetweet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract tweetID from path (format: /tweets/{tweetID}/retweet)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/retweet")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	if err := h.tweetUseCase.UndoRetweet(r.Context(), userID, tweetID); err != nil {
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
-	response := TweetResponse{
-		ID:        tweet.ID,
-		UserID:    tweet.UserID,
-		Content:   tweet.Content,
-		CreatedAt: tweet.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully undid retweet"})
+}
+
+// DeleteTweet deletes a tweet of the authenticated user
+func (h *Handlers) DeleteTweet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract tweetID from path (format: /tweets/{tweetID})
+	tweetID := extractTweetIDFromPath(r.URL.Path)
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
 	}
 
-	writeJSON(w, http.StatusCreated, response)
+	err := h.tweetUseCase.DeleteTweet(r.Context(), userID, tweetID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully deleted tweet"})
 }
 
-// GetTimeline gets a user's timeline
+// GetTimeline gets a page of a user's timeline
 func (h *Handlers) GetTimeline(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from path (format: /users/{userID}/timeline)
 	userID := extractUserIDFromPath(r.URL.Path, "/timeline")
@@ -130,34 +527,22 @@ func (h *Handlers) GetTimeline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 50 // default value
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
-			limit = parsedLimit
-		}
-	}
-
-	tweets, err := h.tweetUseCase.GetTimeline(r.Context(), userID, limit)
+	page, err := parsePageQuery(r)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
-	var response []TweetResponse
-	for _, tweet := range tweets {
-		response = append(response, TweetResponse{
-			ID:        tweet.ID,
-			UserID:    tweet.UserID,
-			Content:   tweet.Content,
-			CreatedAt: tweet.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
+	tweets, err := h.tweetUseCase.GetTimelinePage(r.Context(), userID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	h.writeTweetPage(w, r, tweets, page)
 }
 
-// GetUserTweets gets all tweets from a user
+// GetUserTweets gets a page of the tweets from a user
 func (h *Handlers) GetUserTweets(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from path (format: /users/{userID}/tweets)
 	userID := extractUserIDFromPath(r.URL.Path, "/tweets")
@@ -167,30 +552,50 @@ func (h *Handlers) GetUserTweets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tweets, err := h.tweetUseCase.GetUserTweets(r.Context(), userID)
+	page, err := parsePageQuery(r)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
-	var response []TweetResponse
-	for _, tweet := range tweets {
-		response = append(response, TweetResponse{
-			ID:        tweet.ID,
-			UserID:    tweet.UserID,
-			Content:   tweet.Content,
-			CreatedAt: tweet.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
+	tweets, err := h.tweetUseCase.GetUserTweetsPage(r.Context(), userID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweetPage(w, r, tweets, page)
+}
+
+// GetMentions gets a page of the tweets that mention a user
+func (h *Handlers) GetMentions(w http.ResponseWriter, r *http.Request) {
+	// Extract userID from path (format: /users/{userID}/mentions)
+	userID := extractUserIDFromPath(r.URL.Path, "/mentions")
+
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userID parameter is required")
+		return
+	}
+
+	page, err := parsePageQuery(r)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	tweets, err := h.tweetUseCase.GetMentions(r.Context(), userID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	h.writeTweetPage(w, r, tweets, page)
 }
 
 // FollowUser allows a user to follow another user
 func (h *Handlers) FollowUser(w http.ResponseWriter, r *http.Request) {
-	followerID := r.Header.Get("X-User-ID")
-	if followerID == "" {
-		writeError(w, http.StatusBadRequest, "X-User-ID header is required")
+	followerID, ok := authenticatedUserID(w, r)
+	if !ok {
 		return
 	}
 
@@ -207,7 +612,7 @@ func (h *Handlers) FollowUser(w http.ResponseWriter, r *http.Request) {
 
 	err := h.followUseCase.FollowUser(r.Context(), followerID, req.FolloweeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
@@ -216,9 +621,8 @@ func (h *Handlers) FollowUser(w http.ResponseWriter, r *http.Request) {
 
 // UnfollowUser allows a user to stop following another user
 func (h *Handlers) UnfollowUser(w http.ResponseWriter, r *http.Request) {
-	followerID := r.Header.Get("X-User-ID")
-	if followerID == "" {
-		writeError(w, http.StatusBadRequest, "X-User-ID header is required")
+	followerID, ok := authenticatedUserID(w, r)
+	if !ok {
 		return
 	}
 
@@ -231,13 +635,61 @@ func (h *Handlers) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 
 	err := h.followUseCase.UnfollowUser(r.Context(), followerID, followeeID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully unfollowed user"})
 }
 
+// BlockUser allows a user to block another user
+func (h *Handlers) BlockUser(w http.ResponseWriter, r *http.Request) {
+	blockerID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if req.BlockedID == "" {
+		writeError(w, http.StatusBadRequest, "blocked_id is required")
+		return
+	}
+
+	if err := h.followUseCase.BlockUser(r.Context(), blockerID, req.BlockedID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully blocked user"})
+}
+
+// UnblockUser allows a user to unblock another user
+func (h *Handlers) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	blockerID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract blockedID from path (format: /users/blocking/{blockedID})
+	blockedID := r.URL.Path[len("/users/blocking/"):]
+	if blockedID == "" {
+		writeError(w, http.StatusBadRequest, "blockedID parameter is required")
+		return
+	}
+
+	if err := h.followUseCase.UnblockUser(r.Context(), blockerID, blockedID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully unblocked user"})
+}
+
 // GetFollowers gets the followers of a user
 func (h *Handlers) GetFollowers(w http.ResponseWriter, r *http.Request) {
 	// Extract userID from path (format: /users/{userID}/followers)
@@ -250,7 +702,7 @@ func (h *Handlers) GetFollowers(w http.ResponseWriter, r *http.Request) {
 
 	followers, err := h.followUseCase.GetFollowers(r.Context(), userID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
@@ -269,7 +721,7 @@ func (h *Handlers) GetFollowing(w http.ResponseWriter, r *http.Request) {
 
 	following, err := h.followUseCase.GetFollowing(r.Context(), userID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeUseCaseError(w, r, err)
 		return
 	}
 
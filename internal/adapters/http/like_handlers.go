@@ -0,0 +1,108 @@
+package http
+
+import (
+	"net/http"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// WithLikes lets users like tweets and adds like counts to tweet responses
+func WithLikes(likeUseCase *usecases.LikeUseCase) Option {
+	return func(h *Handlers) {
+		h.likeUseCase = likeUseCase
+	}
+}
+
+// LikeTweet likes a tweet as the authenticated user (idempotent)
+func (h *Handlers) LikeTweet(w http.ResponseWriter, r *http.Request) {
+	if h.likeUseCase == nil {
+		writeError(w, http.StatusNotFound, "likes are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract tweetID from path (format: /tweets/{tweetID}/like)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/like")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	if err := h.likeUseCase.LikeTweet(r.Context(), userID, tweetID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully liked tweet"})
+}
+
+// UnlikeTweet removes the authenticated user's like of a tweet (idempotent)
+func (h *Handlers) UnlikeTweet(w http.ResponseWriter, r *http.Request) {
+	if h.likeUseCase == nil {
+		writeError(w, http.StatusNotFound, "likes are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract tweetID from path (format: /tweets/{tweetID}/like)
+	tweetID := extractTweetIDFromSubPath(r.URL.Path, "/like")
+	if tweetID == "" {
+		writeError(w, http.StatusBadRequest, "tweetID parameter is required")
+		return
+	}
+
+	if err := h.likeUseCase.UnlikeTweet(r.Context(), userID, tweetID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully unliked tweet"})
+}
+
+// GetUserLikes gets a page of the tweets liked by a user, most recently
+// liked first. Cursors are positions in the list of likes
+func (h *Handlers) GetUserLikes(w http.ResponseWriter, r *http.Request) {
+	if h.likeUseCase == nil {
+		writeError(w, http.StatusNotFound, "likes are disabled")
+		return
+	}
+
+	// Extract userID from path (format: /users/{userID}/likes)
+	userID := extractUserIDFromPath(r.URL.Path, "/likes")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userID parameter is required")
+		return
+	}
+
+	page, err := parsePageQuery(r)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	likes, tweets, err := h.likeUseCase.GetUserLikes(r.Context(), userID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	responses, err := h.tweetResponses(r, tweets)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	positions := make([]domain.Cursor, len(likes))
+	for i, like := range likes {
+		positions[i] = domain.CursorOfLike(like)
+	}
+	writeJSON(w, http.StatusOK, newPageResponse(responses, positions, page))
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// Notification request/response structures
+
+// MarkReadRequest marks notifications as read up to UpTo, a cursor of the
+// list of notifications, included. Without it every one is marked
+type MarkReadRequest struct {
+	UpTo string `json:"up_to"`
+}
+
+// NotificationResponse is a notification, with the latest users that
+// caused it most recent first, and how many did
+type NotificationResponse struct {
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	TweetID    string   `json:"tweet_id,omitempty"`
+	ActorIDs   []string `json:"actor_ids"`
+	ActorCount int      `json:"actor_count"`
+	Read       bool     `json:"read"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// NotificationPageResponse is a page of notifications, with the same
+// cursors as TweetPageResponse
+type NotificationPageResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor,omitempty"`
+	NewestCursor  string                 `json:"newest_cursor,omitempty"`
+}
+
+type UnreadCountResponse struct {
+	UnreadCount int `json:"unread_count"`
+}
+
+// WithNotifications lets users read their notifications
+func WithNotifications(notifications *usecases.NotificationUseCase) Option {
+	return func(h *Handlers) {
+		h.notifications = notifications
+	}
+}
+
+// GetNotifications gets a page of the notifications of the authenticated
+// user, most recently updated first
+func (h *Handlers) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	if h.notifications == nil {
+		writeError(w, http.StatusNotFound, "notifications are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePageQuery(r)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	notifications, err := h.notifications.GetNotifications(r.Context(), userID, page)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	response := NotificationPageResponse{Notifications: make([]NotificationResponse, len(notifications))}
+	positions := make([]domain.Cursor, len(notifications))
+	for i, notification := range notifications {
+		response.Notifications[i] = newNotificationResponse(notification)
+		positions[i] = domain.CursorOfNotification(notification)
+	}
+	response.NextCursor, response.NewestCursor = pageCursors(positions, page)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// MarkNotificationsRead marks notifications of the authenticated user as
+// read and returns how many are left unread
+func (h *Handlers) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if h.notifications == nil {
+		writeError(w, http.StatusNotFound, "notifications are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var upTo *domain.Cursor
+	if req.UpTo != "" {
+		cursor, err := domain.DecodeCursor(req.UpTo)
+		if err != nil {
+			h.writeUseCaseError(w, r, err)
+			return
+		}
+		upTo = &cursor
+	}
+
+	unread, err := h.notifications.MarkRead(r.Context(), userID, upTo)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UnreadCountResponse{UnreadCount: unread})
+}
+
+// GetUnreadCount counts the unread notifications of the authenticated user
+func (h *Handlers) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if h.notifications == nil {
+		writeError(w, http.StatusNotFound, "notifications are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	unread, err := h.notifications.CountUnread(r.Context(), userID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, UnreadCountResponse{UnreadCount: unread})
+}
+
+// newNotificationResponse converts a notification into its response
+func newNotificationResponse(notification *domain.Notification) NotificationResponse {
+	return NotificationResponse{
+		ID:         notification.ID,
+		Type:       notification.Type,
+		TweetID:    notification.TweetID,
+		ActorIDs:   notification.ActorIDs,
+		ActorCount: notification.Actors(),
+		Read:       notification.Read,
+		CreatedAt:  notification.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:  notification.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
@@ -3,15 +3,14 @@ package http
 import (
 	"net/http"
 	"strings"
+	"twitter-clone-backend/internal/domain"
 )
 
 // methodHandler wraps an HTTP handler with method validation
 func methodHandler(method string, handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != method {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			w.Write([]byte(`{"error": "Method not allowed"}`))
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 			return
 		}
 		handler(w, r)
@@ -26,8 +25,8 @@ func SetupRoutes(handlers *Handlers) http.Handler {
 	corsHandler := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, X-User-ID")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-User-ID, Last-Event-ID")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusNoContent)
@@ -46,23 +45,107 @@ func SetupRoutes(handlers *Handlers) http.Handler {
 	})
 
 	// API routes - Clean REST endpoints con validación de métodos
-	mux.HandleFunc("/tweets", methodHandler("POST", handlers.CreateTweet))
+	// requireScope names the scope API keys need; other endpoints reject them
+	mux.HandleFunc("/tweets", methodHandler("POST", requireScope(domain.ScopeTweetsWrite, handlers.CreateTweet)))
+	mux.HandleFunc("/tweets/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasSuffix(path, "/replies") {
+			methodHandler("GET", handlers.GetReplies)(w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/thread") {
+			methodHandler("GET", handlers.GetThread)(w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/like") {
+			if r.Method == "DELETE" {
+				requireScope(domain.ScopeTweetsWrite, handlers.UnlikeTweet)(w, r)
+				return
+			}
+			methodHandler("POST", requireScope(domain.ScopeTweetsWrite, handlers.LikeTweet))(w, r)
+			return
+		}
+		if strings.HasSuffix(path, "/retweet") {
+			if r.Method == "DELETE" {
+				requireScope(domain.ScopeTweetsWrite, handlers.UndoRetweet)(w, r)
+				return
+			}
+			methodHandler("POST", requireScope(domain.ScopeTweetsWrite, handlers.Retweet))(w, r)
+			return
+		}
+		if r.Method == "DELETE" {
+			requireScope(domain.ScopeTweetsWrite, handlers.DeleteTweet)(w, r)
+			return
+		}
+		methodHandler("GET", handlers.GetTweet)(w, r)
+	})
 	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if strings.HasSuffix(path, "/tweets") {
 			methodHandler("GET", handlers.GetUserTweets)(w, r)
+		} else if strings.HasSuffix(path, "/timeline/stream") {
+			methodHandler("GET", requireScope(domain.ScopeTimelineRead, handlers.StreamTimeline))(w, r)
+		} else if strings.HasSuffix(path, "/mentions") {
+			methodHandler("GET", handlers.GetMentions)(w, r)
+		} else if strings.HasSuffix(path, "/likes") {
+			methodHandler("GET", handlers.GetUserLikes)(w, r)
 		} else if strings.HasSuffix(path, "/timeline") {
-			methodHandler("GET", handlers.GetTimeline)(w, r)
+			methodHandler("GET", requireScope(domain.ScopeTimelineRead, handlers.GetTimeline))(w, r)
 		} else if strings.HasSuffix(path, "/followers") {
 			methodHandler("GET", handlers.GetFollowers)(w, r)
 		} else if strings.HasSuffix(path, "/following") {
 			methodHandler("GET", handlers.GetFollowing)(w, r)
+		} else if !strings.Contains(strings.TrimPrefix(path, "/users/"), "/") {
+			methodHandler("GET", handlers.GetUser)(w, r)
+		} else {
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	})
+	mux.HandleFunc("/users", methodHandler("POST", handlers.RegisterUser))
+	mux.HandleFunc("/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			handlers.UpdateMe(w, r)
+			return
+		}
+		methodHandler("GET", handlers.GetMe)(w, r)
+	})
+	mux.HandleFunc("/users/me/password", methodHandler("PUT", handlers.ChangePassword))
+	mux.HandleFunc("/users/me/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.CreateAPIKey(w, r)
+			return
+		}
+		methodHandler("GET", handlers.ListAPIKeys)(w, r)
+	})
+	mux.HandleFunc("/users/me/api-keys/", methodHandler("DELETE", handlers.RevokeAPIKey))
+	mux.HandleFunc("/users/by-username/", methodHandler("GET", handlers.GetUserByUsername))
+	mux.HandleFunc("/auth/login", methodHandler("POST", handlers.Login))
+	mux.HandleFunc("/auth/logout", methodHandler("POST", handlers.Logout))
+	mux.HandleFunc("/auth/refresh", methodHandler("POST", handlers.RefreshToken))
+	mux.HandleFunc("/users/following", methodHandler("POST", requireScope(domain.ScopeFollowsWrite, handlers.FollowUser)))
+	mux.HandleFunc("/users/following/", methodHandler("DELETE", requireScope(domain.ScopeFollowsWrite, handlers.UnfollowUser)))
+	mux.HandleFunc("/users/blocking", methodHandler("POST", requireScope(domain.ScopeFollowsWrite, handlers.BlockUser)))
+	mux.HandleFunc("/users/blocking/", methodHandler("DELETE", requireScope(domain.ScopeFollowsWrite, handlers.UnblockUser)))
+	mux.HandleFunc("/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.CreateWebhook(w, r)
+			return
+		}
+		methodHandler("GET", handlers.ListWebhooks)(w, r)
+	})
+	mux.HandleFunc("/webhooks/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasSuffix(path, "/enable") {
+			methodHandler("POST", handlers.EnableWebhook)(w, r)
+		} else if strings.HasSuffix(path, "/deliveries") {
+			methodHandler("GET", handlers.GetWebhookDeliveries)(w, r)
 		} else {
-			http.NotFound(w, r)
+			methodHandler("DELETE", handlers.DeleteWebhook)(w, r)
 		}
 	})
-	mux.HandleFunc("/users/following", methodHandler("POST", handlers.FollowUser))
-	mux.HandleFunc("/users/following/", methodHandler("DELETE", handlers.UnfollowUser))
+	mux.HandleFunc("/notifications", methodHandler("GET", handlers.GetNotifications))
+	mux.HandleFunc("/notifications/read", methodHandler("POST", handlers.MarkNotificationsRead))
+	mux.HandleFunc("/notifications/unread_count", methodHandler("GET", handlers.GetUnreadCount))
 
-	return corsHandler(mux)
+	return corsHandler(handlers.authenticate(mux))
 }
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// Server-Sent Events settings
+const (
+	// streamWriteTimeout bounds each write to a stream, so a connection
+	// that stopped reading does not hold its handler forever
+	streamWriteTimeout = 10 * time.Second
+	// streamRetry is the reconnection delay suggested to clients
+	streamRetry = 3 * time.Second
+)
+
+// WithTimelineStreams pushes new tweets to the timelines of connected users
+func WithTimelineStreams(timelineStreams *usecases.TimelineStreams) Option {
+	return func(h *Handlers) {
+		h.timelineStreams = timelineStreams
+	}
+}
+
+// StreamTimeline streams the new tweets of a user's timeline as Server-Sent
+// Events. The ID of each event is the cursor of its tweet: a client that
+// reconnects with it in Last-Event-ID first gets the tweets it missed,
+// oldest first. Comments are sent as heartbeats while there are no tweets.
+// A client that falls behind is disconnected, and can resume the same way
+func (h *Handlers) StreamTimeline(w http.ResponseWriter, r *http.Request) {
+	if h.timelineStreams == nil {
+		writeError(w, http.StatusNotFound, "timeline streaming is disabled")
+		return
+	}
+
+	// Extract userID from path (format: /users/{userID}/timeline/stream)
+	userID := extractUserIDFromPath(r.URL.Path, "/timeline/stream")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userID parameter is required")
+		return
+	}
+
+	var resumeFrom *domain.Cursor
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		cursor, err := domain.DecodeCursor(lastEventID)
+		if err != nil {
+			h.writeUseCaseError(w, r, err)
+			return
+		}
+		resumeFrom = &cursor
+	}
+
+	if _, err := h.userUseCase.GetUser(r.Context(), userID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	// Subscribe before reading the missed tweets, so none is lost in between
+	stream := h.timelineStreams.Subscribe(userID)
+	defer h.timelineStreams.Unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &eventStream{w: w, rc: http.NewResponseController(w)}
+	// The connection may be reused once the stream ends
+	defer sse.rc.SetWriteDeadline(time.Time{})
+	sse.write(fmt.Sprintf("retry: %d\n\n", streamRetry.Milliseconds()))
+
+	// Tweets relayed after subscribing may be older than the resume
+	// position, or already replayed
+	lastSeen := resumeFrom
+	replayed := make(map[string]bool)
+	for resumeFrom != nil && sse.err == nil {
+		page := domain.PageQuery{Limit: domain.MaxTimelineLimit, SinceID: resumeFrom}
+		tweets, err := h.tweetUseCase.GetTimelinePage(r.Context(), userID, page)
+		if err != nil {
+			h.logger.Error("failed to replay timeline stream", err, "userID", userID)
+			return
+		}
+
+		// Pages are newest first. Repeated retweets are left out of them,
+		// so a short page is not the last one: only an empty page is
+		if len(tweets) == 0 {
+			break
+		}
+		for i := len(tweets) - 1; i >= 0; i-- {
+			h.writeStreamTweet(r, sse, tweets[i])
+			replayed[tweets[i].ID] = true
+		}
+
+		cursor := domain.CursorOf(tweets[0])
+		resumeFrom = &cursor
+	}
+
+	heartbeat := time.NewTicker(h.timelineStreams.Heartbeat())
+	defer heartbeat.Stop()
+	for sse.err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stream.Done():
+			return
+		case tweet := <-stream.Tweets():
+			seen := lastSeen != nil && !lastSeen.IsNewer(tweet.CreatedAt, tweet.ID)
+			if !seen && !replayed[tweet.ID] {
+				h.writeStreamTweet(r, sse, tweet)
+			}
+		case <-heartbeat.C:
+			sse.write(": heartbeat\n\n")
+		}
+	}
+}
+
+// writeStreamTweet writes a tweet with its counts as an event
+func (h *Handlers) writeStreamTweet(r *http.Request, sse *eventStream, tweet *domain.Tweet) {
+	responses, err := h.tweetResponses(r, []*domain.Tweet{tweet})
+	if err != nil {
+		h.logger.Warn("failed to stream tweet", "error", err, "tweetID", tweet.ID)
+		return
+	}
+
+	data, _ := json.Marshal(responses[0])
+	sse.write(fmt.Sprintf("id: %s\nevent: tweet\ndata: %s\n\n", domain.CursorOf(tweet).Encode(), data))
+}
+
+// eventStream writes Server-Sent Events, flushing each one. After the
+// first failed write, the rest are skipped and err is kept
+type eventStream struct {
+	w   io.Writer
+	rc  *http.ResponseController
+	err error
+}
+
+// write sends a message of the stream
+func (s *eventStream) write(message string) {
+	if s.err != nil {
+		return
+	}
+
+	// Not every writer supports deadlines, the write itself still fails
+	// once the connection is gone
+	s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, s.err = io.WriteString(s.w, message); s.err == nil {
+		s.err = s.rc.Flush()
+	}
+}
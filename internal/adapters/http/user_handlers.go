@@ -0,0 +1,167 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"twitter-clone-backend/internal/domain"
+)
+
+// User request/response structures
+type RegisterUserRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"` // required when token authentication is enabled
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Location    string `json:"location"`
+}
+
+// UpdateProfileRequest is a partial edit: omitted fields are left unchanged
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Location    *string `json:"location"`
+}
+
+// AuthenticatedUserResponse is a user plus its tokens. Registration only
+// includes the tokens when token authentication is enabled
+type AuthenticatedUserResponse struct {
+	UserResponse
+	*TokenResponse
+}
+
+type UserResponse struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Location    string `json:"location"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// newUserResponse converts a user into its response
+func newUserResponse(user *domain.User) UserResponse {
+	return UserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		Location:    user.Location,
+		CreatedAt:   user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// RegisterUser handles the creation of a new user
+func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	var req RegisterUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	user, err := h.userUseCase.RegisterUser(r.Context(), req.Username, req.Password, domain.Profile{
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AvatarURL:   req.AvatarURL,
+		Location:    req.Location,
+	})
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	response := AuthenticatedUserResponse{UserResponse: newUserResponse(user)}
+	if h.authUseCase != nil {
+		tokens, err := h.authUseCase.IssueTokens(r.Context(), user.ID)
+		if err != nil {
+			h.writeUseCaseError(w, r, err)
+			return
+		}
+		response.TokenResponse = newTokenResponse(tokens)
+	}
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// GetUser gets a user by ID
+func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	// Extract userID from path (format: /users/{userID})
+	userID := strings.TrimPrefix(r.URL.Path, "/users/")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userID parameter is required")
+		return
+	}
+
+	user, err := h.userUseCase.GetUser(r.Context(), userID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// GetUserByUsername gets a user by username (ignoring case)
+func (h *Handlers) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	// Extract username from path (format: /users/by-username/{username})
+	username := strings.TrimPrefix(r.URL.Path, "/users/by-username/")
+	if username == "" || strings.Contains(username, "/") {
+		writeError(w, http.StatusBadRequest, "username parameter is required")
+		return
+	}
+
+	user, err := h.userUseCase.GetUserByUsername(r.Context(), username)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// GetMe gets the profile of the authenticated user
+func (h *Handlers) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userUseCase.GetUser(r.Context(), userID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// UpdateMe edits the profile of the authenticated user
+func (h *Handlers) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	user, err := h.userUseCase.UpdateProfile(r.Context(), userID, domain.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AvatarURL:   req.AvatarURL,
+		Location:    req.Location,
+	})
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+)
+
+// Webhook request/response structures
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+// WebhookResponse is a webhook, never with its secret. DisabledAt is set
+// while the webhook is disabled after repeated failures
+type WebhookResponse struct {
+	ID                  string   `json:"id"`
+	URL                 string   `json:"url"`
+	EventTypes          []string `json:"event_types"`
+	Enabled             bool     `json:"enabled"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	DisabledAt          string   `json:"disabled_at,omitempty"`
+	CreatedAt           string   `json:"created_at"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse is an attempt to deliver an event. StatusCode is
+// 0 if the receiver did not respond
+type WebhookDeliveryResponse struct {
+	ID         string `json:"id"`
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code"`
+	Succeeded  bool   `json:"succeeded"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// WithWebhooks lets users register webhooks for the events of their account
+func WithWebhooks(webhookUseCase *usecases.WebhookUseCase) Option {
+	return func(h *Handlers) {
+		h.webhookUseCase = webhookUseCase
+	}
+}
+
+// newWebhookResponse converts a webhook into its response
+func newWebhookResponse(webhook *domain.Webhook) WebhookResponse {
+	response := WebhookResponse{
+		ID:                  webhook.ID,
+		URL:                 webhook.URL,
+		EventTypes:          webhook.EventTypes,
+		Enabled:             webhook.IsEnabled(),
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		CreatedAt:           webhook.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if !webhook.IsEnabled() {
+		response.DisabledAt = webhook.DisabledAt.Format("2006-01-02T15:04:05Z")
+	}
+	return response
+}
+
+// CreateWebhook registers a webhook for the authenticated user
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookUseCase == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	webhook, err := h.webhookUseCase.CreateWebhook(r.Context(), userID, req.URL, req.EventTypes, req.Secret)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newWebhookResponse(webhook))
+}
+
+// ListWebhooks lists the webhooks of the authenticated user
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if h.webhookUseCase == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.webhookUseCase.ListWebhooks(r.Context(), userID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	response := WebhooksResponse{Webhooks: make([]WebhookResponse, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		response.Webhooks = append(response.Webhooks, newWebhookResponse(webhook))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DeleteWebhook deletes a webhook of the authenticated user
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookUseCase == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r, "")
+	if !ok {
+		return
+	}
+
+	if err := h.webhookUseCase.DeleteWebhook(r.Context(), userID, webhookID); err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "successfully deleted webhook"})
+}
+
+// EnableWebhook enables again a webhook of the authenticated user
+func (h *Handlers) EnableWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookUseCase == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r, "/enable")
+	if !ok {
+		return
+	}
+
+	webhook, err := h.webhookUseCase.EnableWebhook(r.Context(), userID, webhookID)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookResponse(webhook))
+}
+
+// GetWebhookDeliveries gets the last delivery attempts of a webhook of the
+// authenticated user, newest first
+func (h *Handlers) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if h.webhookUseCase == nil {
+		writeError(w, http.StatusNotFound, "webhooks are disabled")
+		return
+	}
+
+	userID, ok := authenticatedUserID(w, r)
+	if !ok {
+		return
+	}
+
+	webhookID, ok := webhookIDFromPath(w, r, "/deliveries")
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil {
+			limit = parsedLimit
+		}
+	}
+
+	deliveries, err := h.webhookUseCase.GetDeliveries(r.Context(), userID, webhookID, limit)
+	if err != nil {
+		h.writeUseCaseError(w, r, err)
+		return
+	}
+
+	response := WebhookDeliveriesResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, WebhookDeliveryResponse{
+			ID:         delivery.ID,
+			EventID:    delivery.EventID,
+			EventType:  delivery.EventType,
+			Attempt:    delivery.Attempt,
+			StatusCode: delivery.StatusCode,
+			Succeeded:  delivery.Succeeded(),
+			Error:      delivery.Error,
+			CreatedAt:  delivery.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// webhookIDFromPath extracts the webhook ID from a path of the form
+// /webhooks/{webhookID}{suffix}
+func webhookIDFromPath(w http.ResponseWriter, r *http.Request, suffix string) (string, bool) {
+	webhookID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/webhooks/"), suffix)
+	if webhookID == "" || strings.Contains(webhookID, "/") {
+		writeError(w, http.StatusBadRequest, "webhookID parameter is required")
+		return "", false
+	}
+	return webhookID, true
+}
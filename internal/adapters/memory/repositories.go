@@ -4,23 +4,47 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"time"
 	"twitter-clone-backend/internal/domain"
 )
 
 // Repositories implements repositories in memory
 type Repositories struct {
-	tweets  map[string]*domain.Tweet
-	users   map[string]*domain.User
-	follows map[string]map[string]bool // followerID -> followeeID -> true
-	mu      sync.RWMutex
+	*RevocationList
+
+	tweets        map[string]*domain.Tweet
+	users         map[string]*domain.User
+	usernames     map[string]string                   // normalized username -> userID
+	follows       map[string]map[string]bool          // followerID -> followeeID -> true
+	blocks        map[string]map[string]*domain.Block // blockerID -> blockedID -> block
+	likes         map[string]map[string]*domain.Like  // userID -> tweetID -> like
+	mentions      map[string]map[string]bool          // mentioned userID -> tweetID -> true
+	notifications map[string][]*domain.Notification   // userID -> notifications
+	outbox        map[string]*domain.OutboxEntry      // event ID -> entry not yet relayed
+	credentials   map[string]*domain.Credentials
+	apiKeys       map[string]*domain.APIKey
+	webhooks      map[string]*domain.Webhook
+	deliveries    map[string][]*domain.WebhookDelivery // webhookID -> attempts, oldest first
+	mu            sync.RWMutex
 }
 
 // NewRepositories creates a new instance of in-memory repositories
 func NewRepositories() *Repositories {
 	repo := &Repositories{
-		tweets:  make(map[string]*domain.Tweet),
-		users:   make(map[string]*domain.User),
-		follows: make(map[string]map[string]bool),
+		RevocationList: NewRevocationList(),
+		tweets:         make(map[string]*domain.Tweet),
+		users:          make(map[string]*domain.User),
+		usernames:      make(map[string]string),
+		follows:        make(map[string]map[string]bool),
+		blocks:         make(map[string]map[string]*domain.Block),
+		likes:          make(map[string]map[string]*domain.Like),
+		mentions:       make(map[string]map[string]bool),
+		notifications:  make(map[string][]*domain.Notification),
+		outbox:         make(map[string]*domain.OutboxEntry),
+		credentials:    make(map[string]*domain.Credentials),
+		apiKeys:        make(map[string]*domain.APIKey),
+		webhooks:       make(map[string]*domain.Webhook),
+		deliveries:     make(map[string][]*domain.WebhookDelivery),
 	}
 
 	// Add some example users for testing
@@ -38,17 +62,23 @@ func (r *Repositories) seedUsers() {
 	}
 
 	for _, user := range users {
-		r.users[user.ID] = user
+		r.putUser(user)
 	}
 }
 
 // TweetRepository methods
 
-func (r *Repositories) Create(ctx context.Context, tweet *domain.Tweet) error {
+func (r *Repositories) Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.tweets[tweet.ID] = tweet
+	r.putTweet(tweet)
+	r.putOutbox(entries)
 	return nil
 }
 
@@ -64,26 +94,40 @@ func (r *Repositories) GetByID(ctx context.Context, id string) (*domain.Tweet, e
 	return tweet, nil
 }
 
-func (r *Repositories) GetByUserID(ctx context.Context, userID string) ([]*domain.Tweet, error) {
+func (r *Repositories) GetByIDs(ctx context.Context, ids []string) ([]*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tweets := make([]*domain.Tweet, 0, len(ids))
+	for _, id := range ids {
+		if tweet, exists := r.tweets[id]; exists {
+			tweets = append(tweets, tweet)
+		}
+	}
+
+	return tweets, nil
+}
+
+func (r *Repositories) GetByUserID(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	var tweets []*domain.Tweet
 	for _, tweet := range r.tweets {
-		if tweet.UserID == userID {
+		if tweet.UserID == userID && page.Contains(tweet.CreatedAt, tweet.ID) {
 			tweets = append(tweets, tweet)
 		}
 	}
 
 	// Sort by creation date (most recent first)
 	sort.Slice(tweets, func(i, j int) bool {
-		return tweets[i].CreatedAt.After(tweets[j].CreatedAt)
+		return tweets[i].Before(tweets[j])
 	})
 
-	return tweets, nil
+	return page.ApplyToTweets(tweets), nil
 }
 
-func (r *Repositories) GetTimeline(ctx context.Context, userIDs []string, limit int) ([]*domain.Tweet, error) {
+func (r *Repositories) GetTimeline(ctx context.Context, userIDs []string, page domain.PageQuery) ([]*domain.Tweet, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -94,33 +138,200 @@ func (r *Repositories) GetTimeline(ctx context.Context, userIDs []string, limit
 
 	var tweets []*domain.Tweet
 	for _, tweet := range r.tweets {
-		if userIDMap[tweet.UserID] {
+		if userIDMap[tweet.UserID] && page.Contains(tweet.CreatedAt, tweet.ID) {
 			tweets = append(tweets, tweet)
 		}
 	}
 
 	// Sort by creation date (most recent first)
 	sort.Slice(tweets, func(i, j int) bool {
-		return tweets[i].CreatedAt.After(tweets[j].CreatedAt)
+		return tweets[i].Before(tweets[j])
+	})
+
+	// Apply cursors and limit
+	return page.ApplyToTweets(tweets), nil
+}
+
+func (r *Repositories) GetMentions(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tweets []*domain.Tweet
+	for tweetID := range r.mentions[userID] {
+		tweet := r.tweets[tweetID]
+		if page.Contains(tweet.CreatedAt, tweet.ID) {
+			tweets = append(tweets, tweet)
+		}
+	}
+
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].Before(tweets[j])
+	})
+
+	return page.ApplyToTweets(tweets), nil
+}
+
+func (r *Repositories) GetReplies(ctx context.Context, tweetID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tweets []*domain.Tweet
+	for _, tweet := range r.tweets {
+		if tweet.InReplyToTweetID == tweetID && page.Contains(tweet.CreatedAt, tweet.ID) {
+			tweets = append(tweets, tweet)
+		}
+	}
+
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].Before(tweets[j])
+	})
+
+	return page.ApplyToTweets(tweets), nil
+}
+
+func (r *Repositories) GetConversation(ctx context.Context, conversationID string, limit int) ([]*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tweets []*domain.Tweet
+	for _, tweet := range r.tweets {
+		if tweet.Conversation() == conversationID {
+			tweets = append(tweets, tweet)
+		}
+	}
+
+	// Oldest first
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[j].Before(tweets[i])
 	})
 
-	// Apply limit
 	if limit > 0 && len(tweets) > limit {
 		tweets = tweets[:limit]
 	}
-
 	return tweets, nil
 }
 
-func (r *Repositories) Delete(ctx context.Context, id string) error {
+func (r *Repositories) CountReplies(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(tweetIDs, func(tweet *domain.Tweet) string {
+		return tweet.InReplyToTweetID
+	}), nil
+}
+
+func (r *Repositories) CreateRetweet(ctx context.Context, retweet *domain.Tweet, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Atomically verify if already retweeted
+	if r.findRetweet(retweet.UserID, retweet.ReferencedTweetID) != nil {
+		return domain.ErrAlreadyRetweeted
+	}
+
+	r.tweets[retweet.ID] = retweet
+	r.putOutbox(entries)
+	return nil
+}
+
+func (r *Repositories) GetRetweet(ctx context.Context, userID, tweetID string) (*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	retweet := r.findRetweet(userID, tweetID)
+	if retweet == nil {
+		return nil, domain.ErrNotRetweeted
+	}
+
+	return retweet, nil
+}
+
+func (r *Repositories) GetRetweets(ctx context.Context, tweetID string) ([]*domain.Tweet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var retweets []*domain.Tweet
+	for _, tweet := range r.tweets {
+		if tweet.IsRetweet() && tweet.ReferencedTweetID == tweetID {
+			retweets = append(retweets, tweet)
+		}
+	}
+	return retweets, nil
+}
+
+func (r *Repositories) CountRetweets(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(tweetIDs, func(tweet *domain.Tweet) string {
+		if tweet.IsRetweet() {
+			return tweet.ReferencedTweetID
+		}
+		return ""
+	}), nil
+}
+
+func (r *Repositories) CountQuotes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(tweetIDs, func(tweet *domain.Tweet) string {
+		if tweet.IsQuote() {
+			return tweet.ReferencedTweetID
+		}
+		return ""
+	}), nil
+}
+
+// findRetweet returns the retweet of a tweet by a user, if any (caller must hold the lock)
+func (r *Repositories) findRetweet(userID, tweetID string) *domain.Tweet {
+	for _, tweet := range r.tweets {
+		if tweet.IsRetweet() && tweet.UserID == userID && tweet.ReferencedTweetID == tweetID {
+			return tweet
+		}
+	}
+	return nil
+}
+
+// countReferences counts the tweets that reference each of tweetIDs, as
+// returned by reference ("" for none)
+func (r *Repositories) countReferences(tweetIDs []string, reference func(*domain.Tweet) string) map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(tweetIDs))
+	for _, id := range tweetIDs {
+		wanted[id] = true
+	}
+
+	counts := make(map[string]int)
+	for _, tweet := range r.tweets {
+		if id := reference(tweet); id != "" && wanted[id] {
+			counts[id]++
+		}
+	}
+
+	return counts
+}
+
+func (r *Repositories) Delete(ctx context.Context, id string, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.tweets[id]; !exists {
+	tweet, exists := r.tweets[id]
+	if !exists {
 		return domain.ErrTweetNotFound
 	}
 
+	for _, userID := range tweet.Entities.MentionedUserIDs() {
+		delete(r.mentions[userID], id)
+		if len(r.mentions[userID]) == 0 {
+			delete(r.mentions, userID)
+		}
+	}
 	delete(r.tweets, id)
+	r.putOutbox(entries)
 	return nil
 }
 
@@ -138,7 +349,12 @@ func (r *Repositories) Follow(ctx context.Context, followerID, followeeID string
 	return nil
 }
 
-func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, followeeID string) error {
+func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -153,6 +369,7 @@ func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, follow
 	}
 
 	r.follows[followerID][followeeID] = true
+	r.putOutbox(entries)
 	return nil
 }
 
@@ -170,7 +387,12 @@ func (r *Repositories) Unfollow(ctx context.Context, followerID, followeeID stri
 	return nil
 }
 
-func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followeeID string) error {
+func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -185,6 +407,7 @@ func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followe
 		delete(r.follows, followerID)
 	}
 
+	r.putOutbox(entries)
 	return nil
 }
 
@@ -202,6 +425,20 @@ func (r *Repositories) GetFollowers(ctx context.Context, userID string) ([]strin
 	return followers, nil
 }
 
+func (r *Repositories) CountFollowers(ctx context.Context, userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, following := range r.follows {
+		if following[userID] {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
 func (r *Repositories) GetFollowing(ctx context.Context, userID string) ([]string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -227,13 +464,292 @@ func (r *Repositories) IsFollowing(ctx context.Context, followerID, followeeID s
 	return r.follows[followerID][followeeID], nil
 }
 
+func (r *Repositories) Block(ctx context.Context, block *domain.Block) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Atomically verify if already blocked
+	if r.blocks[block.BlockerID][block.BlockedID] != nil {
+		return domain.ErrAlreadyBlocked
+	}
+
+	r.putBlock(block)
+	return nil
+}
+
+func (r *Repositories) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Atomically verify if blocked
+	if r.blocks[blockerID][blockedID] == nil {
+		return domain.ErrNotBlocked
+	}
+
+	delete(r.blocks[blockerID], blockedID)
+	if len(r.blocks[blockerID]) == 0 {
+		delete(r.blocks, blockerID)
+	}
+	return nil
+}
+
+func (r *Repositories) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.blocks[blockerID][blockedID] != nil, nil
+}
+
+// LikeRepository methods
+
+func (r *Repositories) LikeIfNotExists(ctx context.Context, like *domain.Like, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Atomically verify if already liked
+	if r.likes[like.UserID][like.TweetID] != nil {
+		return domain.ErrAlreadyLiked
+	}
+
+	if r.likes[like.UserID] == nil {
+		r.likes[like.UserID] = make(map[string]*domain.Like)
+	}
+	r.likes[like.UserID][like.TweetID] = like
+	r.putOutbox(entries)
+	return nil
+}
+
+func (r *Repositories) UnlikeIfExists(ctx context.Context, userID, tweetID string, events ...domain.Event) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Atomically verify if liked
+	if r.likes[userID][tweetID] == nil {
+		return domain.ErrNotLiked
+	}
+
+	delete(r.likes[userID], tweetID)
+	if len(r.likes[userID]) == 0 {
+		delete(r.likes, userID)
+	}
+	r.putOutbox(entries)
+	return nil
+}
+
+func (r *Repositories) GetLikes(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Like, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var likes []*domain.Like
+	for _, like := range r.likes[userID] {
+		if page.Contains(like.CreatedAt, like.TweetID) {
+			likes = append(likes, like)
+		}
+	}
+
+	// Sort by like date (most recent first)
+	sort.Slice(likes, func(i, j int) bool {
+		return likes[i].Before(likes[j])
+	})
+
+	return page.ApplyToLikes(likes), nil
+}
+
+func (r *Repositories) CountLikes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, liked := range r.likes {
+		for _, id := range tweetIDs {
+			if liked[id] != nil {
+				counts[id]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *Repositories) GetLikedTweetIDs(ctx context.Context, userID string, tweetIDs []string) (map[string]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	liked := make(map[string]bool)
+	for _, id := range tweetIDs {
+		if r.likes[userID][id] != nil {
+			liked[id] = true
+		}
+	}
+	return liked, nil
+}
+
+// NotificationRepository methods
+
+func (r *Repositories) Notify(ctx context.Context, event *domain.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Notifications are replaced, never modified, since readers share them
+	notifications := r.notifications[event.UserID]
+	for i, notification := range notifications {
+		if notification.GroupsWith(event) {
+			notifications[i] = notification.Merge(event)
+			return nil
+		}
+	}
+
+	r.notifications[event.UserID] = append(notifications, event)
+	return nil
+}
+
+func (r *Repositories) GetNotifications(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var notifications []*domain.Notification
+	for _, notification := range r.notifications[userID] {
+		if page.Contains(notification.UpdatedAt, notification.ID) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].Before(notifications[j])
+	})
+
+	return page.ApplyToNotifications(notifications), nil
+}
+
+func (r *Repositories) CountUnread(ctx context.Context, userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, notification := range r.notifications[userID] {
+		if !notification.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *Repositories) MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, notification := range r.notifications[userID] {
+		if notification.Read || (upTo != nil && upTo.IsNewer(notification.UpdatedAt, notification.ID)) {
+			continue
+		}
+		read := *notification
+		read.Read = true
+		r.notifications[userID][i] = &read
+	}
+	return nil
+}
+
+// OutboxRepository methods
+
+func (r *Repositories) PendingEvents(ctx context.Context, limit int) ([]*domain.OutboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]*domain.OutboxEntry, 0, len(r.outbox))
+	for _, entry := range r.outbox {
+		if entry.Due(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Before(entries[j])
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (r *Repositories) MarkRelayed(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.outbox, id)
+	}
+	return nil
+}
+
+func (r *Repositories) ScheduleRetry(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, exists := r.outbox[id]
+	if !exists {
+		return nil
+	}
+
+	// Entries are replaced, never modified, since readers share them
+	retried := *entry
+	retried.Attempts++
+	retried.NextAttemptAt = at
+	r.outbox[id] = &retried
+	return nil
+}
+
+// AppendOutbox stores entries in the outbox. The file storage uses it to
+// write the entries it logged with a change
+func (r *Repositories) AppendOutbox(entries []*domain.OutboxEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.putOutbox(entries)
+}
+
 // UserRepository methods
 
 func (r *Repositories) CreateUser(ctx context.Context, user *domain.User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.users[user.ID] = user
+	if ownerID, taken := r.usernames[domain.NormalizeUsername(user.Username)]; taken && ownerID != user.ID {
+		return domain.ErrUsernameTaken
+	}
+
+	r.putUser(user)
+	return nil
+}
+
+func (r *Repositories) UpdateUser(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+
+	// Replace the stored copy so readers never see a partial update
+	updated := *current
+	profile := user.Profile()
+	updated.DisplayName = profile.DisplayName
+	updated.Bio = profile.Bio
+	updated.AvatarURL = profile.AvatarURL
+	updated.Location = profile.Location
+	r.users[user.ID] = &updated
 	return nil
 }
 
@@ -253,13 +769,12 @@ func (r *Repositories) GetUserByUsername(ctx context.Context, username string) (
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	for _, user := range r.users {
-		if user.Username == username {
-			return user, nil
-		}
+	userID, exists := r.usernames[domain.NormalizeUsername(username)]
+	if !exists {
+		return nil, domain.ErrUserNotFound
 	}
 
-	return nil, domain.ErrUserNotFound
+	return r.users[userID], nil
 }
 
 func (r *Repositories) Exists(ctx context.Context, id string) (bool, error) {
@@ -269,3 +784,381 @@ func (r *Repositories) Exists(ctx context.Context, id string) (bool, error) {
 	_, exists := r.users[id]
 	return exists, nil
 }
+
+// CredentialRepository methods
+
+func (r *Repositories) SetCredentials(ctx context.Context, credentials *domain.Credentials) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *credentials
+	r.credentials[credentials.UserID] = &stored
+	return nil
+}
+
+func (r *Repositories) GetCredentials(ctx context.Context, userID string) (*domain.Credentials, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credentials, exists := r.credentials[userID]
+	if !exists {
+		return nil, domain.ErrNoCredentials
+	}
+
+	return credentials, nil
+}
+
+func (r *Repositories) DeleteCredentials(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.credentials, userID)
+	return nil
+}
+
+// APIKeyRepository methods
+
+func (r *Repositories) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *key
+	r.apiKeys[key.ID] = &stored
+	return nil
+}
+
+func (r *Repositories) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, exists := r.apiKeys[id]
+	if !exists {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (r *Repositories) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []*domain.APIKey
+	for _, key := range r.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *Repositories) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, exists := r.apiKeys[id]; !exists || key.UserID != userID {
+		return domain.ErrAPIKeyNotFound
+	}
+
+	delete(r.apiKeys, id)
+	return nil
+}
+
+func (r *Repositories) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.apiKeys[id]
+	if !exists {
+		return nil
+	}
+
+	// Replace the stored copy so readers never see a partial update
+	touched := *key
+	touched.LastUsedAt = usedAt
+	r.apiKeys[id] = &touched
+	return nil
+}
+
+// WebhookRepository methods
+
+func (r *Repositories) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *webhook
+	r.webhooks[webhook.ID] = &stored
+	return nil
+}
+
+func (r *Repositories) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	webhook, exists := r.webhooks[id]
+	if !exists {
+		return nil, domain.ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+func (r *Repositories) ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var webhooks []*domain.Webhook
+	for _, webhook := range r.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.After(webhooks[j].CreatedAt)
+	})
+
+	return webhooks, nil
+}
+
+func (r *Repositories) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.webhooks[webhook.ID]; !exists {
+		return domain.ErrWebhookNotFound
+	}
+
+	// Replace the stored copy so readers never see a partial update
+	stored := *webhook
+	r.webhooks[webhook.ID] = &stored
+	return nil
+}
+
+func (r *Repositories) DeleteWebhook(ctx context.Context, userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if webhook, exists := r.webhooks[id]; !exists || webhook.UserID != userID {
+		return domain.ErrWebhookNotFound
+	}
+
+	delete(r.webhooks, id)
+	delete(r.deliveries, id)
+	return nil
+}
+
+func (r *Repositories) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.webhooks[delivery.WebhookID]; exists {
+		r.putDelivery(delivery)
+	}
+	return nil
+}
+
+func (r *Repositories) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	logged := r.deliveries[webhookID]
+	if limit <= 0 || limit > len(logged) {
+		limit = len(logged)
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0, limit)
+	for i := len(logged) - 1; i >= len(logged)-limit; i-- {
+		deliveries = append(deliveries, logged[i])
+	}
+	return deliveries, nil
+}
+
+// State is a point-in-time copy of all the data held by the repositories
+type State struct {
+	Tweets        []*domain.Tweet           `json:"tweets"`
+	Users         []*domain.User            `json:"users"`
+	Follows       []*domain.Follow          `json:"follows"`
+	Blocks        []*domain.Block           `json:"blocks"`
+	Likes         []*domain.Like            `json:"likes"`
+	Notifications []*domain.Notification    `json:"notifications"`
+	Outbox        []*domain.OutboxEntry     `json:"outbox"`
+	Credentials   []*domain.Credentials     `json:"credentials"`
+	APIKeys       []*domain.APIKey          `json:"api_keys"`
+	Webhooks      []*domain.Webhook         `json:"webhooks"`
+	Deliveries    []*domain.WebhookDelivery `json:"webhook_deliveries"`
+	RevokedTokens map[string]time.Time      `json:"revoked_tokens"` // token or token family ID -> expiration
+	RevokedUsers  map[string]time.Time      `json:"revoked_users"`  // userID -> tokens issued before are revoked
+}
+
+// Export returns a consistent copy of the current state
+func (r *Repositories) Export() *State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state := &State{
+		Tweets:        make([]*domain.Tweet, 0, len(r.tweets)),
+		Users:         make([]*domain.User, 0, len(r.users)),
+		Follows:       []*domain.Follow{},
+		Blocks:        []*domain.Block{},
+		Likes:         []*domain.Like{},
+		Notifications: []*domain.Notification{},
+		Outbox:        make([]*domain.OutboxEntry, 0, len(r.outbox)),
+		Credentials:   make([]*domain.Credentials, 0, len(r.credentials)),
+		APIKeys:       make([]*domain.APIKey, 0, len(r.apiKeys)),
+		Webhooks:      make([]*domain.Webhook, 0, len(r.webhooks)),
+		Deliveries:    []*domain.WebhookDelivery{},
+	}
+
+	for _, tweet := range r.tweets {
+		state.Tweets = append(state.Tweets, tweet)
+	}
+	for _, user := range r.users {
+		state.Users = append(state.Users, user)
+	}
+	for followerID, following := range r.follows {
+		for followeeID := range following {
+			state.Follows = append(state.Follows, &domain.Follow{FollowerID: followerID, FolloweeID: followeeID})
+		}
+	}
+	for _, blocked := range r.blocks {
+		for _, block := range blocked {
+			state.Blocks = append(state.Blocks, block)
+		}
+	}
+	for _, liked := range r.likes {
+		for _, like := range liked {
+			state.Likes = append(state.Likes, like)
+		}
+	}
+	for _, notifications := range r.notifications {
+		state.Notifications = append(state.Notifications, notifications...)
+	}
+	for _, entry := range r.outbox {
+		state.Outbox = append(state.Outbox, entry)
+	}
+	for _, credentials := range r.credentials {
+		state.Credentials = append(state.Credentials, credentials)
+	}
+	for _, key := range r.apiKeys {
+		state.APIKeys = append(state.APIKeys, key)
+	}
+	for _, webhook := range r.webhooks {
+		state.Webhooks = append(state.Webhooks, webhook)
+	}
+	for _, deliveries := range r.deliveries {
+		state.Deliveries = append(state.Deliveries, deliveries...)
+	}
+	state.RevokedTokens, state.RevokedUsers = r.RevocationList.export()
+
+	return state
+}
+
+// Import replaces the current state with the given one
+func (r *Repositories) Import(state *State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tweets = make(map[string]*domain.Tweet, len(state.Tweets))
+	r.users = make(map[string]*domain.User, len(state.Users))
+	r.usernames = make(map[string]string, len(state.Users))
+	r.follows = make(map[string]map[string]bool)
+	r.blocks = make(map[string]map[string]*domain.Block)
+	r.likes = make(map[string]map[string]*domain.Like)
+	r.mentions = make(map[string]map[string]bool)
+	r.notifications = make(map[string][]*domain.Notification)
+	r.outbox = make(map[string]*domain.OutboxEntry, len(state.Outbox))
+	r.credentials = make(map[string]*domain.Credentials, len(state.Credentials))
+	r.apiKeys = make(map[string]*domain.APIKey, len(state.APIKeys))
+	r.webhooks = make(map[string]*domain.Webhook, len(state.Webhooks))
+	r.deliveries = make(map[string][]*domain.WebhookDelivery)
+
+	for _, tweet := range state.Tweets {
+		r.putTweet(tweet)
+	}
+	for _, user := range state.Users {
+		r.putUser(user)
+	}
+	for _, follow := range state.Follows {
+		if r.follows[follow.FollowerID] == nil {
+			r.follows[follow.FollowerID] = make(map[string]bool)
+		}
+		r.follows[follow.FollowerID][follow.FolloweeID] = true
+	}
+	for _, block := range state.Blocks {
+		r.putBlock(block)
+	}
+	for _, like := range state.Likes {
+		if r.likes[like.UserID] == nil {
+			r.likes[like.UserID] = make(map[string]*domain.Like)
+		}
+		r.likes[like.UserID][like.TweetID] = like
+	}
+	for _, notification := range state.Notifications {
+		r.notifications[notification.UserID] = append(r.notifications[notification.UserID], notification)
+	}
+	r.putOutbox(state.Outbox)
+	for _, credentials := range state.Credentials {
+		r.credentials[credentials.UserID] = credentials
+	}
+	for _, key := range state.APIKeys {
+		r.apiKeys[key.ID] = key
+	}
+	for _, webhook := range state.Webhooks {
+		r.webhooks[webhook.ID] = webhook
+	}
+	for _, delivery := range state.Deliveries {
+		r.putDelivery(delivery)
+	}
+	r.RevocationList.restore(state.RevokedTokens, state.RevokedUsers)
+}
+
+// putTweet stores a tweet and indexes its mentions (caller must hold the lock)
+func (r *Repositories) putTweet(tweet *domain.Tweet) {
+	r.tweets[tweet.ID] = tweet
+	for _, userID := range tweet.Entities.MentionedUserIDs() {
+		if r.mentions[userID] == nil {
+			r.mentions[userID] = make(map[string]bool)
+		}
+		r.mentions[userID][tweet.ID] = true
+	}
+}
+
+// putBlock stores a block (caller must hold the lock)
+func (r *Repositories) putBlock(block *domain.Block) {
+	if r.blocks[block.BlockerID] == nil {
+		r.blocks[block.BlockerID] = make(map[string]*domain.Block)
+	}
+	r.blocks[block.BlockerID][block.BlockedID] = block
+}
+
+// putOutbox stores outbox entries (caller must hold the lock)
+func (r *Repositories) putOutbox(entries []*domain.OutboxEntry) {
+	for _, entry := range entries {
+		r.outbox[entry.ID] = entry
+	}
+}
+
+// putDelivery appends an attempt to the log of its webhook, dropping the
+// oldest ones beyond domain.MaxWebhookDeliveries (caller must hold the lock)
+func (r *Repositories) putDelivery(delivery *domain.WebhookDelivery) {
+	logged := append(r.deliveries[delivery.WebhookID], delivery)
+	if len(logged) > domain.MaxWebhookDeliveries {
+		logged = append([]*domain.WebhookDelivery{}, logged[len(logged)-domain.MaxWebhookDeliveries:]...)
+	}
+	r.deliveries[delivery.WebhookID] = logged
+}
+
+// putUser stores a user and indexes its username (caller must hold the lock)
+func (r *Repositories) putUser(user *domain.User) {
+	if previous, exists := r.users[user.ID]; exists {
+		delete(r.usernames, domain.NormalizeUsername(previous.Username))
+	}
+	r.users[user.ID] = user
+	r.usernames[domain.NormalizeUsername(user.Username)] = user.ID
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationList implements ports.RevocationList in memory. Revoked tokens
+// are forgotten once expired, so the list only holds live tokens. The
+// repositories embed one, it can also be used on its own
+type RevocationList struct {
+	tokens    map[string]time.Time // tokenID -> expiration
+	users     map[string]time.Time // userID -> tokens issued before are revoked
+	lastPurge time.Time
+	mu        sync.RWMutex
+}
+
+// purgeInterval is the minimum time between purges of expired tokens
+const purgeInterval = time.Minute
+
+// NewRevocationList creates an empty revocation list
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		tokens:    make(map[string]time.Time),
+		users:     make(map[string]time.Time),
+		lastPurge: time.Now(),
+	}
+}
+
+func (l *RevocationList) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.purge()
+	l.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (l *RevocationList) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.purge()
+	if _, revoked := l.tokens[tokenID]; revoked {
+		return false, nil
+	}
+	l.tokens[tokenID] = expiresAt
+	return true, nil
+}
+
+func (l *RevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	_, revoked := l.tokens[tokenID]
+	return revoked, nil
+}
+
+func (l *RevocationList) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if issuedBefore.After(l.users[userID]) {
+		l.users[userID] = issuedBefore
+	}
+	return nil
+}
+
+func (l *RevocationList) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.users[userID], nil
+}
+
+// purge forgets the expired tokens, at most once per purgeInterval (caller
+// must hold the lock)
+func (l *RevocationList) purge() {
+	now := time.Now()
+	if now.Sub(l.lastPurge) < purgeInterval {
+		return
+	}
+
+	// Keep a margin, verifiers tolerate some clock skew on expiration
+	for id, expiration := range l.tokens {
+		if now.Sub(expiration) > purgeInterval {
+			delete(l.tokens, id)
+		}
+	}
+	l.lastPurge = now
+}
+
+// export returns a copy of the revoked tokens and users
+func (l *RevocationList) export() (tokens, users map[string]time.Time) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	tokens = make(map[string]time.Time, len(l.tokens))
+	for id, expiration := range l.tokens {
+		tokens[id] = expiration
+	}
+	users = make(map[string]time.Time, len(l.users))
+	for id, issuedBefore := range l.users {
+		users[id] = issuedBefore
+	}
+	return tokens, users
+}
+
+// restore replaces the revoked tokens and users
+func (l *RevocationList) restore(tokens, users map[string]time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = make(map[string]time.Time, len(tokens))
+	for id, expiration := range tokens {
+		l.tokens[id] = expiration
+	}
+	l.users = make(map[string]time.Time, len(users))
+	for id, issuedBefore := range users {
+		l.users[id] = issuedBefore
+	}
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"twitter-clone-backend/internal/domain"
+)
+
+// timeline is a precomputed home timeline
+type timeline struct {
+	entries []domain.TimelineEntry // most recent first
+	ready   bool                   // false while being rebuilt
+	removed map[string]bool        // authors removed while being rebuilt
+}
+
+// TimelineStore implements ports.TimelineStore in memory
+type TimelineStore struct {
+	timelines map[string]*timeline
+	maxSize   int
+	mu        sync.RWMutex
+}
+
+// NewTimelineStore creates a timeline store keeping up to maxSize entries per user
+func NewTimelineStore(maxSize int) *TimelineStore {
+	if maxSize <= 0 {
+		maxSize = domain.MaxHomeTimelineSize
+	}
+
+	return &TimelineStore{
+		timelines: make(map[string]*timeline),
+		maxSize:   maxSize,
+	}
+}
+
+func (s *TimelineStore) Get(ctx context.Context, userID string, page domain.PageQuery) ([]domain.TimelineEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, exists := s.timelines[userID]
+	if !exists || !t.ready {
+		return nil, false, nil
+	}
+
+	window := page.ApplyToEntries(t.entries)
+	entries := make([]domain.TimelineEntry, len(window))
+	copy(entries, window)
+	return entries, true, nil
+}
+
+func (s *TimelineStore) StartRebuild(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Entries added from now on are kept and merged with the rebuilt ones
+	if _, exists := s.timelines[userID]; !exists {
+		s.timelines[userID] = &timeline{}
+	}
+	return nil
+}
+
+func (s *TimelineStore) CompleteRebuild(ctx context.Context, userID string, entries []domain.TimelineEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.timelines[userID]
+	if !exists {
+		t = &timeline{}
+		s.timelines[userID] = t
+	}
+
+	// The rebuilt entries may predate an unfollow handled meanwhile
+	if len(t.removed) > 0 {
+		kept := make([]domain.TimelineEntry, 0, len(entries))
+		for _, entry := range entries {
+			if !t.removed[entry.AuthorID] {
+				kept = append(kept, entry)
+			}
+		}
+		entries = kept
+	}
+
+	t.entries = s.merge(t.entries, entries)
+	t.ready = true
+	t.removed = nil
+	return nil
+}
+
+func (s *TimelineStore) Add(ctx context.Context, userID string, entries ...domain.TimelineEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.timelines[userID]
+	if !exists {
+		return nil
+	}
+
+	t.entries = s.merge(t.entries, entries)
+	return nil
+}
+
+func (s *TimelineStore) Remove(ctx context.Context, userID, tweetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.timelines[userID]
+	if !exists {
+		return nil
+	}
+
+	for i, entry := range t.entries {
+		if entry.TweetID == tweetID {
+			t.entries = append(t.entries[:i], t.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *TimelineStore) RemoveAuthor(ctx context.Context, userID, authorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, exists := s.timelines[userID]
+	if !exists {
+		return nil
+	}
+
+	kept := t.entries[:0]
+	for _, entry := range t.entries {
+		if entry.AuthorID != authorID {
+			kept = append(kept, entry)
+		}
+	}
+	t.entries = kept
+
+	if !t.ready {
+		if t.removed == nil {
+			t.removed = make(map[string]bool)
+		}
+		t.removed[authorID] = true
+	}
+	return nil
+}
+
+func (s *TimelineStore) Invalidate(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.timelines, userID)
+	return nil
+}
+
+// merge returns the union of both lists in timeline order, without
+// duplicates and bounded to maxSize (caller must hold the lock)
+func (s *TimelineStore) merge(current, added []domain.TimelineEntry) []domain.TimelineEntry {
+	// Fast path: a new tweet goes to the front of the timeline
+	if len(added) == 1 && (len(current) == 0 || added[0].Before(current[0])) {
+		merged := make([]domain.TimelineEntry, 0, len(current)+1)
+		merged = append(merged, added[0])
+		merged = append(merged, current...)
+		if len(merged) > s.maxSize {
+			merged = merged[:s.maxSize]
+		}
+		return merged
+	}
+
+	seen := make(map[string]bool, len(current))
+	merged := make([]domain.TimelineEntry, 0, len(current)+len(added))
+	for _, entry := range current {
+		seen[entry.TweetID] = true
+		merged = append(merged, entry)
+	}
+	for _, entry := range added {
+		if !seen[entry.TweetID] {
+			seen[entry.TweetID] = true
+			merged = append(merged, entry)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Before(merged[j])
+	})
+
+	if len(merged) > s.maxSize {
+		merged = merged[:s.maxSize]
+	}
+	return merged
+}
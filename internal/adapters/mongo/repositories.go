@@ -0,0 +1,1498 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+	"twitter-clone-backend/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Collection names
+const (
+	tweetsCollection        = "tweets"
+	followsCollection       = "follows"
+	blocksCollection        = "blocks"
+	likesCollection         = "likes"
+	notificationsCollection = "notifications"
+	usersCollection         = "users"
+	credentialsCollection   = "credentials"
+	apiKeysCollection       = "api_keys"
+	webhooksCollection      = "webhooks"
+	deliveriesCollection    = "webhook_deliveries"
+	outboxCollection        = "outbox"
+	revokedTokensCollection = "revoked_tokens"
+	revokedUsersCollection  = "revoked_users"
+)
+
+// maxNotifyAttempts bounds how many times Notify tries to update a group
+// that a concurrent Notify is inserting
+const maxNotifyAttempts = 20
+
+// errNotifyContention signals that Notify kept losing against concurrent
+// writes. The event is retried later by the bus
+var errNotifyContention = errors.New("notification group changed concurrently too many times")
+
+// revocationRetention is how long a revocation is kept after the token
+// expires, as verifiers tolerate some clock skew on expiration
+const revocationRetention = time.Minute
+
+// tweetDocument is the BSON representation of a tweet
+type tweetDocument struct {
+	ID                string           `bson:"_id"`
+	UserID            string           `bson:"user_id"`
+	Kind              string           `bson:"kind,omitempty"`
+	Content           string           `bson:"content"`
+	InReplyToTweetID  string           `bson:"in_reply_to_tweet_id,omitempty"`
+	ConversationID    string           `bson:"conversation_id,omitempty"`
+	ReferencedTweetID string           `bson:"referenced_tweet_id,omitempty"`
+	Entities          *domain.Entities `bson:"entities,omitempty"`
+	MentionedUserIDs  []string         `bson:"mentioned_user_ids,omitempty"`
+	CreatedAt         int64            `bson:"created_at"` // Unix nanoseconds
+}
+
+// followDocument is the BSON representation of a follow relationship
+type followDocument struct {
+	FollowerID string    `bson:"follower_id"`
+	FolloweeID string    `bson:"followee_id"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
+// blockDocument is the BSON representation of a block
+type blockDocument struct {
+	BlockerID string `bson:"blocker_id"`
+	BlockedID string `bson:"blocked_id"`
+	CreatedAt int64  `bson:"created_at"` // Unix nanoseconds
+}
+
+// likeDocument is the BSON representation of a like
+type likeDocument struct {
+	UserID    string `bson:"user_id"`
+	TweetID   string `bson:"tweet_id"`
+	CreatedAt int64  `bson:"created_at"` // Unix nanoseconds
+}
+
+// notificationDocument is the BSON representation of a notification
+type notificationDocument struct {
+	ID         string   `bson:"_id"`
+	UserID     string   `bson:"user_id"`
+	Type       string   `bson:"type"`
+	TweetID    string   `bson:"tweet_id"`
+	ActorIDs   []string `bson:"actor_ids"` // the latest ones
+	ActorCount int      `bson:"actor_count"`
+	Read       bool     `bson:"read"`
+	CreatedAt  int64    `bson:"created_at"` // Unix nanoseconds
+	UpdatedAt  int64    `bson:"updated_at"`
+}
+
+// userDocument is the BSON representation of a user
+type userDocument struct {
+	ID            string    `bson:"_id"`
+	Username      string    `bson:"username"`
+	UsernameLower string    `bson:"username_lower"`
+	DisplayName   string    `bson:"display_name"`
+	Bio           string    `bson:"bio"`
+	AvatarURL     string    `bson:"avatar_url"`
+	Location      string    `bson:"location"`
+	CreatedAt     time.Time `bson:"created_at"`
+}
+
+// credentialsDocument is the BSON representation of the credentials of a user
+type credentialsDocument struct {
+	UserID       string    `bson:"_id"`
+	PasswordHash string    `bson:"password_hash"`
+	UpdatedAt    time.Time `bson:"updated_at"`
+}
+
+// apiKeyDocument is the BSON representation of an API key
+type apiKeyDocument struct {
+	ID         string    `bson:"_id"`
+	UserID     string    `bson:"user_id"`
+	Name       string    `bson:"name"`
+	Scopes     []string  `bson:"scopes"`
+	KeyHash    string    `bson:"key_hash"`
+	CreatedAt  time.Time `bson:"created_at"`
+	LastUsedAt time.Time `bson:"last_used_at,omitempty"`
+}
+
+// webhookDocument is the BSON representation of a webhook
+type webhookDocument struct {
+	ID                  string    `bson:"_id"`
+	UserID              string    `bson:"user_id"`
+	URL                 string    `bson:"url"`
+	EventTypes          []string  `bson:"event_types"`
+	Secret              string    `bson:"secret"`
+	ConsecutiveFailures int       `bson:"consecutive_failures"`
+	DisabledAt          time.Time `bson:"disabled_at,omitempty"`
+	CreatedAt           time.Time `bson:"created_at"`
+}
+
+// deliveryDocument is the BSON representation of a webhook delivery
+type deliveryDocument struct {
+	ID         string    `bson:"_id"`
+	WebhookID  string    `bson:"webhook_id"`
+	EventID    string    `bson:"event_id"`
+	EventType  string    `bson:"event_type"`
+	Attempt    int       `bson:"attempt"`
+	StatusCode int       `bson:"status_code"`
+	Error      string    `bson:"error,omitempty"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
+// revokedTokenDocument is the BSON representation of a revoked token or
+// token family, removed by a TTL index once expired
+type revokedTokenDocument struct {
+	ID        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// outboxDocument is the BSON representation of an outbox entry
+type outboxDocument struct {
+	ID            string    `bson:"_id"`
+	Type          string    `bson:"type"`
+	Payload       string    `bson:"payload"`
+	CreatedAt     time.Time `bson:"created_at"`
+	Attempts      int       `bson:"attempts,omitempty"`
+	NextAttemptAt time.Time `bson:"next_attempt_at,omitempty"`
+}
+
+// Repositories implements the repositories on top of MongoDB
+type Repositories struct {
+	client        *mongo.Client
+	tweets        *mongo.Collection
+	follows       *mongo.Collection
+	blocks        *mongo.Collection
+	likes         *mongo.Collection
+	notifications *mongo.Collection
+	users         *mongo.Collection
+	credentials   *mongo.Collection
+	apiKeys       *mongo.Collection
+	webhooks      *mongo.Collection
+	deliveries    *mongo.Collection
+	outbox        *mongo.Collection
+	revokedTokens *mongo.Collection
+	revokedUsers  *mongo.Collection
+}
+
+// Open connects to MongoDB, creates the indexes and seeds the example users
+func Open(ctx context.Context, uri, database string) (*Repositories, error) {
+	clientOpts := options.Client().
+		ApplyURI(uri).
+		SetWriteConcern(writeconcern.Majority())
+
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	db := client.Database(database)
+	repo := &Repositories{
+		client:        client,
+		tweets:        db.Collection(tweetsCollection),
+		follows:       db.Collection(followsCollection),
+		blocks:        db.Collection(blocksCollection),
+		likes:         db.Collection(likesCollection),
+		notifications: db.Collection(notificationsCollection),
+		users:         db.Collection(usersCollection),
+		credentials:   db.Collection(credentialsCollection),
+		apiKeys:       db.Collection(apiKeysCollection),
+		webhooks:      db.Collection(webhooksCollection),
+		deliveries:    db.Collection(deliveriesCollection),
+		outbox:        db.Collection(outboxCollection),
+		revokedTokens: db.Collection(revokedTokensCollection),
+		revokedUsers:  db.Collection(revokedUsersCollection),
+	}
+
+	if err := repo.ensureIndexes(ctx); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	if err := repo.seedUsers(ctx); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// Close disconnects from the server
+func (r *Repositories) Close() error {
+	return r.client.Disconnect(context.Background())
+}
+
+// Drop removes every collection of the database (used by tests)
+func (r *Repositories) Drop(ctx context.Context) error {
+	return r.tweets.Database().Drop(ctx)
+}
+
+// ensureIndexes creates the indexes for each access pattern
+func (r *Repositories) ensureIndexes(ctx context.Context) error {
+	if _, err := r.tweets.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// User timeline and home timeline: {user_id: {$in: [...]}} sorted by date
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Global timeline
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		// Replies of a tweet, most recent first
+		{Keys: bson.D{{Key: "in_reply_to_tweet_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Tweets of a conversation, oldest first
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		// A user retweets a tweet once (also makes CreateRetweet atomic)
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "referenced_tweet_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"kind": domain.KindRetweet}),
+		},
+		// Mentions of a user (multikey), most recent first
+		{Keys: bson.D{{Key: "mentioned_user_ids", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Retweet and quote counts
+		{Keys: bson.D{{Key: "referenced_tweet_id", Value: 1}, {Key: "kind", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := r.follows.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Relationships (also makes FollowIfNotExists atomic)
+		{
+			Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Followers of a user
+		{Keys: bson.D{{Key: "followee_id", Value: 1}, {Key: "follower_id", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := r.blocks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		// A user blocks another once (also makes Block atomic)
+		Keys:    bson.D{{Key: "blocker_id", Value: 1}, {Key: "blocked_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := r.likes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// A user likes a tweet once (also makes LikeIfNotExists atomic)
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "tweet_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Likes of a user, most recent first
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "tweet_id", Value: -1}}},
+		// Like counts
+		{Keys: bson.D{{Key: "tweet_id", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := r.notifications.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// One unread notification per group (lets Notify detect concurrent groups)
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "tweet_id", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"read": false}),
+		},
+		// Notifications of a user, most recently updated first
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}},
+	}); err != nil {
+		return err
+	}
+
+	// Usernames are unique ignoring case (also makes CreateUser atomic)
+	if _, err := r.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "username_lower", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"username_lower": bson.M{"$exists": true}}),
+	}); err != nil {
+		return err
+	}
+
+	// API keys of a user, newest first
+	if _, err := r.apiKeys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// Webhooks of a user, newest first
+	if _, err := r.webhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// Delivery log of a webhook, newest first
+	if _, err := r.deliveries.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	// Revoked tokens are removed once expired
+	if _, err := r.revokedTokens.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(revocationRetention.Seconds())),
+	}); err != nil {
+		return err
+	}
+
+	// Pending outbox entries, oldest first (also creates the collection,
+	// which cannot be created inside a transaction before MongoDB 4.4)
+	_, err := r.outbox.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return err
+}
+
+// seedUsers adds the example users, same as the in-memory storage
+func (r *Repositories) seedUsers(ctx context.Context) error {
+	users := []*domain.User{
+		domain.NewUser("user1", "alice"),
+		domain.NewUser("user2", "bob"),
+		domain.NewUser("user3", "charlie"),
+	}
+
+	for _, user := range users {
+		_, err := r.users.UpdateOne(ctx,
+			bson.M{"_id": user.ID},
+			bson.M{
+				"$setOnInsert": bson.M{"username": user.Username, "created_at": user.CreatedAt},
+				"$set":         bson.M{"username_lower": domain.NormalizeUsername(user.Username)},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TweetRepository methods
+
+func (r *Repositories) Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		_, err := r.tweets.InsertOne(ctx, newTweetDocument(tweet))
+		return err
+	})
+}
+
+func (r *Repositories) GetByID(ctx context.Context, id string) (*domain.Tweet, error) {
+	var doc tweetDocument
+	err := r.tweets.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrTweetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (r *Repositories) GetByIDs(ctx context.Context, ids []string) ([]*domain.Tweet, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tweets, err := r.findTweets(ctx, bson.M{"_id": bson.M{"$in": ids}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return them in the requested order
+	byID := make(map[string]*domain.Tweet, len(tweets))
+	for _, tweet := range tweets {
+		byID[tweet.ID] = tweet
+	}
+	ordered := make([]*domain.Tweet, 0, len(tweets))
+	for _, id := range ids {
+		if tweet, exists := byID[id]; exists {
+			ordered = append(ordered, tweet)
+		}
+	}
+	return ordered, nil
+}
+
+func (r *Repositories) GetByUserID(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.findTweetPage(ctx, bson.M{"user_id": userID}, page)
+}
+
+func (r *Repositories) GetTimeline(ctx context.Context, userIDs []string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	return r.findTweetPage(ctx, bson.M{"user_id": bson.M{"$in": userIDs}}, page)
+}
+
+func (r *Repositories) GetMentions(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.findTweetPage(ctx, bson.M{"mentioned_user_ids": userID}, page)
+}
+
+func (r *Repositories) GetReplies(ctx context.Context, tweetID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.findTweetPage(ctx, bson.M{"in_reply_to_tweet_id": tweetID}, page)
+}
+
+func (r *Repositories) GetConversation(ctx context.Context, conversationID string, limit int) ([]*domain.Tweet, error) {
+	// Tweets stored before conversations existed have no conversation_id
+	filter := bson.M{"$or": bson.A{
+		bson.M{"conversation_id": conversationID},
+		bson.M{"_id": conversationID},
+	}}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	if limit > 0 {
+		findOpts.SetLimit(int64(limit))
+	}
+	return r.findTweets(ctx, filter, findOpts)
+}
+
+func (r *Repositories) CountReplies(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, "in_reply_to_tweet_id", "", tweetIDs)
+}
+
+func (r *Repositories) CreateRetweet(ctx context.Context, retweet *domain.Tweet, events ...domain.Event) error {
+	// The partial unique index makes verify + create atomic
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		_, err := r.tweets.InsertOne(ctx, newTweetDocument(retweet))
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrAlreadyRetweeted
+		}
+		return err
+	})
+}
+
+func (r *Repositories) GetRetweet(ctx context.Context, userID, tweetID string) (*domain.Tweet, error) {
+	var doc tweetDocument
+	err := r.tweets.FindOne(ctx, bson.M{
+		"user_id":             userID,
+		"referenced_tweet_id": tweetID,
+		"kind":                domain.KindRetweet,
+	}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrNotRetweeted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (r *Repositories) GetRetweets(ctx context.Context, tweetID string) ([]*domain.Tweet, error) {
+	return r.findTweets(ctx, bson.M{"referenced_tweet_id": tweetID, "kind": domain.KindRetweet}, options.Find())
+}
+
+func (r *Repositories) CountRetweets(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, "referenced_tweet_id", domain.KindRetweet, tweetIDs)
+}
+
+func (r *Repositories) CountQuotes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, "referenced_tweet_id", domain.KindQuote, tweetIDs)
+}
+
+// countReferences counts the tweets whose field references each of
+// tweetIDs, only of the given kind unless it is empty
+func (r *Repositories) countReferences(ctx context.Context, field, kind string, tweetIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(tweetIDs) == 0 {
+		return counts, nil
+	}
+
+	match := bson.M{field: bson.M{"$in": tweetIDs}}
+	if kind != "" {
+		match["kind"] = kind
+	}
+	cursor, err := r.tweets.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		counts[result.ID] = result.Count
+	}
+	return counts, nil
+}
+
+func (r *Repositories) Delete(ctx context.Context, id string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		result, err := r.tweets.DeleteOne(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return domain.ErrTweetNotFound
+		}
+		return nil
+	})
+}
+
+// FollowRepository methods
+
+func (r *Repositories) Follow(ctx context.Context, followerID, followeeID string) error {
+	err := r.FollowIfNotExists(ctx, followerID, followeeID)
+	if err == domain.ErrAlreadyFollowing {
+		return nil
+	}
+	return err
+}
+
+func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	// The unique index makes verify + create atomic
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		_, err := r.follows.InsertOne(ctx, followDocument{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+			CreatedAt:  time.Now(),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrAlreadyFollowing
+		}
+		return err
+	})
+}
+
+func (r *Repositories) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	_, err := r.follows.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+	return err
+}
+
+func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		result, err := r.follows.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return domain.ErrNotFollowing
+		}
+		return nil
+	})
+}
+
+func (r *Repositories) GetFollowers(ctx context.Context, userID string) ([]string, error) {
+	docs, err := r.findFollows(ctx, bson.M{"followee_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var followers []string
+	for _, doc := range docs {
+		followers = append(followers, doc.FollowerID)
+	}
+	return followers, nil
+}
+
+func (r *Repositories) CountFollowers(ctx context.Context, userID string) (int, error) {
+	count, err := r.follows.CountDocuments(ctx, bson.M{"followee_id": userID})
+	return int(count), err
+}
+
+func (r *Repositories) GetFollowing(ctx context.Context, userID string) ([]string, error) {
+	docs, err := r.findFollows(ctx, bson.M{"follower_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var following []string
+	for _, doc := range docs {
+		following = append(following, doc.FolloweeID)
+	}
+	return following, nil
+}
+
+func (r *Repositories) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	count, err := r.follows.CountDocuments(ctx,
+		bson.M{"follower_id": followerID, "followee_id": followeeID},
+		options.Count().SetLimit(1),
+	)
+	return count > 0, err
+}
+
+func (r *Repositories) Block(ctx context.Context, block *domain.Block) error {
+	// The unique index makes verify + create atomic
+	_, err := r.blocks.InsertOne(ctx, blockDocument{
+		BlockerID: block.BlockerID,
+		BlockedID: block.BlockedID,
+		CreatedAt: block.CreatedAt.UnixNano(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrAlreadyBlocked
+	}
+	return err
+}
+
+func (r *Repositories) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	result, err := r.blocks.DeleteOne(ctx, bson.M{"blocker_id": blockerID, "blocked_id": blockedID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrNotBlocked
+	}
+	return nil
+}
+
+func (r *Repositories) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	count, err := r.blocks.CountDocuments(ctx,
+		bson.M{"blocker_id": blockerID, "blocked_id": blockedID},
+		options.Count().SetLimit(1),
+	)
+	return count > 0, err
+}
+
+// LikeRepository methods
+
+func (r *Repositories) LikeIfNotExists(ctx context.Context, like *domain.Like, events ...domain.Event) error {
+	// The unique index makes verify + create atomic
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		_, err := r.likes.InsertOne(ctx, likeDocument{
+			UserID:    like.UserID,
+			TweetID:   like.TweetID,
+			CreatedAt: like.CreatedAt.UnixNano(),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrAlreadyLiked
+		}
+		return err
+	})
+}
+
+func (r *Repositories) UnlikeIfExists(ctx context.Context, userID, tweetID string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(ctx context.Context) error {
+		result, err := r.likes.DeleteOne(ctx, bson.M{"user_id": userID, "tweet_id": tweetID})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return domain.ErrNotLiked
+		}
+		return nil
+	})
+}
+
+func (r *Repositories) GetLikes(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Like, error) {
+	filter := bson.M{"user_id": userID}
+	cursor, err := r.likes.Find(ctx, filter, windowFind(filter, "created_at", "tweet_id", page))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []likeDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	likes := make([]*domain.Like, len(docs))
+	for i, doc := range docs {
+		likes[i] = &domain.Like{UserID: doc.UserID, TweetID: doc.TweetID, CreatedAt: time.Unix(0, doc.CreatedAt)}
+	}
+	if page.SinceID != nil {
+		for i, j := 0, len(likes)-1; i < j; i, j = i+1, j-1 {
+			likes[i], likes[j] = likes[j], likes[i]
+		}
+	}
+	return likes, nil
+}
+
+func (r *Repositories) CountLikes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(tweetIDs) == 0 {
+		return counts, nil
+	}
+
+	cursor, err := r.likes.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tweet_id": bson.M{"$in": tweetIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$tweet_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		ID    string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		counts[result.ID] = result.Count
+	}
+	return counts, nil
+}
+
+func (r *Repositories) GetLikedTweetIDs(ctx context.Context, userID string, tweetIDs []string) (map[string]bool, error) {
+	liked := make(map[string]bool)
+	if len(tweetIDs) == 0 {
+		return liked, nil
+	}
+
+	cursor, err := r.likes.Find(ctx, bson.M{"user_id": userID, "tweet_id": bson.M{"$in": tweetIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []likeDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		liked[doc.TweetID] = true
+	}
+	return liked, nil
+}
+
+// NotificationRepository methods
+
+func (r *Repositories) Notify(ctx context.Context, event *domain.Notification) error {
+	// The unread notification of the group is merged with the event in a
+	// single atomic update, and a new one is inserted only if there is none:
+	// when a concurrent Notify inserts it first, the update is tried again
+	group := bson.M{"user_id": event.UserID, "type": event.Type, "tweet_id": event.TweetID, "read": false}
+	for attempt := 0; attempt < maxNotifyAttempts; attempt++ {
+		result, err := r.notifications.UpdateOne(ctx, group, mergeNotification(event))
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+
+		_, err = r.notifications.InsertOne(ctx, newNotificationDocument(event))
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return errNotifyContention
+}
+
+// mergeNotification is the update pipeline that does what
+// domain.Notification.Merge does to a stored notification
+func mergeNotification(event *domain.Notification) mongo.Pipeline {
+	// Fields of a stage are computed from the document before the stage
+	kept := bson.M{"$filter": bson.M{
+		"input": "$actor_ids",
+		"cond":  bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", event.ActorIDs}}}},
+	}}
+	return mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"actor_ids": bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{event.ActorIDs, kept}},
+			domain.MaxNotificationActors,
+		}},
+		// Notifications stored before actor_count have all their actors
+		"actor_count": bson.M{"$add": bson.A{
+			bson.M{"$max": bson.A{"$actor_count", bson.M{"$size": "$actor_ids"}}},
+			bson.M{"$size": bson.M{"$setDifference": bson.A{event.ActorIDs, "$actor_ids"}}},
+		}},
+		"updated_at": bson.M{"$max": bson.A{"$updated_at", event.UpdatedAt.UnixNano()}},
+	}}}}
+}
+
+func (r *Repositories) GetNotifications(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Notification, error) {
+	filter := bson.M{"user_id": userID}
+	cursor, err := r.notifications.Find(ctx, filter, windowFind(filter, "updated_at", "_id", page))
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []notificationDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	notifications := make([]*domain.Notification, len(docs))
+	for i := range docs {
+		notifications[i] = docs[i].toDomain()
+	}
+	if page.SinceID != nil {
+		for i, j := 0, len(notifications)-1; i < j; i, j = i+1, j-1 {
+			notifications[i], notifications[j] = notifications[j], notifications[i]
+		}
+	}
+	return notifications, nil
+}
+
+func (r *Repositories) CountUnread(ctx context.Context, userID string) (int, error) {
+	count, err := r.notifications.CountDocuments(ctx, bson.M{"user_id": userID, "read": false})
+	return int(count), err
+}
+
+func (r *Repositories) MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) error {
+	filter := bson.M{"user_id": userID, "read": false}
+	if upTo != nil {
+		filter["$or"] = bson.A{
+			bson.M{"updated_at": bson.M{"$lt": upTo.CreatedAt.UnixNano()}},
+			bson.M{"updated_at": upTo.CreatedAt.UnixNano(), "_id": bson.M{"$lte": upTo.TweetID}},
+		}
+	}
+
+	_, err := r.notifications.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read": true}})
+	return err
+}
+
+// OutboxRepository methods
+
+func (r *Repositories) PendingEvents(ctx context.Context, limit int) ([]*domain.OutboxEntry, error) {
+	// Entries that never failed have no next_attempt_at
+	due := bson.M{"next_attempt_at": bson.M{"$not": bson.M{"$gt": time.Now()}}}
+	cursor, err := r.outbox.Find(ctx, due, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []outboxDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*domain.OutboxEntry, 0, len(docs))
+	for _, doc := range docs {
+		entries = append(entries, &domain.OutboxEntry{
+			ID:            doc.ID,
+			Type:          doc.Type,
+			Payload:       []byte(doc.Payload),
+			CreatedAt:     doc.CreatedAt,
+			Attempts:      doc.Attempts,
+			NextAttemptAt: doc.NextAttemptAt,
+		})
+	}
+	return entries, nil
+}
+
+func (r *Repositories) MarkRelayed(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := r.outbox.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+func (r *Repositories) ScheduleRetry(ctx context.Context, id string, at time.Time) error {
+	_, err := r.outbox.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"next_attempt_at": at}},
+	)
+	return err
+}
+
+// UserRepository methods
+
+func (r *Repositories) CreateUser(ctx context.Context, user *domain.User) error {
+	_, err := r.users.InsertOne(ctx, newUserDocument(user))
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrUsernameTaken
+	}
+	return err
+}
+
+func (r *Repositories) UpdateUser(ctx context.Context, user *domain.User) error {
+	result, err := r.users.UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"display_name": user.DisplayName,
+			"bio":          user.Bio,
+			"avatar_url":   user.AvatarURL,
+			"location":     user.Location,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *Repositories) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.findUser(ctx, bson.M{"_id": id})
+}
+
+func (r *Repositories) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	return r.findUser(ctx, bson.M{"username_lower": domain.NormalizeUsername(username)})
+}
+
+func (r *Repositories) Exists(ctx context.Context, id string) (bool, error) {
+	count, err := r.users.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// CredentialRepository methods
+
+func (r *Repositories) SetCredentials(ctx context.Context, credentials *domain.Credentials) error {
+	_, err := r.credentials.ReplaceOne(ctx,
+		bson.M{"_id": credentials.UserID},
+		credentialsDocument{
+			UserID:       credentials.UserID,
+			PasswordHash: credentials.PasswordHash,
+			UpdatedAt:    credentials.UpdatedAt,
+		},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *Repositories) GetCredentials(ctx context.Context, userID string) (*domain.Credentials, error) {
+	var doc credentialsDocument
+	err := r.credentials.FindOne(ctx, bson.M{"_id": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrNoCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Credentials{
+		UserID:       doc.UserID,
+		PasswordHash: doc.PasswordHash,
+		UpdatedAt:    doc.UpdatedAt,
+	}, nil
+}
+
+func (r *Repositories) DeleteCredentials(ctx context.Context, userID string) error {
+	_, err := r.credentials.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// APIKeyRepository methods
+
+func (r *Repositories) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.apiKeys.InsertOne(ctx, apiKeyDocument{
+		ID:         key.ID,
+		UserID:     key.UserID,
+		Name:       key.Name,
+		Scopes:     key.Scopes,
+		KeyHash:    key.KeyHash,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	})
+	return err
+}
+
+func (r *Repositories) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	var doc apiKeyDocument
+	err := r.apiKeys.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (r *Repositories) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	cursor, err := r.apiKeys.Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []apiKeyDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var keys []*domain.APIKey
+	for i := range docs {
+		keys = append(keys, docs[i].toDomain())
+	}
+	return keys, nil
+}
+
+func (r *Repositories) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	result, err := r.apiKeys.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *Repositories) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := r.apiKeys.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+// WebhookRepository methods
+
+func (r *Repositories) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	_, err := r.webhooks.InsertOne(ctx, webhookDocument{
+		ID:                  webhook.ID,
+		UserID:              webhook.UserID,
+		URL:                 webhook.URL,
+		EventTypes:          webhook.EventTypes,
+		Secret:              webhook.Secret,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		DisabledAt:          webhook.DisabledAt,
+		CreatedAt:           webhook.CreatedAt,
+	})
+	return err
+}
+
+func (r *Repositories) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	var doc webhookDocument
+	err := r.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toDomain(), nil
+}
+
+func (r *Repositories) ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error) {
+	cursor, err := r.webhooks.Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []webhookDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var webhooks []*domain.Webhook
+	for i := range docs {
+		webhooks = append(webhooks, docs[i].toDomain())
+	}
+	return webhooks, nil
+}
+
+func (r *Repositories) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	update := bson.M{
+		"$set": bson.M{"consecutive_failures": webhook.ConsecutiveFailures},
+	}
+	if webhook.IsEnabled() {
+		update["$unset"] = bson.M{"disabled_at": ""}
+	} else {
+		update["$set"].(bson.M)["disabled_at"] = webhook.DisabledAt
+	}
+
+	result, err := r.webhooks.UpdateOne(ctx, bson.M{"_id": webhook.ID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *Repositories) DeleteWebhook(ctx context.Context, userID, id string) error {
+	result, err := r.webhooks.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	_, err = r.deliveries.DeleteMany(ctx, bson.M{"webhook_id": id})
+	return err
+}
+
+func (r *Repositories) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	// Attempts of deleted webhooks are not logged
+	count, err := r.webhooks.CountDocuments(ctx, bson.M{"_id": delivery.WebhookID}, options.Count().SetLimit(1))
+	if err != nil || count == 0 {
+		return err
+	}
+
+	if _, err := r.deliveries.InsertOne(ctx, deliveryDocument{
+		ID:         delivery.ID,
+		WebhookID:  delivery.WebhookID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempt,
+		StatusCode: delivery.StatusCode,
+		Error:      delivery.Error,
+		CreatedAt:  delivery.CreatedAt,
+	}); err != nil {
+		return err
+	}
+
+	// Keep the last attempts only: find the oldest one kept and delete the
+	// ones before it
+	var oldest deliveryDocument
+	err = r.deliveries.FindOne(ctx,
+		bson.M{"webhook_id": delivery.WebhookID},
+		options.FindOne().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip(domain.MaxWebhookDeliveries-1),
+	).Decode(&oldest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.deliveries.DeleteMany(ctx, bson.M{
+		"webhook_id": delivery.WebhookID,
+		"$or": bson.A{
+			bson.M{"created_at": bson.M{"$lt": oldest.CreatedAt}},
+			bson.M{"created_at": oldest.CreatedAt, "_id": bson.M{"$lt": oldest.ID}},
+		},
+	})
+	return err
+}
+
+func (r *Repositories) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = domain.MaxWebhookDeliveries
+	}
+
+	cursor, err := r.deliveries.Find(ctx,
+		bson.M{"webhook_id": webhookID},
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []deliveryDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0, len(docs))
+	for _, doc := range docs {
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			ID:         doc.ID,
+			WebhookID:  doc.WebhookID,
+			EventID:    doc.EventID,
+			EventType:  doc.EventType,
+			Attempt:    doc.Attempt,
+			StatusCode: doc.StatusCode,
+			Error:      doc.Error,
+			CreatedAt:  doc.CreatedAt,
+		})
+	}
+	return deliveries, nil
+}
+
+// Helpers
+
+func (d *webhookDocument) toDomain() *domain.Webhook {
+	return &domain.Webhook{
+		ID:                  d.ID,
+		UserID:              d.UserID,
+		URL:                 d.URL,
+		EventTypes:          d.EventTypes,
+		Secret:              d.Secret,
+		ConsecutiveFailures: d.ConsecutiveFailures,
+		DisabledAt:          d.DisabledAt,
+		CreatedAt:           d.CreatedAt,
+	}
+}
+
+func (d *apiKeyDocument) toDomain() *domain.APIKey {
+	return &domain.APIKey{
+		ID:         d.ID,
+		UserID:     d.UserID,
+		Name:       d.Name,
+		Scopes:     d.Scopes,
+		KeyHash:    d.KeyHash,
+		CreatedAt:  d.CreatedAt,
+		LastUsedAt: d.LastUsedAt,
+	}
+}
+
+// RevocationList methods
+
+func (r *Repositories) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	_, err := r.Consume(ctx, tokenID, expiresAt)
+	return err
+}
+
+func (r *Repositories) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	// The _id makes check + revoke atomic
+	_, err := r.revokedTokens.InsertOne(ctx, revokedTokenDocument{ID: tokenID, ExpiresAt: expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *Repositories) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := r.revokedTokens.CountDocuments(ctx, bson.M{"_id": tokenID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *Repositories) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time) error {
+	// Only moves forward: a later revocation makes the upsert conflict
+	_, err := r.revokedUsers.UpdateOne(ctx,
+		bson.M{"_id": userID, "issued_before": bson.M{"$lt": issuedBefore.UnixNano()}},
+		bson.M{"$set": bson.M{"issued_before": issuedBefore.UnixNano()}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *Repositories) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	var doc struct {
+		IssuedBefore int64 `bson:"issued_before"` // Unix nanoseconds
+	}
+	err := r.revokedUsers.FindOne(ctx, bson.M{"_id": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, doc.IssuedBefore), nil
+}
+
+// withOutbox runs fn inside a transaction that also stores the outbox
+// entries of events, so they are committed only with the change. The
+// transaction needs a replica set, a standalone server is enough for
+// writes without events
+func (r *Repositories) withOutbox(ctx context.Context, events []domain.Event, fn func(ctx context.Context) error) error {
+	if len(events) == 0 {
+		return fn(ctx)
+	}
+
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+	docs := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		docs = append(docs, outboxDocument{
+			ID:        entry.ID,
+			Type:      entry.Type,
+			Payload:   string(entry.Payload),
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+
+	session, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if err := fn(sc); err != nil {
+			return nil, err
+		}
+		_, err := r.outbox.InsertMany(sc, docs)
+		return nil, err
+	})
+	return err
+}
+
+func newTweetDocument(tweet *domain.Tweet) tweetDocument {
+	return tweetDocument{
+		ID:                tweet.ID,
+		UserID:            tweet.UserID,
+		Kind:              tweet.TweetKind(),
+		Content:           tweet.Content,
+		InReplyToTweetID:  tweet.InReplyToTweetID,
+		ConversationID:    tweet.Conversation(),
+		ReferencedTweetID: tweet.ReferencedTweetID,
+		Entities:          tweet.Entities,
+		MentionedUserIDs:  tweet.Entities.MentionedUserIDs(),
+		CreatedAt:         tweet.CreatedAt.UnixNano(),
+	}
+}
+
+func newNotificationDocument(notification *domain.Notification) notificationDocument {
+	return notificationDocument{
+		ID:         notification.ID,
+		UserID:     notification.UserID,
+		Type:       notification.Type,
+		TweetID:    notification.TweetID,
+		ActorIDs:   notification.ActorIDs,
+		ActorCount: notification.Actors(),
+		Read:       notification.Read,
+		CreatedAt:  notification.CreatedAt.UnixNano(),
+		UpdatedAt:  notification.UpdatedAt.UnixNano(),
+	}
+}
+
+func (d *notificationDocument) toDomain() *domain.Notification {
+	return &domain.Notification{
+		ID:         d.ID,
+		UserID:     d.UserID,
+		Type:       d.Type,
+		TweetID:    d.TweetID,
+		ActorIDs:   d.ActorIDs,
+		ActorCount: d.ActorCount,
+		Read:       d.Read,
+		CreatedAt:  time.Unix(0, d.CreatedAt),
+		UpdatedAt:  time.Unix(0, d.UpdatedAt),
+	}
+}
+
+func (d *tweetDocument) toDomain() *domain.Tweet {
+	return &domain.Tweet{
+		ID:                d.ID,
+		UserID:            d.UserID,
+		Kind:              d.Kind,
+		Content:           d.Content,
+		InReplyToTweetID:  d.InReplyToTweetID,
+		ConversationID:    d.ConversationID,
+		ReferencedTweetID: d.ReferencedTweetID,
+		Entities:          d.Entities,
+		CreatedAt:         time.Unix(0, d.CreatedAt),
+	}
+}
+
+// findTweetPage returns the matching tweets windowed by a page query.
+// Cursors compare (created_at, _id) so tweets sharing a timestamp are
+// neither repeated nor skipped between pages
+func (r *Repositories) findTweetPage(ctx context.Context, filter bson.M, page domain.PageQuery) ([]*domain.Tweet, error) {
+	tweets, err := r.findTweets(ctx, filter, windowFind(filter, "created_at", "_id", page))
+	if err != nil {
+		return nil, err
+	}
+
+	if page.SinceID != nil {
+		for i, j := 0, len(tweets)-1; i < j; i, j = i+1, j-1 {
+			tweets[i], tweets[j] = tweets[j], tweets[i]
+		}
+	}
+	return tweets, nil
+}
+
+// windowFind adds the bounds of a page query to a filter over documents
+// positioned by (timeField, idField) and returns the sort and limit.
+// timeField holds Unix nanoseconds: BSON dates only keep milliseconds, so
+// a cursor would not match the position it was taken from. When
+// polling, the documents closest to the cursor are wanted: read oldest first
+func windowFind(filter bson.M, timeField, idField string, page domain.PageQuery) *options.FindOptions {
+	var bounds bson.A
+	if page.MaxID != nil {
+		bounds = append(bounds, bson.M{"$or": bson.A{
+			bson.M{timeField: bson.M{"$lt": page.MaxID.CreatedAt.UnixNano()}},
+			bson.M{timeField: page.MaxID.CreatedAt.UnixNano(), idField: bson.M{"$lt": page.MaxID.TweetID}},
+		}})
+	}
+	if page.SinceID != nil {
+		bounds = append(bounds, bson.M{"$or": bson.A{
+			bson.M{timeField: bson.M{"$gt": page.SinceID.CreatedAt.UnixNano()}},
+			bson.M{timeField: page.SinceID.CreatedAt.UnixNano(), idField: bson.M{"$gt": page.SinceID.TweetID}},
+		}})
+	}
+	if len(bounds) > 0 {
+		filter["$and"] = bounds
+	}
+
+	direction := -1
+	if page.SinceID != nil {
+		direction = 1
+	}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: timeField, Value: direction}, {Key: idField, Value: direction}})
+	if page.Limit > 0 {
+		findOpts.SetLimit(int64(page.Limit))
+	}
+	return findOpts
+}
+
+// findTweets returns the matching tweets
+func (r *Repositories) findTweets(ctx context.Context, filter bson.M, findOpts *options.FindOptions) ([]*domain.Tweet, error) {
+	cursor, err := r.tweets.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []tweetDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var tweets []*domain.Tweet
+	for i := range docs {
+		tweets = append(tweets, docs[i].toDomain())
+	}
+	return tweets, nil
+}
+
+func newUserDocument(user *domain.User) userDocument {
+	return userDocument{
+		ID:            user.ID,
+		Username:      user.Username,
+		UsernameLower: domain.NormalizeUsername(user.Username),
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarURL,
+		Location:      user.Location,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+func (r *Repositories) findFollows(ctx context.Context, filter bson.M) ([]followDocument, error) {
+	cursor, err := r.follows.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []followDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (r *Repositories) findUser(ctx context.Context, filter bson.M) (*domain.User, error) {
+	var doc userDocument
+	err := r.users.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:          doc.ID,
+		Username:    doc.Username,
+		DisplayName: doc.DisplayName,
+		Bio:         doc.Bio,
+		AvatarURL:   doc.AvatarURL,
+		Location:    doc.Location,
+		CreatedAt:   doc.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is a versioned schema change, loaded from migrations/NNNN_name.sql
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrationLockKey identifies the PostgreSQL advisory lock held while
+// migrating
+const migrationLockKey int64 = 0x7477_6565_7473 // "tweets"
+
+// sqliteBusyTimeout is how long a SQLite migration pass waits for another
+// one to finish
+const sqliteBusyTimeout = 30 * time.Second
+
+// execQuerier runs statements on a connection or a transaction
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Migrate applies all pending migrations in order, recording them in the
+// schema_migrations table. Passes of processes starting at the same time
+// run one after the other: on PostgreSQL under an advisory lock, with each
+// migration in its own transaction, and on SQLite in a single BEGIN
+// IMMEDIATE transaction, which holds the database write lock
+func Migrate(ctx context.Context, db *sql.DB, driverName string) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// Locks belong to a connection (session), so the pass uses only one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if isSQLite(driverName) {
+		return migrateSQLite(ctx, conn, migrations)
+	}
+	return migratePostgres(ctx, conn, migrations)
+}
+
+// isSQLite reports whether a driver is a SQLite one
+func isSQLite(driverName string) bool {
+	return strings.HasPrefix(driverName, "sqlite")
+}
+
+// migratePostgres applies the pending migrations holding an advisory lock
+func migratePostgres(ctx context.Context, conn *sql.Conn, migrations []migration) error {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	// The lock is also released when the session ends
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	pending, err := pendingMigrations(ctx, conn, migrations)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, tx, m); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSQLite applies the pending migrations in a single transaction
+// that takes the write lock before reading which ones are applied
+func migrateSQLite(ctx context.Context, conn *sql.Conn, migrations []migration) (err error) {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA busy_timeout = %d`, sqliteBusyTimeout.Milliseconds())); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			conn.ExecContext(context.WithoutCancel(ctx), `ROLLBACK`)
+		}
+	}()
+
+	pending, err := pendingMigrations(ctx, conn, migrations)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, `COMMIT`)
+	return err
+}
+
+// pendingMigrations creates the schema_migrations table if needed and
+// returns the migrations it does not record
+func pendingMigrations(ctx context.Context, q execQuerier, migrations []migration) ([]migration, error) {
+	if _, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := q.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// applyMigration runs the statements of a migration and records it. The
+// caller provides the transaction
+func applyMigration(ctx context.Context, q execQuerier, m migration) error {
+	for _, statement := range m.statements {
+		if _, err := q.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		m.version, m.name, time.Now().UnixNano(),
+	)
+	return err
+}
+
+// loadMigrations reads the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		versionStr, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(versionStr)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{
+			version:    version,
+			name:       name,
+			statements: splitStatements(string(content)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// splitStatements splits a migration into statements, so it runs on drivers
+// that only accept one statement per Exec
+func splitStatements(content string) []string {
+	var statements []string
+	for _, statement := range strings.Split(content, ";") {
+		if hasSQL(statement) {
+			statements = append(statements, strings.TrimSpace(statement))
+		}
+	}
+	return statements
+}
+
+// hasSQL reports whether a chunk contains something other than comments
+func hasSQL(chunk string) bool {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    username   TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE tweets (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    content    TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+-- Timeline and user tweets: WHERE user_id IN (...) ORDER BY created_at DESC
+CREATE INDEX idx_tweets_user_created ON tweets (user_id, created_at DESC, id DESC);
+
+CREATE TABLE follows (
+    follower_id TEXT NOT NULL,
+    followee_id TEXT NOT NULL,
+    created_at  BIGINT NOT NULL,
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+-- GetFollowers: WHERE followee_id = ?
+CREATE INDEX idx_follows_followee ON follows (followee_id, follower_id);
@@ -0,0 +1,4 @@
+-- Example users, same as the in-memory storage
+INSERT INTO users (id, username, created_at) VALUES ('user1', 'alice', 0) ON CONFLICT (id) DO NOTHING;
+INSERT INTO users (id, username, created_at) VALUES ('user2', 'bob', 0) ON CONFLICT (id) DO NOTHING;
+INSERT INTO users (id, username, created_at) VALUES ('user3', 'charlie', 0) ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+-- Usernames are unique ignoring case (also serves GetUserByUsername)
+CREATE UNIQUE INDEX idx_users_username_lower ON users (lower(username));
@@ -0,0 +1,6 @@
+-- Password hashes live apart from the users table so user queries never load them
+CREATE TABLE credentials (
+    user_id       TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    updated_at    BIGINT NOT NULL
+);
@@ -0,0 +1,12 @@
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    scopes       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL,
+    created_at   BIGINT NOT NULL,
+    last_used_at BIGINT NOT NULL DEFAULT 0
+);
+
+-- ListAPIKeys: WHERE user_id = ? ORDER BY created_at DESC
+CREATE INDEX idx_api_keys_user_created ON api_keys (user_id, created_at DESC);
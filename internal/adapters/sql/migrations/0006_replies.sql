@@ -0,0 +1,11 @@
+ALTER TABLE tweets ADD COLUMN in_reply_to_tweet_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tweets ADD COLUMN conversation_id TEXT NOT NULL DEFAULT '';
+
+-- Existing tweets start their own conversation
+UPDATE tweets SET conversation_id = id WHERE conversation_id = '';
+
+-- GetReplies and CountReplies: WHERE in_reply_to_tweet_id = ?
+CREATE INDEX idx_tweets_in_reply_to ON tweets (in_reply_to_tweet_id, created_at DESC, id DESC);
+
+-- GetConversation: WHERE conversation_id = ? ORDER BY created_at
+CREATE INDEX idx_tweets_conversation ON tweets (conversation_id, created_at, id);
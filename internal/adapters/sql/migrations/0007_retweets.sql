@@ -0,0 +1,8 @@
+ALTER TABLE tweets ADD COLUMN kind TEXT NOT NULL DEFAULT 'tweet';
+ALTER TABLE tweets ADD COLUMN referenced_tweet_id TEXT NOT NULL DEFAULT '';
+
+-- A user retweets a tweet once (also makes CreateRetweet atomic)
+CREATE UNIQUE INDEX idx_tweets_retweet ON tweets (user_id, referenced_tweet_id) WHERE kind = 'retweet';
+
+-- CountRetweets and CountQuotes: WHERE referenced_tweet_id IN (...) AND kind = ?
+CREATE INDEX idx_tweets_referenced ON tweets (referenced_tweet_id, kind);
@@ -0,0 +1,12 @@
+CREATE TABLE likes (
+    user_id    TEXT NOT NULL,
+    tweet_id   TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, tweet_id)
+);
+
+-- GetLikes: WHERE user_id = ? ORDER BY created_at DESC, tweet_id DESC
+CREATE INDEX idx_likes_user_created ON likes (user_id, created_at DESC, tweet_id DESC);
+
+-- CountLikes: WHERE tweet_id IN (...) GROUP BY tweet_id
+CREATE INDEX idx_likes_tweet ON likes (tweet_id);
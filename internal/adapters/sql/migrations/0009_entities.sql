@@ -0,0 +1,2 @@
+-- Hashtags, cashtags, mentions and URLs of the content, as JSON (empty if none)
+ALTER TABLE tweets ADD COLUMN entities TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Users mentioned by each tweet, written with the tweet
+CREATE TABLE tweet_mentions (
+    user_id  TEXT NOT NULL,
+    tweet_id TEXT NOT NULL,
+    PRIMARY KEY (user_id, tweet_id)
+);
+
+-- Delete: WHERE tweet_id = ?
+CREATE INDEX idx_tweet_mentions_tweet ON tweet_mentions (tweet_id);
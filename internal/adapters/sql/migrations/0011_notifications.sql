@@ -0,0 +1,16 @@
+CREATE TABLE notifications (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    tweet_id   TEXT NOT NULL DEFAULT '',
+    actor_ids  TEXT NOT NULL,
+    read_at    BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+-- One unread notification per group (lets Notify detect concurrent groups)
+CREATE UNIQUE INDEX idx_notifications_group ON notifications (user_id, type, tweet_id) WHERE read_at = 0;
+
+-- GetNotifications: WHERE user_id = ? ORDER BY updated_at DESC, id DESC
+CREATE INDEX idx_notifications_user_updated ON notifications (user_id, updated_at DESC, id DESC);
@@ -0,0 +1,11 @@
+-- Domain events written in the transaction of the change they describe,
+-- until they are relayed to the subscribers
+CREATE TABLE outbox (
+    id         TEXT PRIMARY KEY,
+    type       TEXT NOT NULL,
+    payload    TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+-- PendingEvents: ORDER BY created_at, id
+CREATE INDEX idx_outbox_created ON outbox (created_at, id);
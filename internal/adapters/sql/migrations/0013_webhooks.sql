@@ -0,0 +1,27 @@
+CREATE TABLE webhooks (
+    id                   TEXT PRIMARY KEY,
+    user_id              TEXT NOT NULL,
+    url                  TEXT NOT NULL,
+    event_types          TEXT NOT NULL,
+    secret               TEXT NOT NULL,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_at          BIGINT NOT NULL DEFAULT 0,
+    created_at           BIGINT NOT NULL
+);
+
+-- ListWebhooks: WHERE user_id = ? ORDER BY created_at DESC
+CREATE INDEX idx_webhooks_user_created ON webhooks (user_id, created_at DESC);
+
+CREATE TABLE webhook_deliveries (
+    id          TEXT PRIMARY KEY,
+    webhook_id  TEXT NOT NULL,
+    event_id    TEXT NOT NULL,
+    event_type  TEXT NOT NULL,
+    attempt     INTEGER NOT NULL,
+    status_code INTEGER NOT NULL,
+    error       TEXT NOT NULL DEFAULT '',
+    created_at  BIGINT NOT NULL
+);
+
+-- ListWebhookDeliveries: WHERE webhook_id = ? ORDER BY created_at DESC, id DESC
+CREATE INDEX idx_webhook_deliveries_webhook_created ON webhook_deliveries (webhook_id, created_at DESC, id DESC);
@@ -0,0 +1,14 @@
+-- Tokens and token families revoked before they expire
+CREATE TABLE revoked_tokens (
+    id         TEXT PRIMARY KEY,
+    expires_at BIGINT NOT NULL
+);
+
+-- Purge of expired revocations: WHERE expires_at < ?
+CREATE INDEX idx_revoked_tokens_expires ON revoked_tokens (expires_at);
+
+-- Users whose tokens issued before a time are revoked
+CREATE TABLE revoked_users (
+    user_id       TEXT PRIMARY KEY,
+    issued_before BIGINT NOT NULL
+);
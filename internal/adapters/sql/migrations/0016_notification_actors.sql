@@ -0,0 +1,7 @@
+-- Notifications keep only their latest actors, and count all of them
+ALTER TABLE notifications ADD COLUMN actor_count INTEGER NOT NULL DEFAULT 0;
+UPDATE notifications SET actor_count = LENGTH(actor_ids) - LENGTH(REPLACE(actor_ids, ' ', '')) + 1;
+
+-- Incremented on every update, so Notify can update a group only if it
+-- did not change since it was read
+ALTER TABLE notifications ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
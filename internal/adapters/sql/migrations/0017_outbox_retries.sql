@@ -0,0 +1,3 @@
+-- Entries that some subscriber failed to handle are relayed again later
+ALTER TABLE outbox ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE outbox ADD COLUMN next_attempt_at BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,1271 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// Repositories implements the repositories on top of database/sql.
+// Queries use $N placeholders and portable types (timestamps are stored as
+// Unix nanoseconds) so they run both on PostgreSQL and SQLite
+type Repositories struct {
+	db        *sql.DB
+	lastPurge atomic.Int64 // Unix nanoseconds of the last purge of expired revocations
+}
+
+// NewRepositories creates the SQL repositories. Migrate must have been run
+func NewRepositories(db *sql.DB) *Repositories {
+	return &Repositories{db: db}
+}
+
+// Open connects to the database and applies pending migrations
+func Open(ctx context.Context, driverName, dsn string) (*Repositories, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := Migrate(ctx, db, driverName); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return NewRepositories(db), nil
+}
+
+// Close closes the database connection pool
+func (r *Repositories) Close() error {
+	return r.db.Close()
+}
+
+// TweetRepository methods
+
+// tweetColumns are the columns read by scanTweet
+const tweetColumns = `id, user_id, kind, content, in_reply_to_tweet_id, conversation_id, referenced_tweet_id, entities, created_at`
+
+func (r *Repositories) Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error {
+	// The mention index is written with the tweet
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		if _, err := r.insertTweet(ctx, tx, tweet, ``); err != nil {
+			return err
+		}
+		for _, userID := range tweet.Entities.MentionedUserIDs() {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO tweet_mentions (user_id, tweet_id) VALUES ($1, $2)`,
+				userID, tweet.ID,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repositories) GetByID(ctx context.Context, id string) (*domain.Tweet, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+tweetColumns+` FROM tweets WHERE id = $1`, id,
+	)
+
+	tweet, err := scanTweet(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrTweetNotFound
+	}
+	return tweet, err
+}
+
+func (r *Repositories) GetByIDs(ctx context.Context, ids []string) ([]*domain.Tweet, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	tweets, err := r.queryTweets(ctx,
+		`SELECT `+tweetColumns+` FROM tweets WHERE id IN (`+placeholders(1, len(ids))+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return orderByIDs(tweets, ids), nil
+}
+
+func (r *Repositories) GetByUserID(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.queryTweetPage(ctx, `user_id = $1`, []interface{}{userID}, page)
+}
+
+func (r *Repositories) GetTimeline(ctx context.Context, userIDs []string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(userIDs)+5)
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+
+	return r.queryTweetPage(ctx, `user_id IN (`+placeholders(1, len(userIDs))+`)`, args, page)
+}
+
+func (r *Repositories) GetMentions(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.queryTweetPage(ctx, `id IN (SELECT tweet_id FROM tweet_mentions WHERE user_id = $1)`, []interface{}{userID}, page)
+}
+
+func (r *Repositories) GetReplies(ctx context.Context, tweetID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return r.queryTweetPage(ctx, `in_reply_to_tweet_id = $1`, []interface{}{tweetID}, page)
+}
+
+func (r *Repositories) GetConversation(ctx context.Context, conversationID string, limit int) ([]*domain.Tweet, error) {
+	query := `SELECT ` + tweetColumns + ` FROM tweets WHERE conversation_id = $1 ORDER BY created_at ASC, id ASC`
+	args := []interface{}{conversationID}
+	if limit > 0 {
+		query += ` LIMIT $2`
+		args = append(args, limit)
+	}
+	return r.queryTweets(ctx, query, args...)
+}
+
+func (r *Repositories) CountReplies(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, `in_reply_to_tweet_id`, ``, tweetIDs)
+}
+
+func (r *Repositories) CreateRetweet(ctx context.Context, retweet *domain.Tweet, events ...domain.Event) error {
+	// The partial unique index on (user_id, referenced_tweet_id) makes
+	// verify + create a single atomic statement
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := r.insertTweet(ctx, tx, retweet, ` ON CONFLICT DO NOTHING`)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, domain.ErrAlreadyRetweeted)
+	})
+}
+
+func (r *Repositories) GetRetweet(ctx context.Context, userID, tweetID string) (*domain.Tweet, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+tweetColumns+` FROM tweets WHERE user_id = $1 AND referenced_tweet_id = $2 AND kind = $3`,
+		userID, tweetID, domain.KindRetweet,
+	)
+
+	retweet, err := scanTweet(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotRetweeted
+	}
+	return retweet, err
+}
+
+func (r *Repositories) GetRetweets(ctx context.Context, tweetID string) ([]*domain.Tweet, error) {
+	return r.queryTweets(ctx,
+		`SELECT `+tweetColumns+` FROM tweets WHERE referenced_tweet_id = $1 AND kind = $2`,
+		tweetID, domain.KindRetweet,
+	)
+}
+
+func (r *Repositories) CountRetweets(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, `referenced_tweet_id`, domain.KindRetweet, tweetIDs)
+}
+
+func (r *Repositories) CountQuotes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	return r.countReferences(ctx, `referenced_tweet_id`, domain.KindQuote, tweetIDs)
+}
+
+func (r *Repositories) Delete(ctx context.Context, id string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM tweets WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, domain.ErrTweetNotFound); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM tweet_mentions WHERE tweet_id = $1`, id)
+		return err
+	})
+}
+
+// FollowRepository methods
+
+func (r *Repositories) Follow(ctx context.Context, followerID, followeeID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO follows (follower_id, followee_id, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING`,
+		followerID, followeeID, time.Now().UnixNano(),
+	)
+	return err
+}
+
+func (r *Repositories) FollowIfNotExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	// The primary key makes verify + create a single atomic statement
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO follows (follower_id, followee_id, created_at) VALUES ($1, $2, $3)
+			ON CONFLICT (follower_id, followee_id) DO NOTHING`,
+			followerID, followeeID, time.Now().UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, domain.ErrAlreadyFollowing)
+	})
+}
+
+func (r *Repositories) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+		followerID, followeeID,
+	)
+	return err
+}
+
+func (r *Repositories) UnfollowIfExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+			followerID, followeeID,
+		)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, domain.ErrNotFollowing)
+	})
+}
+
+func (r *Repositories) GetFollowers(ctx context.Context, userID string) ([]string, error) {
+	return r.queryIDs(ctx, `SELECT follower_id FROM follows WHERE followee_id = $1`, userID)
+}
+
+func (r *Repositories) CountFollowers(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM follows WHERE followee_id = $1`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *Repositories) GetFollowing(ctx context.Context, userID string) ([]string, error) {
+	return r.queryIDs(ctx, `SELECT followee_id FROM follows WHERE follower_id = $1`, userID)
+}
+
+func (r *Repositories) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+		followerID, followeeID,
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *Repositories) Block(ctx context.Context, block *domain.Block) error {
+	// The primary key makes verify + create a single atomic statement
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO blocks (blocker_id, blocked_id, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING`,
+		block.BlockerID, block.BlockedID, block.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrAlreadyBlocked)
+}
+
+func (r *Repositories) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`,
+		blockerID, blockedID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrNotBlocked)
+}
+
+func (r *Repositories) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`,
+		blockerID, blockedID,
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LikeRepository methods
+
+func (r *Repositories) LikeIfNotExists(ctx context.Context, like *domain.Like, events ...domain.Event) error {
+	// The primary key makes verify + create a single atomic statement
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO likes (user_id, tweet_id, created_at) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, tweet_id) DO NOTHING`,
+			like.UserID, like.TweetID, like.CreatedAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, domain.ErrAlreadyLiked)
+	})
+}
+
+func (r *Repositories) UnlikeIfExists(ctx context.Context, userID, tweetID string, events ...domain.Event) error {
+	return r.withOutbox(ctx, events, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM likes WHERE user_id = $1 AND tweet_id = $2`,
+			userID, tweetID,
+		)
+		if err != nil {
+			return err
+		}
+		return requireAffected(result, domain.ErrNotLiked)
+	})
+}
+
+func (r *Repositories) GetLikes(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Like, error) {
+	query, args := windowQuery(
+		`SELECT user_id, tweet_id, created_at FROM likes WHERE user_id = $1`,
+		`created_at`, `tweet_id`, []interface{}{userID}, page,
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var likes []*domain.Like
+	for rows.Next() {
+		var like domain.Like
+		var createdAt int64
+		if err := rows.Scan(&like.UserID, &like.TweetID, &createdAt); err != nil {
+			return nil, err
+		}
+		like.CreatedAt = time.Unix(0, createdAt)
+		likes = append(likes, &like)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if page.SinceID != nil {
+		reverseLikes(likes)
+	}
+	return likes, nil
+}
+
+func (r *Repositories) CountLikes(ctx context.Context, tweetIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(tweetIDs) == 0 {
+		return counts, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT tweet_id, COUNT(*) FROM likes WHERE tweet_id IN (`+placeholders(1, len(tweetIDs))+`) GROUP BY tweet_id`,
+		stringArgs(tweetIDs)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *Repositories) GetLikedTweetIDs(ctx context.Context, userID string, tweetIDs []string) (map[string]bool, error) {
+	liked := make(map[string]bool)
+	if len(tweetIDs) == 0 {
+		return liked, nil
+	}
+
+	ids, err := r.queryIDs(ctx,
+		`SELECT tweet_id FROM likes WHERE user_id = $1 AND tweet_id IN (`+placeholders(2, len(tweetIDs))+`)`,
+		append([]interface{}{userID}, stringArgs(tweetIDs)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked, nil
+}
+
+// NotificationRepository methods
+
+func (r *Repositories) Notify(ctx context.Context, event *domain.Notification) error {
+	// The unread notification of the group is updated only if its version
+	// did not change since it was read, and a new one is inserted only if
+	// there is none: when a concurrent Notify wins, the group is read again
+	for attempt := 0; attempt < maxNotifyAttempts; attempt++ {
+		var version int64
+		row := r.db.QueryRowContext(ctx,
+			`SELECT `+notificationColumns+`, version FROM notifications WHERE user_id = $1 AND type = $2 AND tweet_id = $3 AND read_at = 0`,
+			event.UserID, event.Type, event.TweetID,
+		)
+		existing, err := scanNotification(versionedRow{row, &version})
+		if errors.Is(err, sql.ErrNoRows) {
+			result, err := r.db.ExecContext(ctx,
+				`INSERT INTO notifications (`+notificationColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING`,
+				event.ID, event.UserID, event.Type, event.TweetID, strings.Join(event.ActorIDs, " "), event.Actors(), 0,
+				event.CreatedAt.UnixNano(), event.UpdatedAt.UnixNano(),
+			)
+			if err != nil {
+				return err
+			}
+			if err := requireAffected(result, errConflict); err != errConflict {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		merged := existing.Merge(event)
+		result, err := r.db.ExecContext(ctx,
+			`UPDATE notifications SET actor_ids = $1, actor_count = $2, updated_at = $3, version = version + 1
+			 WHERE id = $4 AND version = $5 AND read_at = 0`,
+			strings.Join(merged.ActorIDs, " "), merged.ActorCount, merged.UpdatedAt.UnixNano(),
+			existing.ID, version,
+		)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, errConflict); err != errConflict {
+			return err
+		}
+	}
+	return errNotifyContention
+}
+
+func (r *Repositories) GetNotifications(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Notification, error) {
+	query, args := windowQuery(
+		`SELECT `+notificationColumns+` FROM notifications WHERE user_id = $1`,
+		`updated_at`, `id`, []interface{}{userID}, page,
+	)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if page.SinceID != nil {
+		reverseNotifications(notifications)
+	}
+	return notifications, nil
+}
+
+func (r *Repositories) CountUnread(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at = 0`, userID,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *Repositories) MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) error {
+	query := `UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at = 0`
+	args := []interface{}{time.Now().UnixNano(), userID}
+	if upTo != nil {
+		query += ` AND (updated_at < $3 OR (updated_at = $3 AND id <= $4))`
+		args = append(args, upTo.CreatedAt.UnixNano(), upTo.TweetID)
+	}
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// OutboxRepository methods
+
+func (r *Repositories) PendingEvents(ctx context.Context, limit int) ([]*domain.OutboxEntry, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, type, payload, created_at, attempts, next_attempt_at FROM outbox
+		WHERE next_attempt_at <= $1 ORDER BY created_at, id LIMIT $2`,
+		time.Now().UnixNano(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.OutboxEntry
+	for rows.Next() {
+		var entry domain.OutboxEntry
+		var payload string
+		var createdAt, nextAttemptAt int64
+		if err := rows.Scan(&entry.ID, &entry.Type, &payload, &createdAt, &entry.Attempts, &nextAttemptAt); err != nil {
+			return nil, err
+		}
+		entry.Payload = []byte(payload)
+		entry.CreatedAt = time.Unix(0, createdAt)
+		if nextAttemptAt != 0 {
+			entry.NextAttemptAt = time.Unix(0, nextAttemptAt)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *Repositories) MarkRelayed(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE id IN (`+placeholders(1, len(ids))+`)`,
+		stringArgs(ids)...,
+	)
+	return err
+}
+
+func (r *Repositories) ScheduleRetry(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1 WHERE id = $2`,
+		at.UnixNano(), id,
+	)
+	return err
+}
+
+// UserRepository methods
+
+func (r *Repositories) CreateUser(ctx context.Context, user *domain.User) error {
+	// The unique index on lower(username) makes verify + create atomic
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (id, username, display_name, bio, avatar_url, location, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT DO NOTHING`,
+		user.ID, user.Username, user.DisplayName, user.Bio, user.AvatarURL, user.Location, user.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrUsernameTaken)
+}
+
+func (r *Repositories) UpdateUser(ctx context.Context, user *domain.User) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET display_name = $2, bio = $3, avatar_url = $4, location = $5 WHERE id = $1`,
+		user.ID, user.DisplayName, user.Bio, user.AvatarURL, user.Location,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrUserNotFound)
+}
+
+func (r *Repositories) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.queryUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+func (r *Repositories) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	return r.queryUser(ctx, `SELECT `+userColumns+` FROM users WHERE lower(username) = lower($1)`, username)
+}
+
+func (r *Repositories) Exists(ctx context.Context, id string) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = $1`, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CredentialRepository methods
+
+func (r *Repositories) SetCredentials(ctx context.Context, credentials *domain.Credentials) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO credentials (user_id, password_hash, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET password_hash = excluded.password_hash, updated_at = excluded.updated_at`,
+		credentials.UserID, credentials.PasswordHash, credentials.UpdatedAt.UnixNano(),
+	)
+	return err
+}
+
+func (r *Repositories) GetCredentials(ctx context.Context, userID string) (*domain.Credentials, error) {
+	credentials := domain.Credentials{UserID: userID}
+	var updatedAt int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT password_hash, updated_at FROM credentials WHERE user_id = $1`, userID,
+	).Scan(&credentials.PasswordHash, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNoCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	credentials.UpdatedAt = time.Unix(0, updatedAt)
+	return &credentials, nil
+}
+
+func (r *Repositories) DeleteCredentials(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM credentials WHERE user_id = $1`, userID)
+	return err
+}
+
+// APIKeyRepository methods
+
+func (r *Repositories) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (id, user_id, name, scopes, key_hash, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		key.ID, key.UserID, key.Name, strings.Join(key.Scopes, " "), key.KeyHash,
+		key.CreatedAt.UnixNano(), optionalUnixNano(key.LastUsedAt),
+	)
+	return err
+}
+
+func (r *Repositories) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	keys, err := r.queryAPIKeys(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+func (r *Repositories) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	return r.queryAPIKeys(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`, userID,
+	)
+}
+
+func (r *Repositories) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrAPIKeyNotFound)
+}
+
+func (r *Repositories) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt.UnixNano(),
+	)
+	return err
+}
+
+// WebhookRepository methods
+
+func (r *Repositories) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhooks (id, user_id, url, event_types, secret, consecutive_failures, disabled_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		webhook.ID, webhook.UserID, webhook.URL, strings.Join(webhook.EventTypes, " "), webhook.Secret,
+		webhook.ConsecutiveFailures, optionalUnixNano(webhook.DisabledAt), webhook.CreatedAt.UnixNano(),
+	)
+	return err
+}
+
+func (r *Repositories) GetWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	webhooks, err := r.queryWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return webhooks[0], nil
+}
+
+func (r *Repositories) ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error) {
+	return r.queryWebhooks(ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = $1 ORDER BY created_at DESC`, userID,
+	)
+}
+
+func (r *Repositories) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE webhooks SET consecutive_failures = $2, disabled_at = $3 WHERE id = $1`,
+		webhook.ID, webhook.ConsecutiveFailures, optionalUnixNano(webhook.DisabledAt),
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result, domain.ErrWebhookNotFound)
+}
+
+func (r *Repositories) DeleteWebhook(ctx context.Context, userID, id string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userID)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result, domain.ErrWebhookNotFound); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id)
+		return err
+	})
+}
+
+func (r *Repositories) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		// Attempts of deleted webhooks are not logged
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, attempt, status_code, error, created_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8 WHERE EXISTS (SELECT 1 FROM webhooks WHERE id = $2)`,
+			delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType,
+			delivery.Attempt, delivery.StatusCode, delivery.Error, delivery.CreatedAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+
+		// Keep the last attempts only
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM webhook_deliveries WHERE webhook_id = $1 AND id NOT IN (
+				SELECT id FROM webhook_deliveries WHERE webhook_id = $1
+				ORDER BY created_at DESC, id DESC LIMIT $2
+			)`,
+			delivery.WebhookID, domain.MaxWebhookDeliveries,
+		)
+		return err
+	})
+}
+
+func (r *Repositories) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = domain.MaxWebhookDeliveries
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, webhook_id, event_id, event_type, attempt, status_code, error, created_at
+		FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		webhookID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		var createdAt int64
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType,
+			&delivery.Attempt, &delivery.StatusCode, &delivery.Error, &createdAt); err != nil {
+			return nil, err
+		}
+		delivery.CreatedAt = time.Unix(0, createdAt)
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Helpers
+
+// RevocationList methods
+
+// revocationPurgeInterval is the minimum time between purges of expired
+// revocations. Revocations are kept that long after expiring, as verifiers
+// tolerate some clock skew on expiration
+const revocationPurgeInterval = time.Minute
+
+func (r *Repositories) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	_, err := r.Consume(ctx, tokenID, expiresAt)
+	return err
+}
+
+func (r *Repositories) Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	if err := r.purgeRevocations(ctx); err != nil {
+		return false, err
+	}
+
+	// The primary key makes check + revoke atomic
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (id, expires_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`,
+		tokenID, expiresAt.UnixNano(),
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *Repositories) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var revoked int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM revoked_tokens WHERE id = $1`, tokenID).Scan(&revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *Repositories) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time) error {
+	// Only moves forward
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_users (user_id, issued_before) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET issued_before = excluded.issued_before
+		WHERE revoked_users.issued_before < excluded.issued_before`,
+		userID, issuedBefore.UnixNano(),
+	)
+	return err
+}
+
+func (r *Repositories) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	var issuedBefore int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT issued_before FROM revoked_users WHERE user_id = $1`, userID,
+	).Scan(&issuedBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, issuedBefore), nil
+}
+
+// purgeRevocations deletes the revocations expired for a while, at most
+// once per revocationPurgeInterval
+func (r *Repositories) purgeRevocations(ctx context.Context) error {
+	now := time.Now()
+	last := r.lastPurge.Load()
+	if now.UnixNano()-last < int64(revocationPurgeInterval) || !r.lastPurge.CompareAndSwap(last, now.UnixNano()) {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM revoked_tokens WHERE expires_at < $1`, now.Add(-revocationPurgeInterval).UnixNano(),
+	)
+	return err
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTweet(row scanner) (*domain.Tweet, error) {
+	var tweet domain.Tweet
+	var entities string
+	var createdAt int64
+	if err := row.Scan(&tweet.ID, &tweet.UserID, &tweet.Kind, &tweet.Content, &tweet.InReplyToTweetID, &tweet.ConversationID, &tweet.ReferencedTweetID, &entities, &createdAt); err != nil {
+		return nil, err
+	}
+	if entities != "" {
+		if err := json.Unmarshal([]byte(entities), &tweet.Entities); err != nil {
+			return nil, err
+		}
+	}
+	tweet.CreatedAt = time.Unix(0, createdAt)
+	return &tweet, nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// inTx runs fn inside a transaction, committed if fn succeeds
+func (r *Repositories) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// withOutbox runs fn inside a transaction that also stores the outbox
+// entries of events, so they are committed only with the change
+func (r *Repositories) withOutbox(ctx context.Context, events []domain.Event, fn func(tx *sql.Tx) error) error {
+	entries, err := domain.NewOutboxEntries(events)
+	if err != nil {
+		return err
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO outbox (id, type, payload, created_at) VALUES ($1, $2, $3, $4)`,
+				entry.ID, entry.Type, string(entry.Payload), entry.CreatedAt.UnixNano(),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertTweet inserts a tweet, with an optional conflict clause
+func (r *Repositories) insertTweet(ctx context.Context, db execer, tweet *domain.Tweet, onConflict string) (sql.Result, error) {
+	var entities []byte
+	if !tweet.Entities.IsEmpty() {
+		var err error
+		if entities, err = json.Marshal(tweet.Entities); err != nil {
+			return nil, err
+		}
+	}
+
+	return db.ExecContext(ctx,
+		`INSERT INTO tweets (`+tweetColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`+onConflict,
+		tweet.ID, tweet.UserID, tweet.TweetKind(), tweet.Content, tweet.InReplyToTweetID, tweet.Conversation(), tweet.ReferencedTweetID, string(entities), tweet.CreatedAt.UnixNano(),
+	)
+}
+
+// countReferences counts the tweets whose column references each of
+// tweetIDs, only of the given kind unless it is empty
+func (r *Repositories) countReferences(ctx context.Context, column, kind string, tweetIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(tweetIDs) == 0 {
+		return counts, nil
+	}
+
+	args := make([]interface{}, len(tweetIDs), len(tweetIDs)+1)
+	for i, id := range tweetIDs {
+		args[i] = id
+	}
+
+	condition := column + ` IN (` + placeholders(1, len(tweetIDs)) + `)`
+	if kind != "" {
+		args = append(args, kind)
+		condition += ` AND kind = $` + strconv.Itoa(len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+column+`, COUNT(*) FROM tweets WHERE `+condition+` GROUP BY `+column,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *Repositories) queryTweets(ctx context.Context, query string, args ...interface{}) ([]*domain.Tweet, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tweets []*domain.Tweet
+	for rows.Next() {
+		tweet, err := scanTweet(rows)
+		if err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, tweet)
+	}
+
+	return tweets, rows.Err()
+}
+
+// queryTweetPage returns the tweets matching a condition, windowed by a page
+// query. Cursors compare (created_at, id) so tweets sharing a timestamp are
+// neither repeated nor skipped between pages
+func (r *Repositories) queryTweetPage(ctx context.Context, condition string, args []interface{}, page domain.PageQuery) ([]*domain.Tweet, error) {
+	query, args := windowQuery(`SELECT `+tweetColumns+` FROM tweets WHERE `+condition, `created_at`, `id`, args, page)
+
+	tweets, err := r.queryTweets(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.SinceID != nil {
+		reverseTweets(tweets)
+	}
+	return tweets, nil
+}
+
+// windowQuery adds the bounds, order and limit of a page query to a query
+// over rows positioned by (timeColumn, idColumn). When polling, the rows
+// closest to the cursor are wanted, so they are read oldest first
+func windowQuery(query, timeColumn, idColumn string, args []interface{}, page domain.PageQuery) (string, []interface{}) {
+	if page.MaxID != nil {
+		at, id := "$"+strconv.Itoa(len(args)+1), "$"+strconv.Itoa(len(args)+2)
+		query += ` AND (` + timeColumn + ` < ` + at + ` OR (` + timeColumn + ` = ` + at + ` AND ` + idColumn + ` < ` + id + `))`
+		args = append(args, page.MaxID.CreatedAt.UnixNano(), page.MaxID.TweetID)
+	}
+	if page.SinceID != nil {
+		at, id := "$"+strconv.Itoa(len(args)+1), "$"+strconv.Itoa(len(args)+2)
+		query += ` AND (` + timeColumn + ` > ` + at + ` OR (` + timeColumn + ` = ` + at + ` AND ` + idColumn + ` > ` + id + `))`
+		args = append(args, page.SinceID.CreatedAt.UnixNano(), page.SinceID.TweetID)
+	}
+
+	if page.SinceID != nil {
+		query += ` ORDER BY ` + timeColumn + ` ASC, ` + idColumn + ` ASC`
+	} else {
+		query += ` ORDER BY ` + timeColumn + ` DESC, ` + idColumn + ` DESC`
+	}
+
+	if page.Limit > 0 {
+		query += ` LIMIT $` + strconv.Itoa(len(args)+1)
+		args = append(args, page.Limit)
+	}
+	return query, args
+}
+
+func (r *Repositories) queryIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// userColumns are the columns scanned by queryUser
+const userColumns = `id, username, display_name, bio, avatar_url, location, created_at`
+
+func (r *Repositories) queryUser(ctx context.Context, query string, args ...interface{}) (*domain.User, error) {
+	var user domain.User
+	var createdAt int64
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID, &user.Username, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.Location, &createdAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt)
+	return &user, nil
+}
+
+// apiKeyColumns are the columns scanned by queryAPIKeys
+const apiKeyColumns = `id, user_id, name, scopes, key_hash, created_at, last_used_at`
+
+func (r *Repositories) queryAPIKeys(ctx context.Context, query string, args ...interface{}) ([]*domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		var key domain.APIKey
+		var scopes string
+		var createdAt, lastUsedAt int64
+		if err := rows.Scan(&key.ID, &key.UserID, &key.Name, &scopes, &key.KeyHash, &createdAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		key.Scopes = strings.Fields(scopes)
+		key.CreatedAt = time.Unix(0, createdAt)
+		if lastUsedAt != 0 {
+			key.LastUsedAt = time.Unix(0, lastUsedAt)
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, rows.Err()
+}
+
+// webhookColumns are the columns scanned by queryWebhooks
+const webhookColumns = `id, user_id, url, event_types, secret, consecutive_failures, disabled_at, created_at`
+
+func (r *Repositories) queryWebhooks(ctx context.Context, query string, args ...interface{}) ([]*domain.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*domain.Webhook
+	for rows.Next() {
+		var webhook domain.Webhook
+		var eventTypes string
+		var disabledAt, createdAt int64
+		if err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &eventTypes, &webhook.Secret,
+			&webhook.ConsecutiveFailures, &disabledAt, &createdAt); err != nil {
+			return nil, err
+		}
+		webhook.EventTypes = strings.Fields(eventTypes)
+		webhook.CreatedAt = time.Unix(0, createdAt)
+		if disabledAt != 0 {
+			webhook.DisabledAt = time.Unix(0, disabledAt)
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// optionalUnixNano stores a zero time as 0
+func optionalUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// orderByIDs sorts tweets in the order of ids, skipping missing ones
+func orderByIDs(tweets []*domain.Tweet, ids []string) []*domain.Tweet {
+	byID := make(map[string]*domain.Tweet, len(tweets))
+	for _, tweet := range tweets {
+		byID[tweet.ID] = tweet
+	}
+
+	ordered := make([]*domain.Tweet, 0, len(tweets))
+	for _, id := range ids {
+		if tweet, exists := byID[id]; exists {
+			ordered = append(ordered, tweet)
+		}
+	}
+	return ordered
+}
+
+// reverseTweets reverses a list of tweets in place
+func reverseTweets(tweets []*domain.Tweet) {
+	for i, j := 0, len(tweets)-1; i < j; i, j = i+1, j-1 {
+		tweets[i], tweets[j] = tweets[j], tweets[i]
+	}
+}
+
+// reverseLikes reverses a list of likes in place
+func reverseLikes(likes []*domain.Like) {
+	for i, j := 0, len(likes)-1; i < j; i, j = i+1, j-1 {
+		likes[i], likes[j] = likes[j], likes[i]
+	}
+}
+
+// reverseNotifications reverses a list of notifications in place
+func reverseNotifications(notifications []*domain.Notification) {
+	for i, j := 0, len(notifications)-1; i < j; i, j = i+1, j-1 {
+		notifications[i], notifications[j] = notifications[j], notifications[i]
+	}
+}
+
+// placeholders returns "$start, $start+1, ..." for count arguments
+func placeholders(start, count int) string {
+	parts := make([]string, count)
+	for i := range parts {
+		parts[i] = "$" + strconv.Itoa(start+i)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stringArgs converts strings into query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+// errConflict signals that a conditional write lost against a concurrent one
+var errConflict = errors.New("concurrent write")
+
+// maxNotifyAttempts bounds how many times Notify reads a group again
+// after losing against concurrent writes
+const maxNotifyAttempts = 20
+
+// errNotifyContention signals that Notify kept losing against concurrent
+// writes. The event is retried later by the bus
+var errNotifyContention = errors.New("notification group changed concurrently too many times")
+
+// notificationColumns are the columns read by scanNotification
+const notificationColumns = `id, user_id, type, tweet_id, actor_ids, actor_count, read_at, created_at, updated_at`
+
+func scanNotification(row scanner) (*domain.Notification, error) {
+	var notification domain.Notification
+	var actorIDs string
+	var readAt, createdAt, updatedAt int64
+	if err := row.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.TweetID, &actorIDs, &notification.ActorCount, &readAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	notification.ActorIDs = strings.Fields(actorIDs)
+	notification.Read = readAt != 0
+	notification.CreatedAt = time.Unix(0, createdAt)
+	notification.UpdatedAt = time.Unix(0, updatedAt)
+	return &notification, nil
+}
+
+// versionedRow scans a version column that follows the ones read by a
+// scan function
+type versionedRow struct {
+	scanner
+	version *int64
+}
+
+func (r versionedRow) Scan(dest ...interface{}) error {
+	return r.scanner.Scan(append(dest, r.version)...)
+}
+
+// requireAffected returns notFound if the statement did not change any row
+func requireAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
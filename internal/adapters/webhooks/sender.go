@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+	"twitter-clone-backend/internal/domain"
+)
+
+// DefaultTimeout bounds each delivery attempt
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseBody is how much of a response is read before closing it, so
+// the connection can be reused without trusting the receiver's body size
+const maxResponseBody = 64 << 10
+
+// Request headers of a delivery
+const (
+	HeaderWebhookID = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// ErrAddressNotAllowed is returned when a webhook URL resolves to an
+// address of the internal network
+var ErrAddressNotAllowed = errors.New("webhook address is not public")
+
+// HTTPSender implements ports.WebhookSender with POST requests. Redirects
+// are not followed: a signed delivery goes to the registered URL only.
+// Unless allowed, connections to non public addresses (see
+// domain.IsPublicAddr) are refused once the host is resolved, so a host
+// name can not point a delivery to the internal network, not even by
+// changing what it resolves to after the webhook was registered. For the
+// same reason proxies are not used
+type HTTPSender struct {
+	client *http.Client
+}
+
+// NewHTTPSender creates a sender whose attempts time out after timeout.
+// allowPrivate lets it deliver to any address, meant for tests and local
+// development
+func NewHTTPSender(timeout time.Duration, allowPrivate bool) *HTTPSender {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = checkPublicAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &HTTPSender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// checkPublicAddress is a net.Dialer Control function refusing to connect
+// to non public addresses. It runs for every resolved address dialed
+func checkPublicAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, address)
+	}
+	if !domain.IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addrPort.Addr())
+	}
+	return nil
+}
+
+// Send posts a message to a webhook URL
+func (s *HTTPSender) Send(ctx context.Context, url string, message *domain.WebhookMessage) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(message.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "twitter-clone-webhooks")
+	req.Header.Set(HeaderWebhookID, message.EventID)
+	req.Header.Set(HeaderEvent, message.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(message.Timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, message.Signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
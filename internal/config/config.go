@@ -3,25 +3,104 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config contains all application configuration
 type Config struct {
-	Port        string
-	StorageType string
-	MongoURI    string
-	RedisURI    string
-	EnableCache bool
+	Port                string
+	StorageType         string
+	DataDir             string
+	FsyncPolicy         string
+	FsyncInterval       time.Duration
+	SnapshotInterval    time.Duration
+	SQLDriver           string
+	SQLDSN              string
+	MongoURI            string
+	MongoDatabase       string
+	RedisURI            string
+	EnableCache         bool
+	CacheType           string
+	CacheMaxEntries     int
+	CacheTTL            time.Duration
+	EnableFanout        bool
+	FanoutWorkers       int
+	HomeTimelineSize    int
+	CelebrityThreshold  int
+	EventQueueSize      int
+	EventMaxAttempts    int
+	EventRetryBackoff   time.Duration
+	OutboxPollInterval  time.Duration
+	OutboxBatchSize     int
+	OutboxRetryBackoff  time.Duration
+	OutboxMaxAttempts   int
+	WebhookWorkers      int
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookBackoff      time.Duration
+	WebhookDisableAfter int
+	WebhookAllowPrivate bool
+	StreamBufferSize    int
+	StreamHeartbeat     time.Duration
+	AuthMode            string
+	JWTAlgorithm        string
+	JWTSecret           string
+	JWTPrivateKeyFile   string
+	JWTIssuer           string
+	AccessTokenTTL      time.Duration
+	RefreshTokenTTL     time.Duration
+	LoginMaxAttempts    int
+	LoginMaxPerIP       int
+	LoginWindow         time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
-		StorageType: getEnv("STORAGE_TYPE", "memory"),
-		MongoURI:    getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		RedisURI:    getEnv("REDIS_URI", "redis://localhost:6379"),
-		EnableCache: getEnvAsBool("ENABLE_CACHE", false),
+		Port:                getEnv("PORT", "8080"),
+		StorageType:         getEnv("STORAGE_TYPE", "memory"),
+		DataDir:             getEnv("DATA_DIR", "./data"),
+		FsyncPolicy:         getEnv("FSYNC_POLICY", "always"),
+		FsyncInterval:       getEnvAsDuration("FSYNC_INTERVAL", time.Second),
+		SnapshotInterval:    getEnvAsDuration("SNAPSHOT_INTERVAL", 5*time.Minute),
+		SQLDriver:           getEnv("SQL_DRIVER", "postgres"),
+		SQLDSN:              getEnv("SQL_DSN", "postgres://localhost:5432/twitter_clone?sslmode=disable"),
+		MongoURI:            getEnv("MONGO_URI", "mongodb://localhost:27017/?directConnection=true"),
+		MongoDatabase:       getEnv("MONGO_DATABASE", "twitter_clone"),
+		RedisURI:            getEnv("REDIS_URI", "redis://localhost:6379"),
+		EnableCache:         getEnvAsBool("ENABLE_CACHE", false),
+		CacheType:           getEnv("CACHE_TYPE", "memory"),
+		CacheMaxEntries:     getEnvAsInt("CACHE_MAX_ENTRIES", 10000),
+		CacheTTL:            getEnvAsDuration("CACHE_TTL", time.Hour),
+		EnableFanout:        getEnvAsBool("ENABLE_FANOUT", true),
+		FanoutWorkers:       getEnvAsInt("FANOUT_WORKERS", 8),
+		HomeTimelineSize:    getEnvAsInt("HOME_TIMELINE_SIZE", 800),
+		CelebrityThreshold:  getEnvAsInt("CELEBRITY_FOLLOWER_THRESHOLD", 10000),
+		EventQueueSize:      getEnvAsInt("EVENT_QUEUE_SIZE", 1024),
+		EventMaxAttempts:    getEnvAsInt("EVENT_MAX_ATTEMPTS", 5),
+		EventRetryBackoff:   getEnvAsDuration("EVENT_RETRY_BACKOFF", 100*time.Millisecond),
+		OutboxPollInterval:  getEnvAsDuration("OUTBOX_POLL_INTERVAL", 250*time.Millisecond),
+		OutboxBatchSize:     getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetryBackoff:  getEnvAsDuration("OUTBOX_RETRY_BACKOFF", time.Second),
+		OutboxMaxAttempts:   getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+		WebhookWorkers:      getEnvAsInt("WEBHOOK_WORKERS", 4),
+		WebhookTimeout:      getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:  getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookBackoff:      getEnvAsDuration("WEBHOOK_RETRY_BACKOFF", time.Second),
+		WebhookDisableAfter: getEnvAsInt("WEBHOOK_DISABLE_AFTER", 5),
+		WebhookAllowPrivate: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE", false),
+		StreamBufferSize:    getEnvAsInt("STREAM_BUFFER_SIZE", 64),
+		StreamHeartbeat:     getEnvAsDuration("STREAM_HEARTBEAT", 15*time.Second),
+		AuthMode:            getEnv("AUTH_MODE", "jwt"),
+		JWTAlgorithm:        getEnv("JWT_ALGORITHM", "HS256"),
+		JWTSecret:           getEnv("JWT_SECRET", ""),
+		JWTPrivateKeyFile:   getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTIssuer:           getEnv("JWT_ISSUER", "twitter-clone-backend"),
+		AccessTokenTTL:      getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:     getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		LoginMaxAttempts:    getEnvAsInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginMaxPerIP:       getEnvAsInt("LOGIN_MAX_ATTEMPTS_PER_IP", 50),
+		LoginWindow:         getEnvAsDuration("LOGIN_THROTTLE_WINDOW", 15*time.Minute),
 	}
 }
 
@@ -42,3 +121,23 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvAsInt gets an environment variable as integer
+func getEnvAsInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if result, err := strconv.Atoi(value); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsDuration gets an environment variable as duration (e.g. "30m", "1h")
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if result, err := time.ParseDuration(value); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// Block represents a user blocking another. The blocked user can not
+// follow the blocker, and their mentions of the blocker are not resolved
+type Block struct {
+	BlockerID string    `json:"blocker_id"` // User who blocks
+	BlockedID string    `json:"blocked_id"` // User who is blocked
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewBlock creates a new block
+func NewBlock(blockerID, blockedID string) (*Block, error) {
+	if blockerID == "" || blockedID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	if blockerID == blockedID {
+		return nil, ErrCannotBlockSelf
+	}
+
+	return &Block{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now(),
+	}, nil
+}
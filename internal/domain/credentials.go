@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Credentials are the password credentials of a user. Only a slow hash of
+// the password is stored, never the password itself
+type Credentials struct {
+	UserID       string    `json:"user_id"`
+	PasswordHash string    `json:"password_hash"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewCredentials creates the credentials of a user from a password hash
+func NewCredentials(userID, passwordHash string) *Credentials {
+	return &Credentials{
+		UserID:       userID,
+		PasswordHash: passwordHash,
+		UpdatedAt:    time.Now(),
+	}
+}
+
+// ValidatePassword checks the password policy. Length is counted in
+// characters; there are no composition rules, long passphrases are better
+func ValidatePassword(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < MinPasswordLength || length > MaxPasswordLength {
+		return ErrInvalidPassword
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entities are the parts of the content of a tweet that clients render as
+// links. Start and End are offsets in code points of the content, End
+// excluded, in order of appearance
+type Entities struct {
+	Hashtags []TagEntity     `json:"hashtags,omitempty"`
+	Cashtags []TagEntity     `json:"cashtags,omitempty"`
+	Mentions []MentionEntity `json:"mentions,omitempty"`
+	URLs     []URLEntity     `json:"urls,omitempty"`
+}
+
+// TagEntity is a hashtag ("#golang") or a cashtag ("$GOOG"), without its sign
+type TagEntity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Tag   string `json:"tag"`
+}
+
+// MentionEntity is an @mention. UserID is set once the username is resolved
+type MentionEntity struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Username string `json:"username"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+// URLEntity is a link
+type URLEntity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	URL   string `json:"url"`
+}
+
+// Entity patterns. The sign must not follow a letter, digit or underscore
+// (so "a#b" or "mail@example.com" are not entities), and Go regexps have no
+// lookahead, so what follows a match is checked by entityEndsAt
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_&])[#＃]([\p{L}\p{M}\p{N}_]*[\p{L}\p{M}][\p{L}\p{M}\p{N}_]*)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_@])[@＠]([A-Za-z0-9_]+)`)
+	cashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_$])\$([A-Za-z]{1,6}(?:[._][A-Za-z]{1,2})?)`)
+)
+
+// ExtractEntities finds the hashtags, cashtags, mentions and URLs of a
+// text. Signs inside URLs are not entities, and neither are mentions longer
+// than MaxUsernameLength. Mentions are not resolved. Returns nil if the
+// text has none
+func ExtractEntities(text string) *Entities {
+	entities := &Entities{}
+
+	urls := findURLs(text)
+	insideURL := func(start int) bool {
+		for _, url := range urls {
+			if start >= url[0] && start < url[1] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, url := range urls {
+		start, end := codePointRange(text, url[0], url[1])
+		entities.URLs = append(entities.URLs, URLEntity{Start: start, End: end, URL: text[url[0]:url[1]]})
+	}
+
+	for _, match := range hashtagPattern.FindAllStringSubmatchIndex(text, -1) {
+		sign := signStart(text, match[2])
+		if insideURL(sign) {
+			continue
+		}
+		start, end := codePointRange(text, sign, match[3])
+		entities.Hashtags = append(entities.Hashtags, TagEntity{Start: start, End: end, Tag: text[match[2]:match[3]]})
+	}
+
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		sign := signStart(text, match[2])
+		username := text[match[2]:match[3]]
+		if insideURL(sign) || len(username) > MaxUsernameLength || !entityEndsAt(text, match[3], "@＠") {
+			continue
+		}
+		start, end := codePointRange(text, sign, match[3])
+		entities.Mentions = append(entities.Mentions, MentionEntity{Start: start, End: end, Username: username})
+	}
+
+	for _, match := range cashtagPattern.FindAllStringSubmatchIndex(text, -1) {
+		sign := signStart(text, match[2])
+		if insideURL(sign) || !entityEndsAt(text, match[3], "$") {
+			continue
+		}
+		start, end := codePointRange(text, sign, match[3])
+		entities.Cashtags = append(entities.Cashtags, TagEntity{Start: start, End: end, Tag: text[match[2]:match[3]]})
+	}
+
+	if entities.IsEmpty() {
+		return nil
+	}
+	return entities
+}
+
+// IsEmpty reports whether there are no entities
+func (e *Entities) IsEmpty() bool {
+	return e == nil ||
+		len(e.Hashtags) == 0 && len(e.Cashtags) == 0 && len(e.Mentions) == 0 && len(e.URLs) == 0
+}
+
+// MentionedUserIDs returns the resolved mentioned users, without repeats
+func (e *Entities) MentionedUserIDs() []string {
+	if e == nil {
+		return nil
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, mention := range e.Mentions {
+		if mention.UserID != "" && !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			ids = append(ids, mention.UserID)
+		}
+	}
+	return ids
+}
+
+// signStart returns the byte offset of the sign (one code point) that
+// precedes the name starting at nameStart
+func signStart(text string, nameStart int) int {
+	_, size := utf8.DecodeLastRuneInString(text[:nameStart])
+	return nameStart - size
+}
+
+// entityEndsAt reports whether an entity ending at end is not glued to a
+// letter, digit, underscore or any of the signs in extra
+func entityEndsAt(text string, end int, extra string) bool {
+	if end == len(text) {
+		return true
+	}
+	next, _ := utf8.DecodeRuneInString(text[end:])
+	isWord := (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z') || (next >= '0' && next <= '9') || next == '_'
+	return !isWord && !strings.ContainsRune(extra, next)
+}
+
+// codePointRange converts a byte range of text into code point offsets
+func codePointRange(text string, start, end int) (int, int) {
+	codeStart := utf8.RuneCountInString(text[:start])
+	return codeStart, codeStart + utf8.RuneCountInString(text[start:end])
+}
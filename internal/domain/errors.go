@@ -4,18 +4,73 @@ import "errors"
 
 // Domain errors
 var (
-	ErrInvalidUserID    = errors.New("invalid user ID")
-	ErrEmptyContent     = errors.New("tweet content cannot be empty")
-	ErrContentTooLong   = errors.New("tweet content exceeds maximum length")
-	ErrUserNotFound     = errors.New("user not found")
-	ErrTweetNotFound    = errors.New("tweet not found")
-	ErrAlreadyFollowing = errors.New("already following this user")
-	ErrNotFollowing     = errors.New("not following this user")
-	ErrCannotFollowSelf = errors.New("cannot follow yourself")
+	ErrInvalidUserID        = errors.New("invalid user ID")
+	ErrEmptyContent         = errors.New("tweet content cannot be empty")
+	ErrContentTooLong       = errors.New("tweet content exceeds maximum length")
+	ErrInvalidContent       = errors.New("tweet content has control characters or invalid UTF-8")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidUsername      = errors.New("username must have 1 to 15 letters, digits or underscores")
+	ErrUsernameTaken        = errors.New("username is already taken")
+	ErrProfileTooLong       = errors.New("profile field exceeds maximum length")
+	ErrInvalidAvatarURL     = errors.New("avatar URL must be an absolute http(s) URL")
+	ErrTweetNotFound        = errors.New("tweet not found")
+	ErrNotTweetAuthor       = errors.New("only the author can delete this tweet")
+	ErrParentNotFound       = errors.New("the tweet being replied to does not exist")
+	ErrQuotedNotFound       = errors.New("the quoted tweet does not exist")
+	ErrCannotReshare        = errors.New("retweets can not be retweeted or quoted, reshare the original tweet")
+	ErrAlreadyRetweeted     = errors.New("already retweeted this tweet")
+	ErrNotRetweeted         = errors.New("not retweeted this tweet")
+	ErrAlreadyLiked         = errors.New("already liked this tweet")
+	ErrNotLiked             = errors.New("not liked this tweet")
+	ErrAlreadyFollowing     = errors.New("already following this user")
+	ErrNotFollowing         = errors.New("not following this user")
+	ErrCannotFollowSelf     = errors.New("cannot follow yourself")
+	ErrAlreadyBlocked       = errors.New("already blocking this user")
+	ErrNotBlocked           = errors.New("not blocking this user")
+	ErrCannotBlockSelf      = errors.New("cannot block yourself")
+	ErrBlocked              = errors.New("blocked by this user")
+	ErrInvalidCursor        = errors.New("invalid pagination cursor")
+	ErrUnauthenticated      = errors.New("authentication required")
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrInvalidPassword      = errors.New("password must have 8 to 128 characters")
+	ErrInvalidLogin         = errors.New("invalid username or password")
+	ErrWrongPassword        = errors.New("current password is incorrect")
+	ErrTooManyAttempts      = errors.New("too many failed login attempts, try again later")
+	ErrNoCredentials        = errors.New("credentials not found")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+	ErrInvalidAPIKeyName    = errors.New("API key name must have 1 to 50 characters")
+	ErrInvalidScope         = errors.New("API keys need one or more of the scopes tweets:write, timeline:read, follows:write")
+	ErrInsufficientScope    = errors.New("API key does not grant access to this endpoint")
+	ErrUnknownEventType     = errors.New("unknown domain event type")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrInvalidWebhookURL    = errors.New("webhook URL must be an absolute http(s) URL")
+	ErrWebhookURLNotPublic  = errors.New("webhook URL must not point to a loopback, private or reserved address")
+	ErrInvalidWebhookEvents = errors.New("webhooks need one or more of the events tweet.created, tweet.deleted, user.followed, user.unfollowed")
+	ErrInvalidWebhookSecret = errors.New("webhook secret must have 16 to 256 characters")
+	ErrTooManyWebhooks      = errors.New("too many webhooks, delete one first")
 )
 
 // Business constants
 const (
-	MaxTweetLength   = 280
-	MaxTimelineLimit = 100
+	MaxTweetLength      = 280 // weighted, see WeightedLength
+	MaxTimelineLimit    = 100
+	MaxThreadSize       = 500 // tweets returned for a conversation
+	MaxHomeTimelineSize = 800 // tweets kept in each precomputed home timeline
+
+	MaxUsernameLength    = 15
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+	MaxLocationLength    = 30
+	MaxAvatarURLLength   = 512
+
+	MinPasswordLength = 8
+	MaxPasswordLength = 128
+
+	MaxAPIKeyNameLength = 50
+
+	MaxWebhooksPerUser     = 10
+	MaxWebhookURLLength    = 2048
+	MinWebhookSecretLength = 16
+	MaxWebhookSecretLength = 256
+	MaxWebhookDeliveries   = 100 // attempts kept in the delivery log of each webhook
 )
@@ -0,0 +1,153 @@
+package domain
+
+import "time"
+
+// Event types
+const (
+	EventTweetCreated   = "tweet.created"
+	EventTweetDeleted   = "tweet.deleted"
+	EventTweetLiked     = "tweet.liked"
+	EventTweetUnliked   = "tweet.unliked"
+	EventUserFollowed   = "user.followed"
+	EventUserUnfollowed = "user.unfollowed"
+)
+
+// Event is something that happened in the domain. Events are published
+// once the change they describe is persisted, and subscribers react to them
+// independently of the action that caused them
+type Event interface {
+	EventID() string
+	EventType() string
+	OccurredAt() time.Time
+}
+
+// EventMetadata identifies an occurrence of an event, so subscribers can
+// recognize an event delivered more than once
+type EventMetadata struct {
+	ID   string    `json:"event_id"`
+	Time time.Time `json:"occurred_at"`
+}
+
+// newEventMetadata identifies an event that happens now
+func newEventMetadata() EventMetadata {
+	return EventMetadata{ID: generateID(), Time: time.Now()}
+}
+
+// EventID returns the unique ID of the event
+func (m EventMetadata) EventID() string {
+	return m.ID
+}
+
+// OccurredAt returns when the event happened
+func (m EventMetadata) OccurredAt() time.Time {
+	return m.Time
+}
+
+// TweetCreated is published when a tweet, reply, quote or retweet is
+// created. ReferencedUserID is the author of the tweet it answers, quotes
+// or reshares, if any
+type TweetCreated struct {
+	EventMetadata
+	Tweet            *Tweet `json:"tweet"`
+	ReferencedUserID string `json:"referenced_user_id,omitempty"`
+}
+
+// NewTweetCreated creates the event of a new tweet referencing another one
+// (nil for plain tweets)
+func NewTweetCreated(tweet, referenced *Tweet) TweetCreated {
+	event := TweetCreated{EventMetadata: newEventMetadata(), Tweet: tweet}
+	if referenced != nil {
+		event.ReferencedUserID = referenced.UserID
+	}
+	return event
+}
+
+// EventType returns the type of the event
+func (TweetCreated) EventType() string {
+	return EventTweetCreated
+}
+
+// TweetDeleted is published when a tweet is deleted or a retweet undone
+type TweetDeleted struct {
+	EventMetadata
+	Tweet *Tweet `json:"tweet"`
+}
+
+// NewTweetDeleted creates the event of a deleted tweet
+func NewTweetDeleted(tweet *Tweet) TweetDeleted {
+	return TweetDeleted{EventMetadata: newEventMetadata(), Tweet: tweet}
+}
+
+// EventType returns the type of the event
+func (TweetDeleted) EventType() string {
+	return EventTweetDeleted
+}
+
+// TweetLiked is published when a user likes a tweet. AuthorID is the
+// author of the liked tweet, empty if it was deleted meanwhile
+type TweetLiked struct {
+	EventMetadata
+	Like     *Like  `json:"like"`
+	AuthorID string `json:"author_id"`
+}
+
+// NewTweetLiked creates the event of a like of a tweet by authorID
+func NewTweetLiked(like *Like, authorID string) TweetLiked {
+	return TweetLiked{EventMetadata: newEventMetadata(), Like: like, AuthorID: authorID}
+}
+
+// EventType returns the type of the event
+func (TweetLiked) EventType() string {
+	return EventTweetLiked
+}
+
+// TweetUnliked is published when a user removes their like of a tweet
+type TweetUnliked struct {
+	EventMetadata
+	UserID  string `json:"user_id"`
+	TweetID string `json:"tweet_id"`
+}
+
+// NewTweetUnliked creates the event of a removed like
+func NewTweetUnliked(userID, tweetID string) TweetUnliked {
+	return TweetUnliked{EventMetadata: newEventMetadata(), UserID: userID, TweetID: tweetID}
+}
+
+// EventType returns the type of the event
+func (TweetUnliked) EventType() string {
+	return EventTweetUnliked
+}
+
+// UserFollowed is published when a user starts following another one
+type UserFollowed struct {
+	EventMetadata
+	FollowerID string `json:"follower_id"`
+	FolloweeID string `json:"followee_id"`
+}
+
+// NewUserFollowed creates the event of a new follow
+func NewUserFollowed(followerID, followeeID string) UserFollowed {
+	return UserFollowed{EventMetadata: newEventMetadata(), FollowerID: followerID, FolloweeID: followeeID}
+}
+
+// EventType returns the type of the event
+func (UserFollowed) EventType() string {
+	return EventUserFollowed
+}
+
+// UserUnfollowed is published when a user stops following another one
+type UserUnfollowed struct {
+	EventMetadata
+	FollowerID string `json:"follower_id"`
+	FolloweeID string `json:"followee_id"`
+}
+
+// NewUserUnfollowed creates the event of a removed follow
+func NewUserUnfollowed(followerID, followeeID string) UserUnfollowed {
+	return UserUnfollowed{EventMetadata: newEventMetadata(), FollowerID: followerID, FolloweeID: followeeID}
+}
+
+// EventType returns the type of the event
+func (UserUnfollowed) EventType() string {
+	return EventUserUnfollowed
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Like records that a user liked a tweet
+type Like struct {
+	UserID    string    `json:"user_id"`
+	TweetID   string    `json:"tweet_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewLike creates a new like
+func NewLike(userID, tweetID string) (*Like, error) {
+	if userID == "" {
+		return nil, ErrInvalidUserID
+	}
+	if tweetID == "" {
+		return nil, ErrTweetNotFound
+	}
+
+	return &Like{
+		UserID:    userID,
+		TweetID:   tweetID,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Before reports whether the like goes before other in a list of likes
+// (most recent first, ties broken by tweet ID)
+func (l *Like) Before(other *Like) bool {
+	if !l.CreatedAt.Equal(other.CreatedAt) {
+		return l.CreatedAt.After(other.CreatedAt)
+	}
+	return l.TweetID > other.TweetID
+}
@@ -0,0 +1,115 @@
+package domain
+
+import "time"
+
+// Notification types
+const (
+	NotificationFollow  = "follow"
+	NotificationLike    = "like"
+	NotificationReply   = "reply"
+	NotificationMention = "mention"
+	NotificationRetweet = "retweet"
+	NotificationQuote   = "quote"
+)
+
+// MaxNotificationActors is how many of the most recent actors a
+// notification keeps, so a popular tweet does not grow it without bound
+const MaxNotificationActors = 20
+
+// Notification tells a user that others interacted with them. Events of the
+// same type about the same tweet are grouped into one unread notification
+// ("5 people liked your tweet"): ActorIDs are the latest who caused them,
+// most recent first, ActorCount is how many did, and UpdatedAt is the time
+// of the latest one. Follows are about no tweet. Replies, mentions and
+// quotes are about the tweet that replies, mentions or quotes, so each one
+// is a notification of its own
+type Notification struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Type       string    `json:"type"`
+	TweetID    string    `json:"tweet_id,omitempty"`
+	ActorIDs   []string  `json:"actor_ids"`
+	ActorCount int       `json:"actor_count,omitempty"`
+	Read       bool      `json:"read,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewNotification creates the notification of a single event for userID
+func NewNotification(userID, notificationType, actorID, tweetID string) (*Notification, error) {
+	if userID == "" || actorID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	now := time.Now()
+	return &Notification{
+		ID:         generateID(),
+		UserID:     userID,
+		Type:       notificationType,
+		TweetID:    tweetID,
+		ActorIDs:   []string{actorID},
+		ActorCount: 1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// GroupsWith reports whether event is grouped into the notification
+func (n *Notification) GroupsWith(event *Notification) bool {
+	return !n.Read && n.UserID == event.UserID && n.Type == event.Type && n.TweetID == event.TweetID
+}
+
+// Actors returns how many users caused the notification. Notifications
+// stored before it was counted have all their actors in ActorIDs
+func (n *Notification) Actors() int {
+	if n.ActorCount < len(n.ActorIDs) {
+		return len(n.ActorIDs)
+	}
+	return n.ActorCount
+}
+
+// Merge returns the notification with the actors of event added in front,
+// keeping up to MaxNotificationActors. Actors are not repeated nor counted
+// twice while they are kept; one that acts again after being dropped is.
+// The notification itself is not modified
+func (n *Notification) Merge(event *Notification) *Notification {
+	merged := *n
+	merged.ActorIDs = append([]string{}, event.ActorIDs...)
+	merged.ActorCount = n.Actors()
+	for _, actorID := range event.ActorIDs {
+		if !containsString(n.ActorIDs, actorID) {
+			merged.ActorCount++
+		}
+	}
+	for _, actorID := range n.ActorIDs {
+		if !containsString(event.ActorIDs, actorID) {
+			merged.ActorIDs = append(merged.ActorIDs, actorID)
+		}
+	}
+	if len(merged.ActorIDs) > MaxNotificationActors {
+		merged.ActorIDs = merged.ActorIDs[:MaxNotificationActors]
+	}
+	if event.UpdatedAt.After(merged.UpdatedAt) {
+		merged.UpdatedAt = event.UpdatedAt
+	}
+	return &merged
+}
+
+// Before reports whether the notification goes before other in a list of
+// notifications (most recently updated first, ties broken by ID)
+func (n *Notification) Before(other *Notification) bool {
+	if !n.UpdatedAt.Equal(other.UpdatedAt) {
+		return n.UpdatedAt.After(other.UpdatedAt)
+	}
+	return n.ID > other.ID
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
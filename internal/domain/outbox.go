@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEntry is a domain event stored together with the change it
+// describes, until it is relayed to the subscribers. The entry has the ID
+// of its event, so an event relayed twice can be recognized. Attempts
+// counts the relays that some subscriber failed, and NextAttemptAt is when
+// the entry is relayed again (zero if it never failed)
+type OutboxEntry struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int             `json:"attempts,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+}
+
+// NewOutboxEntry encodes an event to be stored in the outbox
+func NewOutboxEntry(event Event) (*OutboxEntry, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxEntry{
+		ID:        event.EventID(),
+		Type:      event.EventType(),
+		Payload:   payload,
+		CreatedAt: event.OccurredAt(),
+	}, nil
+}
+
+// NewOutboxEntries encodes the events written with a change
+func NewOutboxEntries(events []Event) ([]*OutboxEntry, error) {
+	entries := make([]*OutboxEntry, 0, len(events))
+	for _, event := range events {
+		entry, err := NewOutboxEntry(event)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Event decodes the event of the entry
+func (e *OutboxEntry) Event() (Event, error) {
+	var event Event
+	var err error
+	switch e.Type {
+	case EventTweetCreated:
+		var decoded TweetCreated
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	case EventTweetDeleted:
+		var decoded TweetDeleted
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	case EventTweetLiked:
+		var decoded TweetLiked
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	case EventTweetUnliked:
+		var decoded TweetUnliked
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	case EventUserFollowed:
+		var decoded UserFollowed
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	case EventUserUnfollowed:
+		var decoded UserUnfollowed
+		err = json.Unmarshal(e.Payload, &decoded)
+		event = decoded
+	default:
+		return nil, ErrUnknownEventType
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Due reports whether the entry can be relayed at now
+func (e *OutboxEntry) Due(now time.Time) bool {
+	return !e.NextAttemptAt.After(now)
+}
+
+// Before reports whether the entry goes before other in the outbox
+// (oldest first, ties broken by ID)
+func (e *OutboxEntry) Before(other *OutboxEntry) bool {
+	if !e.CreatedAt.Equal(other.CreatedAt) {
+		return e.CreatedAt.Before(other.CreatedAt)
+	}
+	return e.ID < other.ID
+}
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursor is a position in a timeline: the creation time and ID of a tweet.
+// Including the ID keeps positions unique when tweets share a timestamp
+type Cursor struct {
+	CreatedAt time.Time
+	TweetID   string
+}
+
+// CursorOf returns the position of a tweet
+func CursorOf(tweet *Tweet) Cursor {
+	return Cursor{CreatedAt: tweet.CreatedAt, TweetID: tweet.ID}
+}
+
+// CursorOfLike returns the position of a like in a list of likes
+func CursorOfLike(like *Like) Cursor {
+	return Cursor{CreatedAt: like.CreatedAt, TweetID: like.TweetID}
+}
+
+// CursorOfNotification returns the position of a notification in a list of
+// notifications
+func CursorOfNotification(notification *Notification) Cursor {
+	return Cursor{CreatedAt: notification.UpdatedAt, TweetID: notification.ID}
+}
+
+// Encode returns the cursor as an opaque token
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + c.TweetID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a token produced by Encode
+func DecodeCursor(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{CreatedAt: time.Unix(0, unixNano), TweetID: id}, nil
+}
+
+// IsNewer reports whether a position goes before the cursor in a timeline
+func (c Cursor) IsNewer(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.After(c.CreatedAt)
+	}
+	return id > c.TweetID
+}
+
+// IsOlder reports whether a position goes after the cursor in a timeline
+func (c Cursor) IsOlder(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.Before(c.CreatedAt)
+	}
+	return id < c.TweetID
+}
+
+// PageQuery selects a window of a timeline (most recent first).
+//
+// MaxID returns tweets strictly older than the cursor (infinite scroll).
+// SinceID returns tweets strictly newer than the cursor (polling); when there
+// are more than Limit of them, the ones closest to the cursor are returned so
+// repeated polling never skips tweets. A Limit of 0 means no limit
+type PageQuery struct {
+	Limit   int
+	MaxID   *Cursor
+	SinceID *Cursor
+}
+
+// Contains reports whether a position is inside the window bounds
+func (q PageQuery) Contains(createdAt time.Time, id string) bool {
+	if q.MaxID != nil && !q.MaxID.IsOlder(createdAt, id) {
+		return false
+	}
+	if q.SinceID != nil && !q.SinceID.IsNewer(createdAt, id) {
+		return false
+	}
+	return true
+}
+
+// ApplyToTweets selects the page from tweets sorted in timeline order
+func (q PageQuery) ApplyToTweets(tweets []*Tweet) []*Tweet {
+	start, end := q.window(len(tweets), func(i int) (time.Time, string) {
+		return tweets[i].CreatedAt, tweets[i].ID
+	})
+	return tweets[start:end]
+}
+
+// ApplyToEntries selects the page from entries sorted in timeline order
+func (q PageQuery) ApplyToEntries(entries []TimelineEntry) []TimelineEntry {
+	start, end := q.window(len(entries), func(i int) (time.Time, string) {
+		return entries[i].CreatedAt, entries[i].TweetID
+	})
+	return entries[start:end]
+}
+
+// ApplyToLikes selects the page from likes sorted most recent first
+func (q PageQuery) ApplyToLikes(likes []*Like) []*Like {
+	start, end := q.window(len(likes), func(i int) (time.Time, string) {
+		return likes[i].CreatedAt, likes[i].TweetID
+	})
+	return likes[start:end]
+}
+
+// ApplyToNotifications selects the page from notifications sorted most
+// recently updated first
+func (q PageQuery) ApplyToNotifications(notifications []*Notification) []*Notification {
+	start, end := q.window(len(notifications), func(i int) (time.Time, string) {
+		return notifications[i].UpdatedAt, notifications[i].ID
+	})
+	return notifications[start:end]
+}
+
+// window returns the range of a sorted list selected by the query
+func (q PageQuery) window(n int, position func(i int) (time.Time, string)) (start, end int) {
+	// Positions inside the bounds form a contiguous range of a sorted list
+	start = n
+	for i := 0; i < n; i++ {
+		if q.Contains(position(i)) {
+			start = i
+			break
+		}
+	}
+	end = start
+	for end < n && q.Contains(position(end)) {
+		end++
+	}
+
+	if q.Limit > 0 && end-start > q.Limit {
+		if q.SinceID != nil {
+			start = end - q.Limit
+		} else {
+			end = start + q.Limit
+		}
+	}
+	return start, end
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TweetURLLength is the weight of a URL in a tweet, whatever its length
+// (links are counted as if they were shortened)
+const TweetURLLength = 23
+
+// urlPattern finds the links of a text
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// urlTrailingPunctuation is not part of a link at the end of a sentence
+const urlTrailingPunctuation = `.,:;!?'")]}`
+
+// lightRanges are the code points that weigh 1 (Latin, Greek, Cyrillic,
+// Hebrew, Arabic, ... and general punctuation). Anything else, such as
+// CJK or emoji, weighs 2
+var lightRanges = []struct{ lo, hi rune }{
+	{0x0000, 0x10FF},
+	{0x2000, 0x200D},
+	{0x2010, 0x201F},
+	{0x2032, 0x2037},
+}
+
+// NormalizeText puts a text in NFC form and turns CRLF into LF. Texts that
+// are not valid UTF-8 or have control characters other than newlines and
+// tabs are rejected with ErrInvalidContent
+func NormalizeText(text string) (string, error) {
+	if !utf8.ValidString(text) {
+		return "", ErrInvalidContent
+	}
+
+	text = strings.ReplaceAll(norm.NFC.String(text), "\r\n", "\n")
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return "", ErrInvalidContent
+		}
+	}
+	return text, nil
+}
+
+// WeightedLength returns the length of a text as users see it: each
+// grapheme cluster (a letter with its accents, a whole emoji) counts once,
+// with a weight of 1 or 2 (CJK, emoji), and each URL counts TweetURLLength
+func WeightedLength(text string) int {
+	length := 0
+	start := 0
+	for _, url := range findURLs(text) {
+		length += graphemesLength(text[start:url[0]]) + TweetURLLength
+		start = url[1]
+	}
+	return length + graphemesLength(text[start:])
+}
+
+// findURLs returns the byte ranges of the links of a text, without the
+// punctuation that ends a sentence
+func findURLs(text string) [][2]int {
+	var urls [][2]int
+	for _, match := range urlPattern.FindAllStringIndex(text, -1) {
+		end := match[0] + len(strings.TrimRight(text[match[0]:match[1]], urlTrailingPunctuation))
+		urls = append(urls, [2]int{match[0], end})
+	}
+	return urls
+}
+
+// NormalizeTweetContent normalizes the content of a tweet and checks that
+// it is not empty and fits in MaxTweetLength
+func NormalizeTweetContent(content string) (string, error) {
+	content, err := NormalizeText(content)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", ErrEmptyContent
+	}
+	if WeightedLength(content) > MaxTweetLength {
+		return "", ErrContentTooLong
+	}
+	return content, nil
+}
+
+// graphemesLength returns the weighted length of a text without URLs
+func graphemesLength(text string) int {
+	length := 0
+	graphemes := uniseg.NewGraphemes(text)
+	for graphemes.Next() {
+		length += graphemeWeight(graphemes.Runes())
+	}
+	return length
+}
+
+// graphemeWeight returns the weight of a grapheme cluster, decided by its
+// first code point. Emoji presentation sequences (e.g. "❤️") weigh 2
+func graphemeWeight(runes []rune) int {
+	for _, r := range runes[1:] {
+		if r == 0xFE0F {
+			return 2
+		}
+	}
+	for _, light := range lightRanges {
+		if runes[0] >= light.lo && runes[0] <= light.hi {
+			return 1
+		}
+	}
+	return 2
+}
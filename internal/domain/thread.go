@@ -0,0 +1,36 @@
+package domain
+
+import "sort"
+
+// ThreadNode is a tweet of a conversation with the replies it received
+type ThreadNode struct {
+	Tweet   *Tweet
+	Replies []*ThreadNode
+}
+
+// BuildThread arranges the tweets of a conversation as a tree, each level
+// in chronological order. Replies to a tweet that is not among them (e.g.
+// deleted) are returned as roots, next to the first tweet
+func BuildThread(tweets []*Tweet) []*ThreadNode {
+	sorted := make([]*Tweet, len(tweets))
+	copy(sorted, tweets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[j].Before(sorted[i])
+	})
+
+	nodes := make(map[string]*ThreadNode, len(sorted))
+	for _, tweet := range sorted {
+		nodes[tweet.ID] = &ThreadNode{Tweet: tweet}
+	}
+
+	var roots []*ThreadNode
+	for _, tweet := range sorted {
+		node := nodes[tweet.ID]
+		if parent, ok := nodes[tweet.InReplyToTweetID]; ok && tweet.IsReply() {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// TimelineEntry is a reference to a tweet stored in a precomputed home timeline
+type TimelineEntry struct {
+	TweetID   string    `json:"tweet_id"`
+	AuthorID  string    `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewTimelineEntry creates the timeline entry of a tweet
+func NewTimelineEntry(tweet *Tweet) TimelineEntry {
+	return TimelineEntry{
+		TweetID:   tweet.ID,
+		AuthorID:  tweet.UserID,
+		CreatedAt: tweet.CreatedAt,
+	}
+}
+
+// Before reports whether the entry goes before other in a timeline
+// (most recent first, ties broken by tweet ID)
+func (e TimelineEntry) Before(other TimelineEntry) bool {
+	if !e.CreatedAt.Equal(other.CreatedAt) {
+		return e.CreatedAt.After(other.CreatedAt)
+	}
+	return e.TweetID > other.TweetID
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// Token uses
+const (
+	TokenUseAccess  = "access"
+	TokenUseRefresh = "refresh"
+)
+
+// TokenClaims are the claims of an authentication token
+type TokenClaims struct {
+	ID        string // unique token ID (jti)
+	Subject   string // user ID
+	Use       string // TokenUseAccess or TokenUseRefresh
+	Family    string // ID of the session: tokens obtained by refreshing keep it
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// NewTokenClaims creates the claims of a new token for a user, starting a
+// new token family
+func NewTokenClaims(userID, use string, ttl time.Duration) TokenClaims {
+	return NewFamilyTokenClaims(userID, use, generateID(), ttl)
+}
+
+// NewFamilyTokenClaims creates the claims of a new token for a user in an
+// existing token family
+func NewFamilyTokenClaims(userID, use, family string, ttl time.Duration) TokenClaims {
+	now := time.Now()
+	return TokenClaims{
+		ID:        generateID(),
+		Subject:   userID,
+		Use:       use,
+		Family:    family,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// TokenPair is the result of a successful authentication
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
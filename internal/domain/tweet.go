@@ -11,12 +11,35 @@ func generateID() string {
 	return uuid.New().String()
 }
 
-// Tweet represents a tweet in the system
+// Tweet kinds
+const (
+	KindTweet   = "tweet"
+	KindRetweet = "retweet" // reshare of ReferencedTweetID, without content
+	KindQuote   = "quote"   // reshare of ReferencedTweetID with commentary
+)
+
+// Tweet represents a tweet in the system. A reply points to the tweet it
+// answers; every tweet of a conversation shares the ID of its first tweet.
+// Retweets and quotes point to the tweet they reshare. Entities are found in
+// the content when the tweet is created
 type Tweet struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                string    `json:"id"`
+	UserID            string    `json:"user_id"`
+	Kind              string    `json:"kind,omitempty"`
+	Content           string    `json:"content"`
+	InReplyToTweetID  string    `json:"in_reply_to_tweet_id,omitempty"`
+	ConversationID    string    `json:"conversation_id,omitempty"`
+	ReferencedTweetID string    `json:"referenced_tweet_id,omitempty"`
+	Entities          *Entities `json:"entities,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// TweetCounts are the interactions with a tweet, counted at read time
+type TweetCounts struct {
+	Replies  int
+	Retweets int
+	Quotes   int
+	Likes    int
 }
 
 // NewTweet creates a new tweet with validations
@@ -25,26 +48,131 @@ func NewTweet(userID, content string) (*Tweet, error) {
 		return nil, ErrInvalidUserID
 	}
 
-	if content == "" {
-		return nil, ErrEmptyContent
+	content, err := NormalizeTweetContent(content)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(content) > MaxTweetLength {
-		return nil, ErrContentTooLong
+	id := generateID()
+	return &Tweet{
+		ID:             id,
+		UserID:         userID,
+		Kind:           KindTweet,
+		Content:        content,
+		ConversationID: id,
+		Entities:       ExtractEntities(content),
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// NewRetweet creates a reshare of original. Retweeting a retweet is not
+// allowed: the tweet it reshares must be retweeted instead
+func NewRetweet(userID string, original *Tweet) (*Tweet, error) {
+	if userID == "" {
+		return nil, ErrInvalidUserID
+	}
+	if original.IsRetweet() {
+		return nil, ErrCannotReshare
 	}
 
+	id := generateID()
 	return &Tweet{
-		ID:        generateID(),
-		UserID:    userID,
-		Content:   content,
-		CreatedAt: time.Now(),
+		ID:                id,
+		UserID:            userID,
+		Kind:              KindRetweet,
+		ConversationID:    id,
+		ReferencedTweetID: original.ID,
+		CreatedAt:         time.Now(),
 	}, nil
 }
 
+// NewQuote creates a tweet with commentary on quoted. Retweets can not be
+// quoted, the tweet they reshare must be quoted instead
+func NewQuote(userID, content string, quoted *Tweet) (*Tweet, error) {
+	if quoted.IsRetweet() {
+		return nil, ErrCannotReshare
+	}
+
+	tweet, err := NewTweet(userID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	tweet.Kind = KindQuote
+	tweet.ReferencedTweetID = quoted.ID
+	return tweet, nil
+}
+
+// NewReply creates a tweet answering parent, in the same conversation
+func NewReply(userID, content string, parent *Tweet) (*Tweet, error) {
+	tweet, err := NewTweet(userID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	tweet.InReplyToTweetID = parent.ID
+	tweet.ConversationID = parent.Conversation()
+	return tweet, nil
+}
+
+// Conversation returns the ID of the conversation of the tweet. Tweets
+// stored before conversations existed start their own
+func (t *Tweet) Conversation() string {
+	if t.ConversationID == "" {
+		return t.ID
+	}
+	return t.ConversationID
+}
+
+// IsReply reports whether the tweet answers another one
+func (t *Tweet) IsReply() bool {
+	return t.InReplyToTweetID != ""
+}
+
+// TweetKind returns the kind of the tweet. Tweets stored before kinds
+// existed are plain tweets
+func (t *Tweet) TweetKind() string {
+	if t.Kind == "" {
+		return KindTweet
+	}
+	return t.Kind
+}
+
+// IsRetweet reports whether the tweet is a reshare without content
+func (t *Tweet) IsRetweet() bool {
+	return t.Kind == KindRetweet
+}
+
+// IsQuote reports whether the tweet reshares another one with commentary
+func (t *Tweet) IsQuote() bool {
+	return t.Kind == KindQuote
+}
+
+// DisplayedTweetID returns the ID of the tweet shown to readers: the
+// reshared tweet for retweets, the tweet itself otherwise
+func (t *Tweet) DisplayedTweetID() string {
+	if t.IsRetweet() {
+		return t.ReferencedTweetID
+	}
+	return t.ID
+}
+
 // IsValid verifies if the tweet is valid
 func (t *Tweet) IsValid() bool {
-	return t.ID != "" &&
-		t.UserID != "" &&
-		t.Content != "" &&
-		len(t.Content) <= MaxTweetLength
+	if t.ID == "" || t.UserID == "" {
+		return false
+	}
+	if t.IsRetweet() {
+		return t.Content == "" && t.ReferencedTweetID != ""
+	}
+	return t.Content != "" && WeightedLength(t.Content) <= MaxTweetLength
+}
+
+// Before reports whether the tweet goes before other in a timeline
+// (most recent first, ties broken by ID)
+func (t *Tweet) Before(other *Tweet) bool {
+	if !t.CreatedAt.Equal(other.CreatedAt) {
+		return t.CreatedAt.After(other.CreatedAt)
+	}
+	return t.ID > other.ID
 }
@@ -1,12 +1,37 @@
 package domain
 
-import "time"
+import (
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
 
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Location    string    `json:"location"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Profile contains the editable fields of a user
+type Profile struct {
+	DisplayName string
+	Bio         string
+	AvatarURL   string
+	Location    string
+}
+
+// ProfileUpdate is a partial profile edit: nil fields are left unchanged
+type ProfileUpdate struct {
+	DisplayName *string
+	Bio         *string
+	AvatarURL   *string
+	Location    *string
 }
 
 // NewUser creates a new user
@@ -18,6 +43,102 @@ func NewUser(id, username string) *User {
 	}
 }
 
+// RegisterUser creates a new user with a generated ID after validating
+// the username and profile
+func RegisterUser(username string, profile Profile) (*User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	user := NewUser(generateID(), username)
+	if err := user.SetProfile(profile); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ValidateUsername verifies that a username has 1 to MaxUsernameLength
+// ASCII letters, digits or underscores
+func ValidateUsername(username string) error {
+	if username == "" || len(username) > MaxUsernameLength {
+		return ErrInvalidUsername
+	}
+	for _, c := range username {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && c != '_' {
+			return ErrInvalidUsername
+		}
+	}
+	return nil
+}
+
+// NormalizeUsername returns the form used to compare usernames (case-insensitive)
+func NormalizeUsername(username string) string {
+	return strings.ToLower(username)
+}
+
+// Profile returns the editable fields of the user
+func (u *User) Profile() Profile {
+	return Profile{
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		Location:    u.Location,
+	}
+}
+
+// SetProfile validates and replaces the editable fields of the user
+func (u *User) SetProfile(profile Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+
+	u.DisplayName = profile.DisplayName
+	u.Bio = profile.Bio
+	u.AvatarURL = profile.AvatarURL
+	u.Location = profile.Location
+	return nil
+}
+
+// Validate verifies the length of the fields and the avatar URL
+func (p Profile) Validate() error {
+	if utf8.RuneCountInString(p.DisplayName) > MaxDisplayNameLength ||
+		utf8.RuneCountInString(p.Bio) > MaxBioLength ||
+		utf8.RuneCountInString(p.Location) > MaxLocationLength {
+		return ErrProfileTooLong
+	}
+
+	if p.AvatarURL != "" {
+		if len(p.AvatarURL) > MaxAvatarURLLength {
+			return ErrInvalidAvatarURL
+		}
+		parsed, err := url.Parse(p.AvatarURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return ErrInvalidAvatarURL
+		}
+	}
+
+	return nil
+}
+
+// Apply returns the profile with the update applied
+func (p Profile) Apply(update ProfileUpdate) Profile {
+	if update.DisplayName != nil {
+		p.DisplayName = *update.DisplayName
+	}
+	if update.Bio != nil {
+		p.Bio = *update.Bio
+	}
+	if update.AvatarURL != nil {
+		p.AvatarURL = *update.AvatarURL
+	}
+	if update.Location != nil {
+		p.Location = *update.Location
+	}
+	return p
+}
+
 // IsValid verifies if the user is valid
 func (u *User) IsValid() bool {
 	return u.ID != "" && u.Username != ""
@@ -0,0 +1,284 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// webhookEventTypes are the events a webhook can subscribe to: those about
+// the tweets and followers of its owner
+var webhookEventTypes = map[string]bool{
+	EventTweetCreated:   true,
+	EventTweetDeleted:   true,
+	EventUserFollowed:   true,
+	EventUserUnfollowed: true,
+}
+
+// Webhook delivers the events about a user (their tweets and followers) to
+// an URL of a partner integration. Deliveries are signed with Secret, which
+// is never shown again. A webhook whose deliveries keep failing is disabled
+// (DisabledAt is set) until its owner enables it again
+type Webhook struct {
+	ID                  string    `json:"id"`
+	UserID              string    `json:"user_id"`
+	URL                 string    `json:"url"`
+	EventTypes          []string  `json:"event_types"`
+	Secret              string    `json:"secret"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledAt          time.Time `json:"disabled_at"` // zero while enabled
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// NewWebhook creates a webhook of a user for some event types. URLs whose
+// host is localhost or a non public address (see IsPublicAddr) are
+// rejected unless allowPrivate, meant for tests and local development.
+// Host names can resolve to any address, so the sender checks it again
+// when connecting
+func NewWebhook(userID, rawURL string, eventTypes []string, secret string, allowPrivate bool) (*Webhook, error) {
+	if userID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	if len(rawURL) > MaxWebhookURLLength {
+		return nil, ErrInvalidWebhookURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if !allowPrivate && !isPublicHost(parsed.Hostname()) {
+		return nil, ErrWebhookURLNotPublic
+	}
+
+	if len(secret) < MinWebhookSecretLength || len(secret) > MaxWebhookSecretLength {
+		return nil, ErrInvalidWebhookSecret
+	}
+
+	eventTypes, err = normalizeWebhookEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Webhook{
+		ID:         generateID(),
+		UserID:     userID,
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// IsPublicAddr reports whether webhooks can be delivered to an address:
+// loopback, private, link-local, multicast and unspecified addresses
+// (including IPv4 ones mapped to IPv6) belong to the internal network
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// isPublicHost reports whether a URL host can be public, which host names
+// other than localhost can
+func isPublicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+	return IsPublicAddr(addr)
+}
+
+// IsEnabled reports whether the webhook gets deliveries
+func (w *Webhook) IsEnabled() bool {
+	return w.DisabledAt.IsZero()
+}
+
+// Subscribes reports whether the webhook gets the events of a type
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, subscribed := range w.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordSuccess returns the webhook after a successful delivery, which
+// resets its failures. The webhook itself is not modified
+func (w *Webhook) RecordSuccess() *Webhook {
+	updated := *w
+	updated.ConsecutiveFailures = 0
+	return &updated
+}
+
+// RecordFailure returns the webhook after a delivery that failed every
+// attempt, disabled once disableAfter deliveries in a row failed. The
+// webhook itself is not modified
+func (w *Webhook) RecordFailure(disableAfter int, now time.Time) *Webhook {
+	updated := *w
+	updated.ConsecutiveFailures++
+	if updated.IsEnabled() && updated.ConsecutiveFailures >= disableAfter {
+		updated.DisabledAt = now
+	}
+	return &updated
+}
+
+// Enable returns the webhook enabled again, with its failures reset. The
+// webhook itself is not modified
+func (w *Webhook) Enable() *Webhook {
+	updated := *w
+	updated.ConsecutiveFailures = 0
+	updated.DisabledAt = time.Time{}
+	return &updated
+}
+
+// WebhookOwner returns the user whose webhooks get an event: the author
+// of a created or deleted tweet, or the followed user. It is empty for
+// events webhooks can not subscribe to
+func WebhookOwner(event Event) string {
+	switch e := event.(type) {
+	case TweetCreated:
+		return e.Tweet.UserID
+	case TweetDeleted:
+		return e.Tweet.UserID
+	case UserFollowed:
+		return e.FolloweeID
+	case UserUnfollowed:
+		return e.FolloweeID
+	}
+	return ""
+}
+
+// WebhookMessage is the signed request of an attempt to deliver an event.
+// Signature is "sha256=" and the hex HMAC-SHA256, keyed with the secret of
+// the webhook, of the timestamp (unix seconds), a dot and the body, so
+// receivers can check both the origin and the freshness of the request
+type WebhookMessage struct {
+	EventID   string
+	EventType string
+	Body      []byte
+	Timestamp time.Time
+	Signature string
+}
+
+// webhookBody is the JSON body of a webhook request
+type webhookBody struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       Event     `json:"data"`
+}
+
+// NewWebhookMessage encodes and signs an event for a webhook
+func NewWebhookMessage(webhook *Webhook, event Event, now time.Time) (*WebhookMessage, error) {
+	body, err := json.Marshal(webhookBody{
+		ID:         event.EventID(),
+		Type:       event.EventType(),
+		OccurredAt: event.OccurredAt(),
+		Data:       event,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := now.Truncate(time.Second)
+	return &WebhookMessage{
+		EventID:   event.EventID(),
+		EventType: event.EventType(),
+		Body:      body,
+		Timestamp: timestamp,
+		Signature: SignWebhook(webhook.Secret, timestamp, body),
+	}, nil
+}
+
+// SignWebhook computes the signature of a webhook request body
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature of a webhook request in constant time
+func VerifyWebhook(secret string, timestamp time.Time, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook.
+// StatusCode is 0 if no response was received, and Error is empty if the
+// attempt succeeded
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhook_id"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewWebhookDelivery records the result of an attempt to deliver a message
+func NewWebhookDelivery(webhookID string, message *WebhookMessage, attempt, statusCode int, err error) *WebhookDelivery {
+	delivery := &WebhookDelivery{
+		ID:         generateID(),
+		WebhookID:  webhookID,
+		EventID:    message.EventID,
+		EventType:  message.EventType,
+		Attempt:    attempt,
+		StatusCode: statusCode,
+		CreatedAt:  time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return delivery
+}
+
+// Succeeded reports whether the attempt delivered the event
+func (d *WebhookDelivery) Succeeded() bool {
+	return d.Error == ""
+}
+
+// normalizeWebhookEventTypes validates, deduplicates and sorts a list of
+// event types
+func normalizeWebhookEventTypes(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return nil, ErrInvalidWebhookEvents
+	}
+
+	seen := make(map[string]bool, len(eventTypes))
+	normalized := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !webhookEventTypes[eventType] {
+			return nil, ErrInvalidWebhookEvents
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			normalized = append(normalized, eventType)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
@@ -2,33 +2,152 @@ package ports
 
 import (
 	"context"
+	"time"
 	"twitter-clone-backend/internal/domain"
 )
 
-// TweetRepository defines operations for tweets
+// TweetRepository defines operations for tweets.
+// Lists of tweets are returned most recent first, windowed by a PageQuery,
+// except GetConversation, which returns up to limit tweets oldest first.
+// A user retweets a tweet once: CreateRetweet fails with
+// domain.ErrAlreadyRetweeted atomically, and GetRetweet with
+// domain.ErrNotRetweeted. GetRetweets lists every retweet of a tweet, in no
+// particular order. GetMentions lists the tweets that mention a user,
+// indexed on Create from the resolved mentions of their entities. Count
+// methods leave out tweets without any.
+// Writes store the given events in the outbox atomically with the change,
+// only if the change is made
 type TweetRepository interface {
-	Create(ctx context.Context, tweet *domain.Tweet) error
+	Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error
 	GetByID(ctx context.Context, id string) (*domain.Tweet, error)
-	GetByUserID(ctx context.Context, userID string) ([]*domain.Tweet, error)
-	GetTimeline(ctx context.Context, userIDs []string, limit int) ([]*domain.Tweet, error)
-	Delete(ctx context.Context, id string) error
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Tweet, error)
+	GetByUserID(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error)
+	GetTimeline(ctx context.Context, userIDs []string, page domain.PageQuery) ([]*domain.Tweet, error)
+	GetMentions(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error)
+	GetReplies(ctx context.Context, tweetID string, page domain.PageQuery) ([]*domain.Tweet, error)
+	GetConversation(ctx context.Context, conversationID string, limit int) ([]*domain.Tweet, error)
+	CountReplies(ctx context.Context, tweetIDs []string) (map[string]int, error)
+	CreateRetweet(ctx context.Context, retweet *domain.Tweet, events ...domain.Event) error
+	GetRetweet(ctx context.Context, userID, tweetID string) (*domain.Tweet, error)
+	GetRetweets(ctx context.Context, tweetID string) ([]*domain.Tweet, error)
+	CountRetweets(ctx context.Context, tweetIDs []string) (map[string]int, error)
+	CountQuotes(ctx context.Context, tweetIDs []string) (map[string]int, error)
+	Delete(ctx context.Context, id string, events ...domain.Event) error
 }
 
-// FollowRepository defines operations related to following
+// FollowRepository defines operations related to following and blocking.
+// FollowIfNotExists and UnfollowIfExists store the given events in the
+// outbox atomically with the change, only if the change is made. A user
+// blocks another once: Block fails with domain.ErrAlreadyBlocked and
+// Unblock with domain.ErrNotBlocked, both atomically
 type FollowRepository interface {
 	Follow(ctx context.Context, followerID, followeeID string) error
-	FollowIfNotExists(ctx context.Context, followerID, followeeID string) error
+	FollowIfNotExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error
 	Unfollow(ctx context.Context, followerID, followeeID string) error
-	UnfollowIfExists(ctx context.Context, followerID, followeeID string) error
+	UnfollowIfExists(ctx context.Context, followerID, followeeID string, events ...domain.Event) error
 	GetFollowers(ctx context.Context, userID string) ([]string, error)
+	CountFollowers(ctx context.Context, userID string) (int, error)
 	GetFollowing(ctx context.Context, userID string) ([]string, error)
 	IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error)
+	Block(ctx context.Context, block *domain.Block) error
+	Unblock(ctx context.Context, blockerID, blockedID string) error
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
 }
 
-// UserRepository defines operations for users
+// LikeRepository defines operations for likes. A user likes a tweet once:
+// LikeIfNotExists fails with domain.ErrAlreadyLiked and UnlikeIfExists with
+// domain.ErrNotLiked, both atomically. GetLikes returns the likes of a user
+// most recent first, windowed by a PageQuery over (like time, tweet ID).
+// CountLikes leaves out tweets without any. Writes store the given events
+// in the outbox atomically with the change, only if the change is made
+type LikeRepository interface {
+	LikeIfNotExists(ctx context.Context, like *domain.Like, events ...domain.Event) error
+	UnlikeIfExists(ctx context.Context, userID, tweetID string, events ...domain.Event) error
+	GetLikes(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Like, error)
+	CountLikes(ctx context.Context, tweetIDs []string) (map[string]int, error)
+	GetLikedTweetIDs(ctx context.Context, userID string, tweetIDs []string) (map[string]bool, error)
+}
+
+// NotificationRepository defines operations for notifications. Notify
+// groups an event into the recipient's unread notification of the same type
+// and tweet, or stores it as a new one, atomically. GetNotifications returns
+// them most recently updated first, windowed by a PageQuery over (update
+// time, ID). MarkRead marks as read the notifications of a user up to a
+// position, included, or all of them when upTo is nil
+type NotificationRepository interface {
+	Notify(ctx context.Context, event *domain.Notification) error
+	GetNotifications(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Notification, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) error
+}
+
+// OutboxRepository reads the domain events written with the changes they
+// describe. PendingEvents returns up to limit entries not yet relayed that
+// are due, oldest first, and MarkRelayed removes them from the outbox.
+// ScheduleRetry counts a failed relay of an entry and leaves it out of
+// PendingEvents until the given time (an entry already removed is ignored)
+type OutboxRepository interface {
+	PendingEvents(ctx context.Context, limit int) ([]*domain.OutboxEntry, error)
+	MarkRelayed(ctx context.Context, ids []string) error
+	ScheduleRetry(ctx context.Context, id string, at time.Time) error
+}
+
+// UserRepository defines operations for users.
+// Usernames are unique ignoring case: CreateUser fails with
+// domain.ErrUsernameTaken atomically, and lookups by username ignore case
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) error
+	UpdateUser(ctx context.Context, user *domain.User) error
 	GetUserByID(ctx context.Context, id string) (*domain.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*domain.User, error)
 	Exists(ctx context.Context, id string) (bool, error)
 }
+
+// CredentialRepository stores the password credentials of the users
+type CredentialRepository interface {
+	SetCredentials(ctx context.Context, credentials *domain.Credentials) error
+	GetCredentials(ctx context.Context, userID string) (*domain.Credentials, error)
+	DeleteCredentials(ctx context.Context, userID string) error
+}
+
+// APIKeyRepository stores the API keys of the users. Lists are returned
+// newest first; deleting a key of another user fails with
+// domain.ErrAPIKeyNotFound, and touching a missing key is a no-op
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// WebhookRepository stores the webhooks of the users and the log of their
+// delivery attempts. Lists are returned newest first, and the log keeps the
+// last domain.MaxWebhookDeliveries attempts of each webhook. Updating a
+// missing webhook or deleting one of another user fails with
+// domain.ErrWebhookNotFound; deleting a webhook deletes its log too, and
+// later attempts of a deleted webhook are not logged
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *domain.Webhook) error
+	GetWebhook(ctx context.Context, id string) (*domain.Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// TimelineStore keeps precomputed home timelines (fan-out on write).
+// Entries are ordered most recent first and bounded in size. A timeline only
+// exists once it has been built; Add is ignored for users without one.
+// Authors removed while a timeline is being rebuilt are filtered out of the
+// rebuilt entries, which may have been read before the removal
+type TimelineStore interface {
+	Get(ctx context.Context, userID string, page domain.PageQuery) (entries []domain.TimelineEntry, found bool, err error)
+	StartRebuild(ctx context.Context, userID string) error
+	CompleteRebuild(ctx context.Context, userID string, entries []domain.TimelineEntry) error
+	Add(ctx context.Context, userID string, entries ...domain.TimelineEntry) error
+	Remove(ctx context.Context, userID, tweetID string) error
+	RemoveAuthor(ctx context.Context, userID, authorID string) error
+	Invalidate(ctx context.Context, userID string) error
+}
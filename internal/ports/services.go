@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 	"twitter-clone-backend/internal/domain"
 )
 
@@ -12,6 +13,32 @@ type CacheService interface {
 	InvalidateTimeline(ctx context.Context, userID string) error
 }
 
+// TokenService signs and verifies authentication tokens
+type TokenService interface {
+	Sign(claims domain.TokenClaims) (string, error)
+	Verify(token string) (*domain.TokenClaims, error)
+}
+
+// PasswordHasher hashes passwords with a slow, salted algorithm.
+// Hashes are self-describing, so parameters can change over time
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+}
+
+// RevocationList keeps the tokens revoked before they expire. Entries can
+// be dropped once the token would have expired anyway. Token families are
+// revoked by their ID like tokens. Consume revokes a token and reports
+// whether it was not revoked yet, atomically: of concurrent calls for the
+// same token only one gets true
+type RevocationList interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	Consume(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	RevokeUser(ctx context.Context, userID string, issuedBefore time.Time) error
+	RevokedBefore(ctx context.Context, userID string) (time.Time, error)
+}
+
 // Logger defines logging operations
 type Logger interface {
 	Info(msg string, args ...interface{})
@@ -19,3 +46,29 @@ type Logger interface {
 	Debug(msg string, args ...interface{})
 	Warn(msg string, args ...interface{})
 }
+
+// WebhookSender sends a signed message to the URL of a webhook. It returns
+// the status code of the response (0 if there was none), and an error
+// unless the receiver accepted the message with a 2xx status
+type WebhookSender interface {
+	Send(ctx context.Context, url string, message *domain.WebhookMessage) (int, error)
+}
+
+// EventPublisher publishes domain events to their subscribers, once the
+// change they describe is persisted
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
+
+// EventBus is an EventPublisher that can wait for its subscribers to
+// handle the published events. Flush returns the IDs of the events
+// published since the previous Flush that some subscriber failed to handle
+type EventBus interface {
+	EventPublisher
+	Flush(ctx context.Context) (failed []string, err error)
+}
+
+// EventHandler handles a domain event delivered to a subscriber. A handler
+// returning an error gets the event again, and events are delivered at
+// least once, so it must be idempotent
+type EventHandler func(ctx context.Context, event domain.Event) error
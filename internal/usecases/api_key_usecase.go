@@ -0,0 +1,97 @@
+package usecases
+
+import (
+	"context"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// apiKeyTouchInterval is the resolution of the last-used time of API keys,
+// so busy keys do not write on every request
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyUseCase manages the API keys of the users and authenticates
+// requests made with them
+type APIKeyUseCase struct {
+	apiKeyRepo ports.APIKeyRepository
+	logger     ports.Logger
+}
+
+// NewAPIKeyUseCase creates a new instance of the use case
+func NewAPIKeyUseCase(apiKeyRepo ports.APIKeyRepository, logger ports.Logger) *APIKeyUseCase {
+	return &APIKeyUseCase{
+		apiKeyRepo: apiKeyRepo,
+		logger:     logger,
+	}
+}
+
+// CreateAPIKey creates an API key for a user. The plain key is returned
+// only here, it can not be recovered later
+func (uc *APIKeyUseCase) CreateAPIKey(ctx context.Context, userID, name string, scopes []string) (*domain.APIKey, string, error) {
+	key, plain, err := domain.NewAPIKey(userID, name, scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := uc.apiKeyRepo.CreateAPIKey(ctx, key); err != nil {
+		uc.logger.Error("failed to create API key", err, "userID", userID)
+		return nil, "", err
+	}
+
+	uc.logger.Info("API key created", "userID", userID, "keyID", key.ID, "scopes", key.Scopes)
+	return key, plain, nil
+}
+
+// ListAPIKeys returns the API keys of a user, newest first
+func (uc *APIKeyUseCase) ListAPIKeys(ctx context.Context, userID string) ([]*domain.APIKey, error) {
+	keys, err := uc.apiKeyRepo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to list API keys", err, "userID", userID)
+	}
+	return keys, err
+}
+
+// RevokeAPIKey deletes an API key of a user
+func (uc *APIKeyUseCase) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	if err := uc.apiKeyRepo.DeleteAPIKey(ctx, userID, keyID); err != nil {
+		if err != domain.ErrAPIKeyNotFound {
+			uc.logger.Error("failed to delete API key", err, "userID", userID, "keyID", keyID)
+		}
+		return err
+	}
+
+	uc.logger.Info("API key revoked", "userID", userID, "keyID", keyID)
+	return nil
+}
+
+// Authenticate verifies a plain API key and records its use. Any failure
+// to match is reported as domain.ErrInvalidToken
+func (uc *APIKeyUseCase) Authenticate(ctx context.Context, plain string) (*domain.APIKey, error) {
+	keyID, ok := domain.ParseAPIKeyID(plain)
+	if !ok {
+		return nil, domain.ErrInvalidToken
+	}
+
+	key, err := uc.apiKeyRepo.GetAPIKey(ctx, keyID)
+	if err == domain.ErrAPIKeyNotFound {
+		return nil, domain.ErrInvalidToken
+	}
+	if err != nil {
+		uc.logger.Error("failed to get API key", err, "keyID", keyID)
+		return nil, err
+	}
+	if !key.Matches(plain) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Recording the use is best effort, it never fails the request
+	now := time.Now()
+	if now.Sub(key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := uc.apiKeyRepo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			uc.logger.Warn("failed to record API key use", "error", err, "keyID", key.ID)
+		}
+	}
+
+	return key, nil
+}
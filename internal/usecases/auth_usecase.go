@@ -0,0 +1,416 @@
+package usecases
+
+import (
+	"context"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// Default token lifetimes and login limits
+const (
+	DefaultAccessTokenTTL        = 15 * time.Minute
+	DefaultRefreshTokenTTL       = 30 * 24 * time.Hour
+	DefaultMaxLoginAttempts      = 5
+	DefaultMaxLoginAttemptsPerIP = 50
+	DefaultLoginThrottleWindow   = 15 * time.Minute
+)
+
+// AuthConfig contains the token settings and the login limits
+type AuthConfig struct {
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration
+	MaxLoginAttempts      int // per username and window
+	MaxLoginAttemptsPerIP int // per client IP and window
+	LoginThrottleWindow   time.Duration
+}
+
+// AuthUseCase logs users in and out, and issues and verifies authentication
+// tokens. Access tokens are short-lived; refresh tokens obtain a new pair of
+// tokens and are rotated on use. The tokens of a session form a family:
+// reusing a refresh token revokes the whole family, as it was stolen by
+// someone. Revoked tokens are kept in a revocation list until they expire
+type AuthUseCase struct {
+	tokens                ports.TokenService
+	userRepo              ports.UserRepository
+	credentials           ports.CredentialRepository
+	hasher                ports.PasswordHasher
+	revocations           ports.RevocationList
+	logger                ports.Logger
+	throttle              *loginThrottle
+	accessTokenTTL        time.Duration
+	refreshTokenTTL       time.Duration
+	maxLoginAttempts      int
+	maxLoginAttemptsPerIP int
+
+	// dummyHash is verified for unknown users, so a login takes the same
+	// time whether the username exists or not
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+// NewAuthUseCase creates a new instance of the use case
+func NewAuthUseCase(
+	tokens ports.TokenService,
+	userRepo ports.UserRepository,
+	credentials ports.CredentialRepository,
+	hasher ports.PasswordHasher,
+	revocations ports.RevocationList,
+	logger ports.Logger,
+	cfg AuthConfig,
+) *AuthUseCase {
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	if cfg.MaxLoginAttempts <= 0 {
+		cfg.MaxLoginAttempts = DefaultMaxLoginAttempts
+	}
+	if cfg.MaxLoginAttemptsPerIP <= 0 {
+		cfg.MaxLoginAttemptsPerIP = DefaultMaxLoginAttemptsPerIP
+	}
+	if cfg.LoginThrottleWindow <= 0 {
+		cfg.LoginThrottleWindow = DefaultLoginThrottleWindow
+	}
+
+	return &AuthUseCase{
+		tokens:                tokens,
+		userRepo:              userRepo,
+		credentials:           credentials,
+		hasher:                hasher,
+		revocations:           revocations,
+		logger:                logger,
+		throttle:              newLoginThrottle(cfg.LoginThrottleWindow),
+		accessTokenTTL:        cfg.AccessTokenTTL,
+		refreshTokenTTL:       cfg.RefreshTokenTTL,
+		maxLoginAttempts:      cfg.MaxLoginAttempts,
+		maxLoginAttemptsPerIP: cfg.MaxLoginAttemptsPerIP,
+	}
+}
+
+// IssueTokens creates a new pair of tokens for a user, in a new family
+func (uc *AuthUseCase) IssueTokens(ctx context.Context, userID string) (*domain.TokenPair, error) {
+	return uc.issueTokens(ctx, domain.NewTokenClaims(userID, domain.TokenUseAccess, uc.accessTokenTTL))
+}
+
+// issueTokens creates a new pair of tokens in the family of the given
+// access token claims
+func (uc *AuthUseCase) issueTokens(ctx context.Context, access domain.TokenClaims) (*domain.TokenPair, error) {
+	userID := access.Subject
+	refresh := domain.NewFamilyTokenClaims(userID, domain.TokenUseRefresh, access.Family, uc.refreshTokenTTL)
+
+	accessToken, err := uc.tokens.Sign(access)
+	if err != nil {
+		uc.logger.Error("failed to sign access token", err, "userID", userID)
+		return nil, err
+	}
+
+	refreshToken, err := uc.tokens.Sign(refresh)
+	if err != nil {
+		uc.logger.Error("failed to sign refresh token", err, "userID", userID)
+		return nil, err
+	}
+
+	return &domain.TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		AccessExpiresAt:  access.ExpiresAt,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// Login checks the password of a user and issues a pair of tokens.
+// Attempts are throttled per username and per client IP
+func (uc *AuthUseCase) Login(ctx context.Context, username, password, clientIP string) (*domain.User, *domain.TokenPair, error) {
+	userKey := "user:" + domain.NormalizeUsername(username)
+	ipKey := "ip:" + clientIP
+	if !uc.throttle.Attempt(
+		throttleKey{key: userKey, limit: uc.maxLoginAttempts},
+		throttleKey{key: ipKey, limit: uc.maxLoginAttemptsPerIP},
+	) {
+		uc.logger.Warn("login throttled", "username", username, "ip", clientIP)
+		return nil, nil, domain.ErrTooManyAttempts
+	}
+
+	user, err := uc.userRepo.GetUserByUsername(ctx, username)
+	if err != nil && err != domain.ErrUserNotFound {
+		uc.logger.Error("failed to get user by username", err, "username", username)
+		return nil, nil, err
+	}
+
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	if err := uc.checkPassword(ctx, userID, password); err != nil {
+		if err == domain.ErrWrongPassword {
+			uc.logger.Warn("failed login", "username", username, "ip", clientIP)
+			return nil, nil, domain.ErrInvalidLogin
+		}
+		return nil, nil, err
+	}
+
+	// Successful logins do not count against the limits
+	uc.throttle.Reset(userKey)
+	uc.throttle.Forgive(ipKey)
+
+	tokens, err := uc.IssueTokens(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uc.logger.Info("user logged in", "userID", user.ID)
+	return user, tokens, nil
+}
+
+// Logout revokes an access token and, if given, the refresh token of the
+// same session. The family of the access token is revoked too, so the
+// tokens obtained by refreshing are not usable either
+func (uc *AuthUseCase) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	access, err := uc.verify(ctx, accessToken, domain.TokenUseAccess)
+	if err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		// An already unusable token has nothing to revoke
+		if refresh, err := uc.verify(ctx, refreshToken, domain.TokenUseRefresh); err == nil {
+			if refresh.Subject != access.Subject {
+				return domain.ErrInvalidToken
+			}
+			if err := uc.revoke(ctx, refresh); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := uc.revoke(ctx, access); err != nil {
+		return err
+	}
+	if err := uc.revokeFamily(ctx, access); err != nil {
+		return err
+	}
+
+	uc.logger.Info("user logged out", "userID", access.Subject)
+	return nil
+}
+
+// ChangePassword replaces the password of a user after checking the
+// current one. Every token issued before is revoked and a new pair is
+// returned for the current session
+func (uc *AuthUseCase) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*domain.TokenPair, error) {
+	if err := domain.ValidatePassword(newPassword); err != nil {
+		return nil, err
+	}
+
+	key := "password:" + userID
+	if !uc.throttle.Attempt(throttleKey{key: key, limit: uc.maxLoginAttempts}) {
+		uc.logger.Warn("password change throttled", "userID", userID)
+		return nil, domain.ErrTooManyAttempts
+	}
+
+	if err := uc.checkPassword(ctx, userID, currentPassword); err != nil {
+		return nil, err
+	}
+	uc.throttle.Reset(key)
+
+	hash, err := uc.hasher.Hash(newPassword)
+	if err != nil {
+		uc.logger.Error("failed to hash password", err, "userID", userID)
+		return nil, err
+	}
+
+	if err := uc.credentials.SetCredentials(ctx, domain.NewCredentials(userID, hash)); err != nil {
+		uc.logger.Error("failed to store credentials", err, "userID", userID)
+		return nil, err
+	}
+
+	// Token times have a resolution of seconds: revoke up to the current
+	// second so the new pair below stays valid
+	if err := uc.revocations.RevokeUser(ctx, userID, time.Now().Truncate(time.Second)); err != nil {
+		uc.logger.Error("failed to revoke tokens", err, "userID", userID)
+		return nil, err
+	}
+
+	uc.logger.Info("password changed", "userID", userID)
+	return uc.IssueTokens(ctx, userID)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens of the same
+// family. The refresh token is consumed atomically, so each one can only be
+// used once even by concurrent requests. Using it again means it leaked:
+// its whole family is revoked
+func (uc *AuthUseCase) Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+	claims, err := uc.tokens.Verify(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Use != domain.TokenUseRefresh {
+		return nil, domain.ErrInvalidToken
+	}
+	if err := uc.checkFamily(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	// Tokens of deleted users are not renewed
+	exists, err := uc.userRepo.Exists(ctx, claims.Subject)
+	if err != nil {
+		uc.logger.Error("failed to check user existence", err, "userID", claims.Subject)
+		return nil, err
+	}
+	if !exists {
+		return nil, domain.ErrInvalidToken
+	}
+
+	consumed, err := uc.revocations.Consume(ctx, claims.ID, claims.ExpiresAt)
+	if err != nil {
+		uc.logger.Error("failed to revoke token", err, "userID", claims.Subject)
+		return nil, err
+	}
+	if !consumed {
+		uc.logger.Warn("refresh token reused, revoking its family", "userID", claims.Subject)
+		if err := uc.revokeFamily(ctx, claims); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Tokens issued before families existed start one
+	family := claims.Family
+	if family == "" {
+		return uc.IssueTokens(ctx, claims.Subject)
+	}
+	return uc.issueTokens(ctx, domain.NewFamilyTokenClaims(claims.Subject, domain.TokenUseAccess, family, uc.accessTokenTTL))
+}
+
+// Authenticate verifies an access token and returns the user ID
+func (uc *AuthUseCase) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	claims, err := uc.verify(ctx, accessToken, domain.TokenUseAccess)
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// verify checks a token, its use and that it has not been revoked
+func (uc *AuthUseCase) verify(ctx context.Context, token, use string) (*domain.TokenClaims, error) {
+	claims, err := uc.tokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Use != use {
+		return nil, domain.ErrInvalidToken
+	}
+
+	revoked, err := uc.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		uc.logger.Error("failed to check token revocation", err, "userID", claims.Subject)
+		return nil, err
+	}
+	if revoked {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err := uc.checkFamily(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkFamily checks that neither the family of a token nor the tokens of
+// its user issued before it have been revoked
+func (uc *AuthUseCase) checkFamily(ctx context.Context, claims *domain.TokenClaims) error {
+	if claims.Family != "" {
+		revoked, err := uc.revocations.IsRevoked(ctx, claims.Family)
+		if err != nil {
+			uc.logger.Error("failed to check token revocation", err, "userID", claims.Subject)
+			return err
+		}
+		if revoked {
+			return domain.ErrInvalidToken
+		}
+	}
+
+	revokedBefore, err := uc.revocations.RevokedBefore(ctx, claims.Subject)
+	if err != nil {
+		uc.logger.Error("failed to check token revocation", err, "userID", claims.Subject)
+		return err
+	}
+	if claims.IssuedAt.Before(revokedBefore) {
+		return domain.ErrInvalidToken
+	}
+	return nil
+}
+
+// revoke adds a token to the revocation list until it expires
+func (uc *AuthUseCase) revoke(ctx context.Context, claims *domain.TokenClaims) error {
+	if err := uc.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		uc.logger.Error("failed to revoke token", err, "userID", claims.Subject)
+		return err
+	}
+	return nil
+}
+
+// revokeFamily revokes every token of the family of a token. No token of
+// the family outlives a refresh token issued now
+func (uc *AuthUseCase) revokeFamily(ctx context.Context, claims *domain.TokenClaims) error {
+	if claims.Family == "" {
+		return nil
+	}
+	if err := uc.revocations.Revoke(ctx, claims.Family, time.Now().Add(uc.refreshTokenTTL)); err != nil {
+		uc.logger.Error("failed to revoke token family", err, "userID", claims.Subject)
+		return err
+	}
+	return nil
+}
+
+// checkPassword verifies the password of a user, returning
+// domain.ErrWrongPassword if it does not match. Unknown users and users
+// without credentials are checked against a dummy hash to take the same time
+func (uc *AuthUseCase) checkPassword(ctx context.Context, userID, password string) error {
+	hash := ""
+	if userID != "" {
+		credentials, err := uc.credentials.GetCredentials(ctx, userID)
+		if err != nil && err != domain.ErrNoCredentials {
+			uc.logger.Error("failed to get credentials", err, "userID", userID)
+			return err
+		}
+		if credentials != nil {
+			hash = credentials.PasswordHash
+		}
+	}
+
+	if hash == "" {
+		uc.verifyDummyHash(password)
+		return domain.ErrWrongPassword
+	}
+
+	match, err := uc.hasher.Verify(password, hash)
+	if err != nil {
+		uc.logger.Error("failed to verify password", err, "userID", userID)
+		return err
+	}
+	if !match {
+		return domain.ErrWrongPassword
+	}
+	return nil
+}
+
+// verifyDummyHash spends the time of a password verification
+func (uc *AuthUseCase) verifyDummyHash(password string) {
+	uc.dummyHashOnce.Do(func() {
+		hash, err := uc.hasher.Hash("dummy password")
+		if err != nil {
+			uc.logger.Warn("failed to create dummy password hash", "error", err)
+			return
+		}
+		uc.dummyHash = hash
+	})
+
+	if uc.dummyHash != "" {
+		uc.hasher.Verify(password, uc.dummyHash)
+	}
+}
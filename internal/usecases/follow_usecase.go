@@ -10,7 +10,6 @@ import (
 type FollowUseCase struct {
 	followRepo ports.FollowRepository
 	userRepo   ports.UserRepository
-	cache      ports.CacheService
 	logger     ports.Logger
 }
 
@@ -18,13 +17,11 @@ type FollowUseCase struct {
 func NewFollowUseCase(
 	followRepo ports.FollowRepository,
 	userRepo ports.UserRepository,
-	cache ports.CacheService,
 	logger ports.Logger,
 ) *FollowUseCase {
 	return &FollowUseCase{
 		followRepo: followRepo,
 		userRepo:   userRepo,
-		cache:      cache,
 		logger:     logger,
 	}
 }
@@ -56,8 +53,18 @@ func (uc *FollowUseCase) FollowUser(ctx context.Context, followerID, followeeID
 		return domain.ErrUserNotFound
 	}
 
+	blocked, err := uc.followRepo.IsBlocked(ctx, followeeID, followerID)
+	if err != nil {
+		uc.logger.Error("failed to check block", err, "blockerID", followeeID, "blockedID", followerID)
+		return err
+	}
+	if blocked {
+		return domain.ErrBlocked
+	}
+
 	// Atomic operation: verify + create in a single transaction
-	if err := uc.followRepo.FollowIfNotExists(ctx, follow.FollowerID, follow.FolloweeID); err != nil {
+	event := domain.NewUserFollowed(followerID, followeeID)
+	if err := uc.followRepo.FollowIfNotExists(ctx, follow.FollowerID, follow.FolloweeID, event); err != nil {
 		if err == domain.ErrAlreadyFollowing {
 			return err
 		}
@@ -65,15 +72,6 @@ func (uc *FollowUseCase) FollowUser(ctx context.Context, followerID, followeeID
 		return err
 	}
 
-	// Invalidate follower's timeline cache asynchronously (not critical path)
-	if uc.cache != nil {
-		go func() {
-			if err := uc.cache.InvalidateTimeline(context.Background(), followerID); err != nil {
-				uc.logger.Warn("failed to invalidate timeline cache", "error", err, "followerID", followerID)
-			}
-		}()
-	}
-
 	uc.logger.Info("user followed successfully", "followerID", followerID, "followeeID", followeeID)
 	return nil
 }
@@ -89,7 +87,8 @@ func (uc *FollowUseCase) UnfollowUser(ctx context.Context, followerID, followeeI
 	}
 
 	// Atomic operation: verify + delete in a single transaction
-	if err := uc.followRepo.UnfollowIfExists(ctx, followerID, followeeID); err != nil {
+	event := domain.NewUserUnfollowed(followerID, followeeID)
+	if err := uc.followRepo.UnfollowIfExists(ctx, followerID, followeeID, event); err != nil {
 		if err == domain.ErrNotFollowing {
 			return err
 		}
@@ -97,16 +96,67 @@ func (uc *FollowUseCase) UnfollowUser(ctx context.Context, followerID, followeeI
 		return err
 	}
 
-	// Invalidate follower's timeline cache asynchronously (not critical path)
-	if uc.cache != nil {
-		go func() {
-			if err := uc.cache.InvalidateTimeline(context.Background(), followerID); err != nil {
-				uc.logger.Warn("failed to invalidate timeline cache", "error", err, "followerID", followerID)
-			}
-		}()
+	uc.logger.Info("user unfollowed successfully", "followerID", followerID, "followeeID", followeeID)
+	return nil
+}
+
+// BlockUser allows a user to block another user. Both stop following
+// each other
+func (uc *FollowUseCase) BlockUser(ctx context.Context, blockerID, blockedID string) error {
+	block, err := domain.NewBlock(blockerID, blockedID)
+	if err != nil {
+		return err
 	}
 
-	uc.logger.Info("user unfollowed successfully", "followerID", followerID, "followeeID", followeeID)
+	exists, err := uc.userRepo.Exists(ctx, blockedID)
+	if err != nil {
+		uc.logger.Error("failed to check blocked user existence", err, "blockedID", blockedID)
+		return err
+	}
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+
+	// Atomic operation: verify + create in a single statement
+	if err := uc.followRepo.Block(ctx, block); err != nil {
+		if err == domain.ErrAlreadyBlocked {
+			return err
+		}
+		uc.logger.Error("failed to block user", err, "blockerID", blockerID, "blockedID", blockedID)
+		return err
+	}
+
+	// Neither follows the other anymore
+	for _, follow := range [][2]string{{blockedID, blockerID}, {blockerID, blockedID}} {
+		if err := uc.UnfollowUser(ctx, follow[0], follow[1]); err != nil && err != domain.ErrNotFollowing {
+			return err
+		}
+	}
+
+	uc.logger.Info("user blocked successfully", "blockerID", blockerID, "blockedID", blockedID)
+	return nil
+}
+
+// UnblockUser allows a user to unblock another user. Unfollows made by
+// the block are not undone
+func (uc *FollowUseCase) UnblockUser(ctx context.Context, blockerID, blockedID string) error {
+	if blockerID == "" || blockedID == "" {
+		return domain.ErrInvalidUserID
+	}
+
+	if blockerID == blockedID {
+		return domain.ErrCannotBlockSelf
+	}
+
+	if err := uc.followRepo.Unblock(ctx, blockerID, blockedID); err != nil {
+		if err == domain.ErrNotBlocked {
+			return err
+		}
+		uc.logger.Error("failed to unblock user", err, "blockerID", blockerID, "blockedID", blockedID)
+		return err
+	}
+
+	uc.logger.Info("user unblocked successfully", "blockerID", blockerID, "blockedID", blockedID)
 	return nil
 }
 
@@ -0,0 +1,389 @@
+package usecases
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// DefaultFanoutWorkers is the number of follower timelines a fan-out
+// updates at a time
+const DefaultFanoutWorkers = 8
+
+// HomeTimelinesConfig contains the fan-out settings
+type HomeTimelinesConfig struct {
+	// Workers is the number of follower timelines a fan-out updates at a time
+	Workers int
+	// CelebrityThreshold is the number of followers from which an author's
+	// tweets are not fanned out but merged at read time (0 disables it)
+	CelebrityThreshold int
+}
+
+// HomeTimelines maintains precomputed home timelines (fan-out on write):
+// new tweets are pushed to every follower's timeline, so reading a
+// timeline only needs the first entries of a list. The fan-out is driven by
+// the domain events (see HandleEvent), so the outbox relays it again until
+// it succeeds.
+//
+// Authors with many followers ("celebrities") use a hybrid strategy: their
+// tweets are not pushed to followers, but merged into the timeline at read time
+type HomeTimelines struct {
+	store              ports.TimelineStore
+	tweetRepo          ports.TweetRepository
+	followRepo         ports.FollowRepository
+	cache              ports.CacheService
+	logger             ports.Logger
+	workers            int
+	celebrityThreshold int
+	celebrities        map[string]bool // authors ever classified as celebrities
+	celebritiesMu      sync.RWMutex
+}
+
+// NewHomeTimelines creates the service
+func NewHomeTimelines(
+	store ports.TimelineStore,
+	tweetRepo ports.TweetRepository,
+	followRepo ports.FollowRepository,
+	cache ports.CacheService,
+	logger ports.Logger,
+	cfg HomeTimelinesConfig,
+) *HomeTimelines {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultFanoutWorkers
+	}
+
+	return &HomeTimelines{
+		store:              store,
+		tweetRepo:          tweetRepo,
+		followRepo:         followRepo,
+		cache:              cache,
+		logger:             logger,
+		workers:            cfg.Workers,
+		celebrityThreshold: cfg.CelebrityThreshold,
+		celebrities:        make(map[string]bool),
+	}
+}
+
+// Publish adds a new tweet to the author's own timeline, so they see it
+// immediately. Followers get it from HandleEvent
+func (h *HomeTimelines) Publish(ctx context.Context, tweet *domain.Tweet) {
+	h.updateTimeline(ctx, tweet.UserID, domain.NewTimelineEntry(tweet), false)
+}
+
+// Retract removes a deleted tweet from the author's own timeline. Followers
+// get the removal from HandleEvent; reads skip deleted tweets anyway, so an
+// entry re-added by a late fan-out is never shown
+func (h *HomeTimelines) Retract(ctx context.Context, tweet *domain.Tweet) {
+	h.updateTimeline(ctx, tweet.UserID, domain.NewTimelineEntry(tweet), true)
+}
+
+// HandleEvent updates the home timelines affected by a domain event: it
+// fans a created tweet out to the author's followers or removes a deleted
+// one from them, and backfills or prunes the follower's timeline when a
+// follow changes. Every update is idempotent, and follow changes are
+// applied only if they still hold, so events relayed again or late are
+// harmless
+func (h *HomeTimelines) HandleEvent(ctx context.Context, event domain.Event) error {
+	switch e := event.(type) {
+	case domain.TweetCreated:
+		return h.fanout(ctx, domain.NewTimelineEntry(e.Tweet), false)
+	case domain.TweetDeleted:
+		return h.fanout(ctx, domain.NewTimelineEntry(e.Tweet), true)
+	case domain.UserFollowed:
+		return h.followed(ctx, e.FollowerID, e.FolloweeID)
+	case domain.UserUnfollowed:
+		return h.unfollowed(ctx, e.FollowerID, e.FolloweeID)
+	}
+	return nil
+}
+
+// Read returns a page of a user's home timeline, rebuilding it from the
+// repositories if it does not exist yet
+func (h *HomeTimelines) Read(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	entries, found, err := h.store.Get(ctx, userID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		entries, err = h.rebuild(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		entries = page.ApplyToEntries(entries)
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.TweetID
+	}
+
+	tweets, err := h.tweetRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Precomputed timelines are bounded: scrolling past their end continues
+	// from the repositories (pull model)
+	if page.MaxID != nil && page.SinceID == nil && page.Limit > 0 && len(entries) < page.Limit {
+		older, err := h.readOlder(ctx, userID, entries, page)
+		if err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, older...)
+	}
+
+	return tweets, nil
+}
+
+// readOlder reads the tweets that follow a partial page of a home timeline
+func (h *HomeTimelines) readOlder(ctx context.Context, userID string, entries []domain.TimelineEntry, page domain.PageQuery) ([]*domain.Tweet, error) {
+	cursor := *page.MaxID
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		cursor = domain.Cursor{CreatedAt: last.CreatedAt, TweetID: last.TweetID}
+	}
+
+	following, err := h.followRepo.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	following = append(following, userID)
+
+	return h.tweetRepo.GetTimeline(ctx, following, domain.PageQuery{
+		Limit: page.Limit - len(entries),
+		MaxID: &cursor,
+	})
+}
+
+// MergeCelebrities merges the tweets of the celebrities followed by a user
+// into a page of their timeline, keeping the same order as the regular path
+func (h *HomeTimelines) MergeCelebrities(ctx context.Context, userID string, tweets []*domain.Tweet, page domain.PageQuery) ([]*domain.Tweet, error) {
+	celebrities, err := h.followedCelebrities(ctx, userID)
+	if err != nil || len(celebrities) == 0 {
+		return tweets, err
+	}
+
+	celebrityTweets, err := h.tweetRepo.GetTimeline(ctx, celebrities, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeTweets(tweets, celebrityTweets, page), nil
+}
+
+// followed backfills the follower's timeline with the followee's recent
+// tweets, unless they unfollowed since
+func (h *HomeTimelines) followed(ctx context.Context, followerID, followeeID string) error {
+	following, err := h.followRepo.IsFollowing(ctx, followerID, followeeID)
+	if err != nil || !following {
+		return err
+	}
+
+	tweets, err := h.tweetRepo.GetTimeline(ctx, []string{followeeID}, domain.PageQuery{Limit: domain.MaxHomeTimelineSize})
+	if err == nil {
+		err = h.store.Add(ctx, followerID, toTimelineEntries(tweets)...)
+	}
+
+	if err != nil {
+		// Drop the timeline so the next read rebuilds it consistently
+		h.logger.Warn("failed to backfill home timeline", "error", err, "followerID", followerID, "followeeID", followeeID)
+		h.invalidate(followerID)
+		return nil
+	}
+	h.invalidateCache(followerID)
+	return nil
+}
+
+// unfollowed prunes the followee's tweets from the follower's timeline,
+// unless they followed again since
+func (h *HomeTimelines) unfollowed(ctx context.Context, followerID, followeeID string) error {
+	following, err := h.followRepo.IsFollowing(ctx, followerID, followeeID)
+	if err != nil || following {
+		return err
+	}
+
+	if err := h.store.RemoveAuthor(ctx, followerID, followeeID); err != nil {
+		h.logger.Warn("failed to prune home timeline", "error", err, "followerID", followerID, "followeeID", followeeID)
+		h.invalidate(followerID)
+		return nil
+	}
+	h.invalidateCache(followerID)
+	return nil
+}
+
+// rebuild computes a timeline from the repositories (pull model) and stores it
+func (h *HomeTimelines) rebuild(ctx context.Context, userID string) ([]domain.TimelineEntry, error) {
+	// Tweets fanned out while rebuilding are kept by the store
+	if err := h.store.StartRebuild(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	following, err := h.followRepo.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	following = append(following, userID)
+
+	tweets, err := h.tweetRepo.GetTimeline(ctx, following, domain.PageQuery{Limit: domain.MaxHomeTimelineSize})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := toTimelineEntries(tweets)
+	if err := h.store.CompleteRebuild(ctx, userID, entries); err != nil {
+		return nil, err
+	}
+
+	h.logger.Debug("home timeline rebuilt", "userID", userID, "entries", len(entries))
+	return entries, nil
+}
+
+// fanout pushes an entry to the timeline of its author and every follower,
+// or removes it from them, updating up to Workers timelines at a time. If
+// the followers cannot be read the error is returned, so the event is
+// handled again (updates are idempotent)
+func (h *HomeTimelines) fanout(ctx context.Context, entry domain.TimelineEntry, removed bool) error {
+	h.updateTimeline(ctx, entry.AuthorID, entry, removed)
+
+	// Removals always reach every follower: their cached timelines may
+	// contain the tweet even if it was merged at read time
+	if h.celebrityThreshold > 0 && !removed {
+		count, err := h.followRepo.CountFollowers(ctx, entry.AuthorID)
+		if err != nil {
+			return err
+		}
+		if count >= h.celebrityThreshold {
+			h.markCelebrity(entry.AuthorID, count)
+			return nil
+		}
+	}
+
+	followers, err := h.followRepo.GetFollowers(ctx, entry.AuthorID)
+	if err != nil {
+		return err
+	}
+
+	next := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(h.workers, len(followers)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for followerID := range next {
+				h.updateTimeline(ctx, followerID, entry, removed)
+			}
+		}()
+	}
+	for _, followerID := range followers {
+		next <- followerID
+	}
+	close(next)
+	wg.Wait()
+	return nil
+}
+
+// updateTimeline adds an entry to a user's timeline or removes it. A
+// timeline that could not be updated is dropped, to be rebuilt on next read
+func (h *HomeTimelines) updateTimeline(ctx context.Context, userID string, entry domain.TimelineEntry, removed bool) {
+	var err error
+	if removed {
+		err = h.store.Remove(ctx, userID, entry.TweetID)
+	} else {
+		err = h.store.Add(ctx, userID, entry)
+	}
+	if err != nil {
+		h.logger.Warn("failed to update home timeline", "error", err, "userID", userID, "tweetID", entry.TweetID)
+		h.invalidate(userID)
+		return
+	}
+	h.invalidateCache(userID)
+}
+
+// markCelebrity records that an author's tweets are merged at read time.
+// Authors stay marked even if they lose followers, so tweets that were not
+// fanned out keep showing up (duplicates are removed when merging)
+func (h *HomeTimelines) markCelebrity(userID string, followers int) {
+	h.celebritiesMu.Lock()
+	defer h.celebritiesMu.Unlock()
+
+	if !h.celebrities[userID] {
+		h.celebrities[userID] = true
+		h.logger.Info("author classified as celebrity, skipping fan-out", "userID", userID, "followers", followers)
+	}
+}
+
+// followedCelebrities returns the celebrities followed by a user
+func (h *HomeTimelines) followedCelebrities(ctx context.Context, userID string) ([]string, error) {
+	h.celebritiesMu.RLock()
+	empty := len(h.celebrities) == 0
+	h.celebritiesMu.RUnlock()
+	if empty {
+		return nil, nil
+	}
+
+	following, err := h.followRepo.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.celebritiesMu.RLock()
+	defer h.celebritiesMu.RUnlock()
+
+	var celebrities []string
+	for _, followeeID := range following {
+		if h.celebrities[followeeID] {
+			celebrities = append(celebrities, followeeID)
+		}
+	}
+	return celebrities, nil
+}
+
+// invalidate drops a timeline (and its cached copy) so it is rebuilt on next read
+func (h *HomeTimelines) invalidate(userID string) {
+	if err := h.store.Invalidate(context.Background(), userID); err != nil {
+		h.logger.Warn("failed to invalidate home timeline", "error", err, "userID", userID)
+	}
+	h.invalidateCache(userID)
+}
+
+// invalidateCache removes the cached copy of a timeline
+func (h *HomeTimelines) invalidateCache(userID string) {
+	if h.cache == nil {
+		return
+	}
+	if err := h.cache.InvalidateTimeline(context.Background(), userID); err != nil {
+		h.logger.Warn("failed to invalidate timeline cache", "error", err, "userID", userID)
+	}
+}
+
+// toTimelineEntries converts tweets into timeline entries
+func toTimelineEntries(tweets []*domain.Tweet) []domain.TimelineEntry {
+	entries := make([]domain.TimelineEntry, len(tweets))
+	for i, tweet := range tweets {
+		entries[i] = domain.NewTimelineEntry(tweet)
+	}
+	return entries
+}
+
+// mergeTweets merges two pages of timelines in timeline order without duplicates
+func mergeTweets(a, b []*domain.Tweet, page domain.PageQuery) []*domain.Tweet {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]*domain.Tweet, 0, len(a)+len(b))
+	for _, list := range [][]*domain.Tweet{a, b} {
+		for _, tweet := range list {
+			if !seen[tweet.ID] {
+				seen[tweet.ID] = true
+				merged = append(merged, tweet)
+			}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Before(merged[j])
+	})
+
+	return page.ApplyToTweets(merged)
+}
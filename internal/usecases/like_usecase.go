@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// LikeUseCase handles business logic related to likes
+type LikeUseCase struct {
+	likeRepo  ports.LikeRepository
+	tweetRepo ports.TweetRepository
+	userRepo  ports.UserRepository
+	logger    ports.Logger
+}
+
+// NewLikeUseCase creates a new instance of the use case
+func NewLikeUseCase(
+	likeRepo ports.LikeRepository,
+	tweetRepo ports.TweetRepository,
+	userRepo ports.UserRepository,
+	logger ports.Logger,
+	opts ...Option,
+) *LikeUseCase {
+	return &LikeUseCase{
+		likeRepo:  likeRepo,
+		tweetRepo: tweetRepo,
+		userRepo:  userRepo,
+		logger:    logger,
+	}
+}
+
+// LikeTweet likes a tweet as the user. Liking a retweet likes the tweet it
+// reshares, and liking a tweet again is a no-op
+func (uc *LikeUseCase) LikeTweet(ctx context.Context, userID, tweetID string) error {
+	tweet, err := uc.tweetRepo.GetByID(ctx, tweetID)
+	if err != nil {
+		if err != domain.ErrTweetNotFound {
+			uc.logger.Error("failed to get tweet", err, "tweetID", tweetID)
+		}
+		return err
+	}
+
+	like, err := domain.NewLike(userID, tweet.DisplayedTweetID())
+	if err != nil {
+		return err
+	}
+
+	// Atomic operation: verify + create in a single step
+	event := domain.NewTweetLiked(like, uc.likedAuthor(ctx, tweet, like))
+	if err := uc.likeRepo.LikeIfNotExists(ctx, like, event); err != nil {
+		if err == domain.ErrAlreadyLiked {
+			return nil
+		}
+		uc.logger.Error("failed to like tweet", err, "tweetID", like.TweetID, "userID", userID)
+		return err
+	}
+
+	uc.logger.Info("tweet liked successfully", "tweetID", like.TweetID, "userID", userID)
+	return nil
+}
+
+// likedAuthor returns the author of the liked tweet, which is the original
+// when a retweet was liked. It is empty if the original cannot be read
+func (uc *LikeUseCase) likedAuthor(ctx context.Context, tweet *domain.Tweet, like *domain.Like) string {
+	if tweet.ID == like.TweetID {
+		return tweet.UserID
+	}
+
+	original, err := uc.tweetRepo.GetByID(ctx, like.TweetID)
+	switch {
+	case err == nil:
+		return original.UserID
+	case err != domain.ErrTweetNotFound:
+		uc.logger.Warn("failed to get liked tweet", "error", err, "tweetID", like.TweetID)
+	}
+	return ""
+}
+
+// UnlikeTweet removes the user's like of a tweet. Unliking a tweet that is
+// not liked is a no-op; tweets deleted after being liked can be unliked too
+func (uc *LikeUseCase) UnlikeTweet(ctx context.Context, userID, tweetID string) error {
+	if userID == "" {
+		return domain.ErrInvalidUserID
+	}
+
+	tweet, err := uc.tweetRepo.GetByID(ctx, tweetID)
+	switch {
+	case err == nil:
+		tweetID = tweet.DisplayedTweetID()
+	case err != domain.ErrTweetNotFound:
+		uc.logger.Error("failed to get tweet", err, "tweetID", tweetID)
+		return err
+	}
+
+	// Atomic operation: verify + delete in a single step
+	event := domain.NewTweetUnliked(userID, tweetID)
+	if err := uc.likeRepo.UnlikeIfExists(ctx, userID, tweetID, event); err != nil {
+		if err == domain.ErrNotLiked {
+			return nil
+		}
+		uc.logger.Error("failed to unlike tweet", err, "tweetID", tweetID, "userID", userID)
+		return err
+	}
+
+	uc.logger.Info("tweet unliked successfully", "tweetID", tweetID, "userID", userID)
+	return nil
+}
+
+// GetUserLikes gets a page of the likes of a user, most recent first, and
+// the liked tweets in the same order. Deleted tweets are left out of the
+// tweets, but their likes still position the page
+func (uc *LikeUseCase) GetUserLikes(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Like, []*domain.Tweet, error) {
+	exists, err := uc.userRepo.Exists(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to check user existence", err, "userID", userID)
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, domain.ErrUserNotFound
+	}
+
+	likes, err := uc.likeRepo.GetLikes(ctx, userID, clampPage(page))
+	if err != nil {
+		uc.logger.Error("failed to get likes", err, "userID", userID)
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(likes))
+	for i, like := range likes {
+		ids[i] = like.TweetID
+	}
+	tweets, err := uc.tweetRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		uc.logger.Error("failed to get liked tweets", err, "userID", userID)
+		return nil, nil, err
+	}
+	return likes, tweets, nil
+}
+
+// GetLikeInfo counts the likes of each tweet and tells which of them the
+// viewer liked (none for anonymous viewers)
+func (uc *LikeUseCase) GetLikeInfo(ctx context.Context, viewerID string, tweetIDs []string) (map[string]int, map[string]bool, error) {
+	counts, err := uc.likeRepo.CountLikes(ctx, tweetIDs)
+	if err != nil {
+		uc.logger.Error("failed to count likes", err, "tweets", len(tweetIDs))
+		return nil, nil, err
+	}
+
+	liked := make(map[string]bool)
+	if viewerID != "" {
+		liked, err = uc.likeRepo.GetLikedTweetIDs(ctx, viewerID, tweetIDs)
+		if err != nil {
+			uc.logger.Error("failed to get liked tweets", err, "userID", viewerID)
+			return nil, nil, err
+		}
+	}
+	return counts, liked, nil
+}
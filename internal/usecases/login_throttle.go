@@ -0,0 +1,104 @@
+package usecases
+
+import (
+	"sync"
+	"time"
+)
+
+// throttleKey is a throttled key (username or client IP) and its limit
+type throttleKey struct {
+	key   string
+	limit int
+}
+
+// loginAttempts counts the attempts of a key within a window
+type loginAttempts struct {
+	count   int
+	resetAt time.Time
+}
+
+// loginThrottle limits login attempts per key in fixed windows. Attempts
+// are counted before the password is checked, so concurrent requests can
+// not exceed the limit; successful logins are forgiven afterwards
+type loginThrottle struct {
+	window   time.Duration
+	attempts map[string]*loginAttempts
+	mu       sync.Mutex
+}
+
+// maxThrottledKeys is the size above which expired keys are purged
+const maxThrottledKeys = 10000
+
+// newLoginThrottle creates a throttle with the given window
+func newLoginThrottle(window time.Duration) *loginThrottle {
+	return &loginThrottle{
+		window:   window,
+		attempts: make(map[string]*loginAttempts),
+	}
+}
+
+// Attempt records an attempt for every key, unless one of them already
+// reached its limit in the current window
+func (t *loginThrottle) Attempt(keys ...throttleKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range keys {
+		if attempts := t.current(k.key, now); attempts != nil && attempts.count >= k.limit {
+			return false
+		}
+	}
+
+	for _, k := range keys {
+		attempts := t.current(k.key, now)
+		if attempts == nil {
+			attempts = &loginAttempts{resetAt: now.Add(t.window)}
+			t.attempts[k.key] = attempts
+		}
+		attempts.count++
+	}
+
+	// Attempts from many distinct keys must not grow the map forever
+	if len(t.attempts) > maxThrottledKeys {
+		for key := range t.attempts {
+			t.current(key, now)
+		}
+	}
+	return true
+}
+
+// Forgive removes one attempt of a key
+func (t *loginThrottle) Forgive(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if attempts := t.current(key, time.Now()); attempts != nil {
+		attempts.count--
+		if attempts.count <= 0 {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// Reset forgets all the attempts of a key
+func (t *loginThrottle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+}
+
+// current returns the attempts of a key in the current window, dropping
+// them if the window is over (caller must hold the lock)
+func (t *loginThrottle) current(key string, now time.Time) *loginAttempts {
+	attempts, exists := t.attempts[key]
+	if !exists {
+		return nil
+	}
+	if !now.Before(attempts.resetAt) {
+		delete(t.attempts, key)
+		return nil
+	}
+	return attempts
+}
@@ -0,0 +1,136 @@
+package usecases
+
+import (
+	"context"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// NotificationUseCase handles business logic related to notifications
+type NotificationUseCase struct {
+	notificationRepo ports.NotificationRepository
+	logger           ports.Logger
+}
+
+// NewNotificationUseCase creates a new instance of the use case
+func NewNotificationUseCase(notificationRepo ports.NotificationRepository, logger ports.Logger) *NotificationUseCase {
+	return &NotificationUseCase{
+		notificationRepo: notificationRepo,
+		logger:           logger,
+	}
+}
+
+// HandleEvent notifies the users concerned by a domain event: the followed
+// user, the author of a liked, answered, quoted or retweeted tweet, and the
+// users mentioned by a new tweet. Grouped notifications do not repeat
+// actors, so an event handled twice notifies once
+func (uc *NotificationUseCase) HandleEvent(ctx context.Context, event domain.Event) error {
+	switch e := event.(type) {
+	case domain.UserFollowed:
+		return uc.notify(ctx, e.FolloweeID, domain.NotificationFollow, e.FollowerID, "")
+	case domain.TweetLiked:
+		if e.AuthorID == "" {
+			return nil
+		}
+		return uc.notify(ctx, e.AuthorID, domain.NotificationLike, e.Like.UserID, e.Like.TweetID)
+	case domain.TweetCreated:
+		return uc.notifyTweet(ctx, e)
+	}
+	return nil
+}
+
+// notifyTweet notifies the author of the tweet a new tweet answers, quotes
+// or retweets, and the users it mentions. Each user is notified once
+func (uc *NotificationUseCase) notifyTweet(ctx context.Context, event domain.TweetCreated) error {
+	tweet := event.Tweet
+	if tweet.IsRetweet() {
+		return uc.notify(ctx, event.ReferencedUserID, domain.NotificationRetweet, tweet.UserID, tweet.ReferencedTweetID)
+	}
+
+	notified := make(map[string]bool)
+	if event.ReferencedUserID != "" {
+		notificationType := domain.NotificationReply
+		if tweet.IsQuote() {
+			notificationType = domain.NotificationQuote
+		}
+		if err := uc.notify(ctx, event.ReferencedUserID, notificationType, tweet.UserID, tweet.ID); err != nil {
+			return err
+		}
+		notified[event.ReferencedUserID] = true
+	}
+
+	for _, userID := range tweet.Entities.MentionedUserIDs() {
+		if notified[userID] {
+			continue
+		}
+		if err := uc.notify(ctx, userID, domain.NotificationMention, tweet.UserID, tweet.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify records that actorID caused an event for userID, grouped with the
+// unread notification of the same type and tweet. Users are not notified
+// of their own actions
+func (uc *NotificationUseCase) notify(ctx context.Context, userID, notificationType, actorID, tweetID string) error {
+	if userID == actorID {
+		return nil
+	}
+
+	notification, err := domain.NewNotification(userID, notificationType, actorID, tweetID)
+	if err != nil {
+		uc.logger.Warn("invalid notification", "error", err, "userID", userID, "type", notificationType)
+		return nil
+	}
+
+	if err := uc.notificationRepo.Notify(ctx, notification); err != nil {
+		uc.logger.Warn("failed to record notification", "error", err, "userID", userID, "type", notificationType, "actorID", actorID)
+		return err
+	}
+	return nil
+}
+
+// GetNotifications gets a page of the notifications of a user, most
+// recently updated first
+func (uc *NotificationUseCase) GetNotifications(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Notification, error) {
+	if userID == "" {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	notifications, err := uc.notificationRepo.GetNotifications(ctx, userID, clampPage(page))
+	if err != nil {
+		uc.logger.Error("failed to get notifications", err, "userID", userID)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// CountUnread counts the unread notifications of a user
+func (uc *NotificationUseCase) CountUnread(ctx context.Context, userID string) (int, error) {
+	if userID == "" {
+		return 0, domain.ErrInvalidUserID
+	}
+
+	count, err := uc.notificationRepo.CountUnread(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to count unread notifications", err, "userID", userID)
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkRead marks as read the notifications of a user up to a position,
+// included, or all of them when upTo is nil, and returns how many are
+// left unread
+func (uc *NotificationUseCase) MarkRead(ctx context.Context, userID string, upTo *domain.Cursor) (int, error) {
+	if userID == "" {
+		return 0, domain.ErrInvalidUserID
+	}
+
+	if err := uc.notificationRepo.MarkRead(ctx, userID, upTo); err != nil {
+		uc.logger.Error("failed to mark notifications as read", err, "userID", userID)
+		return 0, err
+	}
+	return uc.CountUnread(ctx, userID)
+}
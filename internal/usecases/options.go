@@ -0,0 +1,37 @@
+package usecases
+
+import "twitter-clone-backend/internal/ports"
+
+// Option configures optional collaborators of the use cases
+type Option func(*options)
+
+// options contains the optional collaborators shared by the use cases
+type options struct {
+	homeTimelines *HomeTimelines
+	credentials   ports.CredentialRepository
+	hasher        ports.PasswordHasher
+}
+
+// WithHomeTimelines enables precomputed home timelines (fan-out on write)
+func WithHomeTimelines(homeTimelines *HomeTimelines) Option {
+	return func(o *options) {
+		o.homeTimelines = homeTimelines
+	}
+}
+
+// WithCredentials requires a password when registering users
+func WithCredentials(credentials ports.CredentialRepository, hasher ports.PasswordHasher) Option {
+	return func(o *options) {
+		o.credentials = credentials
+		o.hasher = hasher
+	}
+}
+
+// applyOptions builds the options from a list of Option
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
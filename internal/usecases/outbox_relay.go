@@ -0,0 +1,237 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// Default outbox relay settings
+const (
+	DefaultOutboxPollInterval = 250 * time.Millisecond
+	DefaultOutboxBatchSize    = 100
+	DefaultOutboxMaxBackoff   = 30 * time.Second
+	DefaultOutboxRetryBackoff = time.Second
+	DefaultOutboxMaxAttempts  = 10
+)
+
+// errRelayAttemptsExhausted is logged for the events discarded after failing
+// every relay attempt
+var errRelayAttemptsExhausted = errors.New("not handled by every subscriber after every relay attempt")
+
+// OutboxRelayConfig contains the outbox relay settings
+type OutboxRelayConfig struct {
+	// PollInterval is the wait between relay passes while they succeed.
+	// Failed passes double it up to MaxBackoff
+	PollInterval time.Duration
+	MaxBackoff   time.Duration
+	// BatchSize is the number of entries read from the outbox at a time
+	BatchSize int
+	// RetryBackoff is the wait before relaying again an event that some
+	// subscriber failed, doubled on every failure up to MaxBackoff
+	RetryBackoff time.Duration
+	// MaxAttempts is how many times an event is relayed before it is
+	// discarded, when some subscriber keeps failing it
+	MaxAttempts int
+}
+
+// OutboxRelay delivers the events stored in the outbox to the subscribers
+// of the bus, at least once: an entry is only removed from the outbox after
+// every subscriber handled it, so events whose delivery was interrupted (by
+// a crash or a full queue) are relayed again, and the subscribers skip the
+// ones they already handled. Events that a subscriber failed to handle stay
+// in the outbox and are relayed again with backoff, without holding back
+// the later ones, until MaxAttempts relays fail and they are discarded. The bus only remembers the handled events in memory, so
+// subscribers must be idempotent
+type OutboxRelay struct {
+	outbox ports.OutboxRepository
+	bus    ports.EventBus
+	logger ports.Logger
+	config OutboxRelayConfig
+	passMu sync.Mutex // one relay pass at a time
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewOutboxRelay creates a relay, started with Start
+func NewOutboxRelay(outbox ports.OutboxRepository, bus ports.EventBus, logger ports.Logger, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultOutboxPollInterval
+	}
+	if cfg.MaxBackoff < cfg.PollInterval {
+		cfg.MaxBackoff = DefaultOutboxMaxBackoff
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultOutboxBatchSize
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultOutboxRetryBackoff
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+
+	return &OutboxRelay{outbox: outbox, bus: bus, logger: logger, config: cfg}
+}
+
+// Start relays the pending events in the background until Stop is called
+func (r *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop stops relaying and waits for the current pass to finish. Events
+// not relayed yet stay in the outbox
+func (r *OutboxRelay) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run relays the pending events every poll interval, backing off while
+// relaying fails
+func (r *OutboxRelay) run(ctx context.Context) {
+	defer close(r.done)
+
+	wait := r.config.PollInterval
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if _, err := r.RelayPending(ctx); err != nil && ctx.Err() == nil {
+			wait *= 2
+			if wait > r.config.MaxBackoff {
+				wait = r.config.MaxBackoff
+			}
+			r.logger.Warn("failed to relay outbox events, retrying", "error", err, "backoff", wait)
+		} else {
+			wait = r.config.PollInterval
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RelayPending delivers the pending events of the outbox, oldest first,
+// until none is due or an event cannot be published, and returns how many
+// were relayed. Events some subscriber failed are scheduled for a retry
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	r.passMu.Lock()
+	defer r.passMu.Unlock()
+
+	relayed := 0
+	for {
+		entries, err := r.outbox.PendingEvents(ctx, r.config.BatchSize)
+		if err != nil {
+			return relayed, err
+		}
+		if len(entries) == 0 {
+			return relayed, nil
+		}
+
+		// Events keep their order: publishing stops at the first failure
+		var publishErr error
+		ids := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			event, err := entry.Event()
+			if err != nil {
+				// Relaying it again would fail forever
+				r.logger.Error("failed to decode outbox entry, discarded", err, "eventID", entry.ID, "type", entry.Type)
+				ids = append(ids, entry.ID)
+				continue
+			}
+			if publishErr = r.bus.Publish(ctx, event); publishErr != nil {
+				break
+			}
+			ids = append(ids, entry.ID)
+		}
+
+		// The entries are removed once handled, not just queued
+		failed, err := r.bus.Flush(ctx)
+		if err != nil {
+			return relayed, err
+		}
+		ids, err = r.scheduleRetries(ctx, entries, ids, failed)
+		if err != nil {
+			return relayed, err
+		}
+		if err := r.outbox.MarkRelayed(ctx, ids); err != nil {
+			return relayed, err
+		}
+		relayed += len(ids)
+
+		if publishErr != nil {
+			return relayed, publishErr
+		}
+		if len(entries) < r.config.BatchSize {
+			return relayed, nil
+		}
+	}
+}
+
+// scheduleRetries schedules the retry of the published entries that some
+// subscriber failed to handle, and returns the IDs of the other ones along
+// with the failed entries out of attempts, to be discarded
+func (r *OutboxRelay) scheduleRetries(ctx context.Context, entries []*domain.OutboxEntry, ids, failed []string) ([]string, error) {
+	if len(failed) == 0 {
+		return ids, nil
+	}
+
+	failedIDs := make(map[string]bool, len(failed))
+	for _, id := range failed {
+		failedIDs[id] = true
+	}
+
+	handled := ids[:0]
+	for _, id := range ids {
+		if !failedIDs[id] {
+			handled = append(handled, id)
+		}
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if !failedIDs[entry.ID] {
+			continue
+		}
+
+		if entry.Attempts+1 >= r.config.MaxAttempts {
+			// Relaying it again would keep failing: log it with its payload
+			// so it can be replayed by hand
+			r.logger.Error("outbox event discarded", errRelayAttemptsExhausted, "eventID", entry.ID, "type", entry.Type, "attempts", entry.Attempts+1, "payload", string(entry.Payload))
+			handled = append(handled, entry.ID)
+			continue
+		}
+
+		backoff := r.config.RetryBackoff
+		for i := 0; i < entry.Attempts && backoff < r.config.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+
+		if err := r.outbox.ScheduleRetry(ctx, entry.ID, now.Add(backoff)); err != nil {
+			return nil, err
+		}
+		r.logger.Warn("outbox event not handled by every subscriber, retrying later", "eventID", entry.ID, "type", entry.Type, "attempt", entry.Attempts+1, "backoff", backoff)
+	}
+	return handled, nil
+}
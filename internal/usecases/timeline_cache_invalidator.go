@@ -0,0 +1,71 @@
+package usecases
+
+import (
+	"context"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// TimelineCacheInvalidator invalidates the cached timelines that a domain
+// event makes stale: those of the author and their followers when a tweet
+// is created or deleted, and the follower's when a follow changes
+type TimelineCacheInvalidator struct {
+	followRepo    ports.FollowRepository
+	cache         ports.CacheService
+	homeTimelines *HomeTimelines
+	logger        ports.Logger
+}
+
+// NewTimelineCacheInvalidator creates a new instance of the subscriber
+func NewTimelineCacheInvalidator(
+	followRepo ports.FollowRepository,
+	cache ports.CacheService,
+	logger ports.Logger,
+	opts ...Option,
+) *TimelineCacheInvalidator {
+	o := applyOptions(opts)
+
+	return &TimelineCacheInvalidator{
+		followRepo:    followRepo,
+		cache:         cache,
+		homeTimelines: o.homeTimelines,
+		logger:        logger,
+	}
+}
+
+// HandleEvent invalidates the cached timelines affected by a domain event
+func (i *TimelineCacheInvalidator) HandleEvent(ctx context.Context, event domain.Event) error {
+	switch e := event.(type) {
+	case domain.TweetCreated:
+		return i.invalidateAudience(ctx, e.Tweet.UserID)
+	case domain.TweetDeleted:
+		return i.invalidateAudience(ctx, e.Tweet.UserID)
+	case domain.UserFollowed:
+		return i.cache.InvalidateTimeline(ctx, e.FollowerID)
+	case domain.UserUnfollowed:
+		return i.cache.InvalidateTimeline(ctx, e.FollowerID)
+	}
+	return nil
+}
+
+// invalidateAudience invalidates the timelines of an author and their
+// followers. With home timelines, the fan-out already invalidates them
+func (i *TimelineCacheInvalidator) invalidateAudience(ctx context.Context, userID string) error {
+	if i.homeTimelines != nil {
+		return nil
+	}
+
+	followers, err := i.followRepo.GetFollowers(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, id := range append(followers, userID) {
+		if err := i.cache.InvalidateTimeline(ctx, id); err != nil {
+			i.logger.Warn("failed to invalidate timeline", "error", err, "userID", id)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,204 @@
+package usecases
+
+import (
+	"context"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// Default timeline stream settings
+const (
+	DefaultStreamBufferSize = 64
+	DefaultStreamHeartbeat  = 15 * time.Second
+)
+
+// TimelineStreamsConfig contains the timeline stream settings
+type TimelineStreamsConfig struct {
+	// BufferSize is the number of tweets a stream can have pending before
+	// it is dropped as a slow consumer
+	BufferSize int
+	// Heartbeat is the interval of the keep-alive messages of a stream
+	Heartbeat time.Duration
+}
+
+// TimelineStream receives the new tweets of a user's timeline while the
+// user is connected. Done is closed when the stream is dropped, because its
+// consumer fell behind or the server is shutting down
+type TimelineStream struct {
+	UserID string
+	tweets chan *domain.Tweet
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Tweets returns the channel of new tweets, oldest first
+func (s *TimelineStream) Tweets() <-chan *domain.Tweet {
+	return s.tweets
+}
+
+// Done returns a channel closed when the stream is dropped
+func (s *TimelineStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// stop closes Done once
+func (s *TimelineStream) stop() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// TimelineStreams pushes new tweets to the timelines of the connected
+// users: their own tweets and those of the accounts they follow. Tweets are
+// handed to each stream without waiting: a stream whose buffer is full is
+// dropped, so a slow consumer never holds back the others, and it can
+// resume from the last tweet it got
+type TimelineStreams struct {
+	followRepo ports.FollowRepository
+	logger     ports.Logger
+	config     TimelineStreamsConfig
+	mu         sync.RWMutex
+	streams    map[string]map[*TimelineStream]bool // by user
+	closed     bool
+}
+
+// NewTimelineStreams creates a new instance of the subscriber
+func NewTimelineStreams(followRepo ports.FollowRepository, logger ports.Logger, cfg TimelineStreamsConfig) *TimelineStreams {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultStreamBufferSize
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = DefaultStreamHeartbeat
+	}
+
+	return &TimelineStreams{
+		followRepo: followRepo,
+		logger:     logger,
+		config:     cfg,
+		streams:    make(map[string]map[*TimelineStream]bool),
+	}
+}
+
+// Heartbeat returns the interval of the keep-alive messages of a stream
+func (s *TimelineStreams) Heartbeat() time.Duration {
+	return s.config.Heartbeat
+}
+
+// Subscribe opens a stream of the timeline of a user, which must be
+// released with Unsubscribe. After Close, the stream is already done
+func (s *TimelineStreams) Subscribe(userID string) *TimelineStream {
+	stream := &TimelineStream{
+		UserID: userID,
+		tweets: make(chan *domain.Tweet, s.config.BufferSize),
+		done:   make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		stream.stop()
+		return stream
+	}
+	if s.streams[userID] == nil {
+		s.streams[userID] = make(map[*TimelineStream]bool)
+	}
+	s.streams[userID][stream] = true
+	return stream
+}
+
+// Unsubscribe releases a stream
+func (s *TimelineStreams) Unsubscribe(stream *TimelineStream) {
+	s.mu.Lock()
+	s.remove(stream)
+	s.mu.Unlock()
+	stream.stop()
+}
+
+// Count returns the number of open streams
+func (s *TimelineStreams) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, streams := range s.streams {
+		count += len(streams)
+	}
+	return count
+}
+
+// Close drops every stream and rejects new ones, so the connections
+// serving them can end
+func (s *TimelineStreams) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, streams := range s.streams {
+		for stream := range streams {
+			stream.stop()
+		}
+	}
+	s.streams = make(map[string]map[*TimelineStream]bool)
+}
+
+// HandleEvent pushes a new tweet to the streams of its author and of the
+// connected followers. Only the connected users are looked up, so the cost
+// does not grow with the author's followers
+func (s *TimelineStreams) HandleEvent(ctx context.Context, event domain.Event) error {
+	created, ok := event.(domain.TweetCreated)
+	if !ok {
+		return nil
+	}
+	authorID := created.Tweet.UserID
+
+	s.mu.RLock()
+	connected := make([]string, 0, len(s.streams))
+	for userID := range s.streams {
+		connected = append(connected, userID)
+	}
+	s.mu.RUnlock()
+
+	// Every lookup is done before pushing, so a failed event handled again
+	// is not pushed twice
+	recipients := make([]string, 0, len(connected))
+	for _, userID := range connected {
+		if userID != authorID {
+			following, err := s.followRepo.IsFollowing(ctx, userID, authorID)
+			if err != nil {
+				return err
+			}
+			if !following {
+				continue
+			}
+		}
+		recipients = append(recipients, userID)
+	}
+
+	var dropped []*TimelineStream
+	s.mu.RLock()
+	for _, userID := range recipients {
+		for stream := range s.streams[userID] {
+			select {
+			case stream.tweets <- created.Tweet:
+			default:
+				dropped = append(dropped, stream)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, stream := range dropped {
+		s.logger.Warn("timeline stream is full, slow consumer dropped", "userID", stream.UserID, "tweetID", created.Tweet.ID)
+		s.Unsubscribe(stream)
+	}
+	return nil
+}
+
+// remove forgets a stream. The caller holds the lock
+func (s *TimelineStreams) remove(stream *TimelineStream) {
+	streams := s.streams[stream.UserID]
+	delete(streams, stream)
+	if len(streams) == 0 {
+		delete(s.streams, stream.UserID)
+	}
+}
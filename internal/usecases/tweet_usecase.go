@@ -8,11 +8,12 @@ import (
 
 // TweetUseCase handles business logic related to tweets
 type TweetUseCase struct {
-	tweetRepo  ports.TweetRepository
-	followRepo ports.FollowRepository
-	userRepo   ports.UserRepository
-	cache      ports.CacheService
-	logger     ports.Logger
+	tweetRepo     ports.TweetRepository
+	followRepo    ports.FollowRepository
+	userRepo      ports.UserRepository
+	cache         ports.CacheService
+	homeTimelines *HomeTimelines
+	logger        ports.Logger
 }
 
 // NewTweetUseCase creates a new instance of the use case
@@ -22,27 +23,26 @@ func NewTweetUseCase(
 	userRepo ports.UserRepository,
 	cache ports.CacheService,
 	logger ports.Logger,
+	opts ...Option,
 ) *TweetUseCase {
+	o := applyOptions(opts)
+
 	return &TweetUseCase{
-		tweetRepo:  tweetRepo,
-		followRepo: followRepo,
-		userRepo:   userRepo,
-		cache:      cache,
-		logger:     logger,
+		tweetRepo:     tweetRepo,
+		followRepo:    followRepo,
+		userRepo:      userRepo,
+		cache:         cache,
+		homeTimelines: o.homeTimelines,
+		logger:        logger,
 	}
 }
 
 // CreateTweet creates a new tweet
 func (uc *TweetUseCase) CreateTweet(ctx context.Context, userID, content string) (*domain.Tweet, error) {
 	// Verify that the user exists
-	exists, err := uc.userRepo.Exists(ctx, userID)
-	if err != nil {
-		uc.logger.Error("failed to check user existence", err, "userID", userID)
+	if err := uc.requireUser(ctx, userID); err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, domain.ErrUserNotFound
-	}
 
 	// Create the tweet
 	tweet, err := domain.NewTweet(userID, content)
@@ -50,69 +50,528 @@ func (uc *TweetUseCase) CreateTweet(ctx context.Context, userID, content string)
 		return nil, err
 	}
 
+	if err := uc.publish(ctx, tweet, nil); err != nil {
+		return nil, err
+	}
+	return tweet, nil
+}
+
+// CreateReply creates a tweet answering another one, in its conversation
+func (uc *TweetUseCase) CreateReply(ctx context.Context, userID, inReplyToTweetID, content string) (*domain.Tweet, error) {
+	if err := uc.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	parent, err := uc.getOriginal(ctx, inReplyToTweetID, domain.ErrParentNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	tweet, err := domain.NewReply(userID, content, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.publish(ctx, tweet, parent); err != nil {
+		return nil, err
+	}
+	return tweet, nil
+}
+
+// CreateQuote creates a tweet with commentary on another one
+func (uc *TweetUseCase) CreateQuote(ctx context.Context, userID, quotedTweetID, content string) (*domain.Tweet, error) {
+	if err := uc.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	quoted, err := uc.getOriginal(ctx, quotedTweetID, domain.ErrQuotedNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	tweet, err := domain.NewQuote(userID, content, quoted)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.publish(ctx, tweet, quoted); err != nil {
+		return nil, err
+	}
+	return tweet, nil
+}
+
+// Retweet reshares a tweet in the timelines of the user and their
+// followers. A tweet is retweeted once per user (domain.ErrAlreadyRetweeted)
+func (uc *TweetUseCase) Retweet(ctx context.Context, userID, tweetID string) (*domain.Tweet, error) {
+	if err := uc.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	original, err := uc.getOriginal(ctx, tweetID, domain.ErrTweetNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	retweet, err := domain.NewRetweet(userID, original)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify + create is atomic in the repository
+	if err := uc.tweetRepo.CreateRetweet(ctx, retweet, domain.NewTweetCreated(retweet, original)); err != nil {
+		if err != domain.ErrAlreadyRetweeted {
+			uc.logger.Error("failed to create retweet", err, "tweetID", original.ID, "userID", userID)
+		}
+		return nil, err
+	}
+
+	uc.deliver(ctx, retweet)
+	return retweet, nil
+}
+
+// UndoRetweet removes the user's retweet of a tweet from every timeline
+func (uc *TweetUseCase) UndoRetweet(ctx context.Context, userID, tweetID string) error {
+	retweet, err := uc.tweetRepo.GetRetweet(ctx, userID, tweetID)
+	if err != nil {
+		if err != domain.ErrNotRetweeted {
+			uc.logger.Error("failed to get retweet", err, "tweetID", tweetID, "userID", userID)
+		}
+		return err
+	}
+
+	if err := uc.tweetRepo.Delete(ctx, retweet.ID, domain.NewTweetDeleted(retweet)); err != nil {
+		if err == domain.ErrTweetNotFound {
+			// Undone concurrently
+			return domain.ErrNotRetweeted
+		}
+		uc.logger.Error("failed to delete retweet", err, "retweetID", retweet.ID)
+		return err
+	}
+
+	uc.retract(ctx, retweet)
+	uc.logger.Info("retweet undone successfully", "tweetID", tweetID, "userID", userID)
+	return nil
+}
+
+// getOriginal gets the tweet to reply to or reshare: a retweet stands for
+// the tweet it reshares. Missing tweets are reported as notFound
+func (uc *TweetUseCase) getOriginal(ctx context.Context, tweetID string, notFound error) (*domain.Tweet, error) {
+	tweet, err := uc.GetTweet(ctx, tweetID)
+	if err == nil && tweet.IsRetweet() {
+		tweet, err = uc.GetTweet(ctx, tweet.ReferencedTweetID)
+	}
+	if err == domain.ErrTweetNotFound {
+		return nil, notFound
+	}
+	return tweet, err
+}
+
+// requireUser checks that the author of a new tweet exists
+func (uc *TweetUseCase) requireUser(ctx context.Context, userID string) error {
+	exists, err := uc.userRepo.Exists(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to check user existence", err, "userID", userID)
+		return err
+	}
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// publish persists a new tweet with its creation event and delivers it to
+// the followers' timelines. referenced is the tweet it answers or quotes,
+// if any
+func (uc *TweetUseCase) publish(ctx context.Context, tweet, referenced *domain.Tweet) error {
+	if err := uc.resolveMentions(ctx, tweet); err != nil {
+		return err
+	}
+
 	// Persist the tweet
-	if err := uc.tweetRepo.Create(ctx, tweet); err != nil {
+	if err := uc.tweetRepo.Create(ctx, tweet, domain.NewTweetCreated(tweet, referenced)); err != nil {
 		uc.logger.Error("failed to create tweet", err, "tweetID", tweet.ID)
-		return nil, err
+		return err
 	}
 
-	// Invalidate followers' timeline cache asynchronously (not critical path)
-	if uc.cache != nil {
-		go uc.invalidateFollowersTimeline(context.Background(), userID)
+	uc.deliver(ctx, tweet)
+	return nil
+}
+
+// resolveMentions sets the mentioned user of each mention of a new tweet.
+// Mentions of usernames that do not exist, or of users who block the
+// author, are not entities: they are neither indexed nor notified
+func (uc *TweetUseCase) resolveMentions(ctx context.Context, tweet *domain.Tweet) error {
+	if tweet.Entities == nil || len(tweet.Entities.Mentions) == 0 {
+		return nil
 	}
 
-	uc.logger.Info("tweet created successfully", "tweetID", tweet.ID, "userID", userID)
+	userIDs := make(map[string]string)
+	mentions := tweet.Entities.Mentions[:0]
+	for _, mention := range tweet.Entities.Mentions {
+		username := domain.NormalizeUsername(mention.Username)
+		userID, resolved := userIDs[username]
+		if !resolved {
+			user, err := uc.userRepo.GetUserByUsername(ctx, username)
+			switch {
+			case err == nil:
+				userID = user.ID
+			case err != domain.ErrUserNotFound:
+				uc.logger.Error("failed to resolve mention", err, "username", username)
+				return err
+			}
+
+			if userID != "" && userID != tweet.UserID {
+				blocked, err := uc.followRepo.IsBlocked(ctx, userID, tweet.UserID)
+				if err != nil {
+					uc.logger.Error("failed to check block", err, "blockerID", userID, "blockedID", tweet.UserID)
+					return err
+				}
+				if blocked {
+					userID = ""
+				}
+			}
+			userIDs[username] = userID
+		}
+
+		if userID != "" {
+			mention.UserID = userID
+			mentions = append(mentions, mention)
+		}
+	}
+
+	if len(mentions) == 0 {
+		mentions = nil
+	}
+	tweet.Entities.Mentions = mentions
+	if tweet.Entities.IsEmpty() {
+		tweet.Entities = nil
+	}
+	return nil
+}
+
+// deliver adds a persisted tweet to the author's home timeline. The
+// fan-out to followers is driven by its TweetCreated event
+func (uc *TweetUseCase) deliver(ctx context.Context, tweet *domain.Tweet) {
+	if uc.homeTimelines != nil {
+		uc.homeTimelines.Publish(ctx, tweet)
+	}
+
+	uc.logger.Info("tweet created successfully", "tweetID", tweet.ID, "userID", tweet.UserID, "kind", tweet.TweetKind())
+}
+
+// GetTweet gets a tweet by its ID
+func (uc *TweetUseCase) GetTweet(ctx context.Context, tweetID string) (*domain.Tweet, error) {
+	tweet, err := uc.tweetRepo.GetByID(ctx, tweetID)
+	if err != nil {
+		if err != domain.ErrTweetNotFound {
+			uc.logger.Error("failed to get tweet", err, "tweetID", tweetID)
+		}
+		return nil, err
+	}
 	return tweet, nil
 }
 
-// GetTimeline gets a user's timeline
+// GetReplies gets a page of the direct replies to a tweet
+func (uc *TweetUseCase) GetReplies(ctx context.Context, tweetID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if _, err := uc.GetTweet(ctx, tweetID); err != nil {
+		return nil, err
+	}
+
+	tweets, err := uc.tweetRepo.GetReplies(ctx, tweetID, clampPage(page))
+	if err != nil {
+		uc.logger.Error("failed to get replies", err, "tweetID", tweetID)
+		return nil, err
+	}
+	return tweets, nil
+}
+
+// GetThread gets the whole conversation of a tweet as a tree, up to
+// domain.MaxThreadSize tweets
+func (uc *TweetUseCase) GetThread(ctx context.Context, tweetID string) (string, []*domain.ThreadNode, error) {
+	tweet, err := uc.GetTweet(ctx, tweetID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	conversationID := tweet.Conversation()
+	tweets, err := uc.tweetRepo.GetConversation(ctx, conversationID, domain.MaxThreadSize)
+	if err != nil {
+		uc.logger.Error("failed to get conversation", err, "conversationID", conversationID)
+		return "", nil, err
+	}
+	return conversationID, domain.BuildThread(tweets), nil
+}
+
+// GetCounts counts the interactions with each of the tweets
+func (uc *TweetUseCase) GetCounts(ctx context.Context, tweets []*domain.Tweet) (map[string]domain.TweetCounts, error) {
+	counts := make(map[string]domain.TweetCounts, len(tweets))
+	if len(tweets) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]string, len(tweets))
+	for i, tweet := range tweets {
+		ids[i] = tweet.ID
+	}
+
+	replies, err := uc.tweetRepo.CountReplies(ctx, ids)
+	if err != nil {
+		uc.logger.Error("failed to count replies", err, "tweets", len(ids))
+		return nil, err
+	}
+	retweets, err := uc.tweetRepo.CountRetweets(ctx, ids)
+	if err != nil {
+		uc.logger.Error("failed to count retweets", err, "tweets", len(ids))
+		return nil, err
+	}
+	quotes, err := uc.tweetRepo.CountQuotes(ctx, ids)
+	if err != nil {
+		uc.logger.Error("failed to count quotes", err, "tweets", len(ids))
+		return nil, err
+	}
+
+	for _, id := range ids {
+		counts[id] = domain.TweetCounts{
+			Replies:  replies[id],
+			Retweets: retweets[id],
+			Quotes:   quotes[id],
+		}
+	}
+	return counts, nil
+}
+
+// GetReferencedTweets gets the tweets reshared by retweets and quotes,
+// by ID. Deleted tweets are absent
+func (uc *TweetUseCase) GetReferencedTweets(ctx context.Context, tweets []*domain.Tweet) (map[string]*domain.Tweet, error) {
+	referenced := make(map[string]*domain.Tweet)
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, tweet := range tweets {
+		if id := tweet.ReferencedTweetID; id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return referenced, nil
+	}
+
+	found, err := uc.tweetRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		uc.logger.Error("failed to get referenced tweets", err, "tweets", len(ids))
+		return nil, err
+	}
+	for _, tweet := range found {
+		referenced[tweet.ID] = tweet
+	}
+	return referenced, nil
+}
+
+// DeleteTweet deletes a tweet of the user, with its retweets, and removes
+// them from every precomputed or cached timeline that may contain them
+func (uc *TweetUseCase) DeleteTweet(ctx context.Context, userID, tweetID string) error {
+	tweet, err := uc.GetTweet(ctx, tweetID)
+	if err != nil {
+		return err
+	}
+	if tweet.UserID != userID {
+		return domain.ErrNotTweetAuthor
+	}
+
+	// Retweets go first, so after a failure the tweet can be deleted again
+	if err := uc.deleteRetweets(ctx, tweet); err != nil {
+		return err
+	}
+
+	if err := uc.tweetRepo.Delete(ctx, tweetID, domain.NewTweetDeleted(tweet)); err != nil {
+		if err != domain.ErrTweetNotFound {
+			uc.logger.Error("failed to delete tweet", err, "tweetID", tweetID)
+		}
+		return err
+	}
+	uc.retract(ctx, tweet)
+
+	// Retweets made in the meantime, which can no longer be retried
+	if err := uc.deleteRetweets(ctx, tweet); err != nil {
+		uc.logger.Warn("retweets of deleted tweet left", "error", err, "tweetID", tweetID)
+	}
+
+	uc.logger.Info("tweet deleted successfully", "tweetID", tweetID, "userID", userID)
+	return nil
+}
+
+// deleteRetweets deletes the retweets of a tweet, as if they were undone
+func (uc *TweetUseCase) deleteRetweets(ctx context.Context, tweet *domain.Tweet) error {
+	if tweet.IsRetweet() {
+		return nil
+	}
+
+	retweets, err := uc.tweetRepo.GetRetweets(ctx, tweet.ID)
+	if err != nil {
+		uc.logger.Error("failed to get retweets", err, "tweetID", tweet.ID)
+		return err
+	}
+
+	for _, retweet := range retweets {
+		err := uc.tweetRepo.Delete(ctx, retweet.ID, domain.NewTweetDeleted(retweet))
+		if err == domain.ErrTweetNotFound {
+			// Undone concurrently
+			continue
+		}
+		if err != nil {
+			uc.logger.Error("failed to delete retweet", err, "retweetID", retweet.ID)
+			return err
+		}
+		uc.retract(ctx, retweet)
+	}
+	return nil
+}
+
+// retract removes a deleted tweet from the author's home timeline. Its
+// TweetDeleted event removes it from the followers' ones
+func (uc *TweetUseCase) retract(ctx context.Context, tweet *domain.Tweet) {
+	if uc.homeTimelines != nil {
+		uc.homeTimelines.Retract(ctx, tweet)
+	}
+}
+
+// GetTimeline gets the most recent tweets of a user's timeline
 func (uc *TweetUseCase) GetTimeline(ctx context.Context, userID string, limit int) ([]*domain.Tweet, error) {
-	if limit <= 0 || limit > domain.MaxTimelineLimit {
-		limit = domain.MaxTimelineLimit
+	return uc.GetTimelinePage(ctx, userID, domain.PageQuery{Limit: limit})
+}
+
+// GetTimelinePage gets a page of a user's timeline. A tweet retweeted by
+// several followed users (or also posted by one of them) is shown once, as
+// its most recent occurrence, and the page is refilled with older tweets
+func (uc *TweetUseCase) GetTimelinePage(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	page = clampPage(page)
+
+	var tweets []*domain.Tweet
+	shown := make(map[string]bool)
+	for {
+		batch, err := uc.readTimelinePage(ctx, userID, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tweet := range batch {
+			if len(tweets) == page.Limit {
+				break
+			}
+			if !shown[tweet.DisplayedTweetID()] {
+				shown[tweet.DisplayedTweetID()] = true
+				tweets = append(tweets, tweet)
+			}
+		}
+
+		// Polling pages are not refilled: the next poll starts at the
+		// newest tweet anyway
+		if len(tweets) == page.Limit || len(batch) < page.Limit || page.SinceID != nil {
+			break
+		}
+		cursor := domain.CursorOf(batch[len(batch)-1])
+		page.MaxID = &cursor
+	}
+
+	uc.logger.Info("timeline retrieved", "userID", userID, "tweetsCount", len(tweets))
+	return tweets, nil
+}
+
+// readTimelinePage reads a page of a timeline, as stored
+func (uc *TweetUseCase) readTimelinePage(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	tweets, err := uc.readTimeline(ctx, userID, page)
+	if err != nil {
+		uc.logger.Error("failed to get timeline", err, "userID", userID)
+		return nil, err
+	}
+
+	// Tweets of high-follower accounts are not fanned out; merge them at read time
+	if uc.homeTimelines != nil {
+		tweets, err = uc.homeTimelines.MergeCelebrities(ctx, userID, tweets, page)
+		if err != nil {
+			uc.logger.Error("failed to merge celebrity tweets", err, "userID", userID)
+			return nil, err
+		}
+	}
+	return tweets, nil
+}
+
+// readTimeline reads a page of a timeline. The first page is served from the
+// cache, or loaded and cached
+func (uc *TweetUseCase) readTimeline(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if uc.cache == nil || page.MaxID != nil || page.SinceID != nil {
+		return uc.loadTimeline(ctx, userID, page)
 	}
 
 	// Try to get from cache first
-	if uc.cache != nil {
-		tweets, err := uc.cache.GetTimeline(ctx, userID)
-		if err == nil && tweets != nil {
-			uc.logger.Debug("timeline served from cache", "userID", userID)
-			return tweets, nil
+	tweets, err := uc.cache.GetTimeline(ctx, userID)
+	if err == nil && tweets != nil {
+		uc.logger.Debug("timeline served from cache", "userID", userID)
+		return page.ApplyToTweets(tweets), nil
+	}
+
+	// The cache keeps the whole first page so any limit can be served from it
+	tweets, err = uc.loadTimeline(ctx, userID, domain.PageQuery{Limit: domain.MaxTimelineLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	// Save to cache asynchronously (not critical path for current response)
+	go func() {
+		if err := uc.cache.SetTimeline(context.Background(), userID, tweets); err != nil {
+			uc.logger.Warn("failed to cache timeline", "error", err, "userID", userID)
 		}
+	}()
+
+	return page.ApplyToTweets(tweets), nil
+}
+
+// loadTimeline reads a timeline from the precomputed home timelines if
+// enabled, otherwise it merges the tweets of followed users (pull model)
+func (uc *TweetUseCase) loadTimeline(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if uc.homeTimelines != nil {
+		return uc.homeTimelines.Read(ctx, userID, page)
 	}
 
 	// Get users being followed
 	following, err := uc.followRepo.GetFollowing(ctx, userID)
 	if err != nil {
-		uc.logger.Error("failed to get following users", err, "userID", userID)
 		return nil, err
 	}
 
 	// Include tweets from the user themselves
 	following = append(following, userID)
 
-	// Get timeline tweets
-	tweets, err := uc.tweetRepo.GetTimeline(ctx, following, limit)
-	if err != nil {
-		uc.logger.Error("failed to get timeline", err, "userID", userID)
+	return uc.tweetRepo.GetTimeline(ctx, following, page)
+}
+
+// GetUserTweets gets all tweets from a specific user
+func (uc *TweetUseCase) GetUserTweets(ctx context.Context, userID string) ([]*domain.Tweet, error) {
+	return uc.getUserTweets(ctx, userID, domain.PageQuery{})
+}
+
+// GetUserTweetsPage gets a page of the tweets from a specific user
+func (uc *TweetUseCase) GetUserTweetsPage(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	return uc.getUserTweets(ctx, userID, clampPage(page))
+}
+
+// GetMentions gets a page of the tweets that mention a user
+func (uc *TweetUseCase) GetMentions(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	if err := uc.requireUser(ctx, userID); err != nil {
 		return nil, err
 	}
 
-	// Save to cache asynchronously (not critical path for current response)
-	if uc.cache != nil {
-		go func() {
-			if err := uc.cache.SetTimeline(context.Background(), userID, tweets); err != nil {
-				uc.logger.Warn("failed to cache timeline", "error", err, "userID", userID)
-			}
-		}()
+	tweets, err := uc.tweetRepo.GetMentions(ctx, userID, clampPage(page))
+	if err != nil {
+		uc.logger.Error("failed to get mentions", err, "userID", userID)
+		return nil, err
 	}
-
-	uc.logger.Info("timeline retrieved", "userID", userID, "tweetsCount", len(tweets))
 	return tweets, nil
 }
 
-// GetUserTweets gets all tweets from a specific user
-func (uc *TweetUseCase) GetUserTweets(ctx context.Context, userID string) ([]*domain.Tweet, error) {
-	tweets, err := uc.tweetRepo.GetByUserID(ctx, userID)
+func (uc *TweetUseCase) getUserTweets(ctx context.Context, userID string, page domain.PageQuery) ([]*domain.Tweet, error) {
+	tweets, err := uc.tweetRepo.GetByUserID(ctx, userID, page)
 	if err != nil {
 		uc.logger.Error("failed to get user tweets", err, "userID", userID)
 		return nil, err
@@ -122,17 +581,10 @@ func (uc *TweetUseCase) GetUserTweets(ctx context.Context, userID string) ([]*do
 	return tweets, nil
 }
 
-// invalidateFollowersTimeline invalidates the timeline cache of followers
-func (uc *TweetUseCase) invalidateFollowersTimeline(ctx context.Context, userID string) {
-	followers, err := uc.followRepo.GetFollowers(ctx, userID)
-	if err != nil {
-		uc.logger.Warn("failed to get followers for cache invalidation", "error", err, "userID", userID)
-		return
-	}
-
-	for _, followerID := range followers {
-		if err := uc.cache.InvalidateTimeline(ctx, followerID); err != nil {
-			uc.logger.Warn("failed to invalidate follower timeline", "error", err, "followerID", followerID)
-		}
+// clampPage bounds the page size to domain.MaxTimelineLimit
+func clampPage(page domain.PageQuery) domain.PageQuery {
+	if page.Limit <= 0 || page.Limit > domain.MaxTimelineLimit {
+		page.Limit = domain.MaxTimelineLimit
 	}
+	return page
 }
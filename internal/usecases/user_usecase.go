@@ -0,0 +1,119 @@
+package usecases
+
+import (
+	"context"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// UserUseCase handles business logic related to users and their profiles
+type UserUseCase struct {
+	userRepo    ports.UserRepository
+	credentials ports.CredentialRepository // optional
+	hasher      ports.PasswordHasher       // optional
+	logger      ports.Logger
+}
+
+// NewUserUseCase creates a new instance of the use case
+func NewUserUseCase(userRepo ports.UserRepository, logger ports.Logger, opts ...Option) *UserUseCase {
+	o := applyOptions(opts)
+	return &UserUseCase{
+		userRepo:    userRepo,
+		credentials: o.credentials,
+		hasher:      o.hasher,
+		logger:      logger,
+	}
+}
+
+// RegisterUser creates a new user. Usernames are unique ignoring case.
+// The password is required (and stored hashed) when credentials are enabled
+func (uc *UserUseCase) RegisterUser(ctx context.Context, username, password string, profile domain.Profile) (*domain.User, error) {
+	user, err := domain.RegisterUser(username, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Credentials are stored first: if the user can not be created they are
+	// just unreachable, while a user without credentials could never log in
+	if uc.credentials != nil {
+		if err := uc.storeCredentials(ctx, user.ID, password); err != nil {
+			return nil, err
+		}
+	}
+
+	// The repository enforces uniqueness atomically
+	if err := uc.userRepo.CreateUser(ctx, user); err != nil {
+		if err != domain.ErrUsernameTaken {
+			uc.logger.Error("failed to create user", err, "username", username)
+		}
+		if uc.credentials != nil {
+			if err := uc.credentials.DeleteCredentials(ctx, user.ID); err != nil {
+				uc.logger.Warn("failed to delete credentials of unregistered user", "error", err, "userID", user.ID)
+			}
+		}
+		return nil, err
+	}
+
+	uc.logger.Info("user registered successfully", "userID", user.ID, "username", user.Username)
+	return user, nil
+}
+
+// storeCredentials validates and hashes a password and stores it
+func (uc *UserUseCase) storeCredentials(ctx context.Context, userID, password string) error {
+	if err := domain.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := uc.hasher.Hash(password)
+	if err != nil {
+		uc.logger.Error("failed to hash password", err, "userID", userID)
+		return err
+	}
+
+	if err := uc.credentials.SetCredentials(ctx, domain.NewCredentials(userID, hash)); err != nil {
+		uc.logger.Error("failed to store credentials", err, "userID", userID)
+		return err
+	}
+	return nil
+}
+
+// GetUser gets a user by ID
+func (uc *UserUseCase) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil && err != domain.ErrUserNotFound {
+		uc.logger.Error("failed to get user", err, "userID", userID)
+	}
+	return user, err
+}
+
+// GetUserByUsername gets a user by username (ignoring case)
+func (uc *UserUseCase) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	user, err := uc.userRepo.GetUserByUsername(ctx, username)
+	if err != nil && err != domain.ErrUserNotFound {
+		uc.logger.Error("failed to get user by username", err, "username", username)
+	}
+	return user, err
+}
+
+// UpdateProfile applies a partial edit to the profile of a user
+func (uc *UserUseCase) UpdateProfile(ctx context.Context, userID string, update domain.ProfileUpdate) (*domain.User, error) {
+	current, err := uc.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := *current
+	if err := user.SetProfile(current.Profile().Apply(update)); err != nil {
+		return nil, err
+	}
+
+	if err := uc.userRepo.UpdateUser(ctx, &user); err != nil {
+		if err != domain.ErrUserNotFound {
+			uc.logger.Error("failed to update user", err, "userID", userID)
+		}
+		return nil, err
+	}
+
+	uc.logger.Info("profile updated successfully", "userID", userID)
+	return &user, nil
+}
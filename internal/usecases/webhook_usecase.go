@@ -0,0 +1,547 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+)
+
+// Default webhook delivery settings
+const (
+	DefaultWebhookWorkers      = 4
+	DefaultWebhookQueueSize    = 1024
+	DefaultWebhookMaxAttempts  = 5
+	DefaultWebhookRetryBackoff = time.Second
+	DefaultWebhookMaxBackoff   = time.Minute
+	DefaultWebhookDisableAfter = 5
+	DefaultWebhookDedupeWindow = 10000
+)
+
+var (
+	// ErrWebhookQueueFull is returned when the queue of a webhook has no
+	// room for a delivery, so the event is handled again later
+	ErrWebhookQueueFull = errors.New("webhook delivery queue is full")
+
+	// ErrWebhooksClosed is returned when handling an event after Close
+	ErrWebhooksClosed = errors.New("webhook deliveries are closed")
+)
+
+// WebhookConfig contains the webhook delivery settings
+type WebhookConfig struct {
+	Workers int
+	// QueueSize is the number of deliveries a worker can have pending, and
+	// that a webhook can have held back while waiting for a retry
+	QueueSize int
+	// MaxAttempts is how many times an event is sent to a failing webhook.
+	// RetryBackoff is the wait before the first retry, doubled on every
+	// retry up to MaxBackoff
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// DisableAfter is the number of deliveries in a row that failed every
+	// attempt after which a webhook is disabled
+	DisableAfter int
+	// DedupeWindow is how many accepted deliveries are remembered, so an
+	// event handled again is not sent twice to the same webhook
+	DedupeWindow int
+	// AllowPrivateTargets accepts webhook URLs of localhost and private
+	// addresses, only meant for tests and local development
+	AllowPrivateTargets bool
+}
+
+// webhookJob is an event to deliver to a webhook
+type webhookJob struct {
+	webhookID string
+	event     domain.Event
+	attempts  int           // attempts already made
+	backoff   time.Duration // wait before the next retry
+}
+
+// key identifies the delivery of an event to a webhook
+func (j webhookJob) key() string {
+	return j.webhookID + "/" + j.event.EventID()
+}
+
+// acceptedWindow remembers the keys of the last accepted deliveries
+type acceptedWindow struct {
+	keys  map[string]bool
+	order []string // ring buffer, oldest at next
+	next  int
+}
+
+func newAcceptedWindow(size int) *acceptedWindow {
+	return &acceptedWindow{keys: make(map[string]bool, size), order: make([]string, 0, size)}
+}
+
+func (w *acceptedWindow) contains(key string) bool {
+	return w.keys[key]
+}
+
+// add remembers key, forgetting the oldest one if the window is full
+func (w *acceptedWindow) add(key string) {
+	if w.keys[key] {
+		return
+	}
+	if len(w.order) < cap(w.order) {
+		w.order = append(w.order, key)
+	} else {
+		delete(w.keys, w.order[w.next])
+		w.order[w.next] = key
+		w.next = (w.next + 1) % len(w.order)
+	}
+	w.keys[key] = true
+}
+
+// WebhookUseCase manages the webhooks of the users and delivers them the
+// events about their account. Deliveries run in the background, apart from
+// the event bus, so slow or failing receivers do not hold back the other
+// subscribers. The deliveries of a webhook are always made by the same
+// worker, so they keep the order of the events. Retries wait on a timer of
+// their own instead of the worker: while a delivery waits for its retry,
+// the later ones of the same webhook are held back, and the worker goes on
+// with the other webhooks
+type WebhookUseCase struct {
+	webhookRepo ports.WebhookRepository
+	sender      ports.WebhookSender
+	logger      ports.Logger
+	config      WebhookConfig
+	queues      []chan webhookJob
+	mu          sync.Mutex              // guards closed, held and accepted
+	closed      bool                    // no more events are accepted
+	held        map[string][]webhookJob // by webhook, present while one waits for a retry
+	accepted    *acceptedWindow         // deliveries already queued
+	pending     sync.WaitGroup          // accepted deliveries not finished yet
+	drained     chan struct{}           // closed once Close delivered everything
+	ctx         context.Context         // canceled to abort deliveries on Close
+	cancel      context.CancelFunc
+	workers     sync.WaitGroup
+}
+
+// NewWebhookUseCase creates the use case and starts its delivery workers
+func NewWebhookUseCase(
+	webhookRepo ports.WebhookRepository,
+	sender ports.WebhookSender,
+	logger ports.Logger,
+	cfg WebhookConfig,
+) *WebhookUseCase {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWebhookWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultWebhookQueueSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultWebhookRetryBackoff
+	}
+	if cfg.MaxBackoff < cfg.RetryBackoff {
+		cfg.MaxBackoff = DefaultWebhookMaxBackoff
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = DefaultWebhookDisableAfter
+	}
+	if cfg.DedupeWindow <= 0 {
+		cfg.DedupeWindow = DefaultWebhookDedupeWindow
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	uc := &WebhookUseCase{
+		webhookRepo: webhookRepo,
+		sender:      sender,
+		logger:      logger,
+		config:      cfg,
+		queues:      make([]chan webhookJob, cfg.Workers),
+		held:        make(map[string][]webhookJob),
+		accepted:    newAcceptedWindow(cfg.DedupeWindow),
+		drained:     make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	for i := range uc.queues {
+		uc.queues[i] = make(chan webhookJob, cfg.QueueSize)
+		uc.workers.Add(1)
+		go uc.worker(uc.queues[i])
+	}
+
+	return uc
+}
+
+// CreateWebhook registers a webhook of a user. The secret is only shown to
+// the user when they choose it
+func (uc *WebhookUseCase) CreateWebhook(ctx context.Context, userID, url string, eventTypes []string, secret string) (*domain.Webhook, error) {
+	webhook, err := domain.NewWebhook(userID, url, eventTypes, secret, uc.config.AllowPrivateTargets)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := uc.webhookRepo.ListWebhooks(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to list webhooks", err, "userID", userID)
+		return nil, err
+	}
+	if len(existing) >= domain.MaxWebhooksPerUser {
+		return nil, domain.ErrTooManyWebhooks
+	}
+
+	if err := uc.webhookRepo.CreateWebhook(ctx, webhook); err != nil {
+		uc.logger.Error("failed to create webhook", err, "userID", userID)
+		return nil, err
+	}
+
+	uc.logger.Info("webhook created", "userID", userID, "webhookID", webhook.ID, "events", webhook.EventTypes)
+	return webhook, nil
+}
+
+// ListWebhooks returns the webhooks of a user, newest first
+func (uc *WebhookUseCase) ListWebhooks(ctx context.Context, userID string) ([]*domain.Webhook, error) {
+	webhooks, err := uc.webhookRepo.ListWebhooks(ctx, userID)
+	if err != nil {
+		uc.logger.Error("failed to list webhooks", err, "userID", userID)
+	}
+	return webhooks, err
+}
+
+// DeleteWebhook deletes a webhook of a user and its delivery log
+func (uc *WebhookUseCase) DeleteWebhook(ctx context.Context, userID, webhookID string) error {
+	if err := uc.webhookRepo.DeleteWebhook(ctx, userID, webhookID); err != nil {
+		if err != domain.ErrWebhookNotFound {
+			uc.logger.Error("failed to delete webhook", err, "userID", userID, "webhookID", webhookID)
+		}
+		return err
+	}
+
+	uc.logger.Info("webhook deleted", "userID", userID, "webhookID", webhookID)
+	return nil
+}
+
+// EnableWebhook enables again a webhook of a user disabled after repeated
+// failures. Events that happened meanwhile are not delivered
+func (uc *WebhookUseCase) EnableWebhook(ctx context.Context, userID, webhookID string) (*domain.Webhook, error) {
+	webhook, err := uc.ownedWebhook(ctx, userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := webhook.Enable()
+	if err := uc.webhookRepo.UpdateWebhook(ctx, enabled); err != nil {
+		if err != domain.ErrWebhookNotFound {
+			uc.logger.Error("failed to enable webhook", err, "webhookID", webhookID)
+		}
+		return nil, err
+	}
+
+	uc.logger.Info("webhook enabled", "userID", userID, "webhookID", webhookID)
+	return enabled, nil
+}
+
+// GetDeliveries returns the last delivery attempts of a webhook of a user,
+// newest first
+func (uc *WebhookUseCase) GetDeliveries(ctx context.Context, userID, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	if _, err := uc.ownedWebhook(ctx, userID, webhookID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > domain.MaxWebhookDeliveries {
+		limit = domain.MaxWebhookDeliveries
+	}
+
+	deliveries, err := uc.webhookRepo.ListWebhookDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		uc.logger.Error("failed to list webhook deliveries", err, "webhookID", webhookID)
+	}
+	return deliveries, err
+}
+
+// HandleEvent queues the delivery of a domain event to the enabled webhooks
+// of the user it is about that subscribe to it. If the queue of a webhook
+// is full it fails with ErrWebhookQueueFull, so the event stays in the
+// outbox and is handled again: the webhooks that accepted it already are
+// skipped then, as long as the delivery is remembered (DedupeWindow).
+// Deliveries are at least once
+func (uc *WebhookUseCase) HandleEvent(ctx context.Context, event domain.Event) error {
+	ownerID := domain.WebhookOwner(event)
+	if ownerID == "" {
+		return nil
+	}
+
+	webhooks, err := uc.webhookRepo.ListWebhooks(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.closed {
+		return ErrWebhooksClosed
+	}
+
+	var queueErr error
+	for _, webhook := range webhooks {
+		if !webhook.IsEnabled() || !webhook.Subscribes(event.EventType()) {
+			continue
+		}
+
+		job := webhookJob{webhookID: webhook.ID, event: event}
+		if uc.accepted.contains(job.key()) {
+			continue
+		}
+		uc.pending.Add(1)
+		if !uc.enqueue(job) {
+			uc.pending.Done()
+			uc.logger.Warn("webhook queue is full, delivery postponed", "webhookID", webhook.ID, "eventID", event.EventID())
+			queueErr = ErrWebhookQueueFull
+			continue
+		}
+		uc.accepted.add(job.key())
+	}
+	return queueErr
+}
+
+// enqueue hands a new delivery to the worker of its webhook, or holds it
+// back if an earlier one is waiting for a retry. It reports false if there
+// is no room for it. The caller holds the lock
+func (uc *WebhookUseCase) enqueue(job webhookJob) bool {
+	if held, waiting := uc.held[job.webhookID]; waiting {
+		if len(held) >= uc.config.QueueSize {
+			return false
+		}
+		uc.held[job.webhookID] = append(held, job)
+		return true
+	}
+
+	select {
+	case uc.queueOf(job.webhookID) <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting events and waits until the accepted deliveries are
+// made, retries included. If ctx is done first, the pending deliveries are
+// abandoned
+func (uc *WebhookUseCase) Close(ctx context.Context) error {
+	uc.mu.Lock()
+	if !uc.closed {
+		uc.closed = true
+		go uc.drain()
+	}
+	uc.mu.Unlock()
+
+	select {
+	case <-uc.drained:
+		uc.cancel()
+		return nil
+	case <-ctx.Done():
+		uc.cancel()
+		return ctx.Err()
+	}
+}
+
+// drain waits for the accepted deliveries and stops the workers. Retries
+// are queued until the last delivery finishes, so only then the queues
+// can be closed
+func (uc *WebhookUseCase) drain() {
+	uc.pending.Wait()
+	for _, queue := range uc.queues {
+		close(queue)
+	}
+	uc.workers.Wait()
+	close(uc.drained)
+}
+
+// ownedWebhook returns a webhook of a user. Other users' webhooks are
+// reported as not found
+func (uc *WebhookUseCase) ownedWebhook(ctx context.Context, userID, webhookID string) (*domain.Webhook, error) {
+	webhook, err := uc.webhookRepo.GetWebhook(ctx, webhookID)
+	if err == domain.ErrWebhookNotFound || (err == nil && webhook.UserID != userID) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		uc.logger.Error("failed to get webhook", err, "webhookID", webhookID)
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// queueOf returns the queue of the worker delivering to a webhook
+func (uc *WebhookUseCase) queueOf(webhookID string) chan webhookJob {
+	h := fnv.New32a()
+	h.Write([]byte(webhookID))
+	return uc.queues[h.Sum32()%uint32(len(uc.queues))]
+}
+
+// worker makes the deliveries of a queue, one at a time. Once a delivery
+// is finished, the deliveries of its webhook held back meanwhile follow
+func (uc *WebhookUseCase) worker(queue chan webhookJob) {
+	defer uc.workers.Done()
+
+	for job := range queue {
+		// A delivery queued before an earlier one failed waits behind it
+		if job.attempts == 0 && uc.hold(job) {
+			continue
+		}
+
+		for !uc.deliver(job) {
+			uc.pending.Done()
+			next, ok := uc.release(job.webhookID)
+			if !ok {
+				break
+			}
+			job = next
+		}
+	}
+}
+
+// hold holds back a new delivery if an earlier one of its webhook is
+// waiting for a retry, and reports whether it did
+func (uc *WebhookUseCase) hold(job webhookJob) bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	held, waiting := uc.held[job.webhookID]
+	if waiting {
+		uc.held[job.webhookID] = append(held, job)
+	}
+	return waiting
+}
+
+// release returns the next delivery held back for a webhook, if any. The
+// webhook stops holding deliveries once there are none left
+func (uc *WebhookUseCase) release(webhookID string) (webhookJob, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	held, waiting := uc.held[webhookID]
+	if !waiting {
+		return webhookJob{}, false
+	}
+	if len(held) == 0 {
+		delete(uc.held, webhookID)
+		return webhookJob{}, false
+	}
+	uc.held[webhookID] = held[1:]
+	return held[0], true
+}
+
+// deliver makes an attempt to send an event to a webhook, and records the
+// result in the webhook. Every attempt is logged, and is signed again so its
+// timestamp stays fresh. If it fails and attempts remain, a retry is
+// scheduled with exponential backoff and deliver reports true: the later
+// deliveries of the webhook are held back until the retry is finished
+func (uc *WebhookUseCase) deliver(job webhookJob) (retrying bool) {
+	if uc.ctx.Err() != nil {
+		return false
+	}
+
+	// The webhook may have been deleted or disabled since it was queued
+	webhook, err := uc.webhookRepo.GetWebhook(uc.ctx, job.webhookID)
+	if err == domain.ErrWebhookNotFound {
+		return false
+	}
+	if err != nil {
+		uc.logger.Error("failed to get webhook, delivery dropped", err, "webhookID", job.webhookID, "eventID", job.event.EventID())
+		return false
+	}
+	if !webhook.IsEnabled() {
+		return false
+	}
+
+	message, err := domain.NewWebhookMessage(webhook, job.event, time.Now())
+	if err != nil {
+		uc.logger.Error("failed to encode webhook message, delivery dropped", err, "webhookID", webhook.ID, "eventID", job.event.EventID())
+		return false
+	}
+
+	job.attempts++
+	status, sendErr := uc.sender.Send(uc.ctx, webhook.URL, message)
+	delivery := domain.NewWebhookDelivery(webhook.ID, message, job.attempts, status, sendErr)
+	if err := uc.webhookRepo.AddWebhookDelivery(uc.ctx, delivery); err != nil {
+		uc.logger.Warn("failed to log webhook delivery", "error", err, "webhookID", webhook.ID, "eventID", message.EventID)
+	}
+
+	if sendErr == nil {
+		uc.recordResult(webhook.ID, true)
+		return false
+	}
+	if uc.ctx.Err() != nil {
+		return false
+	}
+	if job.attempts == uc.config.MaxAttempts {
+		uc.logger.Warn("webhook delivery failed", "error", sendErr, "webhookID", webhook.ID, "eventID", message.EventID, "attempts", job.attempts)
+		uc.recordResult(webhook.ID, false)
+		return false
+	}
+
+	uc.retryLater(job)
+	return true
+}
+
+// retryLater holds back the later deliveries of a webhook and queues the
+// job again after its backoff. The job is queued even if Close gives up
+// waiting, so the worker finishes it and the deliveries held behind it
+func (uc *WebhookUseCase) retryLater(job webhookJob) {
+	if job.backoff == 0 {
+		job.backoff = uc.config.RetryBackoff
+	}
+	wait := job.backoff
+	job.backoff = min(2*job.backoff, uc.config.MaxBackoff)
+
+	uc.mu.Lock()
+	if _, waiting := uc.held[job.webhookID]; !waiting {
+		uc.held[job.webhookID] = nil
+	}
+	uc.mu.Unlock()
+
+	go func() {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-uc.ctx.Done():
+		}
+		uc.queueOf(job.webhookID) <- job
+	}()
+}
+
+// recordResult counts the consecutive failed deliveries of a webhook,
+// disabling it once there are too many
+func (uc *WebhookUseCase) recordResult(webhookID string, succeeded bool) {
+	// Read it again: it may have been enabled or deleted meanwhile
+	webhook, err := uc.webhookRepo.GetWebhook(uc.ctx, webhookID)
+	if err == domain.ErrWebhookNotFound {
+		return
+	}
+	if err != nil {
+		uc.logger.Error("failed to get webhook", err, "webhookID", webhookID)
+		return
+	}
+
+	var updated *domain.Webhook
+	switch {
+	case succeeded && webhook.ConsecutiveFailures == 0:
+		return
+	case succeeded:
+		updated = webhook.RecordSuccess()
+	default:
+		updated = webhook.RecordFailure(uc.config.DisableAfter, time.Now())
+	}
+
+	if err := uc.webhookRepo.UpdateWebhook(uc.ctx, updated); err != nil {
+		if err != domain.ErrWebhookNotFound {
+			uc.logger.Error("failed to update webhook", err, "webhookID", webhookID)
+		}
+		return
+	}
+
+	if webhook.IsEnabled() && !updated.IsEnabled() {
+		uc.logger.Warn("webhook disabled after repeated failures", "webhookID", webhookID, "userID", webhook.UserID, "failures", updated.ConsecutiveFailures)
+	}
+}
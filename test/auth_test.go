@@ -0,0 +1,518 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/auth"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+func newHMACService(t *testing.T) *auth.JWTService {
+	t.Helper()
+	service, err := auth.NewHMACService([]byte(strings.Repeat("s", auth.MinHMACSecretLength)), "test")
+	if err != nil {
+		t.Fatalf("Failed to create HMAC service: %v", err)
+	}
+	return service
+}
+
+// testHasher is a cheap Argon2id hasher, the default parameters are too slow for tests
+var testHasher = auth.NewArgon2Hasher(auth.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+// newTokenServer starts an API server with token authentication
+func newTokenServer(t *testing.T, cfg usecases.AuthConfig) (*httptest.Server, *usecases.AuthUseCase) {
+	t.Helper()
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	authUseCase := usecases.NewAuthUseCase(newHMACService(t), repo, repo, testHasher, repo, logger, cfg)
+	handlers := httpAdapters.NewHandlers(
+		usecases.NewTweetUseCase(repo, repo, repo, nil, logger),
+		usecases.NewFollowUseCase(repo, repo, logger),
+		usecases.NewUserUseCase(repo, logger, usecases.WithCredentials(repo, testHasher)),
+		logger,
+		httpAdapters.WithAuth(authUseCase),
+		httpAdapters.WithAPIKeys(usecases.NewAPIKeyUseCase(repo, logger)),
+	)
+	server := httptest.NewServer(httpAdapters.SetupRoutes(handlers))
+	t.Cleanup(server.Close)
+	return server, authUseCase
+}
+
+// jsonRequest sends a JSON request with an optional bearer token
+func jsonRequest(t *testing.T, server *httptest.Server, method, path, token string, body interface{}) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+// decodeResponse decodes and closes a JSON response, returning its status
+func decodeResponse(resp *http.Response, v interface{}) int {
+	defer resp.Body.Close()
+	if v != nil {
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp.StatusCode
+}
+
+func TestJWTSignAndVerify(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	edService, err := auth.NewEd25519Service(privateKey, "test")
+	if err != nil {
+		t.Fatalf("Failed to create Ed25519 service: %v", err)
+	}
+	hmacService := newHMACService(t)
+
+	for name, service := range map[string]*auth.JWTService{"HS256": hmacService, "EdDSA": edService} {
+		t.Run(name, func(t *testing.T) {
+			claims := domain.NewTokenClaims("user1", domain.TokenUseAccess, time.Minute)
+			token, err := service.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+
+			verified, err := service.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if verified.Subject != "user1" || verified.Use != domain.TokenUseAccess || verified.ID != claims.ID {
+				t.Errorf("Unexpected claims: %+v", verified)
+			}
+
+			// Tampered payload
+			parts := strings.Split(token, ".")
+			forged, _ := json.Marshal(map[string]interface{}{"iss": "test", "sub": "user2", "exp": time.Now().Add(time.Hour).Unix()})
+			tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+			if _, err := service.Verify(tampered); err != domain.ErrInvalidToken {
+				t.Errorf("Expected tampered token to be rejected, got %v", err)
+			}
+
+			// Unsigned token ("alg": "none")
+			none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+			if _, err := service.Verify(none + "." + parts[1] + "."); err != domain.ErrInvalidToken {
+				t.Errorf("Expected unsigned token to be rejected, got %v", err)
+			}
+
+			// Expired token
+			expired, _ := service.Sign(domain.NewTokenClaims("user1", domain.TokenUseAccess, -time.Hour))
+			if _, err := service.Verify(expired); err != domain.ErrInvalidToken {
+				t.Errorf("Expected expired token to be rejected, got %v", err)
+			}
+		})
+	}
+
+	// A token is only valid for the algorithm and key of the service
+	token, _ := hmacService.Sign(domain.NewTokenClaims("user1", domain.TokenUseAccess, time.Minute))
+	if _, err := edService.Verify(token); err != domain.ErrInvalidToken {
+		t.Errorf("Expected HS256 token to be rejected by EdDSA service, got %v", err)
+	}
+	otherSecret, _ := auth.NewHMACService([]byte(strings.Repeat("o", auth.MinHMACSecretLength)), "test")
+	if _, err := otherSecret.Verify(token); err != domain.ErrInvalidToken {
+		t.Errorf("Expected token signed with another secret to be rejected, got %v", err)
+	}
+
+	if _, err := auth.NewHMACService([]byte("short"), "test"); err == nil {
+		t.Error("Expected short HMAC secret to be rejected")
+	}
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	server, authUseCase := newTokenServer(t, usecases.AuthConfig{})
+	request := func(method, path, token string, body interface{}) *http.Response {
+		t.Helper()
+		return jsonRequest(t, server, method, path, token, body)
+	}
+
+	// Registration requires a password and returns tokens
+	resp := request("POST", "/users", "", map[string]string{"username": "heidi"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 without password, got %d", resp.StatusCode)
+	}
+	resp = request("POST", "/users", "", map[string]string{"username": "heidi", "password": "correct horse"})
+	var registered httpAdapters.AuthenticatedUserResponse
+	json.NewDecoder(resp.Body).Decode(&registered)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || registered.TokenResponse == nil || registered.AccessToken == "" {
+		t.Fatalf("Expected tokens on registration, got %d %+v", resp.StatusCode, registered)
+	}
+
+	// The X-User-ID header is not trusted in token mode
+	req, _ := http.NewRequest("POST", server.URL+"/tweets", strings.NewReader(`{"content":"spoofed"}`))
+	req.Header.Set("X-User-ID", "user1")
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	// The identity comes from the access token
+	resp = request("POST", "/tweets", registered.AccessToken, map[string]string{"content": "hello"})
+	var tweet httpAdapters.TweetResponse
+	json.NewDecoder(resp.Body).Decode(&tweet)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || tweet.UserID != registered.ID {
+		t.Errorf("Expected tweet by %s, got %d %+v", registered.ID, resp.StatusCode, tweet)
+	}
+
+	// Refresh tokens are not access tokens and vice versa
+	resp = request("POST", "/tweets", registered.RefreshToken, map[string]string{"content": "hello"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with refresh token, got %d", resp.StatusCode)
+	}
+	resp = request("POST", "/auth/refresh", "", map[string]string{"refresh_token": registered.AccessToken})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 refreshing with access token, got %d", resp.StatusCode)
+	}
+
+	resp = request("POST", "/auth/refresh", "", map[string]string{"refresh_token": registered.RefreshToken})
+	var refreshed httpAdapters.TokenResponse
+	json.NewDecoder(resp.Body).Decode(&refreshed)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || refreshed.AccessToken == "" {
+		t.Fatalf("Expected new tokens, got %d %+v", resp.StatusCode, refreshed)
+	}
+	if userID, err := authUseCase.Authenticate(context.Background(), refreshed.AccessToken); err != nil || userID != registered.ID {
+		t.Errorf("Expected refreshed token for %s, got %s (err: %v)", registered.ID, userID, err)
+	}
+
+	// Invalid tokens are rejected even on public endpoints
+	resp = request("GET", "/users/user1/timeline", "garbage", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with invalid token, got %d", resp.StatusCode)
+	}
+	resp = request("GET", "/users/user1/timeline", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected anonymous read to succeed, got %d", resp.StatusCode)
+	}
+}
+
+func TestArgon2Hasher(t *testing.T) {
+	hash, err := testHasher.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Unexpected hash format: %s", hash)
+	}
+
+	if match, err := testHasher.Verify("correct horse", hash); err != nil || !match {
+		t.Errorf("Expected password to match (err: %v)", err)
+	}
+	if match, _ := testHasher.Verify("wrong horse", hash); match {
+		t.Error("Expected wrong password not to match")
+	}
+
+	// Salts are random
+	if other, _ := testHasher.Hash("correct horse"); other == hash {
+		t.Error("Expected different hashes for the same password")
+	}
+
+	// Hashes keep verifying after the parameters change
+	stronger := auth.NewArgon2Hasher(auth.Argon2Params{Memory: 128, Iterations: 2, Parallelism: 2, SaltLength: 8, KeyLength: 16})
+	if match, err := stronger.Verify("correct horse", hash); err != nil || !match {
+		t.Errorf("Expected old hash to verify with new parameters (err: %v)", err)
+	}
+
+	for _, malformed := range []string{"", "plain", "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5"} {
+		if _, err := testHasher.Verify("correct horse", malformed); err == nil {
+			t.Errorf("Expected error for malformed hash %q", malformed)
+		}
+	}
+}
+
+func TestPasswordLogin(t *testing.T) {
+	server, _ := newTokenServer(t, usecases.AuthConfig{MaxLoginAttempts: 3})
+	request := func(method, path, token string, body interface{}) *http.Response {
+		t.Helper()
+		return jsonRequest(t, server, method, path, token, body)
+	}
+
+	var registered httpAdapters.AuthenticatedUserResponse
+	status := decodeResponse(request("POST", "/users", "", map[string]string{"username": "ivan", "password": "first password"}), &registered)
+	if status != http.StatusCreated {
+		t.Fatalf("Registration failed: %d", status)
+	}
+
+	// Login ignores the case of the username
+	var session httpAdapters.AuthenticatedUserResponse
+	status = decodeResponse(request("POST", "/auth/login", "", map[string]string{"username": "IVAN", "password": "first password"}), &session)
+	if status != http.StatusOK || session.ID != registered.ID || session.TokenResponse == nil || session.AccessToken == "" {
+		t.Fatalf("Expected login to succeed, got %d %+v", status, session)
+	}
+
+	// Unknown users and wrong passwords get the same answer
+	for _, login := range []map[string]string{
+		{"username": "ivan", "password": "wrong password"},
+		{"username": "nobody", "password": "first password"},
+		{"username": "user1", "password": "first password"}, // seed user without password
+	} {
+		var errResp httpAdapters.ProblemResponse
+		if status := decodeResponse(request("POST", "/auth/login", "", login), &errResp); status != http.StatusUnauthorized || errResp.Code != "invalid_login" || errResp.Detail != domain.ErrInvalidLogin.Error() {
+			t.Errorf("Expected 401 for %v, got %d %+v", login, status, errResp)
+		}
+	}
+
+	// Refresh tokens can only be used once: reusing one means it leaked,
+	// and revokes every token of its session
+	var refreshed httpAdapters.TokenResponse
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": session.RefreshToken}), &refreshed); status != http.StatusOK {
+		t.Fatalf("Expected refresh to succeed, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": session.RefreshToken}), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected reused refresh token to be rejected, got %d", status)
+	}
+	if status := decodeResponse(request("GET", "/users/me", refreshed.AccessToken, nil), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected the session of a reused refresh token to be revoked, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": refreshed.RefreshToken}), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected the session of a reused refresh token to be revoked, got %d", status)
+	}
+
+	// Logout revokes every token of the session
+	status = decodeResponse(request("POST", "/auth/login", "", map[string]string{"username": "ivan", "password": "first password"}), &session)
+	if status != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": session.RefreshToken}), &refreshed); status != http.StatusOK {
+		t.Fatalf("Expected refresh to succeed, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/logout", refreshed.AccessToken, map[string]string{"refresh_token": refreshed.RefreshToken}), nil); status != http.StatusOK {
+		t.Fatalf("Expected logout to succeed, got %d", status)
+	}
+	for _, token := range []string{refreshed.AccessToken, session.AccessToken} {
+		if status := decodeResponse(request("GET", "/users/me", token, nil), nil); status != http.StatusUnauthorized {
+			t.Errorf("Expected revoked access token to be rejected, got %d", status)
+		}
+	}
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": refreshed.RefreshToken}), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected revoked refresh token to be rejected, got %d", status)
+	}
+
+	// Other sessions are still valid
+	if status := decodeResponse(request("GET", "/users/me", registered.AccessToken, nil), nil); status != http.StatusOK {
+		t.Errorf("Expected other session to stay valid, got %d", status)
+	}
+
+	// Password change: wrong current password, weak new password, success
+	change := func(current, next string) (int, httpAdapters.TokenResponse) {
+		var tokens httpAdapters.TokenResponse
+		status := decodeResponse(request("PUT", "/users/me/password", registered.AccessToken,
+			map[string]string{"current_password": current, "new_password": next}), &tokens)
+		return status, tokens
+	}
+	if status, _ := change("wrong password", "second password"); status != http.StatusForbidden {
+		t.Errorf("Expected 403 with wrong current password, got %d", status)
+	}
+	if status, _ := change("first password", "short"); status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 with short new password, got %d", status)
+	}
+
+	// Tokens issued before the change are revoked; the change returns new ones.
+	// Wait for the next second, token times have a resolution of seconds
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	status, changed := change("first password", "second password")
+	if status != http.StatusOK || changed.AccessToken == "" {
+		t.Fatalf("Expected password change to succeed, got %d", status)
+	}
+	if status := decodeResponse(request("GET", "/users/me", registered.AccessToken, nil), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected tokens issued before the change to be revoked, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/refresh", "", map[string]string{"refresh_token": registered.RefreshToken}), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected refresh tokens issued before the change to be revoked, got %d", status)
+	}
+	if status := decodeResponse(request("GET", "/users/me", changed.AccessToken, nil), nil); status != http.StatusOK {
+		t.Errorf("Expected new access token to be valid, got %d", status)
+	}
+
+	if status := decodeResponse(request("POST", "/auth/login", "", map[string]string{"username": "ivan", "password": "first password"}), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected old password to be rejected, got %d", status)
+	}
+	if status := decodeResponse(request("POST", "/auth/login", "", map[string]string{"username": "ivan", "password": "second password"}), nil); status != http.StatusOK {
+		t.Errorf("Expected new password to be accepted, got %d", status)
+	}
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	server, _ := newTokenServer(t, usecases.AuthConfig{})
+
+	var registered httpAdapters.AuthenticatedUserResponse
+	status := decodeResponse(jsonRequest(t, server, "POST", "/users", "", map[string]string{"username": "oscar", "password": "correct horse"}), &registered)
+	if status != http.StatusCreated {
+		t.Fatalf("Registration failed: %d", status)
+	}
+
+	// Only one of the concurrent refreshes with a token gets new tokens
+	var wg sync.WaitGroup
+	statuses := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- decodeResponse(jsonRequest(t, server, "POST", "/auth/refresh", "", map[string]string{"refresh_token": registered.RefreshToken}), nil)
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	succeeded := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			succeeded++
+		} else if status != http.StatusUnauthorized {
+			t.Errorf("Expected 200 or 401, got %d", status)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected one refresh to succeed, got %d", succeeded)
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	server, _ := newTokenServer(t, usecases.AuthConfig{MaxLoginAttempts: 3, MaxLoginAttemptsPerIP: 10})
+	login := func(username, password string) int {
+		t.Helper()
+		return decodeResponse(jsonRequest(t, server, "POST", "/auth/login", "", map[string]string{"username": username, "password": password}), nil)
+	}
+
+	for _, username := range []string{"judy", "mallory"} {
+		var registered httpAdapters.AuthenticatedUserResponse
+		decodeResponse(jsonRequest(t, server, "POST", "/users", "", map[string]string{"username": username, "password": "right password"}), &registered)
+	}
+
+	// A successful login does not count
+	if status := login("judy", "right password"); status != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d", status)
+	}
+
+	// Concurrent guesses can not exceed the limit of the username
+	var wg sync.WaitGroup
+	var throttled int32
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if login("judy", "wrong password") == http.StatusTooManyRequests {
+				atomic.AddInt32(&throttled, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if throttled != 3 {
+		t.Errorf("Expected 3 throttled attempts, got %d", throttled)
+	}
+
+	// Locked out even with the right password, other users are not affected
+	if status := login("Judy", "right password"); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for locked username, got %d", status)
+	}
+	if status := login("mallory", "right password"); status != http.StatusOK {
+		t.Errorf("Expected other user to log in, got %d", status)
+	}
+
+	// The client IP has its own limit across usernames (3 failures so far)
+	for i := 0; i < 7; i++ {
+		login(fmt.Sprintf("guess%d", i), "wrong password")
+	}
+	if status := login("mallory", "right password"); status != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for throttled IP, got %d", status)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	server, _ := newTokenServer(t, usecases.AuthConfig{})
+	request := func(method, path, token string, body interface{}) int {
+		t.Helper()
+		return decodeResponse(jsonRequest(t, server, method, path, token, body), nil)
+	}
+
+	var session httpAdapters.AuthenticatedUserResponse
+	decodeResponse(jsonRequest(t, server, "POST", "/users", "", map[string]string{"username": "kevin", "password": "kevin password"}), &session)
+
+	if status := request("POST", "/users/me/api-keys", session.AccessToken, map[string]interface{}{"name": "bot", "scopes": []string{"admin"}}); status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for unknown scope, got %d", status)
+	}
+
+	var created httpAdapters.CreatedAPIKeyResponse
+	status := decodeResponse(jsonRequest(t, server, "POST", "/users/me/api-keys", session.AccessToken,
+		map[string]interface{}{"name": "bot", "scopes": []string{domain.ScopeTweetsWrite}}), &created)
+	if status != http.StatusCreated || !strings.HasPrefix(created.Key, domain.APIKeyPrefix) || created.LastUsedAt != "" {
+		t.Fatalf("Expected API key to be created, got %d %+v", status, created)
+	}
+
+	// The key posts as its owner, only within its scopes
+	var tweet httpAdapters.TweetResponse
+	if status := decodeResponse(jsonRequest(t, server, "POST", "/tweets", created.Key, map[string]string{"content": "from a bot"}), &tweet); status != http.StatusCreated || tweet.UserID != session.ID {
+		t.Errorf("Expected tweet by %s, got %d %+v", session.ID, status, tweet)
+	}
+	if status := request("POST", "/users/following", created.Key, map[string]string{"followee_id": "user1"}); status != http.StatusForbidden {
+		t.Errorf("Expected 403 without follows:write, got %d", status)
+	}
+	if status := request("GET", "/users/"+session.ID+"/timeline", created.Key, nil); status != http.StatusForbidden {
+		t.Errorf("Expected 403 without timeline:read, got %d", status)
+	}
+
+	// Keys can not manage the account
+	for _, path := range []string{"/users/me/api-keys", "/users/me"} {
+		if status := request("GET", path, created.Key, nil); status != http.StatusForbidden {
+			t.Errorf("Expected 403 for %s with API key, got %d", path, status)
+		}
+	}
+	if status := request("POST", "/auth/logout", created.Key, nil); status != http.StatusForbidden {
+		t.Errorf("Expected 403 logging out with API key, got %d", status)
+	}
+
+	// Listing shows the last use but never the key
+	var listed struct {
+		APIKeys []map[string]interface{} `json:"api_keys"`
+	}
+	decodeResponse(jsonRequest(t, server, "GET", "/users/me/api-keys", session.AccessToken, nil), &listed)
+	if len(listed.APIKeys) != 1 || listed.APIKeys[0]["id"] != created.ID || listed.APIKeys[0]["last_used_at"] == nil {
+		t.Errorf("Unexpected API keys: %+v", listed.APIKeys)
+	}
+	if _, exposed := listed.APIKeys[0]["key"]; exposed {
+		t.Error("Expected the key not to be listed")
+	}
+
+	// Revoked keys stop working; other users can not revoke them
+	if status := request("DELETE", "/users/me/api-keys/"+created.ID, "", nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 revoking anonymously, got %d", status)
+	}
+	if status := request("DELETE", "/users/me/api-keys/"+created.ID, session.AccessToken, nil); status != http.StatusOK {
+		t.Fatalf("Expected key to be revoked, got %d", status)
+	}
+	if status := request("POST", "/tweets", created.Key, map[string]string{"content": "revoked"}); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with revoked key, got %d", status)
+	}
+	if status := request("POST", "/tweets", created.Key+"x", map[string]string{"content": "forged"}); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with forged key, got %d", status)
+	}
+}
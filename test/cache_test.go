@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/cache"
+	"twitter-clone-backend/internal/adapters/events"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+func TestMemoryCacheHitMissAndLRUEviction(t *testing.T) {
+	c := cache.NewMemoryCache(2, time.Minute)
+	ctx := context.Background()
+
+	tweet, _ := domain.NewTweet("user1", "cached tweet")
+
+	// Miss on empty cache
+	if tweets, _ := c.GetTimeline(ctx, "user1"); tweets != nil {
+		t.Fatalf("Expected cache miss, got %d tweets", len(tweets))
+	}
+
+	c.SetTimeline(ctx, "user1", []*domain.Tweet{tweet})
+	c.SetTimeline(ctx, "user2", nil)
+
+	// Touch user1 so user2 becomes the least recently used entry
+	if tweets, _ := c.GetTimeline(ctx, "user1"); len(tweets) != 1 {
+		t.Fatalf("Expected 1 cached tweet, got %d", len(tweets))
+	}
+
+	c.SetTimeline(ctx, "user3", nil)
+
+	if tweets, _ := c.GetTimeline(ctx, "user2"); tweets != nil {
+		t.Error("Expected user2 to be evicted")
+	}
+	if tweets, _ := c.GetTimeline(ctx, "user1"); tweets == nil {
+		t.Error("Expected user1 to remain cached")
+	}
+	if tweets, _ := c.GetTimeline(ctx, "user3"); tweets == nil {
+		t.Error("Expected empty timeline of user3 to be cached")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Misses != 2 || stats.Evictions != 1 || stats.Size != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestMemoryCacheTTLAndInvalidation(t *testing.T) {
+	c := cache.NewMemoryCache(10, time.Minute)
+	ctx := context.Background()
+
+	c.SetTimelineWithTTL(ctx, "user1", nil, 10*time.Millisecond)
+	c.SetTimeline(ctx, "user2", nil)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if tweets, _ := c.GetTimeline(ctx, "user1"); tweets != nil {
+		t.Error("Expected user1 entry to be expired")
+	}
+
+	c.InvalidateTimeline(ctx, "user2")
+	if tweets, _ := c.GetTimeline(ctx, "user2"); tweets != nil {
+		t.Error("Expected user2 entry to be invalidated")
+	}
+
+	if size := c.Stats().Size; size != 0 {
+		t.Errorf("Expected empty cache, got %d entries", size)
+	}
+}
+
+func TestTimelineWithCacheRespectsLimit(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	c := cache.NewMemoryCache(100, time.Minute)
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, c, logger)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := tweetUseCase.CreateTweet(ctx, "user1", fmt.Sprintf("Tweet %d", i)); err != nil {
+			t.Fatalf("Error creating tweet: %v", err)
+		}
+	}
+
+	tweets, err := tweetUseCase.GetTimeline(ctx, "user1", 2)
+	if err != nil || len(tweets) != 2 {
+		t.Fatalf("Expected 2 tweets, got %d (err: %v)", len(tweets), err)
+	}
+
+	// Wait for the asynchronous cache fill
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().Size == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A larger limit must still be served completely from the cached page
+	tweets, err = tweetUseCase.GetTimeline(ctx, "user1", 10)
+	if err != nil || len(tweets) != 5 {
+		t.Fatalf("Expected 5 tweets, got %d (err: %v)", len(tweets), err)
+	}
+	if c.Stats().Hits != 1 {
+		t.Errorf("Expected timeline to be served from cache, stats: %+v", c.Stats())
+	}
+}
+
+func TestDeleteTweetInvalidatesCachedTimelines(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	c := cache.NewMemoryCache(100, time.Minute)
+	bus := events.NewBus(logger, events.BusConfig{})
+	defer bus.Close(context.Background())
+	bus.Subscribe("timeline-cache", usecases.NewTimelineCacheInvalidator(repo, c, logger).HandleEvent)
+	relay := usecases.NewOutboxRelay(repo, bus, logger, usecases.OutboxRelayConfig{})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, c, logger)
+	ctx := context.Background()
+
+	repo.Follow(ctx, "user2", "user1")
+	tweet, _ := tweetUseCase.CreateTweet(ctx, "user1", "Soon deleted")
+	relay.RelayPending(ctx)
+
+	// Cache both the author's and the follower's timelines
+	for _, userID := range []string{"user1", "user2"} {
+		c.SetTimeline(ctx, userID, []*domain.Tweet{tweet})
+	}
+
+	if err := tweetUseCase.DeleteTweet(ctx, "user1", tweet.ID); err != nil {
+		t.Fatalf("Error deleting tweet: %v", err)
+	}
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 1 {
+		t.Fatalf("Expected the deletion to be relayed, got %d (err: %v)", relayed, err)
+	}
+
+	for _, userID := range []string{"user1", "user2"} {
+		if tweets, _ := c.GetTimeline(ctx, userID); tweets != nil {
+			t.Errorf("Expected %s timeline to be invalidated", userID)
+		}
+	}
+}
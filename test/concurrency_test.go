@@ -59,7 +59,7 @@ func TestConcurrentFollowOperations(t *testing.T) {
 	// Setup
 	repo := memory.NewRepositories()
 	logger := logger.NewLogger()
-	followUseCase := usecases.NewFollowUseCase(repo, repo, nil, logger)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
 
 	const numGoroutines = 50
 	ctx := context.Background()
@@ -128,7 +128,7 @@ func TestConcurrentTimelineAccess(t *testing.T) {
 	repo := memory.NewRepositories()
 	logger := logger.NewLogger()
 	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger)
-	followUseCase := usecases.NewFollowUseCase(repo, repo, nil, logger)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
 
 	ctx := context.Background()
 
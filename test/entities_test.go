@@ -0,0 +1,72 @@
+package test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/domain"
+)
+
+func TestExtractEntities(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected *domain.Entities
+	}{
+		{"none", "just text", nil},
+		{"hashtag", "#go rocks", &domain.Entities{
+			Hashtags: []domain.TagEntity{{Start: 0, End: 3, Tag: "go"}},
+		}},
+		{"offsets in code points", "café #ñandú 😀 #x1", &domain.Entities{
+			Hashtags: []domain.TagEntity{{Start: 5, End: 11, Tag: "ñandú"}, {Start: 14, End: 17, Tag: "x1"}},
+		}},
+		{"numeric hashtag", "#2024 and a#b", nil},
+		{"mention", "hi @Bob_1, @alice!", &domain.Entities{
+			Mentions: []domain.MentionEntity{{Start: 3, End: 9, Username: "Bob_1"}, {Start: 11, End: 17, Username: "alice"}},
+		}},
+		{"email and long username", "mail me@example.com or @abcdefghijklmnopq", nil},
+		{"cashtag", "buy $GOOG and $BRK.A, not $1 or $TOOLONG", &domain.Entities{
+			Cashtags: []domain.TagEntity{{Start: 4, End: 9, Tag: "GOOG"}, {Start: 14, End: 20, Tag: "BRK.A"}},
+		}},
+		{"url", "see https://example.com/a#frag?x=@y.", &domain.Entities{
+			URLs: []domain.URLEntity{{Start: 4, End: 35, URL: "https://example.com/a#frag?x=@y"}},
+		}},
+		{"mixed", "@bob #go www.go.dev", &domain.Entities{
+			Hashtags: []domain.TagEntity{{Start: 5, End: 8, Tag: "go"}},
+			Mentions: []domain.MentionEntity{{Start: 0, End: 4, Username: "bob"}},
+			URLs:     []domain.URLEntity{{Start: 9, End: 19, URL: "www.go.dev"}},
+		}},
+	}
+
+	for _, c := range cases {
+		if entities := domain.ExtractEntities(c.text); !reflect.DeepEqual(entities, c.expected) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, entities)
+		}
+	}
+}
+
+func TestTweetEntities(t *testing.T) {
+	server := newHeaderServer(t)
+
+	var tweet httpAdapters.TweetResponse
+	body := map[string]string{"content": "hey @BOB and @nobody #go"}
+	if status := decodeResponse(headerRequest(t, server, "POST", "/tweets", "user1", body), &tweet); status != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+
+	// Mentions are resolved to the user; unknown usernames are not mentions
+	expected := &domain.Entities{
+		Hashtags: []domain.TagEntity{{Start: 21, End: 24, Tag: "go"}},
+		Mentions: []domain.MentionEntity{{Start: 4, End: 8, Username: "BOB", UserID: "user2"}},
+	}
+	if !reflect.DeepEqual(tweet.Entities, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, tweet.Entities)
+	}
+
+	var got httpAdapters.TweetResponse
+	decodeResponse(headerRequest(t, server, "GET", "/tweets/"+tweet.ID, "", nil), &got)
+	if !reflect.DeepEqual(got.Entities, expected) {
+		t.Errorf("Expected the entities stored, got %+v", got.Entities)
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+// failingTweetRepository fails every tweet write with an internal error
+type failingTweetRepository struct {
+	*memory.Repositories
+}
+
+func (r failingTweetRepository) Create(ctx context.Context, tweet *domain.Tweet, events ...domain.Event) error {
+	return errors.New("connection refused: db.internal:5432")
+}
+
+func TestProblemResponses(t *testing.T) {
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	handlers := httpAdapters.NewHandlers(
+		usecases.NewTweetUseCase(failingTweetRepository{repo}, repo, repo, nil, appLogger),
+		usecases.NewFollowUseCase(repo, repo, appLogger),
+		usecases.NewUserUseCase(repo, appLogger),
+		appLogger,
+	)
+	server := httptest.NewServer(httpAdapters.SetupRoutes(handlers))
+	defer server.Close()
+
+	request := func(method, path, userID string, body interface{}) (int, httpAdapters.ProblemResponse, string) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(data))
+		if userID != "" {
+			req.Header.Set("X-User-ID", userID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var problem httpAdapters.ProblemResponse
+		status := decodeResponse(resp, &problem)
+		return status, problem, resp.Header.Get("Content-Type")
+	}
+
+	if status, _, _ := request("POST", "/users/following", "user1", map[string]string{"followee_id": "user2"}); status != http.StatusOK {
+		t.Fatalf("Expected follow to succeed, got %d", status)
+	}
+
+	cases := []struct {
+		name         string
+		method, path string
+		userID       string
+		body         interface{}
+		status       int
+		code         string
+		detail       string
+	}{
+		{"unknown user", "GET", "/users/nobody", "", nil, http.StatusNotFound, "user_not_found", domain.ErrUserNotFound.Error()},
+		{"unknown tweet", "GET", "/tweets/nothing", "", nil, http.StatusNotFound, "tweet_not_found", domain.ErrTweetNotFound.Error()},
+		{"follow unknown user", "POST", "/users/following", "user1", map[string]string{"followee_id": "nobody"}, http.StatusNotFound, "user_not_found", domain.ErrUserNotFound.Error()},
+		{"follow twice", "POST", "/users/following", "user1", map[string]string{"followee_id": "user2"}, http.StatusConflict, "already_following", domain.ErrAlreadyFollowing.Error()},
+		{"follow self", "POST", "/users/following", "user1", map[string]string{"followee_id": "user1"}, http.StatusUnprocessableEntity, "cannot_follow_self", domain.ErrCannotFollowSelf.Error()},
+		{"unfollow not followed", "DELETE", "/users/following/user3", "user1", nil, http.StatusNotFound, "not_following", domain.ErrNotFollowing.Error()},
+		{"empty tweet", "POST", "/tweets", "user1", map[string]string{"content": ""}, http.StatusUnprocessableEntity, "empty_content", domain.ErrEmptyContent.Error()},
+		{"long tweet", "POST", "/tweets", "user1", map[string]string{"content": strings.Repeat("a", 281)}, http.StatusUnprocessableEntity, "content_too_long", domain.ErrContentTooLong.Error()},
+		{"bad cursor", "GET", "/users/user1/tweets?max_id=garbage", "", nil, http.StatusBadRequest, "invalid_cursor", domain.ErrInvalidCursor.Error()},
+		{"anonymous", "POST", "/tweets", "", map[string]string{"content": "hi"}, http.StatusUnauthorized, "unauthenticated", domain.ErrUnauthenticated.Error()},
+		{"wrong method", "PUT", "/tweets", "user1", nil, http.StatusMethodNotAllowed, "method_not_allowed", ""},
+		{"internal error", "POST", "/tweets", "user1", map[string]string{"content": "hi"}, http.StatusInternalServerError, "internal_error", "an internal error occurred"},
+	}
+
+	for _, c := range cases {
+		status, problem, contentType := request(c.method, c.path, c.userID, c.body)
+		if status != c.status || problem.Status != c.status || problem.Code != c.code {
+			t.Errorf("%s: expected %d %s, got %d %+v", c.name, c.status, c.code, status, problem)
+		}
+		if contentType != "application/problem+json" {
+			t.Errorf("%s: expected problem+json content type, got %q", c.name, contentType)
+		}
+		if problem.Type != "about:blank" || problem.Title != http.StatusText(c.status) {
+			t.Errorf("%s: unexpected type or title %+v", c.name, problem)
+		}
+		// The text of internal errors is never sent
+		if c.detail != "" && problem.Detail != c.detail {
+			t.Errorf("%s: expected detail %q, got %q", c.name, c.detail, problem.Detail)
+		}
+	}
+}
@@ -0,0 +1,572 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/events"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+// recordedEvents collects the events handled by a subscriber
+type recordedEvents struct {
+	events []domain.Event
+	mu     sync.Mutex
+}
+
+func (r *recordedEvents) handle(ctx context.Context, event domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// Publish implements ports.EventPublisher synchronously
+func (r *recordedEvents) Publish(ctx context.Context, event domain.Event) error {
+	return r.handle(ctx, event)
+}
+
+func (r *recordedEvents) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, event := range r.events {
+		types = append(types, event.EventType())
+	}
+	return types
+}
+
+// newTestBus creates a bus with fast retries, closed at the end of the test
+func newTestBus(t *testing.T, config events.BusConfig) *events.Bus {
+	t.Helper()
+	config.RetryBackoff = time.Millisecond
+	bus := events.NewBus(logger.NewLogger(), config)
+	t.Cleanup(func() { bus.Close(context.Background()) })
+	return bus
+}
+
+// flush waits for the bus to handle every queued event
+func flush(t *testing.T, bus *events.Bus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := bus.Flush(ctx); err != nil {
+		t.Fatalf("Events not handled: %v", err)
+	}
+}
+
+func assertEventTypes(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, got)
+		}
+	}
+}
+
+func TestEventBusDeliversToEverySubscriber(t *testing.T) {
+	bus := newTestBus(t, events.BusConfig{})
+	ctx := context.Background()
+
+	var all, follows recordedEvents
+	bus.Subscribe("all", all.handle)
+	bus.Subscribe("follows", follows.handle, domain.EventUserFollowed, domain.EventUserUnfollowed)
+
+	tweet, _ := domain.NewTweet("user1", "hello")
+	bus.Publish(ctx, domain.NewTweetCreated(tweet, nil))
+	bus.Publish(ctx, domain.NewUserFollowed("user2", "user1"))
+	bus.Publish(ctx, domain.NewUserUnfollowed("user2", "user1"))
+	flush(t, bus)
+
+	// Each subscriber gets the events of its types in publishing order
+	assertEventTypes(t, all.types(), domain.EventTweetCreated, domain.EventUserFollowed, domain.EventUserUnfollowed)
+	assertEventTypes(t, follows.types(), domain.EventUserFollowed, domain.EventUserUnfollowed)
+
+	if stats := bus.Stats(); stats.Delivered != 5 || stats.Pending != 0 {
+		t.Errorf("Expected 5 deliveries, stats: %+v", stats)
+	}
+}
+
+func TestEventBusRetriesFailingHandlers(t *testing.T) {
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 3})
+	ctx := context.Background()
+
+	// Fails twice, then succeeds
+	var attempts int
+	bus.Subscribe("flaky", func(ctx context.Context, event domain.Event) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	// Always fails
+	bus.Subscribe("broken", func(ctx context.Context, event domain.Event) error {
+		return errors.New("permanent failure")
+	})
+
+	bus.Publish(ctx, domain.NewUserFollowed("user2", "user1"))
+	flush(t, bus)
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	stats := bus.Stats()
+	if stats.Delivered != 1 || stats.Failed != 1 || stats.Retried != 4 {
+		t.Errorf("Expected 1 delivered, 1 failed and 4 retries, stats: %+v", stats)
+	}
+}
+
+func TestEventBusIsolatesPanics(t *testing.T) {
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 1})
+	ctx := context.Background()
+
+	var healthy recordedEvents
+	var panicking recordedEvents
+	bus.Subscribe("healthy", healthy.handle)
+	bus.Subscribe("panicking", func(ctx context.Context, event domain.Event) error {
+		if event.EventType() == domain.EventUserFollowed {
+			panic("handler bug")
+		}
+		return panicking.handle(ctx, event)
+	})
+
+	bus.Publish(ctx, domain.NewUserFollowed("user2", "user1"))
+	bus.Publish(ctx, domain.NewUserUnfollowed("user2", "user1"))
+	flush(t, bus)
+
+	// The panic neither reaches the other subscriber nor stops the worker
+	assertEventTypes(t, healthy.types(), domain.EventUserFollowed, domain.EventUserUnfollowed)
+	assertEventTypes(t, panicking.types(), domain.EventUserUnfollowed)
+	if stats := bus.Stats(); stats.Failed != 1 {
+		t.Errorf("Expected the panic to fail one delivery, stats: %+v", stats)
+	}
+}
+
+func TestEventBusDropsEventsWhenQueueIsFull(t *testing.T) {
+	bus := newTestBus(t, events.BusConfig{QueueSize: 2})
+	ctx := context.Background()
+
+	// The slow subscriber handles nothing until released
+	release := make(chan struct{})
+	released := false
+	defer func() {
+		if !released {
+			close(release)
+		}
+	}()
+	started := make(chan struct{}, 1)
+	var slow, fast recordedEvents
+	bus.Subscribe("slow", func(ctx context.Context, event domain.Event) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return slow.handle(ctx, event)
+	})
+	bus.Subscribe("fast", fast.handle)
+
+	// publish waits until the fast subscriber handled the event, so only
+	// the slow subscriber's queue fills up
+	publish := func(event domain.Event) error {
+		t.Helper()
+		handled := len(fast.types())
+		err := bus.Publish(ctx, event)
+		deadline := time.Now().Add(5 * time.Second)
+		for len(fast.types()) == handled && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return err
+	}
+
+	// One event is being handled and two fill the queue
+	if err := publish(domain.NewUserFollowed("user2", "user1")); err != nil {
+		t.Fatalf("Error publishing: %v", err)
+	}
+	<-started
+	for i := 0; i < 2; i++ {
+		if err := publish(domain.NewUserFollowed("user3", "user1")); err != nil {
+			t.Fatalf("Error publishing: %v", err)
+		}
+	}
+
+	// The publisher is not blocked: the event is dropped for the slow
+	// subscriber only
+	if err := publish(domain.NewUserUnfollowed("user2", "user1")); err != events.ErrQueueFull {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+
+	released = true
+	close(release)
+	flush(t, bus)
+
+	if got := len(slow.types()); got != 3 {
+		t.Errorf("Expected slow subscriber to handle 3 events, got %d", got)
+	}
+	if got := len(fast.types()); got != 4 {
+		t.Errorf("Expected fast subscriber to handle 4 events, got %d", got)
+	}
+	if stats := bus.Stats(); stats.Dropped != 1 {
+		t.Errorf("Expected 1 dropped event, stats: %+v", stats)
+	}
+}
+
+func TestEventBusCloseDeliversPendingEvents(t *testing.T) {
+	bus := events.NewBus(logger.NewLogger(), events.BusConfig{})
+	ctx := context.Background()
+
+	var recorded recordedEvents
+	bus.Subscribe("slow", func(ctx context.Context, event domain.Event) error {
+		time.Sleep(time.Millisecond)
+		return recorded.handle(ctx, event)
+	})
+	for i := 0; i < 10; i++ {
+		bus.Publish(ctx, domain.NewUserFollowed("user2", "user1"))
+	}
+
+	if err := bus.Close(ctx); err != nil {
+		t.Fatalf("Error closing bus: %v", err)
+	}
+	if got := len(recorded.types()); got != 10 {
+		t.Errorf("Expected 10 events handled before closing, got %d", got)
+	}
+	if err := bus.Publish(ctx, domain.NewUserFollowed("user2", "user1")); err != events.ErrBusClosed {
+		t.Errorf("Expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestEventBusSkipsDuplicateEvents(t *testing.T) {
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 1})
+	ctx := context.Background()
+
+	// Fails the first delivery of each event
+	var recorded recordedEvents
+	failed := make(map[string]bool)
+	bus.Subscribe("flaky", func(ctx context.Context, event domain.Event) error {
+		if !failed[event.EventID()] {
+			failed[event.EventID()] = true
+			return errors.New("temporary failure")
+		}
+		return recorded.handle(ctx, event)
+	})
+
+	event := domain.NewUserFollowed("user2", "user1")
+	for i := 0; i < 3; i++ {
+		bus.Publish(ctx, event)
+	}
+	flush(t, bus)
+
+	// A failed delivery is not remembered, a handled one is
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed)
+	stats := bus.Stats()
+	if stats.Failed != 1 || stats.Delivered != 1 || stats.Duplicates != 1 {
+		t.Errorf("Expected 1 failed, 1 delivered and 1 duplicate, stats: %+v", stats)
+	}
+}
+
+func TestUseCasesStoreDomainEventsInOutbox(t *testing.T) {
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	ctx := context.Background()
+
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, appLogger)
+	likeUseCase := usecases.NewLikeUseCase(repo, repo, repo, appLogger)
+
+	if err := followUseCase.FollowUser(ctx, "user2", "user1"); err != nil {
+		t.Fatalf("Error following: %v", err)
+	}
+	tweet, _ := tweetUseCase.CreateTweet(ctx, "user1", "hello")
+	reply, _ := tweetUseCase.CreateReply(ctx, "user2", tweet.ID, "hi")
+	retweet, _ := tweetUseCase.Retweet(ctx, "user3", tweet.ID)
+	likeUseCase.LikeTweet(ctx, "user3", retweet.ID)
+	likeUseCase.UnlikeTweet(ctx, "user3", tweet.ID)
+	tweetUseCase.UndoRetweet(ctx, "user3", tweet.ID)
+	tweetUseCase.DeleteTweet(ctx, "user2", reply.ID)
+	followUseCase.UnfollowUser(ctx, "user2", "user1")
+
+	// Failed actions store nothing
+	if err := followUseCase.FollowUser(ctx, "user2", "user2"); err == nil {
+		t.Fatal("Expected following oneself to fail")
+	}
+	if err := followUseCase.UnfollowUser(ctx, "user2", "user1"); err != domain.ErrNotFollowing {
+		t.Fatalf("Expected ErrNotFollowing, got %v", err)
+	}
+	if _, err := tweetUseCase.Retweet(ctx, "user2", tweet.ID); err != nil {
+		t.Fatalf("Error retweeting: %v", err)
+	}
+	if _, err := tweetUseCase.Retweet(ctx, "user2", tweet.ID); err != domain.ErrAlreadyRetweeted {
+		t.Fatalf("Expected ErrAlreadyRetweeted, got %v", err)
+	}
+
+	entries, err := repo.PendingEvents(ctx, 100)
+	if err != nil {
+		t.Fatalf("Error reading outbox: %v", err)
+	}
+	var recorded recordedEvents
+	for _, entry := range entries {
+		event, err := entry.Event()
+		if err != nil {
+			t.Fatalf("Error decoding %s entry: %v", entry.Type, err)
+		}
+		if event.EventID() != entry.ID {
+			t.Errorf("Expected entry ID %s to be the event ID, got %s", entry.ID, event.EventID())
+		}
+		recorded.handle(ctx, event)
+	}
+
+	assertEventTypes(t, recorded.types(),
+		domain.EventUserFollowed,
+		domain.EventTweetCreated,
+		domain.EventTweetCreated,
+		domain.EventTweetCreated,
+		domain.EventTweetLiked,
+		domain.EventTweetUnliked,
+		domain.EventTweetDeleted,
+		domain.EventTweetDeleted,
+		domain.EventUserUnfollowed,
+		domain.EventTweetCreated,
+	)
+
+	// Events carry who they concern
+	created := recorded.events[2].(domain.TweetCreated)
+	if created.Tweet.ID != reply.ID || created.ReferencedUserID != "user1" {
+		t.Errorf("Expected reply to reference user1, got %+v", created)
+	}
+	liked := recorded.events[4].(domain.TweetLiked)
+	if liked.Like.TweetID != tweet.ID || liked.AuthorID != "user1" {
+		t.Errorf("Expected like of a retweet to be a like of the original, got %+v", liked)
+	}
+	if deleted := recorded.events[6].(domain.TweetDeleted); deleted.Tweet.ID != retweet.ID {
+		t.Errorf("Expected undone retweet to be deleted, got %s", deleted.Tweet.ID)
+	}
+
+	ids := make(map[string]bool)
+	for _, event := range recorded.events {
+		if event.EventID() == "" || event.OccurredAt().IsZero() || ids[event.EventID()] {
+			t.Errorf("Expected a unique ID and time, got %+v", event)
+		}
+		ids[event.EventID()] = true
+	}
+}
+
+// flakyOutbox fails marking entries as relayed while failures remain, as a
+// crash between delivering the events and removing them would
+type flakyOutbox struct {
+	*memory.Repositories
+	failures int
+}
+
+func (o *flakyOutbox) MarkRelayed(ctx context.Context, ids []string) error {
+	if o.failures > 0 {
+		o.failures--
+		return errors.New("outbox unavailable")
+	}
+	return o.Repositories.MarkRelayed(ctx, ids)
+}
+
+func TestOutboxRelayDeliversAtLeastOnce(t *testing.T) {
+	repo := memory.NewRepositories()
+	outbox := &flakyOutbox{Repositories: repo, failures: 1}
+	bus := newTestBus(t, events.BusConfig{})
+	relay := usecases.NewOutboxRelay(outbox, bus, logger.NewLogger(), usecases.OutboxRelayConfig{BatchSize: 2})
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger.NewLogger())
+	ctx := context.Background()
+
+	var recorded recordedEvents
+	bus.Subscribe("recorder", recorded.handle)
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	followUseCase.UnfollowUser(ctx, "user2", "user1")
+
+	// The first batch is delivered but stays in the outbox
+	if _, err := relay.RelayPending(ctx); err == nil {
+		t.Fatal("Expected relaying to fail")
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed, domain.EventUserFollowed)
+
+	// Relaying again delivers the batch again, which subscribers skip
+	relayed, err := relay.RelayPending(ctx)
+	if err != nil || relayed != 3 {
+		t.Fatalf("Expected 3 events relayed, got %d (err: %v)", relayed, err)
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed, domain.EventUserFollowed, domain.EventUserUnfollowed)
+	if stats := bus.Stats(); stats.Duplicates != 2 {
+		t.Errorf("Expected 2 duplicates, stats: %+v", stats)
+	}
+
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 0 {
+		t.Errorf("Expected an empty outbox, got %d entries", len(entries))
+	}
+}
+
+func TestOutboxRelayDiscardsUnknownEvents(t *testing.T) {
+	repo := memory.NewRepositories()
+	bus := newTestBus(t, events.BusConfig{})
+	relay := usecases.NewOutboxRelay(repo, bus, logger.NewLogger(), usecases.OutboxRelayConfig{})
+	ctx := context.Background()
+
+	var recorded recordedEvents
+	bus.Subscribe("recorder", recorded.handle)
+
+	repo.AppendOutbox([]*domain.OutboxEntry{{ID: "unknown", Type: "tweet.exploded", CreatedAt: time.Now()}})
+	repo.FollowIfNotExists(ctx, "user2", "user1", domain.NewUserFollowed("user2", "user1"))
+
+	// Relaying an unknown event would fail forever, it does not block the rest
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 2 {
+		t.Fatalf("Expected 2 entries relayed, got %d (err: %v)", relayed, err)
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed)
+}
+
+func TestOutboxRelayKeepsEventsSubscribersFailed(t *testing.T) {
+	repo := memory.NewRepositories()
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 2})
+	relay := usecases.NewOutboxRelay(repo, bus, logger.NewLogger(), usecases.OutboxRelayConfig{RetryBackoff: 100 * time.Millisecond})
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger.NewLogger())
+	ctx := context.Background()
+
+	var recorded recordedEvents
+	failing := true
+	var mu sync.Mutex
+	bus.Subscribe("recorder", recorded.handle)
+	bus.Subscribe("broken", func(ctx context.Context, event domain.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing && event.EventType() == domain.EventUserFollowed {
+			return errors.New("handler down")
+		}
+		return nil
+	})
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.UnfollowUser(ctx, "user2", "user1")
+
+	// The failed event stays in the outbox, the other one is relayed
+	relayed, err := relay.RelayPending(ctx)
+	if err != nil || relayed != 1 {
+		t.Fatalf("Expected 1 event relayed, got %d (err: %v)", relayed, err)
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed, domain.EventUserUnfollowed)
+
+	// It is not retried before its backoff
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 0 {
+		t.Fatalf("Expected no due entries, got %d", len(entries))
+	}
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 0 {
+		t.Fatalf("Expected no event relayed, got %d (err: %v)", relayed, err)
+	}
+
+	// A subscriber failing forever keeps it in the outbox, backing off
+	time.Sleep(110 * time.Millisecond)
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 0 {
+		t.Fatalf("Expected no event relayed, got %d (err: %v)", relayed, err)
+	}
+	time.Sleep(110 * time.Millisecond)
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 0 {
+		t.Fatalf("Expected the backoff to double, got %d due entries", len(entries))
+	}
+	var entries []*domain.OutboxEntry
+	deadline := time.Now().Add(5 * time.Second)
+	for len(entries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		entries, _ = repo.PendingEvents(ctx, 10)
+	}
+	if len(entries) != 1 || entries[0].Type != domain.EventUserFollowed || entries[0].Attempts != 2 {
+		t.Fatalf("Expected the failed event after 2 attempts, got %+v", entries)
+	}
+
+	// Once the subscriber recovers the event is relayed, and the others
+	// skip it
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 1 {
+		t.Fatalf("Expected 1 event relayed, got %d (err: %v)", relayed, err)
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed, domain.EventUserUnfollowed)
+	if stats := bus.Stats(); stats.Failed != 2 || stats.Duplicates != 2 {
+		t.Errorf("Expected 2 failures and 2 duplicates, stats: %+v", stats)
+	}
+}
+
+func TestOutboxRelayDiscardsEventsAfterMaxAttempts(t *testing.T) {
+	repo := memory.NewRepositories()
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 1})
+	relay := usecases.NewOutboxRelay(repo, bus, logger.NewLogger(), usecases.OutboxRelayConfig{
+		RetryBackoff: time.Millisecond,
+		MaxAttempts:  3,
+	})
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger.NewLogger())
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	bus.Subscribe("broken", func(ctx context.Context, event domain.Event) error {
+		calls.Add(1)
+		return errors.New("handler down")
+	})
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+
+	// The event is relayed again until its last attempt fails, and then
+	// removed from the outbox instead of being retried forever
+	for attempt := 1; attempt <= 3; attempt++ {
+		time.Sleep(5 * time.Millisecond)
+		relayed, err := relay.RelayPending(ctx)
+		if err != nil {
+			t.Fatalf("Error relaying: %v", err)
+		}
+		if discarded := attempt == 3; discarded != (relayed == 1) {
+			t.Fatalf("Attempt %d: expected the event discarded only on the last attempt, got %d relayed", attempt, relayed)
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 0 {
+		t.Errorf("Expected the event out of the outbox, got %+v", entries)
+	}
+	if relayed, _ := relay.RelayPending(ctx); relayed != 0 || calls.Load() != 3 {
+		t.Errorf("Expected 3 deliveries and nothing left to relay, got %d calls and %d relayed", calls.Load(), relayed)
+	}
+}
+
+func TestOutboxRelayRunsInBackground(t *testing.T) {
+	repo := memory.NewRepositories()
+	bus := newTestBus(t, events.BusConfig{})
+	relay := usecases.NewOutboxRelay(repo, bus, logger.NewLogger(), usecases.OutboxRelayConfig{PollInterval: time.Millisecond})
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger.NewLogger())
+	ctx := context.Background()
+
+	var recorded recordedEvents
+	bus.Subscribe("recorder", recorded.handle)
+	relay.Start()
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorded.types()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := relay.Stop(ctx); err != nil {
+		t.Fatalf("Error stopping relay: %v", err)
+	}
+	assertEventTypes(t, recorded.types(), domain.EventUserFollowed)
+
+	// Events stored after stopping stay in the outbox
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	time.Sleep(10 * time.Millisecond)
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 1 {
+		t.Errorf("Expected 1 pending entry, got %d", len(entries))
+	}
+}
@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/file"
+	"twitter-clone-backend/internal/domain"
+)
+
+func TestFileStorageRecoversFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := file.Open(file.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+
+	tweet, _ := domain.NewTweet("user1", "persisted tweet")
+	deleted, _ := domain.NewTweet("user1", "deleted tweet")
+	repo.Create(ctx, tweet)
+	repo.Create(ctx, deleted)
+	repo.Delete(ctx, deleted.ID)
+	repo.CreateUser(ctx, domain.NewUser("user4", "dave"))
+	repo.SetCredentials(ctx, domain.NewCredentials("user4", "hash"))
+	repo.FollowIfNotExists(ctx, "user2", "user1")
+	repo.FollowIfNotExists(ctx, "user3", "user1")
+	repo.UnfollowIfExists(ctx, "user3", "user1")
+	for _, userID := range []string{"user2", "user3"} {
+		like, _ := domain.NewLike(userID, tweet.ID)
+		repo.LikeIfNotExists(ctx, like)
+	}
+	repo.UnlikeIfExists(ctx, "user3", tweet.ID)
+	for _, actorID := range []string{"user2", "user3"} {
+		event, _ := domain.NewNotification("user1", domain.NotificationLike, actorID, tweet.ID)
+		repo.Notify(ctx, event)
+	}
+	repo.MarkRead(ctx, "user1", nil)
+	follow, _ := domain.NewNotification("user1", domain.NotificationFollow, "user2", "")
+	repo.Notify(ctx, follow)
+	retweet, _ := domain.NewRetweet("user2", tweet)
+	relayed := domain.NewTweetCreated(retweet, tweet)
+	repo.CreateRetweet(ctx, retweet, relayed)
+	repo.MarkRelayed(ctx, []string{relayed.ID})
+	pending := domain.NewUserFollowed("user3", "user2")
+	repo.FollowIfNotExists(ctx, "user3", "user2", pending)
+	retryAt := time.Now().Add(-time.Second)
+	repo.ScheduleRetry(ctx, pending.ID, retryAt)
+	for _, blockedID := range []string{"user2", "user3"} {
+		block, _ := domain.NewBlock("user1", blockedID)
+		repo.Block(ctx, block)
+	}
+	repo.Unblock(ctx, "user1", "user3")
+	webhook, _ := domain.NewWebhook("user1", "https://example.com/hook", []string{domain.EventTweetCreated}, "0123456789abcdef", false)
+	removed, _ := domain.NewWebhook("user1", "https://example.com/old", []string{domain.EventTweetCreated}, "0123456789abcdef", false)
+	repo.CreateWebhook(ctx, webhook)
+	repo.CreateWebhook(ctx, removed)
+	repo.UpdateWebhook(ctx, webhook.RecordFailure(1, time.Now()))
+	message := &domain.WebhookMessage{EventID: relayed.ID, EventType: relayed.EventType()}
+	repo.AddWebhookDelivery(ctx, domain.NewWebhookDelivery(webhook.ID, message, 1, 204, nil))
+	repo.DeleteWebhook(ctx, "user1", removed.ID)
+	revokedAt := time.Now().Truncate(time.Second)
+	repo.Revoke(ctx, "revoked-token", time.Now().Add(time.Hour))
+	repo.Consume(ctx, "consumed-token", time.Now().Add(time.Hour))
+	repo.RevokeUser(ctx, "user4", revokedAt)
+
+	if err := repo.FollowIfNotExists(ctx, "user2", "user1"); err != domain.ErrAlreadyFollowing {
+		t.Errorf("Expected ErrAlreadyFollowing, got %v", err)
+	}
+
+	// Simulate a crash: reopen without Close, so only the WAL is available
+	recovered, err := file.Open(file.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer recovered.Close()
+
+	assertRecoveredState(t, recovered, tweet.ID, deleted.ID)
+
+	liked, _ := recovered.GetLikedTweetIDs(ctx, "user2", []string{tweet.ID})
+	if counts, _ := recovered.CountLikes(ctx, []string{tweet.ID}); counts[tweet.ID] != 1 || !liked[tweet.ID] {
+		t.Errorf("Expected the like of user2 to be recovered, got %v", counts)
+	}
+
+	if blocked, _ := recovered.IsBlocked(ctx, "user1", "user2"); !blocked {
+		t.Errorf("Expected the block of user2 to be recovered")
+	}
+	if blocked, _ := recovered.IsBlocked(ctx, "user1", "user3"); blocked {
+		t.Errorf("Expected the block of user3 to stay undone")
+	}
+
+	notifications, _ := recovered.GetNotifications(ctx, "user1", domain.PageQuery{})
+	if unread, _ := recovered.CountUnread(ctx, "user1"); unread != 1 || len(notifications) != 2 || len(notifications[1].ActorIDs) != 2 {
+		t.Errorf("Expected the grouped, read like and the unread follow, got %d unread of %+v", unread, notifications)
+	}
+
+	if entries, _ := recovered.PendingEvents(ctx, 10); len(entries) != 1 || entries[0].ID != pending.ID ||
+		entries[0].Attempts != 1 || !entries[0].NextAttemptAt.Equal(retryAt) {
+		t.Errorf("Expected only the retried, unrelayed event in the outbox, got %+v", entries)
+	}
+
+	webhooks, _ := recovered.ListWebhooks(ctx, "user1")
+	if len(webhooks) != 1 || webhooks[0].ID != webhook.ID || webhooks[0].IsEnabled() {
+		t.Errorf("Expected only the disabled webhook to be recovered, got %+v", webhooks)
+	}
+	if deliveries, _ := recovered.ListWebhookDeliveries(ctx, webhook.ID, 0); len(deliveries) != 1 || deliveries[0].StatusCode != 204 {
+		t.Errorf("Expected the delivery log to be recovered, got %+v", deliveries)
+	}
+
+	for _, tokenID := range []string{"revoked-token", "consumed-token"} {
+		if revoked, _ := recovered.IsRevoked(ctx, tokenID); !revoked {
+			t.Errorf("Expected %s to stay revoked", tokenID)
+		}
+	}
+	if before, _ := recovered.RevokedBefore(ctx, "user4"); !before.Equal(revokedAt) {
+		t.Errorf("Expected the tokens of user4 to stay revoked, got %v", before)
+	}
+}
+
+func TestFileStorageSnapshotAndTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := file.Open(file.Options{Dir: dir, FsyncPolicy: file.FsyncInterval})
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+
+	tweet, _ := domain.NewTweet("user1", "persisted tweet")
+	deleted, _ := domain.NewTweet("user1", "deleted tweet")
+	repo.Create(ctx, tweet)
+	repo.Create(ctx, deleted)
+	repo.CreateUser(ctx, domain.NewUser("user4", "dave"))
+	repo.SetCredentials(ctx, domain.NewCredentials("user4", "hash"))
+	repo.FollowIfNotExists(ctx, "user2", "user1")
+
+	if err := repo.Snapshot(); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+
+	// Records after the snapshot live only in the WAL
+	repo.Delete(ctx, deleted.ID)
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Simulate a crash in the middle of a write
+	walPath := filepath.Join(dir, "wal.log")
+	f, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open WAL: %v", err)
+	}
+	f.WriteString(`1a2b3c4d {"seq":99,"op":"create_tw`)
+	f.Close()
+
+	recovered, err := file.Open(file.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+
+	assertRecoveredState(t, recovered, tweet.ID, deleted.ID)
+
+	// The torn record is discarded and new writes are still recoverable
+	another, _ := domain.NewTweet("user2", "after recovery")
+	if err := recovered.Create(ctx, another); err != nil {
+		t.Fatalf("Failed to write after recovery: %v", err)
+	}
+
+	reopened, err := file.Open(file.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(ctx, another.ID); err != nil {
+		t.Errorf("Expected tweet written after recovery to persist: %v", err)
+	}
+}
+
+func assertRecoveredState(t *testing.T, repo *file.Repositories, tweetID, deletedID string) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := repo.GetByID(ctx, tweetID); err != nil {
+		t.Errorf("Expected tweet to be recovered: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, deletedID); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected deleted tweet to stay deleted, got %v", err)
+	}
+	if user, err := repo.GetUserByID(ctx, "user4"); err != nil || user.Username != "dave" {
+		t.Errorf("Expected user4 to be recovered: %v", err)
+	}
+	if credentials, err := repo.GetCredentials(ctx, "user4"); err != nil || credentials.PasswordHash != "hash" {
+		t.Errorf("Expected credentials of user4 to be recovered: %v", err)
+	}
+	if following, _ := repo.IsFollowing(ctx, "user2", "user1"); !following {
+		t.Error("Expected user2 to follow user1")
+	}
+	if following, _ := repo.IsFollowing(ctx, "user3", "user1"); following {
+		t.Error("Expected user3 not to follow user1")
+	}
+}
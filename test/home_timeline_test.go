@@ -0,0 +1,485 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/events"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+// waitForTimeline polls a timeline until it has the expected size (fan-out is asynchronous)
+func waitForTimeline(t *testing.T, tweetUseCase *usecases.TweetUseCase, userID string, expected int) []*domain.Tweet {
+	t.Helper()
+	ctx := context.Background()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tweets, err := tweetUseCase.GetTimeline(ctx, userID, domain.MaxTimelineLimit)
+		if err != nil {
+			t.Fatalf("Error getting timeline: %v", err)
+		}
+		if len(tweets) == expected || time.Now().After(deadline) {
+			if len(tweets) != expected {
+				t.Fatalf("Expected %d tweets in %s timeline, got %d", expected, userID, len(tweets))
+			}
+			return tweets
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// relayToHomeTimelines relays the domain events of the outbox to the home
+// timelines in the background, as the server does
+func relayToHomeTimelines(t *testing.T, repo *memory.Repositories, homeTimelines *usecases.HomeTimelines) {
+	t.Helper()
+	bus := newTestBus(t, events.BusConfig{})
+	bus.Subscribe("home-timelines", homeTimelines.HandleEvent)
+	relay := usecases.NewOutboxRelay(repo, bus, logger.NewLogger(), usecases.OutboxRelayConfig{PollInterval: time.Millisecond})
+	relay.Start()
+	t.Cleanup(func() { relay.Stop(context.Background()) })
+}
+
+// waitForOutbox waits until every event of the outbox has been relayed
+func waitForOutbox(t *testing.T, repo *memory.Repositories) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, _ := repo.PendingEvents(context.Background(), 1)
+		if len(entries) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the outbox to be relayed, %s is pending", entries[0].Type)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHomeTimelineFanout(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{Workers: 4})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	relayToHomeTimelines(t, repo, homeTimelines)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := tweetUseCase.CreateTweet(ctx, "user1", fmt.Sprintf("Old tweet %d", i)); err != nil {
+			t.Fatalf("Error creating tweet: %v", err)
+		}
+	}
+
+	// First read builds user2's timeline (only their own tweets so far)
+	waitForTimeline(t, tweetUseCase, "user2", 0)
+
+	// Following backfills the followee's recent tweets
+	if err := followUseCase.FollowUser(ctx, "user2", "user1"); err != nil {
+		t.Fatalf("Error following user: %v", err)
+	}
+	waitForTimeline(t, tweetUseCase, "user2", 3)
+
+	// New tweets are pushed to followers
+	newTweet, _ := tweetUseCase.CreateTweet(ctx, "user1", "New tweet")
+	ownTweet, _ := tweetUseCase.CreateTweet(ctx, "user2", "My own tweet")
+	tweets := waitForTimeline(t, tweetUseCase, "user2", 5)
+	if tweets[0].ID != ownTweet.ID || tweets[1].ID != newTweet.ID {
+		t.Errorf("Expected most recent tweets first, got %s, %s", tweets[0].Content, tweets[1].Content)
+	}
+
+	// The precomputed timeline matches the pull model
+	expected, _ := repo.GetTimeline(ctx, []string{"user1", "user2"}, domain.PageQuery{Limit: domain.MaxTimelineLimit})
+	for i := range expected {
+		if tweets[i].ID != expected[i].ID {
+			t.Errorf("Tweet %d: expected %s, got %s", i, expected[i].Content, tweets[i].Content)
+		}
+	}
+
+	// Unfollowing prunes the followee's tweets
+	if err := followUseCase.UnfollowUser(ctx, "user2", "user1"); err != nil {
+		t.Fatalf("Error unfollowing user: %v", err)
+	}
+	tweets = waitForTimeline(t, tweetUseCase, "user2", 1)
+	if tweets[0].ID != ownTweet.ID {
+		t.Errorf("Expected only own tweet, got %s", tweets[0].Content)
+	}
+}
+
+func TestHomeTimelineFanoutIsDrivenByTheOutbox(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{Workers: 2})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	ctx := context.Background()
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	for _, userID := range []string{"user2", "user3"} {
+		waitForTimeline(t, tweetUseCase, userID, 0)
+	}
+
+	// The request ends before the fan-out, which is relayed from the outbox
+	// once the relay runs
+	requestCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	tweet, err := tweetUseCase.CreateTweet(requestCtx, "user1", "posted by a canceled request")
+	if err != nil {
+		t.Fatalf("Error creating tweet: %v", err)
+	}
+	if entries, _, _ := store.Get(ctx, "user2", domain.PageQuery{}); len(entries) != 0 {
+		t.Fatalf("Expected no fan-out before relaying, got %+v", entries)
+	}
+
+	relayToHomeTimelines(t, repo, homeTimelines)
+	for _, userID := range []string{"user2", "user3"} {
+		if tweets := waitForTimeline(t, tweetUseCase, userID, 1); tweets[0].ID != tweet.ID {
+			t.Errorf("Expected the tweet in %s timeline, got %q", userID, tweets[0].Content)
+		}
+	}
+}
+
+// flakyFollowRepository fails reading followers while failures remain
+type flakyFollowRepository struct {
+	*memory.Repositories
+	mu       sync.Mutex
+	failures int
+}
+
+func (r *flakyFollowRepository) fail() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("follow repository unavailable")
+	}
+	return nil
+}
+
+func (r *flakyFollowRepository) CountFollowers(ctx context.Context, userID string) (int, error) {
+	if err := r.fail(); err != nil {
+		return 0, err
+	}
+	return r.Repositories.CountFollowers(ctx, userID)
+}
+
+func (r *flakyFollowRepository) GetFollowers(ctx context.Context, userID string) ([]string, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.Repositories.GetFollowers(ctx, userID)
+}
+
+func TestHomeTimelineFanoutIsRetriedWhenFollowersCannotBeRead(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	followRepo := &flakyFollowRepository{Repositories: repo}
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, followRepo, nil, logger, usecases.HomeTimelinesConfig{
+		Workers:            2,
+		CelebrityThreshold: 10,
+	})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	relayToHomeTimelines(t, repo, homeTimelines)
+	ctx := context.Background()
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	waitForOutbox(t, repo)
+	for _, userID := range []string{"user2", "user3"} {
+		waitForTimeline(t, tweetUseCase, userID, 0)
+	}
+
+	// Counting and then reading the followers fail once each: the fan-out
+	// is not given up but handled again until it reaches every follower
+	followRepo.mu.Lock()
+	followRepo.failures = 2
+	followRepo.mu.Unlock()
+
+	tweet, err := tweetUseCase.CreateTweet(ctx, "user1", "fanned out on retry")
+	if err != nil {
+		t.Fatalf("Error creating tweet: %v", err)
+	}
+	for _, userID := range []string{"user2", "user3"} {
+		if tweets := waitForTimeline(t, tweetUseCase, userID, 1); tweets[0].ID != tweet.ID {
+			t.Errorf("Expected the tweet in %s timeline, got %q", userID, tweets[0].Content)
+		}
+	}
+	waitForOutbox(t, repo)
+}
+
+func TestHomeTimelineRebuildsMissingTimelines(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	ctx := context.Background()
+
+	// Data written before timelines were precomputed (e.g. after a restart)
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	for i := 0; i < 5; i++ {
+		tweetUseCase.CreateTweet(ctx, "user1", fmt.Sprintf("Tweet %d", i))
+	}
+
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{Workers: 1})
+	tweetUseCase = usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+
+	tweets, err := tweetUseCase.GetTimeline(ctx, "user3", 2)
+	if err != nil || len(tweets) != 2 {
+		t.Fatalf("Expected 2 tweets, got %d (err: %v)", len(tweets), err)
+	}
+
+	if entries, found, _ := store.Get(ctx, "user3", domain.PageQuery{}); !found || len(entries) != 5 {
+		t.Errorf("Expected rebuilt timeline with 5 entries, got %d (found: %v)", len(entries), found)
+	}
+}
+
+// pausedFollowRepository signals when a timeline rebuild has read the
+// followed users and waits to be resumed before returning them
+type pausedFollowRepository struct {
+	*memory.Repositories
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (r *pausedFollowRepository) GetFollowing(ctx context.Context, userID string) ([]string, error) {
+	following, err := r.Repositories.GetFollowing(ctx, userID)
+	close(r.read)
+	<-r.resume
+	return following, err
+}
+
+func TestUnfollowDuringRebuildIsNotUndone(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	followRepo := &pausedFollowRepository{Repositories: repo, read: make(chan struct{}), resume: make(chan struct{})}
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, followRepo, nil, logger, usecases.HomeTimelinesConfig{Workers: 1})
+	ctx := context.Background()
+
+	repo.Follow(ctx, "user2", "user1")
+	for i := 0; i < 3; i++ {
+		repo.Create(ctx, newTweetAt(t, "user1", fmt.Sprintf("Tweet %d", i), time.Now()))
+	}
+
+	rebuilt := make(chan error, 1)
+	go func() {
+		_, err := homeTimelines.Read(ctx, "user2", domain.PageQuery{Limit: domain.MaxTimelineLimit})
+		rebuilt <- err
+	}()
+
+	// user2 unfollows user1 after the rebuild read who they follow
+	<-followRepo.read
+	repo.Unfollow(ctx, "user2", "user1")
+	if err := homeTimelines.HandleEvent(ctx, domain.NewUserUnfollowed("user2", "user1")); err != nil {
+		t.Fatalf("Error handling unfollow: %v", err)
+	}
+	close(followRepo.resume)
+	if err := <-rebuilt; err != nil {
+		t.Fatalf("Error rebuilding timeline: %v", err)
+	}
+
+	// The rebuilt timeline does not bring the unfollowed author back
+	entries, found, _ := store.Get(ctx, "user2", domain.PageQuery{})
+	if !found || len(entries) != 0 {
+		t.Errorf("Expected an empty rebuilt timeline, got %+v (found: %v)", entries, found)
+	}
+
+}
+
+func TestTimelineStoreIsBounded(t *testing.T) {
+	store := memory.NewTimelineStore(3)
+	ctx := context.Background()
+	base := time.Now()
+
+	store.CompleteRebuild(ctx, "user1", nil)
+	for i := 0; i < 5; i++ {
+		store.Add(ctx, "user1", domain.TimelineEntry{
+			TweetID:   fmt.Sprintf("tweet%d", i),
+			AuthorID:  "user2",
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	// Out of order entries are inserted in place, duplicates ignored
+	store.Add(ctx, "user1", domain.TimelineEntry{TweetID: "tweet3", AuthorID: "user2", CreatedAt: base.Add(3 * time.Second)})
+
+	entries, _, _ := store.Get(ctx, "user1", domain.PageQuery{})
+	if len(entries) != 3 || entries[0].TweetID != "tweet4" || entries[2].TweetID != "tweet2" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	// Users without a timeline are not materialized by Add
+	store.Add(ctx, "user2", entries...)
+	if _, found, _ := store.Get(ctx, "user2", domain.PageQuery{}); found {
+		t.Error("Expected no timeline for user2")
+	}
+}
+
+func TestHybridFanoutForCelebrities(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{
+		Workers:            1,
+		CelebrityThreshold: 2,
+	})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	relayToHomeTimelines(t, repo, homeTimelines)
+	ctx := context.Background()
+
+	// user1 has 2 followers (celebrity), user3 has 1
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.FollowUser(ctx, "user3", "user1")
+	followUseCase.FollowUser(ctx, "user2", "user3")
+	waitForOutbox(t, repo)
+	waitForTimeline(t, tweetUseCase, "user2", 0)
+
+	var created []*domain.Tweet
+	for i := 0; i < 3; i++ {
+		celebrityTweet, _ := tweetUseCase.CreateTweet(ctx, "user1", fmt.Sprintf("Celebrity tweet %d", i))
+		regularTweet, _ := tweetUseCase.CreateTweet(ctx, "user3", fmt.Sprintf("Regular tweet %d", i))
+		created = append(created, celebrityTweet, regularTweet)
+	}
+
+	// Events are handled in order: once the last regular tweet is in the
+	// timeline, every celebrity tweet has been processed
+	lastRegular := created[len(created)-1]
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, _, _ := store.Get(ctx, "user2", domain.PageQuery{})
+		if len(entries) > 0 && entries[0].TweetID == lastRegular.ID {
+			for _, entry := range entries {
+				if entry.AuthorID == "user1" {
+					t.Errorf("Celebrity tweet %s was fanned out", entry.TweetID)
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for fan-out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Celebrity tweets are merged at read time in the same order as the pull model
+	tweets, err := tweetUseCase.GetTimeline(ctx, "user2", 4)
+	if err != nil {
+		t.Fatalf("Error getting timeline: %v", err)
+	}
+	expected, _ := repo.GetTimeline(ctx, []string{"user1", "user2", "user3"}, domain.PageQuery{Limit: 4})
+	assertTweetIDs(t, tweets, tweetIDs(expected)...)
+}
+
+func tweetIDs(tweets []*domain.Tweet) []string {
+	ids := make([]string, len(tweets))
+	for i, tweet := range tweets {
+		ids[i] = tweet.ID
+	}
+	return ids
+}
+
+func TestHomeTimelinePaginationPastStoredEntries(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	store := memory.NewTimelineStore(4)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{Workers: 1})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	ctx := context.Background()
+
+	// Tweets sharing a timestamp, more than the stored timeline keeps
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		repo.Create(ctx, newTweetAt(t, "user1", fmt.Sprintf("Tweet %d", i), base.Add(time.Duration(i/3)*time.Second)))
+	}
+	expected, _ := repo.GetByUserID(ctx, "user1", domain.PageQuery{})
+
+	var scrolled []*domain.Tweet
+	page := domain.PageQuery{Limit: 3}
+	for {
+		tweets, err := tweetUseCase.GetTimelinePage(ctx, "user1", page)
+		if err != nil {
+			t.Fatalf("Error getting timeline: %v", err)
+		}
+		if len(tweets) == 0 {
+			break
+		}
+		scrolled = append(scrolled, tweets...)
+		cursor := domain.CursorOf(tweets[len(tweets)-1])
+		page.MaxID = &cursor
+	}
+	assertTweetIDs(t, scrolled, tweetIDs(expected)...)
+
+	// Polling from the newest tweet returns nothing until a new one is posted
+	newest := domain.CursorOf(expected[0])
+	if tweets, _ := tweetUseCase.GetTimelinePage(ctx, "user1", domain.PageQuery{SinceID: &newest}); len(tweets) != 0 {
+		t.Errorf("Expected no new tweets, got %d", len(tweets))
+	}
+	newTweet, _ := tweetUseCase.CreateTweet(ctx, "user1", "New tweet")
+	tweets, _ := tweetUseCase.GetTimelinePage(ctx, "user1", domain.PageQuery{SinceID: &newest})
+	assertTweetIDs(t, tweets, newTweet.ID)
+}
+
+func TestDeleteTweetPurgesHomeTimelines(t *testing.T) {
+	repo := memory.NewRepositories()
+	logger := logger.NewLogger()
+	store := memory.NewTimelineStore(domain.MaxHomeTimelineSize)
+	homeTimelines := usecases.NewHomeTimelines(store, repo, repo, nil, logger, usecases.HomeTimelinesConfig{Workers: 1})
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, logger, usecases.WithHomeTimelines(homeTimelines))
+	followUseCase := usecases.NewFollowUseCase(repo, repo, logger)
+	relayToHomeTimelines(t, repo, homeTimelines)
+	ctx := context.Background()
+
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	waitForTimeline(t, tweetUseCase, "user2", 0)
+
+	kept, _ := tweetUseCase.CreateTweet(ctx, "user1", "Kept tweet")
+	deleted, _ := tweetUseCase.CreateTweet(ctx, "user1", "Deleted tweet")
+	waitForTimeline(t, tweetUseCase, "user2", 2)
+
+	// Only the author can delete a tweet
+	if err := tweetUseCase.DeleteTweet(ctx, "user2", deleted.ID); err != domain.ErrNotTweetAuthor {
+		t.Fatalf("Expected ErrNotTweetAuthor, got %v", err)
+	}
+	if err := tweetUseCase.DeleteTweet(ctx, "user1", deleted.ID); err != nil {
+		t.Fatalf("Error deleting tweet: %v", err)
+	}
+	if err := tweetUseCase.DeleteTweet(ctx, "user1", deleted.ID); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected ErrTweetNotFound, got %v", err)
+	}
+	if _, err := tweetUseCase.GetTweet(ctx, deleted.ID); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected ErrTweetNotFound, got %v", err)
+	}
+
+	// The entry is purged from the author's and the followers' timelines
+	for _, userID := range []string{"user1", "user2"} {
+		tweets := waitForTimeline(t, tweetUseCase, userID, 1)
+		if tweets[0].ID != kept.ID {
+			t.Errorf("Expected only the kept tweet in %s timeline, got %s", userID, tweets[0].Content)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			entries, _, _ := store.Get(ctx, userID, domain.PageQuery{})
+			if len(entries) == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected deleted entry to be removed from %s timeline, got %+v", userID, entries)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
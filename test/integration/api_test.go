@@ -20,8 +20,9 @@ func TestAPI(t *testing.T) {
 	appLogger := logger.NewLogger()
 	repo := memory.NewRepositories()
 	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger)
-	followUseCase := usecases.NewFollowUseCase(repo, repo, nil, appLogger)
-	handlers := httpAdapters.NewHandlers(tweetUseCase, followUseCase)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, appLogger)
+	userUseCase := usecases.NewUserUseCase(repo, appLogger)
+	handlers := httpAdapters.NewHandlers(tweetUseCase, followUseCase, userUseCase, appLogger)
 	router := httpAdapters.SetupRoutes(handlers)
 
 	// Start test server
@@ -129,4 +130,49 @@ func TestAPI(t *testing.T) {
 			t.Errorf("Expected status 200, got %d", resp.StatusCode)
 		}
 	})
+
+	// Test 6: Register user
+	t.Run("Register user", func(t *testing.T) {
+		userData := map[string]string{
+			"username":     "dave",
+			"display_name": "Dave",
+		}
+
+		jsonData, _ := json.Marshal(userData)
+
+		resp, err := http.Post(baseURL+"/users", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to register user: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", resp.StatusCode)
+		}
+
+		// Usernames are unique ignoring case
+		jsonData, _ = json.Marshal(map[string]string{"username": "DAVE"})
+		resp, err = http.Post(baseURL+"/users", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to register user: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test 7: Get user by username
+	t.Run("Get user by username", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/users/by-username/Dave")
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", resp.StatusCode)
+		}
+	})
 }
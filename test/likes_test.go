@@ -0,0 +1,105 @@
+package test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+)
+
+func TestLikes(t *testing.T) {
+	server := newHeaderServer(t)
+
+	post := func(content string) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		if status := decodeResponse(headerRequest(t, server, "POST", "/tweets", "user1", map[string]string{"content": content}), &tweet); status != http.StatusCreated {
+			t.Fatalf("Expected tweet to be created, got %d", status)
+		}
+		return tweet
+	}
+	like := func(method, tweetID, userID string) int {
+		t.Helper()
+		return decodeResponse(headerRequest(t, server, method, "/tweets/"+tweetID+"/like", userID, nil), nil)
+	}
+	get := func(tweetID, userID string) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		decodeResponse(headerRequest(t, server, "GET", "/tweets/"+tweetID, userID, nil), &tweet)
+		return tweet
+	}
+
+	first := post("first")
+	second := post("second")
+	third := post("third")
+
+	// Concurrent likes of the same user count once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status := like("POST", first.ID, "user2"); status != http.StatusOK {
+				t.Errorf("Expected like to succeed, got %d", status)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if status := like("POST", first.ID, "user3"); status != http.StatusOK {
+		t.Fatalf("Expected like to succeed, got %d", status)
+	}
+	if tweet := get(first.ID, "user2"); tweet.LikeCount != 2 || !tweet.LikedByMe {
+		t.Errorf("Expected 2 likes, liked by user2, got %+v", tweet)
+	}
+	if tweet := get(first.ID, "user1"); tweet.LikedByMe {
+		t.Errorf("Expected tweet not liked by user1")
+	}
+	if tweet := get(first.ID, ""); tweet.LikeCount != 2 || tweet.LikedByMe {
+		t.Errorf("Expected 2 likes for anonymous callers, got %+v", tweet)
+	}
+
+	// Unliking is idempotent too
+	for i := 0; i < 2; i++ {
+		if status := like("DELETE", first.ID, "user3"); status != http.StatusOK {
+			t.Errorf("Expected unlike to succeed, got %d", status)
+		}
+	}
+	if tweet := get(first.ID, ""); tweet.LikeCount != 1 {
+		t.Errorf("Expected 1 like after unlike, got %d", tweet.LikeCount)
+	}
+
+	if status := like("POST", "missing", "user2"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 liking a missing tweet, got %d", status)
+	}
+	if status := like("POST", first.ID, ""); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for anonymous likes, got %d", status)
+	}
+
+	// Liking a retweet likes the original
+	var retweet httpAdapters.TweetResponse
+	decodeResponse(headerRequest(t, server, "POST", "/tweets/"+third.ID+"/retweet", "user3", nil), &retweet)
+	like("POST", retweet.ID, "user2")
+	if tweet := get(third.ID, "user2"); tweet.LikeCount != 1 || !tweet.LikedByMe {
+		t.Errorf("Expected the original to be liked, got %+v", tweet)
+	}
+
+	// Likes of a user, most recently liked first, paged by like time
+	like("POST", second.ID, "user2")
+	var page httpAdapters.TweetPageResponse
+	if status := decodeResponse(headerRequest(t, server, "GET", "/users/user2/likes?limit=2", "", nil), &page); status != http.StatusOK {
+		t.Fatalf("Expected likes, got %d", status)
+	}
+	if len(page.Tweets) != 2 || page.Tweets[0].ID != second.ID || page.Tweets[1].ID != third.ID || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page of likes: %+v", page)
+	}
+	var last httpAdapters.TweetPageResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user2/likes?limit=2&max_id="+page.NextCursor, "", nil), &last)
+	if len(last.Tweets) != 1 || last.Tweets[0].ID != first.ID || last.NextCursor != "" {
+		t.Errorf("Unexpected last page of likes: %+v", last)
+	}
+
+	if status := decodeResponse(headerRequest(t, server, "GET", "/users/nobody/likes", "", nil), nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for the likes of a missing user, got %d", status)
+	}
+}
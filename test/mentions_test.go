@@ -0,0 +1,141 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+)
+
+func TestMentionsTimeline(t *testing.T) {
+	server := newHeaderServer(t)
+
+	post := func(userID, content string) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		if status := decodeResponse(headerRequest(t, server, "POST", "/tweets", userID, map[string]string{"content": content}), &tweet); status != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", status)
+		}
+		return tweet
+	}
+
+	first := post("user1", "hola @bob")
+	post("user1", "hola @nobody y mail@bob.com")
+	reply := post("user3", "@BOB @bob mirá esto")
+	post("user2", "@alice")
+
+	var mentions httpAdapters.TweetPageResponse
+	if status := decodeResponse(headerRequest(t, server, "GET", "/users/user2/mentions", "", nil), &mentions); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if len(mentions.Tweets) != 2 || mentions.Tweets[0].ID != reply.ID || mentions.Tweets[1].ID != first.ID {
+		t.Fatalf("Unexpected mentions: %+v", mentions.Tweets)
+	}
+
+	var page httpAdapters.TweetPageResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user2/mentions?limit=1", "", nil), &page)
+	if len(page.Tweets) != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected a page of 1 with a next cursor, got %+v", page)
+	}
+	var next httpAdapters.TweetPageResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user2/mentions?limit=1&max_id="+page.NextCursor, "", nil), &next)
+	if len(next.Tweets) != 1 || next.Tweets[0].ID != first.ID {
+		t.Errorf("Expected the older mention, got %+v", next.Tweets)
+	}
+
+	var problem httpAdapters.ProblemResponse
+	if status := decodeResponse(headerRequest(t, server, "GET", "/users/missing/mentions", "", nil), &problem); status != http.StatusNotFound || problem.Code != "user_not_found" {
+		t.Errorf("Expected 404 user_not_found, got %d %s", status, problem.Code)
+	}
+
+	// Deleting a tweet removes it from the mentions
+	if status := decodeResponse(headerRequest(t, server, "DELETE", "/tweets/"+reply.ID, "user3", nil), nil); status != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d", status)
+	}
+	var afterDelete httpAdapters.TweetPageResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user2/mentions", "", nil), &afterDelete)
+	if len(afterDelete.Tweets) != 1 || afterDelete.Tweets[0].ID != first.ID {
+		t.Errorf("Expected only the first mention, got %+v", afterDelete.Tweets)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	server := newHeaderServer(t)
+
+	send := func(method, path, userID string, body interface{}) (int, string) {
+		t.Helper()
+		var problem httpAdapters.ProblemResponse
+		status := decodeResponse(headerRequest(t, server, method, path, userID, body), &problem)
+		return status, problem.Code
+	}
+	mentions := func(userID string) []httpAdapters.TweetResponse {
+		t.Helper()
+		var page httpAdapters.TweetPageResponse
+		decodeResponse(headerRequest(t, server, "GET", "/users/"+userID+"/mentions", "", nil), &page)
+		return page.Tweets
+	}
+	unread := func(userID string) int {
+		t.Helper()
+		var count httpAdapters.UnreadCountResponse
+		decodeResponse(headerRequest(t, server, "GET", "/notifications/unread_count", userID, nil), &count)
+		return count.UnreadCount
+	}
+
+	// Blocking removes the follows both ways
+	send("POST", "/users/following", "user1", map[string]string{"followee_id": "user2"})
+	send("POST", "/users/following", "user2", map[string]string{"followee_id": "user1"})
+	if status, _ := send("POST", "/users/blocking", "user1", map[string]string{"blocked_id": "user2"}); status != http.StatusOK {
+		t.Fatalf("Expected block to succeed, got %d", status)
+	}
+	var followers httpAdapters.FollowersResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user1/followers", "", nil), &followers)
+	var following httpAdapters.FollowingResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user1/following", "", nil), &following)
+	if len(followers.Followers) != 0 || len(following.Following) != 0 {
+		t.Errorf("Expected no follows left, got %v and %v", followers.Followers, following.Following)
+	}
+
+	cases := []struct {
+		method, path, userID string
+		body                 interface{}
+		status               int
+		code                 string
+	}{
+		{"POST", "/users/blocking", "user1", map[string]string{"blocked_id": "user2"}, http.StatusConflict, "already_blocked"},
+		{"POST", "/users/blocking", "user1", map[string]string{"blocked_id": "user1"}, http.StatusUnprocessableEntity, "cannot_block_self"},
+		{"POST", "/users/blocking", "user1", map[string]string{"blocked_id": "missing"}, http.StatusNotFound, "user_not_found"},
+		{"POST", "/users/following", "user2", map[string]string{"followee_id": "user1"}, http.StatusForbidden, "blocked"},
+		{"DELETE", "/users/blocking/user3", "user1", nil, http.StatusNotFound, "not_blocked"},
+	}
+	for _, c := range cases {
+		if status, code := send(c.method, c.path, c.userID, c.body); status != c.status || code != c.code {
+			t.Errorf("%s %s: expected %d %s, got %d %s", c.method, c.path, c.status, c.code, status, code)
+		}
+	}
+
+	// Mentions of a user who blocks the author are not resolved: they are
+	// neither indexed nor notified
+	before := unread("user1")
+	var tweet httpAdapters.TweetResponse
+	decodeResponse(headerRequest(t, server, "POST", "/tweets", "user2", map[string]string{"content": "hola @alice y @charlie"}), &tweet)
+	if tweet.Entities == nil || len(tweet.Entities.Mentions) != 1 || tweet.Entities.Mentions[0].UserID != "user3" {
+		t.Errorf("Expected only the mention of charlie to be resolved, got %+v", tweet.Entities)
+	}
+	if len(mentions("user1")) != 0 || len(mentions("user3")) != 1 {
+		t.Errorf("Expected the tweet only in the mentions of user3")
+	}
+	if count := unread("user1"); count != before {
+		t.Errorf("Expected no notification for the blocker, got %d unread (was %d)", count, before)
+	}
+
+	// After unblocking, mentions and follows work again
+	if status, _ := send("DELETE", "/users/blocking/user2", "user1", nil); status != http.StatusOK {
+		t.Fatalf("Expected unblock to succeed, got %d", status)
+	}
+	decodeResponse(headerRequest(t, server, "POST", "/tweets", "user2", map[string]string{"content": "hola @alice"}), nil)
+	if len(mentions("user1")) != 1 {
+		t.Errorf("Expected the mention after unblocking")
+	}
+	if status, _ := send("POST", "/users/following", "user2", map[string]string{"followee_id": "user1"}); status != http.StatusOK {
+		t.Errorf("Expected follow after unblocking to succeed, got %d", status)
+	}
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+)
+
+func TestNotifications(t *testing.T) {
+	server := newHeaderServer(t)
+
+	send := func(method, path, userID string, body interface{}, expected int) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		if status := decodeResponse(headerRequest(t, server, method, path, userID, body), &tweet); status != expected {
+			t.Fatalf("%s %s: expected %d, got %d", method, path, expected, status)
+		}
+		return tweet
+	}
+	notifications := func(query string) httpAdapters.NotificationPageResponse {
+		t.Helper()
+		var page httpAdapters.NotificationPageResponse
+		if status := decodeResponse(headerRequest(t, server, "GET", "/notifications"+query, "user1", nil), &page); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		return page
+	}
+	unread := func() int {
+		t.Helper()
+		var count httpAdapters.UnreadCountResponse
+		decodeResponse(headerRequest(t, server, "GET", "/notifications/unread_count", "user1", nil), &count)
+		return count.UnreadCount
+	}
+
+	original := send("POST", "/tweets", "user1", map[string]string{"content": "hola"}, http.StatusCreated)
+
+	send("POST", "/users/following", "user2", map[string]string{"followee_id": "user1"}, http.StatusOK)
+	send("POST", "/tweets/"+original.ID+"/like", "user2", nil, http.StatusOK)
+	send("POST", "/tweets/"+original.ID+"/like", "user1", nil, http.StatusOK) // own actions are not notified
+	retweet := send("POST", "/tweets/"+original.ID+"/retweet", "user3", nil, http.StatusCreated)
+	send("POST", "/tweets/"+retweet.ID+"/like", "user3", nil, http.StatusOK) // likes the original
+	reply := send("POST", "/tweets", "user2", map[string]string{"content": "@alice qué tal", "in_reply_to_tweet_id": original.ID}, http.StatusCreated)
+	quote := send("POST", "/tweets", "user3", map[string]string{"content": "mirá", "quoted_tweet_id": original.ID}, http.StatusCreated)
+	mention := send("POST", "/tweets", "user3", map[string]string{"content": "hola @alice y @bob"}, http.StatusCreated)
+
+	page := notifications("")
+	expected := []struct {
+		kind, tweetID string
+		actors        int
+	}{
+		{"mention", mention.ID, 1},
+		{"quote", quote.ID, 1},
+		{"reply", reply.ID, 1}, // the reply also mentions user1, who is notified once
+		{"like", original.ID, 2},
+		{"retweet", original.ID, 1},
+		{"follow", "", 1},
+	}
+	if len(page.Notifications) != len(expected) {
+		t.Fatalf("Expected %d notifications, got %+v", len(expected), page.Notifications)
+	}
+	for i, e := range expected {
+		n := page.Notifications[i]
+		if n.Type != e.kind || n.TweetID != e.tweetID || n.ActorCount != e.actors || len(n.ActorIDs) != e.actors || n.Read {
+			t.Errorf("Notification %d: expected %s of %q by %d, got %+v", i, e.kind, e.tweetID, e.actors, n)
+		}
+	}
+	if like := page.Notifications[3]; like.ActorIDs[0] != "user3" {
+		t.Errorf("Expected the most recent liker first, got %v", like.ActorIDs)
+	}
+	if count := unread(); count != 6 {
+		t.Errorf("Expected 6 unread, got %d", count)
+	}
+
+	// Pagination
+	first := notifications("?limit=4")
+	if len(first.Notifications) != 4 || first.NextCursor == "" {
+		t.Fatalf("Expected a full page with a next cursor, got %+v", first)
+	}
+	rest := notifications("?limit=4&max_id=" + first.NextCursor)
+	if len(rest.Notifications) != 2 || rest.Notifications[0].Type != "retweet" || rest.NextCursor != "" {
+		t.Errorf("Expected the last 2 notifications, got %+v", rest)
+	}
+
+	// Marking read up to a notification, included, leaves the newer unread
+	var marked httpAdapters.UnreadCountResponse
+	body := map[string]string{"up_to": rest.NewestCursor}
+	if status := decodeResponse(headerRequest(t, server, "POST", "/notifications/read", "user1", body), &marked); status != http.StatusOK || marked.UnreadCount != 4 {
+		t.Errorf("Expected 4 left unread, got %d (status %d)", marked.UnreadCount, status)
+	}
+	if page := notifications(""); page.Notifications[3].Read || !page.Notifications[4].Read || !page.Notifications[5].Read {
+		t.Errorf("Expected the retweet and follow read, got %+v", page.Notifications)
+	}
+
+	// A like after reading the group starts a new one
+	send("POST", "/notifications/read", "user1", nil, http.StatusOK)
+	send("DELETE", "/tweets/"+original.ID+"/like", "user2", nil, http.StatusOK)
+	send("POST", "/tweets/"+original.ID+"/like", "user2", nil, http.StatusOK)
+	if page := notifications(""); page.Notifications[0].Type != "like" || page.Notifications[0].ActorCount != 1 || page.Notifications[0].Read {
+		t.Errorf("Expected a new unread like, got %+v", page.Notifications[0])
+	}
+
+	var all httpAdapters.UnreadCountResponse
+	decodeResponse(headerRequest(t, server, "POST", "/notifications/read", "user1", nil), &all)
+	if all.UnreadCount != 0 || unread() != 0 {
+		t.Errorf("Expected everything read, got %d", all.UnreadCount)
+	}
+
+	var problem httpAdapters.ProblemResponse
+	if status := decodeResponse(headerRequest(t, server, "POST", "/notifications/read", "user1", map[string]string{"up_to": "!"}), &problem); status != http.StatusBadRequest || problem.Code != "invalid_cursor" {
+		t.Errorf("Expected 400 invalid_cursor, got %d %s", status, problem.Code)
+	}
+	if status := decodeResponse(headerRequest(t, server, "GET", "/notifications", "", nil), nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a user, got %d", status)
+	}
+}
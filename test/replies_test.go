@@ -0,0 +1,153 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"twitter-clone-backend/internal/adapters/events"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+// newHeaderServer starts an API server trusting the X-User-ID header.
+// Domain events are relayed and handled before each response is sent
+func newHeaderServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	bus := events.NewBus(appLogger, events.BusConfig{})
+	notifications := usecases.NewNotificationUseCase(repo, appLogger)
+	bus.Subscribe("notifications", notifications.HandleEvent)
+	relay := usecases.NewOutboxRelay(repo, bus, appLogger, usecases.OutboxRelayConfig{})
+	handlers := httpAdapters.NewHandlers(
+		usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger),
+		usecases.NewFollowUseCase(repo, repo, appLogger),
+		usecases.NewUserUseCase(repo, appLogger),
+		appLogger,
+		httpAdapters.WithLikes(usecases.NewLikeUseCase(repo, repo, repo, appLogger)),
+		httpAdapters.WithNotifications(notifications),
+	)
+	router := httpAdapters.SetupRoutes(handlers)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(&relayingWriter{ResponseWriter: w, relay: relay}, r)
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		bus.Close(context.Background())
+	})
+	return server
+}
+
+// relayingWriter waits for the events of a request to be relayed and
+// handled before writing its response, so tests observe their side effects
+type relayingWriter struct {
+	http.ResponseWriter
+	relay *usecases.OutboxRelay
+}
+
+// WriteHeader relays the outbox and writes the status code
+func (w *relayingWriter) WriteHeader(statusCode int) {
+	w.relay.RelayPending(context.Background())
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// headerRequest sends a JSON request as the given user (anonymous if empty)
+func headerRequest(t *testing.T, server *httptest.Server, method, path, userID string, body interface{}) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestRepliesAndThreads(t *testing.T) {
+	server := newHeaderServer(t)
+
+	post := func(userID, content, inReplyTo string) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		body := map[string]string{"content": content, "in_reply_to_tweet_id": inReplyTo}
+		if status := decodeResponse(headerRequest(t, server, "POST", "/tweets", userID, body), &tweet); status != http.StatusCreated {
+			t.Fatalf("Expected tweet to be created, got %d", status)
+		}
+		return tweet
+	}
+
+	root := post("user1", "root", "")
+	first := post("user2", "first reply", root.ID)
+	nested := post("user1", "nested reply", first.ID)
+	second := post("user3", "second reply", root.ID)
+
+	if root.ConversationID != root.ID || root.InReplyToTweetID != "" {
+		t.Errorf("Expected root to start its conversation, got %+v", root)
+	}
+	if nested.InReplyToTweetID != first.ID || nested.ConversationID != root.ID {
+		t.Errorf("Expected nested reply in the root conversation, got %+v", nested)
+	}
+
+	// Replying to a missing tweet is rejected
+	var problem httpAdapters.ProblemResponse
+	resp := headerRequest(t, server, "POST", "/tweets", "user1", map[string]string{"content": "hi", "in_reply_to_tweet_id": "missing"})
+	if status := decodeResponse(resp, &problem); status != http.StatusUnprocessableEntity || problem.Code != "parent_not_found" {
+		t.Errorf("Expected 422 parent_not_found, got %d %+v", status, problem)
+	}
+
+	// Direct replies, most recent first, with their own reply counts
+	var replies httpAdapters.TweetPageResponse
+	if status := decodeResponse(headerRequest(t, server, "GET", "/tweets/"+root.ID+"/replies", "", nil), &replies); status != http.StatusOK {
+		t.Fatalf("Expected replies, got %d", status)
+	}
+	if len(replies.Tweets) != 2 || replies.Tweets[0].ID != second.ID || replies.Tweets[1].ID != first.ID {
+		t.Fatalf("Unexpected replies: %+v", replies.Tweets)
+	}
+	if replies.Tweets[1].ReplyCount != 1 || replies.Tweets[0].ReplyCount != 0 {
+		t.Errorf("Unexpected reply counts: %+v", replies.Tweets)
+	}
+
+	var tweet httpAdapters.TweetResponse
+	decodeResponse(headerRequest(t, server, "GET", "/tweets/"+root.ID, "", nil), &tweet)
+	if tweet.ReplyCount != 2 {
+		t.Errorf("Expected root to have 2 replies, got %d", tweet.ReplyCount)
+	}
+
+	// The thread of any of its tweets is the whole conversation
+	var thread httpAdapters.ThreadResponse
+	if status := decodeResponse(headerRequest(t, server, "GET", "/tweets/"+nested.ID+"/thread", "", nil), &thread); status != http.StatusOK {
+		t.Fatalf("Expected thread, got %d", status)
+	}
+	if thread.ConversationID != root.ID || len(thread.Tweets) != 1 || thread.Tweets[0].ID != root.ID {
+		t.Fatalf("Unexpected thread roots: %+v", thread)
+	}
+	top := thread.Tweets[0].Replies
+	if len(top) != 2 || top[0].ID != first.ID || top[1].ID != second.ID {
+		t.Fatalf("Expected replies in chronological order, got %+v", top)
+	}
+	if len(top[0].Replies) != 1 || top[0].Replies[0].ID != nested.ID || len(top[1].Replies) != 0 {
+		t.Errorf("Unexpected nested replies: %+v", top)
+	}
+
+	// Replies to a deleted tweet stay in the thread as roots
+	if status := decodeResponse(headerRequest(t, server, "DELETE", "/tweets/"+first.ID, "user2", nil), nil); status != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d", status)
+	}
+	decodeResponse(headerRequest(t, server, "GET", "/tweets/"+root.ID+"/thread", "", nil), &thread)
+	if len(thread.Tweets) != 2 || thread.Tweets[0].ID != root.ID || thread.Tweets[1].ID != nested.ID {
+		t.Errorf("Expected orphaned reply as a root, got %+v", thread.Tweets)
+	}
+
+	if status := decodeResponse(headerRequest(t, server, "GET", "/tweets/missing/thread", "", nil), nil); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for the thread of a missing tweet, got %d", status)
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+)
+
+func TestRetweetsAndQuotes(t *testing.T) {
+	server := newHeaderServer(t)
+
+	send := func(method, path, userID string, body interface{}, expected int) httpAdapters.TweetResponse {
+		t.Helper()
+		var tweet httpAdapters.TweetResponse
+		if status := decodeResponse(headerRequest(t, server, method, path, userID, body), &tweet); status != expected {
+			t.Fatalf("%s %s: expected %d, got %d", method, path, expected, status)
+		}
+		return tweet
+	}
+	problem := func(method, path, userID string, body interface{}) (int, string) {
+		t.Helper()
+		var problem httpAdapters.ProblemResponse
+		status := decodeResponse(headerRequest(t, server, method, path, userID, body), &problem)
+		return status, problem.Code
+	}
+
+	older := send("POST", "/tweets", "user3", map[string]string{"content": "older"}, http.StatusCreated)
+	original := send("POST", "/tweets", "user3", map[string]string{"content": "original"}, http.StatusCreated)
+	if original.Kind != "tweet" || original.ReferencedTweet != nil {
+		t.Errorf("Expected a plain tweet, got %+v", original)
+	}
+
+	// A retweet embeds the original with its counts
+	retweet := send("POST", "/tweets/"+original.ID+"/retweet", "user2", nil, http.StatusCreated)
+	if retweet.Kind != "retweet" || retweet.Content != "" || retweet.ReferencedTweetID != original.ID {
+		t.Fatalf("Unexpected retweet: %+v", retweet)
+	}
+	if retweet.ReferencedTweet == nil || retweet.ReferencedTweet.ID != original.ID || retweet.ReferencedTweet.RetweetCount != 1 {
+		t.Errorf("Expected the original embedded, got %+v", retweet.ReferencedTweet)
+	}
+
+	if status, code := problem("POST", "/tweets/"+original.ID+"/retweet", "user2", nil); status != http.StatusConflict || code != "already_retweeted" {
+		t.Errorf("Expected 409 already_retweeted, got %d %s", status, code)
+	}
+	if status, code := problem("POST", "/tweets/missing/retweet", "user2", nil); status != http.StatusNotFound || code != "tweet_not_found" {
+		t.Errorf("Expected 404 tweet_not_found, got %d %s", status, code)
+	}
+
+	// Retweeting or quoting a retweet reshares its original
+	selfRetweet := send("POST", "/tweets/"+retweet.ID+"/retweet", "user3", nil, http.StatusCreated)
+	if selfRetweet.ReferencedTweetID != original.ID {
+		t.Errorf("Expected the retweet of a retweet to reshare the original, got %+v", selfRetweet)
+	}
+	quote := send("POST", "/tweets", "user1", map[string]string{"content": "look", "quoted_tweet_id": retweet.ID}, http.StatusCreated)
+	if quote.Kind != "quote" || quote.Content != "look" || quote.ReferencedTweetID != original.ID {
+		t.Errorf("Unexpected quote: %+v", quote)
+	}
+
+	if status, code := problem("POST", "/tweets", "user1", map[string]string{"content": "hi", "quoted_tweet_id": "missing"}); status != http.StatusUnprocessableEntity || code != "quoted_not_found" {
+		t.Errorf("Expected 422 quoted_not_found, got %d %s", status, code)
+	}
+	both := map[string]string{"content": "hi", "quoted_tweet_id": original.ID, "in_reply_to_tweet_id": original.ID}
+	if status, _ := problem("POST", "/tweets", "user1", both); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a reply that quotes, got %d", status)
+	}
+
+	got := send("GET", "/tweets/"+original.ID, "", nil, http.StatusOK)
+	if got.RetweetCount != 2 || got.QuoteCount != 1 {
+		t.Errorf("Expected 2 retweets and 1 quote, got %+v", got)
+	}
+
+	// Two followed users retweeted the original, which is shown once, as
+	// its most recent retweet, and the page is refilled with older tweets
+	for _, followee := range []string{"user2", "user3"} {
+		if status := decodeResponse(headerRequest(t, server, "POST", "/users/following", "user1", map[string]string{"followee_id": followee}), nil); status != http.StatusOK {
+			t.Fatalf("Expected follow to succeed, got %d", status)
+		}
+	}
+
+	var timeline httpAdapters.TweetPageResponse
+	decodeResponse(headerRequest(t, server, "GET", "/users/user1/timeline?limit=3", "", nil), &timeline)
+	if len(timeline.Tweets) != 3 ||
+		timeline.Tweets[0].ID != quote.ID ||
+		timeline.Tweets[1].ID != selfRetweet.ID ||
+		timeline.Tweets[2].ID != older.ID {
+		t.Fatalf("Unexpected timeline: %+v", timeline.Tweets)
+	}
+	if timeline.NextCursor == "" {
+		t.Errorf("Expected a next cursor for a full page")
+	}
+
+	// Undoing a retweet
+	if status := decodeResponse(headerRequest(t, server, "DELETE", "/tweets/"+original.ID+"/retweet", "user2", nil), nil); status != http.StatusOK {
+		t.Fatalf("Expected undo to succeed, got %d", status)
+	}
+	if status, code := problem("DELETE", "/tweets/"+original.ID+"/retweet", "user2", nil); status != http.StatusNotFound || code != "not_retweeted" {
+		t.Errorf("Expected 404 not_retweeted, got %d %s", status, code)
+	}
+	if got := send("GET", "/tweets/"+original.ID, "", nil, http.StatusOK); got.RetweetCount != 1 {
+		t.Errorf("Expected 1 retweet after undo, got %d", got.RetweetCount)
+	}
+	send("GET", "/tweets/"+retweet.ID, "", nil, http.StatusNotFound)
+
+	// Deleting the original deletes its retweets, also from timelines
+	if status := decodeResponse(headerRequest(t, server, "DELETE", "/tweets/"+original.ID, "user3", nil), nil); status != http.StatusOK {
+		t.Fatalf("Expected delete to succeed, got %d", status)
+	}
+	send("GET", "/tweets/"+selfRetweet.ID, "", nil, http.StatusNotFound)
+	if _, code := problem("DELETE", "/tweets/"+original.ID+"/retweet", "user3", nil); code != "not_retweeted" {
+		t.Errorf("Expected not_retweeted after the original is deleted, got %s", code)
+	}
+	if got := send("GET", "/tweets/"+quote.ID, "", nil, http.StatusOK); got.ReferencedTweet != nil {
+		t.Errorf("Expected the quote to stay without the original, got %+v", got.ReferencedTweet)
+	}
+
+	decodeResponse(headerRequest(t, server, "GET", "/users/user1/timeline?limit=3", "", nil), &timeline)
+	for _, tweet := range timeline.Tweets {
+		if tweet.ReferencedTweetID == original.ID && tweet.Kind == "retweet" {
+			t.Errorf("Expected no retweets of the deleted tweet in the timeline, got %+v", tweet)
+		}
+	}
+	if len(timeline.Tweets) != 2 || timeline.Tweets[0].ID != quote.ID || timeline.Tweets[1].ID != older.ID {
+		t.Errorf("Unexpected timeline after delete: %+v", timeline.Tweets)
+	}
+}
@@ -0,0 +1,1354 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/file"
+	"twitter-clone-backend/internal/adapters/memory"
+	mongoAdapters "twitter-clone-backend/internal/adapters/mongo"
+	sqlAdapters "twitter-clone-backend/internal/adapters/sql"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/ports"
+
+	_ "modernc.org/sqlite"
+)
+
+// storage groups the repository ports every storage adapter implements
+type storage interface {
+	ports.TweetRepository
+	ports.FollowRepository
+	ports.LikeRepository
+	ports.NotificationRepository
+	ports.UserRepository
+	ports.CredentialRepository
+	ports.APIKeyRepository
+	ports.WebhookRepository
+	ports.OutboxRepository
+	ports.RevocationList
+}
+
+// storageFactories returns a fresh instance of every storage adapter. The
+// MongoDB adapter runs only when MONGO_TEST_URI points at a replica set
+// (e.g. MONGO_TEST_URI=mongodb://localhost:27017/?directConnection=true),
+// needed by the outbox transactions, otherwise it is skipped
+func storageFactories() map[string]func(t *testing.T) storage {
+	return map[string]func(t *testing.T) storage{
+		"memory": func(t *testing.T) storage {
+			return memory.NewRepositories()
+		},
+		"file": func(t *testing.T) storage {
+			repo, err := file.Open(file.Options{Dir: t.TempDir(), FsyncPolicy: file.FsyncNever})
+			if err != nil {
+				t.Fatalf("Failed to open file storage: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+		"sql": func(t *testing.T) storage {
+			dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+			repo, err := sqlAdapters.Open(context.Background(), "sqlite", dsn)
+			if err != nil {
+				t.Fatalf("Failed to open sql storage: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+		"mongo": func(t *testing.T) storage {
+			uri := os.Getenv("MONGO_TEST_URI")
+			if uri == "" {
+				t.Skip("MONGO_TEST_URI not set")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			database := fmt.Sprintf("twitter_clone_test_%d", time.Now().UnixNano())
+			repo, err := mongoAdapters.Open(ctx, uri, database)
+			if err != nil {
+				t.Fatalf("Failed to open mongo storage: %v", err)
+			}
+			t.Cleanup(func() {
+				repo.Drop(context.Background())
+				repo.Close()
+			})
+			return repo
+		},
+	}
+}
+
+func TestStorageContract(t *testing.T) {
+	for name, factory := range storageFactories() {
+		factory := factory
+		t.Run(name, func(t *testing.T) {
+			t.Run("Tweets", func(t *testing.T) { testTweetRepositoryContract(t, factory(t)) })
+			t.Run("Pagination", func(t *testing.T) { testTweetPaginationContract(t, factory(t)) })
+			t.Run("Replies", func(t *testing.T) { testReplyContract(t, factory(t)) })
+			t.Run("Retweets", func(t *testing.T) { testRetweetContract(t, factory(t)) })
+			t.Run("Mentions", func(t *testing.T) { testMentionContract(t, factory(t)) })
+			t.Run("Follows", func(t *testing.T) { testFollowRepositoryContract(t, factory(t)) })
+			t.Run("Blocks", func(t *testing.T) { testBlockContract(t, factory(t)) })
+			t.Run("Likes", func(t *testing.T) { testLikeRepositoryContract(t, factory(t)) })
+			t.Run("Notifications", func(t *testing.T) { testNotificationRepositoryContract(t, factory(t)) })
+			t.Run("Users", func(t *testing.T) { testUserRepositoryContract(t, factory(t)) })
+			t.Run("Credentials", func(t *testing.T) { testCredentialRepositoryContract(t, factory(t)) })
+			t.Run("APIKeys", func(t *testing.T) { testAPIKeyRepositoryContract(t, factory(t)) })
+			t.Run("Webhooks", func(t *testing.T) { testWebhookRepositoryContract(t, factory(t)) })
+			t.Run("Outbox", func(t *testing.T) { testOutboxContract(t, factory(t)) })
+			t.Run("Revocations", func(t *testing.T) { testRevocationContract(t, factory(t)) })
+		})
+	}
+}
+
+// newTweetAt creates a tweet with a deterministic creation time
+func newTweetAt(t *testing.T, userID, content string, createdAt time.Time) *domain.Tweet {
+	t.Helper()
+	tweet, err := domain.NewTweet(userID, content)
+	if err != nil {
+		t.Fatalf("Failed to create tweet: %v", err)
+	}
+	tweet.CreatedAt = createdAt
+	return tweet
+}
+
+func testTweetRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := newTweetAt(t, "user1", "first", base)
+	second := newTweetAt(t, "user2", "second", base.Add(time.Minute))
+	third := newTweetAt(t, "user1", "third", base.Add(2*time.Minute))
+	other := newTweetAt(t, "user3", "other", base.Add(3*time.Minute))
+
+	for _, tweet := range []*domain.Tweet{first, second, third, other} {
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	got, err := repo.GetByID(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.UserID != "user2" || got.Content != "second" || !got.CreatedAt.Equal(second.CreatedAt) {
+		t.Errorf("Unexpected tweet: %+v", got)
+	}
+
+	if _, err := repo.GetByID(ctx, "missing"); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected ErrTweetNotFound, got %v", err)
+	}
+
+	// Entities are stored with the tweet
+	tagged := newTweetAt(t, "user3", "@bob #go $GOOG https://go.dev", base.Add(-time.Minute))
+	tagged.Entities.Mentions[0].UserID = "user2"
+	if err := repo.Create(ctx, tagged); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if got, err := repo.GetByID(ctx, tagged.ID); err != nil || !reflect.DeepEqual(got.Entities, tagged.Entities) {
+		t.Errorf("Expected entities %+v, got %+v (err: %v)", tagged.Entities, got, err)
+	}
+
+	byIDs, err := repo.GetByIDs(ctx, []string{third.ID, "missing", first.ID})
+	if err != nil {
+		t.Fatalf("GetByIDs failed: %v", err)
+	}
+	assertTweetIDs(t, byIDs, third.ID, first.ID)
+
+	userTweets, err := repo.GetByUserID(ctx, "user1", domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetByUserID failed: %v", err)
+	}
+	assertTweetIDs(t, userTweets, third.ID, first.ID)
+
+	timeline, err := repo.GetTimeline(ctx, []string{"user1", "user2"}, domain.PageQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	assertTweetIDs(t, timeline, third.ID, second.ID, first.ID)
+
+	limited, err := repo.GetTimeline(ctx, []string{"user1", "user2"}, domain.PageQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	assertTweetIDs(t, limited, third.ID, second.ID)
+
+	if err := repo.Delete(ctx, third.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.Delete(ctx, third.ID); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected ErrTweetNotFound on second delete, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, third.ID); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected deleted tweet to be gone, got %v", err)
+	}
+}
+
+func testTweetPaginationContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Several tweets share a timestamp: cursors must not repeat or skip them
+	tweets := []*domain.Tweet{
+		newTweetAt(t, "user2", "older", base.Add(-time.Minute)),
+		newTweetAt(t, "user2", "newer", base.Add(time.Minute)),
+	}
+	for i := 0; i < 5; i++ {
+		tweets = append(tweets, newTweetAt(t, "user1", fmt.Sprintf("tied %d", i), base))
+	}
+	for _, tweet := range tweets {
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	users := []string{"user1", "user2"}
+	all, err := repo.GetTimeline(ctx, users, domain.PageQuery{})
+	if err != nil || len(all) != len(tweets) {
+		t.Fatalf("Expected %d tweets, got %d (err: %v)", len(tweets), len(all), err)
+	}
+
+	// Infinite scroll with max_id
+	var scrolled []*domain.Tweet
+	page := domain.PageQuery{Limit: 2}
+	for {
+		batch, err := repo.GetTimeline(ctx, users, page)
+		if err != nil {
+			t.Fatalf("GetTimeline failed: %v", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		scrolled = append(scrolled, batch...)
+		cursor := domain.CursorOf(batch[len(batch)-1])
+		page.MaxID = &cursor
+	}
+	assertTweetIDs(t, scrolled, tweetIDs(all)...)
+
+	// Polling with since_id returns the tweets closest to the cursor first
+	since := domain.CursorOf(all[len(all)-1])
+	polled, err := repo.GetTimeline(ctx, users, domain.PageQuery{Limit: 2, SinceID: &since})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	assertTweetIDs(t, polled, all[len(all)-3].ID, all[len(all)-2].ID)
+
+	// Both cursors select the tweets in between
+	maxID := domain.CursorOf(all[1])
+	between, err := repo.GetTimeline(ctx, users, domain.PageQuery{MaxID: &maxID, SinceID: &since})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	assertTweetIDs(t, between, tweetIDs(all[2:len(all)-1])...)
+
+	// User tweets use the same cursors
+	userTweets, _ := repo.GetByUserID(ctx, "user1", domain.PageQuery{})
+	firstPage, err := repo.GetByUserID(ctx, "user1", domain.PageQuery{Limit: 3})
+	if err != nil {
+		t.Fatalf("GetByUserID failed: %v", err)
+	}
+	cursor := domain.CursorOf(firstPage[len(firstPage)-1])
+	secondPage, err := repo.GetByUserID(ctx, "user1", domain.PageQuery{Limit: 3, MaxID: &cursor})
+	if err != nil {
+		t.Fatalf("GetByUserID failed: %v", err)
+	}
+	assertTweetIDs(t, append(firstPage, secondPage...), tweetIDs(userTweets)...)
+
+	// Cursors keep nanoseconds: tweets less than a millisecond apart are
+	// neither repeated nor skipped, with cursors taken from the tweets as
+	// created rather than as stored
+	var precise []*domain.Tweet
+	for i := 0; i < 4; i++ {
+		tweet := newTweetAt(t, "user4", fmt.Sprintf("precise %d", i), base.Add(time.Hour+time.Duration(i)*time.Microsecond))
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		precise = append(precise, tweet)
+	}
+	maxID = domain.CursorOf(precise[2])
+	older, err := repo.GetByUserID(ctx, "user4", domain.PageQuery{MaxID: &maxID})
+	if err != nil {
+		t.Fatalf("GetByUserID failed: %v", err)
+	}
+	assertTweetIDs(t, older, precise[1].ID, precise[0].ID)
+	since = domain.CursorOf(precise[1])
+	newer, err := repo.GetByUserID(ctx, "user4", domain.PageQuery{SinceID: &since})
+	if err != nil {
+		t.Fatalf("GetByUserID failed: %v", err)
+	}
+	assertTweetIDs(t, newer, precise[3].ID, precise[2].ID)
+}
+
+// newReplyAt creates a reply with a deterministic creation time
+func newReplyAt(t *testing.T, userID, content string, parent *domain.Tweet, createdAt time.Time) *domain.Tweet {
+	t.Helper()
+	reply, err := domain.NewReply(userID, content, parent)
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	reply.CreatedAt = createdAt
+	return reply
+}
+
+func testReplyContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	root := newTweetAt(t, "user1", "root", base)
+	first := newReplyAt(t, "user2", "first reply", root, base.Add(time.Minute))
+	nested := newReplyAt(t, "user1", "nested reply", first, base.Add(2*time.Minute))
+	second := newReplyAt(t, "user3", "second reply", root, base.Add(3*time.Minute))
+	unrelated := newTweetAt(t, "user2", "unrelated", base.Add(4*time.Minute))
+
+	for _, tweet := range []*domain.Tweet{root, first, nested, second, unrelated} {
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	got, err := repo.GetByID(ctx, nested.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.InReplyToTweetID != first.ID || got.Conversation() != root.ID {
+		t.Errorf("Unexpected reply fields: %+v", got)
+	}
+
+	replies, err := repo.GetReplies(ctx, root.ID, domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetReplies failed: %v", err)
+	}
+	assertTweetIDs(t, replies, second.ID, first.ID)
+
+	cursor := domain.CursorOf(second)
+	older, err := repo.GetReplies(ctx, root.ID, domain.PageQuery{Limit: 1, MaxID: &cursor})
+	if err != nil {
+		t.Fatalf("GetReplies failed: %v", err)
+	}
+	assertTweetIDs(t, older, first.ID)
+
+	conversation, err := repo.GetConversation(ctx, root.ID, 0)
+	if err != nil {
+		t.Fatalf("GetConversation failed: %v", err)
+	}
+	assertTweetIDs(t, conversation, root.ID, first.ID, nested.ID, second.ID)
+
+	limited, err := repo.GetConversation(ctx, root.ID, 2)
+	if err != nil {
+		t.Fatalf("GetConversation failed: %v", err)
+	}
+	assertTweetIDs(t, limited, root.ID, first.ID)
+
+	counts, err := repo.CountReplies(ctx, []string{root.ID, first.ID, nested.ID, "missing"})
+	if err != nil {
+		t.Fatalf("CountReplies failed: %v", err)
+	}
+	if counts[root.ID] != 2 || counts[first.ID] != 1 || counts[nested.ID] != 0 || counts["missing"] != 0 {
+		t.Errorf("Unexpected reply counts: %v", counts)
+	}
+}
+
+func testRetweetContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	original, _ := domain.NewTweet("user1", "original")
+	other, _ := domain.NewTweet("user2", "other")
+	for _, tweet := range []*domain.Tweet{original, other} {
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	retweet, _ := domain.NewRetweet("user2", original)
+	if err := repo.CreateRetweet(ctx, retweet); err != nil {
+		t.Fatalf("CreateRetweet failed: %v", err)
+	}
+	again, _ := domain.NewRetweet("user2", original)
+	if err := repo.CreateRetweet(ctx, again); err != domain.ErrAlreadyRetweeted {
+		t.Errorf("Expected ErrAlreadyRetweeted, got %v", err)
+	}
+	// Other users, and the same user on other tweets, can still retweet
+	byOther, _ := domain.NewRetweet("user3", original)
+	if err := repo.CreateRetweet(ctx, byOther); err != nil {
+		t.Fatalf("CreateRetweet failed: %v", err)
+	}
+	ofOther, _ := domain.NewRetweet("user2", other)
+	if err := repo.CreateRetweet(ctx, ofOther); err != nil {
+		t.Fatalf("CreateRetweet failed: %v", err)
+	}
+
+	quote, _ := domain.NewQuote("user3", "look at this", original)
+	if err := repo.Create(ctx, quote); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	got, err := repo.GetRetweet(ctx, "user2", original.ID)
+	if err != nil {
+		t.Fatalf("GetRetweet failed: %v", err)
+	}
+	if got.ID != retweet.ID || !got.IsRetweet() || got.ReferencedTweetID != original.ID || got.Content != "" {
+		t.Errorf("Unexpected retweet: %+v", got)
+	}
+	if _, err := repo.GetRetweet(ctx, "user1", original.ID); err != domain.ErrNotRetweeted {
+		t.Errorf("Expected ErrNotRetweeted, got %v", err)
+	}
+	// A quote is not a retweet
+	if _, err := repo.GetRetweet(ctx, "user3", other.ID); err != domain.ErrNotRetweeted {
+		t.Errorf("Expected ErrNotRetweeted, got %v", err)
+	}
+
+	got, err = repo.GetByID(ctx, quote.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if !got.IsQuote() || got.ReferencedTweetID != original.ID || got.Content != "look at this" {
+		t.Errorf("Unexpected quote: %+v", got)
+	}
+
+	ids := []string{original.ID, other.ID, quote.ID}
+	retweets, err := repo.CountRetweets(ctx, ids)
+	if err != nil {
+		t.Fatalf("CountRetweets failed: %v", err)
+	}
+	if retweets[original.ID] != 2 || retweets[other.ID] != 1 || retweets[quote.ID] != 0 {
+		t.Errorf("Unexpected retweet counts: %v", retweets)
+	}
+	quotes, err := repo.CountQuotes(ctx, ids)
+	if err != nil {
+		t.Fatalf("CountQuotes failed: %v", err)
+	}
+	if quotes[original.ID] != 1 || quotes[other.ID] != 0 {
+		t.Errorf("Unexpected quote counts: %v", quotes)
+	}
+
+	all, err := repo.GetRetweets(ctx, original.ID)
+	if err != nil {
+		t.Fatalf("GetRetweets failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ReferencedTweetID != original.ID || all[1].ReferencedTweetID != original.ID ||
+		!all[0].IsRetweet() || !all[1].IsRetweet() {
+		t.Errorf("Expected the 2 retweets of the original, got %+v", all)
+	}
+	if none, err := repo.GetRetweets(ctx, quote.ID); err != nil || len(none) != 0 {
+		t.Errorf("Expected no retweets of the quote, got %v %v", none, err)
+	}
+
+	// Undoing a retweet allows retweeting again
+	if err := repo.Delete(ctx, retweet.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := repo.CreateRetweet(ctx, again); err != nil {
+		t.Errorf("Expected retweet after undo to succeed, got %v", err)
+	}
+}
+
+func testFollowRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	if err := repo.FollowIfNotExists(ctx, "user1", "user2"); err != nil {
+		t.Fatalf("FollowIfNotExists failed: %v", err)
+	}
+	if err := repo.FollowIfNotExists(ctx, "user1", "user2"); err != domain.ErrAlreadyFollowing {
+		t.Errorf("Expected ErrAlreadyFollowing, got %v", err)
+	}
+	if err := repo.Follow(ctx, "user3", "user2"); err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	followers, _ := repo.GetFollowers(ctx, "user2")
+	sort.Strings(followers)
+	if len(followers) != 2 || followers[0] != "user1" || followers[1] != "user3" {
+		t.Errorf("Unexpected followers: %v", followers)
+	}
+
+	if count, err := repo.CountFollowers(ctx, "user2"); err != nil || count != 2 {
+		t.Errorf("Expected 2 followers, got %d (err: %v)", count, err)
+	}
+
+	following, _ := repo.GetFollowing(ctx, "user1")
+	if len(following) != 1 || following[0] != "user2" {
+		t.Errorf("Unexpected following: %v", following)
+	}
+
+	if isFollowing, _ := repo.IsFollowing(ctx, "user2", "user1"); isFollowing {
+		t.Error("Follow relationships must be directed")
+	}
+
+	if err := repo.UnfollowIfExists(ctx, "user1", "user2"); err != nil {
+		t.Fatalf("UnfollowIfExists failed: %v", err)
+	}
+	if err := repo.UnfollowIfExists(ctx, "user1", "user2"); err != domain.ErrNotFollowing {
+		t.Errorf("Expected ErrNotFollowing, got %v", err)
+	}
+	if err := repo.Unfollow(ctx, "user3", "user2"); err != nil {
+		t.Fatalf("Unfollow failed: %v", err)
+	}
+	if followers, _ := repo.GetFollowers(ctx, "user2"); len(followers) != 0 {
+		t.Errorf("Expected no followers, got %v", followers)
+	}
+
+	// Concurrent follows of the same relationship: exactly one succeeds
+	const attempts = 20
+	var successes int32
+	var wg sync.WaitGroup
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			defer wg.Done()
+			if repo.FollowIfNotExists(ctx, "user2", "user3") == nil {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if successes != 1 {
+		t.Errorf("Expected exactly 1 successful follow, got %d", successes)
+	}
+}
+
+func testBlockContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	block, _ := domain.NewBlock("user1", "user2")
+	if err := repo.Block(ctx, block); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	again, _ := domain.NewBlock("user1", "user2")
+	if err := repo.Block(ctx, again); err != domain.ErrAlreadyBlocked {
+		t.Errorf("Expected ErrAlreadyBlocked, got %v", err)
+	}
+
+	// Blocks go one way
+	if blocked, err := repo.IsBlocked(ctx, "user1", "user2"); err != nil || !blocked {
+		t.Errorf("Expected user2 to be blocked by user1, got %v %v", blocked, err)
+	}
+	if blocked, err := repo.IsBlocked(ctx, "user2", "user1"); err != nil || blocked {
+		t.Errorf("Expected user1 not to be blocked by user2, got %v %v", blocked, err)
+	}
+
+	if err := repo.Unblock(ctx, "user1", "user2"); err != nil {
+		t.Fatalf("Unblock failed: %v", err)
+	}
+	if err := repo.Unblock(ctx, "user1", "user2"); err != domain.ErrNotBlocked {
+		t.Errorf("Expected ErrNotBlocked, got %v", err)
+	}
+	if blocked, _ := repo.IsBlocked(ctx, "user1", "user2"); blocked {
+		t.Errorf("Expected user2 to be unblocked")
+	}
+	if err := repo.Block(ctx, again); err != nil {
+		t.Errorf("Expected block after unblock to succeed, got %v", err)
+	}
+}
+
+func testLikeRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	likeAt := func(userID, tweetID string, likedAt time.Time) *domain.Like {
+		like, err := domain.NewLike(userID, tweetID)
+		if err != nil {
+			t.Fatalf("Failed to create like: %v", err)
+		}
+		like.CreatedAt = likedAt
+		return like
+	}
+
+	for i, tweetID := range []string{"tweet1", "tweet2", "tweet3"} {
+		if err := repo.LikeIfNotExists(ctx, likeAt("user1", tweetID, base.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("LikeIfNotExists failed: %v", err)
+		}
+	}
+	if err := repo.LikeIfNotExists(ctx, likeAt("user2", "tweet1", base)); err != nil {
+		t.Fatalf("LikeIfNotExists failed: %v", err)
+	}
+	if err := repo.LikeIfNotExists(ctx, likeAt("user1", "tweet1", base.Add(time.Hour))); err != domain.ErrAlreadyLiked {
+		t.Errorf("Expected ErrAlreadyLiked, got %v", err)
+	}
+
+	// Most recently liked first, paged by like time
+	likes, err := repo.GetLikes(ctx, "user1", domain.PageQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetLikes failed: %v", err)
+	}
+	if len(likes) != 2 || likes[0].TweetID != "tweet3" || likes[1].TweetID != "tweet2" {
+		t.Fatalf("Unexpected likes: %+v", likes)
+	}
+	cursor := domain.CursorOfLike(likes[1])
+	older, err := repo.GetLikes(ctx, "user1", domain.PageQuery{Limit: 2, MaxID: &cursor})
+	if err != nil {
+		t.Fatalf("GetLikes failed: %v", err)
+	}
+	if len(older) != 1 || older[0].TweetID != "tweet1" || !older[0].CreatedAt.Equal(base) {
+		t.Errorf("Unexpected older likes: %+v", older)
+	}
+	cursor = domain.CursorOfLike(older[0])
+	newer, err := repo.GetLikes(ctx, "user1", domain.PageQuery{Limit: 1, SinceID: &cursor})
+	if err != nil {
+		t.Fatalf("GetLikes failed: %v", err)
+	}
+	if len(newer) != 1 || newer[0].TweetID != "tweet2" {
+		t.Errorf("Expected the like closest to the cursor, got %+v", newer)
+	}
+
+	counts, err := repo.CountLikes(ctx, []string{"tweet1", "tweet2", "missing"})
+	if err != nil {
+		t.Fatalf("CountLikes failed: %v", err)
+	}
+	if counts["tweet1"] != 2 || counts["tweet2"] != 1 || counts["missing"] != 0 {
+		t.Errorf("Unexpected like counts: %v", counts)
+	}
+
+	liked, err := repo.GetLikedTweetIDs(ctx, "user2", []string{"tweet1", "tweet2"})
+	if err != nil {
+		t.Fatalf("GetLikedTweetIDs failed: %v", err)
+	}
+	if !liked["tweet1"] || liked["tweet2"] {
+		t.Errorf("Unexpected liked tweets: %v", liked)
+	}
+
+	if err := repo.UnlikeIfExists(ctx, "user1", "tweet1"); err != nil {
+		t.Fatalf("UnlikeIfExists failed: %v", err)
+	}
+	if err := repo.UnlikeIfExists(ctx, "user1", "tweet1"); err != domain.ErrNotLiked {
+		t.Errorf("Expected ErrNotLiked, got %v", err)
+	}
+	if counts, _ := repo.CountLikes(ctx, []string{"tweet1"}); counts["tweet1"] != 1 {
+		t.Errorf("Expected 1 like after unlike, got %d", counts["tweet1"])
+	}
+
+	// Concurrent likes of the same tweet: exactly one succeeds
+	const attempts = 20
+	var successes int32
+	var wg sync.WaitGroup
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			defer wg.Done()
+			if repo.LikeIfNotExists(ctx, likeAt("user3", "tweet1", base)) == nil {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if successes != 1 {
+		t.Errorf("Expected exactly 1 successful like, got %d", successes)
+	}
+}
+
+func testUserRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	// Example users are available in every storage
+	for _, id := range []string{"user1", "user2", "user3"} {
+		if exists, err := repo.Exists(ctx, id); err != nil || !exists {
+			t.Errorf("Expected seed user %s to exist (err: %v)", id, err)
+		}
+	}
+
+	if err := repo.CreateUser(ctx, domain.NewUser("user4", "dave")); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	user, err := repo.GetUserByID(ctx, "user4")
+	if err != nil || user.Username != "dave" {
+		t.Errorf("Unexpected user: %+v (err: %v)", user, err)
+	}
+
+	user, err = repo.GetUserByUsername(ctx, "dave")
+	if err != nil || user.ID != "user4" {
+		t.Errorf("Unexpected user: %+v (err: %v)", user, err)
+	}
+
+	if _, err := repo.GetUserByID(ctx, "missing"); err != domain.ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+	if exists, _ := repo.Exists(ctx, "missing"); exists {
+		t.Error("Expected missing user not to exist")
+	}
+
+	// Usernames are unique and looked up ignoring case
+	if err := repo.CreateUser(ctx, domain.NewUser("user5", "DAVE")); err != domain.ErrUsernameTaken {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if user, err := repo.GetUserByUsername(ctx, "Dave"); err != nil || user.ID != "user4" {
+		t.Errorf("Unexpected user: %+v (err: %v)", user, err)
+	}
+
+	// Profile updates
+	profiled := domain.NewUser("user4", "dave")
+	profiled.SetProfile(domain.Profile{DisplayName: "Dave", Bio: "Hi", AvatarURL: "https://example.com/a.png", Location: "Earth"})
+	if err := repo.UpdateUser(ctx, profiled); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	user, err = repo.GetUserByID(ctx, "user4")
+	if err != nil || user.Profile() != profiled.Profile() || user.Username != "dave" {
+		t.Errorf("Unexpected user after update: %+v (err: %v)", user, err)
+	}
+	if err := repo.UpdateUser(ctx, domain.NewUser("missing", "missing")); err != domain.ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	// Concurrent registrations of the same username: exactly one succeeds
+	const attempts = 20
+	var successes int32
+	var wg sync.WaitGroup
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			username := "Erin"
+			if i%2 == 0 {
+				username = "erin"
+			}
+			if repo.CreateUser(ctx, domain.NewUser(fmt.Sprintf("erin%d", i), username)) == nil {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if successes != 1 {
+		t.Errorf("Expected exactly 1 successful registration, got %d", successes)
+	}
+}
+
+func testCredentialRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	if _, err := repo.GetCredentials(ctx, "user1"); err != domain.ErrNoCredentials {
+		t.Errorf("Expected ErrNoCredentials, got %v", err)
+	}
+
+	first := domain.NewCredentials("user1", "hash-1")
+	if err := repo.SetCredentials(ctx, first); err != nil {
+		t.Fatalf("SetCredentials failed: %v", err)
+	}
+	credentials, err := repo.GetCredentials(ctx, "user1")
+	// MongoDB stores times with millisecond precision
+	if err != nil || credentials.PasswordHash != "hash-1" || credentials.UpdatedAt.Sub(first.UpdatedAt).Abs() > time.Millisecond {
+		t.Errorf("Unexpected credentials: %+v (err: %v)", credentials, err)
+	}
+
+	// Setting again replaces the hash
+	if err := repo.SetCredentials(ctx, domain.NewCredentials("user1", "hash-2")); err != nil {
+		t.Fatalf("SetCredentials failed: %v", err)
+	}
+	if credentials, err := repo.GetCredentials(ctx, "user1"); err != nil || credentials.PasswordHash != "hash-2" {
+		t.Errorf("Unexpected credentials: %+v (err: %v)", credentials, err)
+	}
+
+	// Deleting is idempotent
+	for i := 0; i < 2; i++ {
+		if err := repo.DeleteCredentials(ctx, "user1"); err != nil {
+			t.Errorf("DeleteCredentials failed: %v", err)
+		}
+	}
+	if _, err := repo.GetCredentials(ctx, "user1"); err != domain.ErrNoCredentials {
+		t.Errorf("Expected ErrNoCredentials after delete, got %v", err)
+	}
+}
+
+func testAPIKeyRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+
+	older, _, _ := domain.NewAPIKey("user1", "bot", []string{domain.ScopeTweetsWrite})
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	newer, _, _ := domain.NewAPIKey("user1", "reader", []string{domain.ScopeTimelineRead, domain.ScopeFollowsWrite})
+	other, _, _ := domain.NewAPIKey("user2", "bot", []string{domain.ScopeTweetsWrite})
+	for _, key := range []*domain.APIKey{older, newer, other} {
+		if err := repo.CreateAPIKey(ctx, key); err != nil {
+			t.Fatalf("CreateAPIKey failed: %v", err)
+		}
+	}
+
+	key, err := repo.GetAPIKey(ctx, newer.ID)
+	if err != nil || key.UserID != "user1" || key.Name != "reader" || key.KeyHash != newer.KeyHash ||
+		len(key.Scopes) != 2 || !key.HasScope(domain.ScopeFollowsWrite) || !key.LastUsedAt.IsZero() {
+		t.Errorf("Unexpected API key: %+v (err: %v)", key, err)
+	}
+	if _, err := repo.GetAPIKey(ctx, "missing"); err != domain.ErrAPIKeyNotFound {
+		t.Errorf("Expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	keys, err := repo.ListAPIKeys(ctx, "user1")
+	if err != nil || len(keys) != 2 || keys[0].ID != newer.ID || keys[1].ID != older.ID {
+		t.Errorf("Expected keys of user1 newest first, got %d keys (err: %v)", len(keys), err)
+	}
+
+	usedAt := time.Now().Truncate(time.Millisecond)
+	if err := repo.TouchAPIKey(ctx, newer.ID, usedAt); err != nil {
+		t.Fatalf("TouchAPIKey failed: %v", err)
+	}
+	if key, _ := repo.GetAPIKey(ctx, newer.ID); !key.LastUsedAt.Equal(usedAt) {
+		t.Errorf("Expected last use at %v, got %v", usedAt, key.LastUsedAt)
+	}
+	if err := repo.TouchAPIKey(ctx, "missing", usedAt); err != nil {
+		t.Errorf("Expected touching a missing key to be a no-op, got %v", err)
+	}
+
+	// Keys can only be deleted by their owner
+	if err := repo.DeleteAPIKey(ctx, "user2", newer.ID); err != domain.ErrAPIKeyNotFound {
+		t.Errorf("Expected ErrAPIKeyNotFound deleting another user's key, got %v", err)
+	}
+	if err := repo.DeleteAPIKey(ctx, "user1", newer.ID); err != nil {
+		t.Fatalf("DeleteAPIKey failed: %v", err)
+	}
+	if err := repo.DeleteAPIKey(ctx, "user1", newer.ID); err != domain.ErrAPIKeyNotFound {
+		t.Errorf("Expected ErrAPIKeyNotFound deleting twice, got %v", err)
+	}
+	if keys, _ := repo.ListAPIKeys(ctx, "user1"); len(keys) != 1 || keys[0].ID != older.ID {
+		t.Errorf("Expected only the older key to remain, got %d keys", len(keys))
+	}
+}
+
+func testWebhookRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	secret := "0123456789abcdef"
+
+	older, _ := domain.NewWebhook("user1", "https://example.com/a", []string{domain.EventTweetCreated}, secret, false)
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	newer, _ := domain.NewWebhook("user1", "https://example.com/b",
+		[]string{domain.EventUserFollowed, domain.EventTweetDeleted}, secret, false)
+	other, _ := domain.NewWebhook("user2", "https://example.com/c", []string{domain.EventTweetCreated}, secret, false)
+	for _, webhook := range []*domain.Webhook{older, newer, other} {
+		if err := repo.CreateWebhook(ctx, webhook); err != nil {
+			t.Fatalf("CreateWebhook failed: %v", err)
+		}
+	}
+
+	webhook, err := repo.GetWebhook(ctx, newer.ID)
+	if err != nil || webhook.UserID != "user1" || webhook.URL != newer.URL || webhook.Secret != secret ||
+		len(webhook.EventTypes) != 2 || !webhook.Subscribes(domain.EventUserFollowed) || !webhook.IsEnabled() {
+		t.Errorf("Unexpected webhook: %+v (err: %v)", webhook, err)
+	}
+	if _, err := repo.GetWebhook(ctx, "missing"); err != domain.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+
+	webhooks, err := repo.ListWebhooks(ctx, "user1")
+	if err != nil || len(webhooks) != 2 || webhooks[0].ID != newer.ID || webhooks[1].ID != older.ID {
+		t.Errorf("Expected webhooks of user1 newest first, got %d webhooks (err: %v)", len(webhooks), err)
+	}
+
+	// Failures are recorded until the webhook is disabled, and enabling it
+	// resets them
+	disabledAt := time.Now().Truncate(time.Millisecond)
+	if err := repo.UpdateWebhook(ctx, newer.RecordFailure(1, disabledAt)); err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	webhook, _ = repo.GetWebhook(ctx, newer.ID)
+	if webhook.ConsecutiveFailures != 1 || !webhook.DisabledAt.Equal(disabledAt) {
+		t.Errorf("Expected the webhook disabled after 1 failure, got %+v", webhook)
+	}
+	if err := repo.UpdateWebhook(ctx, webhook.Enable()); err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	if webhook, _ := repo.GetWebhook(ctx, newer.ID); webhook.ConsecutiveFailures != 0 || !webhook.IsEnabled() {
+		t.Errorf("Expected the webhook enabled again, got %+v", webhook)
+	}
+	missing, _ := domain.NewWebhook("user1", "https://example.com/d", []string{domain.EventTweetCreated}, secret, false)
+	if err := repo.UpdateWebhook(ctx, missing); err != domain.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound updating a missing webhook, got %v", err)
+	}
+
+	// The log keeps the last attempts of each webhook, newest first
+	message := &domain.WebhookMessage{EventID: "event1", EventType: domain.EventUserFollowed}
+	base := time.Now().Truncate(time.Millisecond)
+	total := domain.MaxWebhookDeliveries + 5
+	for i := 0; i < total; i++ {
+		delivery := domain.NewWebhookDelivery(newer.ID, message, i+1, 500, fmt.Errorf("server error"))
+		delivery.CreatedAt = base.Add(time.Duration(i) * time.Millisecond)
+		if err := repo.AddWebhookDelivery(ctx, delivery); err != nil {
+			t.Fatalf("AddWebhookDelivery failed: %v", err)
+		}
+	}
+	success := domain.NewWebhookDelivery(older.ID, message, 1, 204, nil)
+	if err := repo.AddWebhookDelivery(ctx, success); err != nil {
+		t.Fatalf("AddWebhookDelivery failed: %v", err)
+	}
+	orphan := domain.NewWebhookDelivery("missing", message, 1, 204, nil)
+	if err := repo.AddWebhookDelivery(ctx, orphan); err != nil {
+		t.Errorf("Expected logging for a missing webhook to be a no-op, got %v", err)
+	}
+
+	deliveries, err := repo.ListWebhookDeliveries(ctx, newer.ID, 0)
+	if err != nil || len(deliveries) != domain.MaxWebhookDeliveries {
+		t.Fatalf("Expected %d deliveries, got %d (err: %v)", domain.MaxWebhookDeliveries, len(deliveries), err)
+	}
+	if deliveries[0].Attempt != total || deliveries[len(deliveries)-1].Attempt != total-domain.MaxWebhookDeliveries+1 {
+		t.Errorf("Expected the last attempts newest first, got %d..%d",
+			deliveries[0].Attempt, deliveries[len(deliveries)-1].Attempt)
+	}
+	if deliveries[0].StatusCode != 500 || deliveries[0].Error != "server error" || deliveries[0].EventID != "event1" {
+		t.Errorf("Unexpected delivery: %+v", deliveries[0])
+	}
+	if deliveries, _ := repo.ListWebhookDeliveries(ctx, newer.ID, 3); len(deliveries) != 3 || deliveries[0].Attempt != total {
+		t.Errorf("Expected the 3 newest deliveries, got %d", len(deliveries))
+	}
+	deliveries, _ = repo.ListWebhookDeliveries(ctx, older.ID, 0)
+	if len(deliveries) != 1 || !deliveries[0].Succeeded() || deliveries[0].StatusCode != 204 {
+		t.Errorf("Expected the successful delivery of the older webhook, got %+v", deliveries)
+	}
+	if deliveries, _ := repo.ListWebhookDeliveries(ctx, "missing", 0); len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries for a missing webhook, got %d", len(deliveries))
+	}
+
+	// Webhooks can only be deleted by their owner, and take their log along
+	if err := repo.DeleteWebhook(ctx, "user2", newer.ID); err != domain.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound deleting another user's webhook, got %v", err)
+	}
+	if err := repo.DeleteWebhook(ctx, "user1", newer.ID); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if err := repo.DeleteWebhook(ctx, "user1", newer.ID); err != domain.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound deleting twice, got %v", err)
+	}
+	if deliveries, _ := repo.ListWebhookDeliveries(ctx, newer.ID, 0); len(deliveries) != 0 {
+		t.Errorf("Expected the log deleted with the webhook, got %d deliveries", len(deliveries))
+	}
+	if webhooks, _ := repo.ListWebhooks(ctx, "user1"); len(webhooks) != 1 || webhooks[0].ID != older.ID {
+		t.Errorf("Expected only the older webhook to remain, got %d webhooks", len(webhooks))
+	}
+}
+
+// newMentionAt creates a tweet whose mentions are resolved to userIDs, in
+// order, as the use case does before storing it
+func newMentionAt(t *testing.T, userID, content string, createdAt time.Time, userIDs ...string) *domain.Tweet {
+	t.Helper()
+	tweet := newTweetAt(t, userID, content, createdAt)
+	for i, mentioned := range userIDs {
+		tweet.Entities.Mentions[i].UserID = mentioned
+	}
+	return tweet
+}
+
+func testMentionContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := newMentionAt(t, "user1", "hi @bob", base, "user2")
+	both := newMentionAt(t, "user3", "@bob @alice @Bob", base.Add(time.Minute), "user2", "user1", "user2")
+	plain := newTweetAt(t, "user1", "no mentions", base.Add(2*time.Minute))
+	last := newMentionAt(t, "user1", "again @bob", base.Add(3*time.Minute), "user2")
+
+	for _, tweet := range []*domain.Tweet{first, both, plain, last} {
+		if err := repo.Create(ctx, tweet); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	mentions, err := repo.GetMentions(ctx, "user2", domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetMentions failed: %v", err)
+	}
+	assertTweetIDs(t, mentions, last.ID, both.ID, first.ID)
+
+	cursor := domain.CursorOf(last)
+	older, err := repo.GetMentions(ctx, "user2", domain.PageQuery{Limit: 1, MaxID: &cursor})
+	if err != nil {
+		t.Fatalf("GetMentions failed: %v", err)
+	}
+	assertTweetIDs(t, older, both.ID)
+
+	if mentions, _ := repo.GetMentions(ctx, "user1", domain.PageQuery{}); len(mentions) != 1 || mentions[0].ID != both.ID {
+		t.Errorf("Expected user1 mentioned once, got %d tweets", len(mentions))
+	}
+	if mentions, _ := repo.GetMentions(ctx, "user3", domain.PageQuery{}); len(mentions) != 0 {
+		t.Errorf("Expected no mentions of user3, got %d", len(mentions))
+	}
+
+	// Deleted tweets leave the index
+	if err := repo.Delete(ctx, both.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	mentions, err = repo.GetMentions(ctx, "user2", domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetMentions failed: %v", err)
+	}
+	assertTweetIDs(t, mentions, last.ID, first.ID)
+	if mentions, _ := repo.GetMentions(ctx, "user1", domain.PageQuery{}); len(mentions) != 0 {
+		t.Errorf("Expected the deleted mention gone, got %d", len(mentions))
+	}
+}
+
+// newEventAt creates the notification of a single event at a given time
+func newEventAt(t *testing.T, userID, notificationType, actorID, tweetID string, at time.Time) *domain.Notification {
+	t.Helper()
+	event, err := domain.NewNotification(userID, notificationType, actorID, tweetID)
+	if err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+	event.CreatedAt, event.UpdatedAt = at, at
+	return event
+}
+
+func testNotificationRepositoryContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	notify := func(events ...*domain.Notification) {
+		t.Helper()
+		for _, event := range events {
+			if err := repo.Notify(ctx, event); err != nil {
+				t.Fatalf("Notify failed: %v", err)
+			}
+		}
+	}
+	get := func(page domain.PageQuery) []*domain.Notification {
+		t.Helper()
+		notifications, err := repo.GetNotifications(ctx, "user1", page)
+		if err != nil {
+			t.Fatalf("GetNotifications failed: %v", err)
+		}
+		return notifications
+	}
+	unread := func() int {
+		t.Helper()
+		count, err := repo.CountUnread(ctx, "user1")
+		if err != nil {
+			t.Fatalf("CountUnread failed: %v", err)
+		}
+		return count
+	}
+
+	follow := newEventAt(t, "user1", domain.NotificationFollow, "user2", "", base)
+	like := newEventAt(t, "user1", domain.NotificationLike, "user2", "tweet1", base.Add(time.Minute))
+	reply := newEventAt(t, "user1", domain.NotificationReply, "user3", "tweet2", base.Add(2*time.Minute))
+	notify(follow, like, reply, newEventAt(t, "user2", domain.NotificationFollow, "user1", "", base))
+
+	// Likes of the same tweet are grouped, most recent actor first, and
+	// the group moves up to the time of its latest event
+	notify(
+		newEventAt(t, "user1", domain.NotificationLike, "user3", "tweet1", base.Add(3*time.Minute)),
+		newEventAt(t, "user1", domain.NotificationLike, "user2", "tweet1", base.Add(4*time.Minute)),
+		newEventAt(t, "user1", domain.NotificationLike, "user3", "tweet9", base.Add(5*time.Minute)),
+	)
+
+	all := get(domain.PageQuery{})
+	if len(all) != 4 {
+		t.Fatalf("Expected 4 notifications, got %d", len(all))
+	}
+	if all[1].ID != like.ID || !reflect.DeepEqual(all[1].ActorIDs, []string{"user2", "user3"}) ||
+		!all[1].UpdatedAt.Equal(base.Add(4*time.Minute)) || !all[1].CreatedAt.Equal(like.CreatedAt) {
+		t.Errorf("Unexpected group: %+v", all[1])
+	}
+	if all[2].ID != reply.ID || all[3].ID != follow.ID || all[0].TweetID != "tweet9" {
+		t.Errorf("Unexpected order: %+v", all)
+	}
+	if count := unread(); count != 4 {
+		t.Errorf("Expected 4 unread, got %d", count)
+	}
+
+	cursor := domain.CursorOfNotification(all[1])
+	older := get(domain.PageQuery{Limit: 1, MaxID: &cursor})
+	if len(older) != 1 || older[0].ID != reply.ID {
+		t.Errorf("Expected the reply after the group, got %+v", older)
+	}
+
+	// Marking read up to the group, included, leaves the newer one unread
+	if err := repo.MarkRead(ctx, "user1", &cursor); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	if count := unread(); count != 1 {
+		t.Errorf("Expected 1 unread, got %d", count)
+	}
+	if others, _ := repo.CountUnread(ctx, "user2"); others != 1 {
+		t.Errorf("Expected other users untouched, got %d unread", others)
+	}
+
+	// Read notifications are not grouped into anymore
+	notify(newEventAt(t, "user1", domain.NotificationLike, "user4", "tweet1", base.Add(6*time.Minute)))
+	all = get(domain.PageQuery{})
+	if len(all) != 5 || all[0].ID == like.ID || all[0].Read || !reflect.DeepEqual(all[0].ActorIDs, []string{"user4"}) {
+		t.Errorf("Expected a new group for the read one, got %+v", all[0])
+	}
+	if group := all[2]; group.ID != like.ID || !group.Read {
+		t.Errorf("Expected the old group read, got %+v", group)
+	}
+
+	if err := repo.MarkRead(ctx, "user1", nil); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	if count := unread(); count != 0 {
+		t.Errorf("Expected none unread, got %d", count)
+	}
+
+	// Concurrent events of the same group end up in a single notification
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		event := newEventAt(t, "user3", domain.NotificationRetweet, fmt.Sprintf("actor%d", i), "tweet1", base.Add(time.Duration(i)*time.Second))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Notify(ctx, event); err != nil {
+				t.Errorf("Notify failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	grouped, err := repo.GetNotifications(ctx, "user3", domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetNotifications failed: %v", err)
+	}
+	if len(grouped) != 1 || len(grouped[0].ActorIDs) != 10 || grouped[0].Actors() != 10 {
+		t.Errorf("Expected 1 notification with 10 actors, got %+v", grouped)
+	}
+
+	// Only the latest actors are kept, but all of them are counted
+	extra := 5
+	for i := 0; i < domain.MaxNotificationActors+extra; i++ {
+		notify(newEventAt(t, "user2", domain.NotificationLike, fmt.Sprintf("fan%02d", i), "tweet5", base.Add(time.Duration(i)*time.Second)))
+	}
+	latest := fmt.Sprintf("fan%02d", domain.MaxNotificationActors+extra-1)
+	oldestKept := fmt.Sprintf("fan%02d", extra)
+	// An actor that is kept is neither repeated nor counted again
+	notify(newEventAt(t, "user2", domain.NotificationLike, oldestKept, "tweet5", base.Add(time.Hour)))
+
+	capped, err := repo.GetNotifications(ctx, "user2", domain.PageQuery{})
+	if err != nil {
+		t.Fatalf("GetNotifications failed: %v", err)
+	}
+	var likes *domain.Notification
+	for _, notification := range capped {
+		if notification.TweetID == "tweet5" {
+			likes = notification
+		}
+	}
+	if likes == nil || len(likes.ActorIDs) != domain.MaxNotificationActors ||
+		likes.Actors() != domain.MaxNotificationActors+extra ||
+		likes.ActorIDs[0] != oldestKept || likes.ActorIDs[1] != latest {
+		t.Errorf("Expected %d actors, counting %d, most recent first, got %+v",
+			domain.MaxNotificationActors, domain.MaxNotificationActors+extra, likes)
+	}
+}
+
+func assertTweetIDs(t *testing.T, tweets []*domain.Tweet, ids ...string) {
+	t.Helper()
+	if len(tweets) != len(ids) {
+		t.Fatalf("Expected %d tweets, got %d", len(ids), len(tweets))
+	}
+	for i, id := range ids {
+		if tweets[i].ID != id {
+			t.Errorf("Tweet %d: expected %s, got %s (%s)", i, id, tweets[i].ID, tweets[i].Content)
+		}
+	}
+}
+
+func testOutboxContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Events get deterministic times, inserted out of order
+	followed := domain.NewUserFollowed("user2", "user1")
+	followed.Time = base.Add(2 * time.Minute)
+	tweet := newTweetAt(t, "user1", "hello", base)
+	created := domain.NewTweetCreated(tweet, nil)
+	created.Time = base
+	like, _ := domain.NewLike("user2", tweet.ID)
+	liked := domain.NewTweetLiked(like, "user1")
+	liked.Time = base.Add(time.Minute)
+
+	if err := repo.FollowIfNotExists(ctx, "user2", "user1", followed); err != nil {
+		t.Fatalf("FollowIfNotExists failed: %v", err)
+	}
+	if err := repo.Create(ctx, tweet, created); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := repo.LikeIfNotExists(ctx, like, liked); err != nil {
+		t.Fatalf("LikeIfNotExists failed: %v", err)
+	}
+
+	// Writes that change nothing store no events
+	if err := repo.FollowIfNotExists(ctx, "user2", "user1", domain.NewUserFollowed("user2", "user1")); err != domain.ErrAlreadyFollowing {
+		t.Errorf("Expected ErrAlreadyFollowing, got %v", err)
+	}
+	if err := repo.UnfollowIfExists(ctx, "user3", "user1", domain.NewUserUnfollowed("user3", "user1")); err != domain.ErrNotFollowing {
+		t.Errorf("Expected ErrNotFollowing, got %v", err)
+	}
+	if err := repo.LikeIfNotExists(ctx, like, domain.NewTweetLiked(like, "user1")); err != domain.ErrAlreadyLiked {
+		t.Errorf("Expected ErrAlreadyLiked, got %v", err)
+	}
+	if err := repo.UnlikeIfExists(ctx, "user3", tweet.ID, domain.NewTweetUnliked("user3", tweet.ID)); err != domain.ErrNotLiked {
+		t.Errorf("Expected ErrNotLiked, got %v", err)
+	}
+	if err := repo.Delete(ctx, "missing", domain.NewTweetDeleted(tweet)); err != domain.ErrTweetNotFound {
+		t.Errorf("Expected ErrTweetNotFound, got %v", err)
+	}
+
+	// Oldest first, up to the limit
+	entries, err := repo.PendingEvents(ctx, 2)
+	if err != nil {
+		t.Fatalf("PendingEvents failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != created.ID || entries[1].ID != liked.ID {
+		t.Fatalf("Expected the creation and the like, got %+v", entries)
+	}
+	event, err := entries[1].Event()
+	if err != nil {
+		t.Fatalf("Failed to decode entry: %v", err)
+	}
+	if decoded, ok := event.(domain.TweetLiked); !ok || decoded.Like.TweetID != tweet.ID || decoded.AuthorID != "user1" {
+		t.Errorf("Expected the like to be decoded, got %+v", event)
+	}
+
+	if err := repo.MarkRelayed(ctx, []string{created.ID, liked.ID}); err != nil {
+		t.Fatalf("MarkRelayed failed: %v", err)
+	}
+	entries, err = repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingEvents failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != followed.ID || entries[0].Type != domain.EventUserFollowed {
+		t.Errorf("Expected only the follow to be pending, got %+v", entries)
+	}
+
+	// A retried entry is left out until it is due
+	if err := repo.ScheduleRetry(ctx, followed.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ScheduleRetry failed: %v", err)
+	}
+	if entries, _ := repo.PendingEvents(ctx, 10); len(entries) != 0 {
+		t.Errorf("Expected no due entries, got %+v", entries)
+	}
+	if err := repo.ScheduleRetry(ctx, followed.ID, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("ScheduleRetry failed: %v", err)
+	}
+	entries, err = repo.PendingEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("PendingEvents failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != followed.ID || entries[0].Attempts != 2 {
+		t.Errorf("Expected the follow after 2 attempts, got %+v", entries)
+	}
+	if err := repo.ScheduleRetry(ctx, "missing", time.Now()); err != nil {
+		t.Errorf("Expected retrying a relayed entry to be ignored, got %v", err)
+	}
+}
+
+func TestSQLMigrationsAreIdempotent(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	repo, err := sqlAdapters.Open(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open sql storage: %v", err)
+	}
+	tweet, _ := domain.NewTweet("user1", "survives reopening")
+	repo.Create(ctx, tweet)
+	repo.Close()
+
+	// Reopening applies no migration twice and keeps the data
+	repo, err = sqlAdapters.Open(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to reopen sql storage: %v", err)
+	}
+	defer repo.Close()
+
+	if _, err := repo.GetByID(ctx, tweet.ID); err != nil {
+		t.Errorf("Expected tweet to persist: %v", err)
+	}
+}
+
+func TestSQLMigrationsRunOnceConcurrently(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	// Replicas starting at the same time apply each migration once
+	const replicas = 4
+	errs := make(chan error, replicas)
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo, err := sqlAdapters.Open(ctx, "sqlite", dsn)
+			if err == nil {
+				repo.Close()
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Failed to open sql storage: %v", err)
+		}
+	}
+
+	repo, err := sqlAdapters.Open(ctx, "sqlite", dsn)
+	if err != nil {
+		t.Fatalf("Failed to reopen sql storage: %v", err)
+	}
+	defer repo.Close()
+	tweet, _ := domain.NewTweet("user1", "after concurrent migrations")
+	if err := repo.Create(ctx, tweet); err != nil {
+		t.Errorf("Expected a usable schema, got %v", err)
+	}
+}
+
+func testRevocationContract(t *testing.T, repo storage) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	if revoked, err := repo.IsRevoked(ctx, "token1"); err != nil || revoked {
+		t.Errorf("Expected token1 not revoked, got %v (err: %v)", revoked, err)
+	}
+	if err := repo.Revoke(ctx, "token1", expiresAt); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := repo.Revoke(ctx, "token1", expiresAt); err != nil {
+		t.Errorf("Expected revoking twice to succeed, got %v", err)
+	}
+	if revoked, err := repo.IsRevoked(ctx, "token1"); err != nil || !revoked {
+		t.Errorf("Expected token1 revoked, got %v (err: %v)", revoked, err)
+	}
+	if consumed, err := repo.Consume(ctx, "token1", expiresAt); err != nil || consumed {
+		t.Errorf("Expected a revoked token not to be consumed, got %v (err: %v)", consumed, err)
+	}
+
+	// Of concurrent consumers of a token, only one succeeds
+	var wg sync.WaitGroup
+	var consumed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := repo.Consume(ctx, "token2", expiresAt)
+			if err != nil {
+				t.Errorf("Consume failed: %v", err)
+			}
+			if ok {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if consumed.Load() != 1 {
+		t.Errorf("Expected token2 consumed once, got %d", consumed.Load())
+	}
+	if revoked, _ := repo.IsRevoked(ctx, "token2"); !revoked {
+		t.Error("Expected a consumed token to be revoked")
+	}
+
+	// User revocations only move forward
+	if before, err := repo.RevokedBefore(ctx, "user1"); err != nil || !before.IsZero() {
+		t.Errorf("Expected no user revocation, got %v (err: %v)", before, err)
+	}
+	later := time.Now().Truncate(time.Second)
+	if err := repo.RevokeUser(ctx, "user1", later); err != nil {
+		t.Fatalf("RevokeUser failed: %v", err)
+	}
+	if err := repo.RevokeUser(ctx, "user1", later.Add(-time.Hour)); err != nil {
+		t.Fatalf("RevokeUser failed: %v", err)
+	}
+	if before, err := repo.RevokedBefore(ctx, "user1"); err != nil || !before.Equal(later) {
+		t.Errorf("Expected tokens revoked before %v, got %v (err: %v)", later, before, err)
+	}
+}
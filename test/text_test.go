@@ -0,0 +1,79 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"twitter-clone-backend/internal/domain"
+)
+
+func TestWeightedLength(t *testing.T) {
+	cases := []struct {
+		name   string
+		text   string
+		length int
+	}{
+		{"ascii", "hello", 5},
+		{"accents", "canción ñandú", 13},
+		{"decomposed accent", "cancio\u0301n", 7},
+		{"cjk", "你好世界", 8},
+		{"hangul", "안녕", 4},
+		{"emoji", "😀", 2},
+		{"family emoji", "👨‍👩‍👧‍👦", 2},
+		{"skin tone", "👍🏽", 2},
+		{"flag", "🇦🇷", 2},
+		{"emoji presentation", "❤️", 2},
+		{"text heart", "❤", 2},
+		{"curly quotes", "“hi”", 4},
+		{"url", "https://example.com/a/very/long/path/that/goes/on/and/on", domain.TweetURLLength},
+		{"www url", "see www.example.com.", 4 + domain.TweetURLLength + 1},
+		{"text and url", "look: http://a.co and more", 6 + domain.TweetURLLength + 9},
+		{"newline", "a\nb", 3},
+	}
+
+	for _, c := range cases {
+		if length := domain.WeightedLength(c.text); length != c.length {
+			t.Errorf("%s: expected length %d of %q, got %d", c.name, c.length, c.text, length)
+		}
+	}
+}
+
+func TestNewTweetUsesWeightedLength(t *testing.T) {
+	valid := []string{
+		strings.Repeat("a", domain.MaxTweetLength),
+		strings.Repeat("é", domain.MaxTweetLength),
+		strings.Repeat("😀", domain.MaxTweetLength/2),
+		strings.Repeat("字", domain.MaxTweetLength/2),
+		strings.Repeat("https://example.com/"+strings.Repeat("x", 100)+" ", 11),
+	}
+	for _, content := range valid {
+		if _, err := domain.NewTweet("user1", content); err != nil {
+			t.Errorf("Expected %d bytes of %q to fit, got %v", len(content), []rune(content)[0], err)
+		}
+	}
+
+	tooLong := []string{
+		strings.Repeat("a", domain.MaxTweetLength+1),
+		strings.Repeat("😀", domain.MaxTweetLength/2+1),
+		strings.Repeat("字", domain.MaxTweetLength/2) + "a",
+	}
+	for _, content := range tooLong {
+		if _, err := domain.NewTweet("user1", content); err != domain.ErrContentTooLong {
+			t.Errorf("Expected ErrContentTooLong for %q, got %v", []rune(content)[0], err)
+		}
+	}
+
+	for _, content := range []string{"bell\a", "nul\x00", "escape\x1b[31m", "c1\u0085", "bad \xff utf8"} {
+		if _, err := domain.NewTweet("user1", content); err != domain.ErrInvalidContent {
+			t.Errorf("Expected ErrInvalidContent for %q, got %v", content, err)
+		}
+	}
+
+	// Content is stored in NFC, with CRLF turned into LF
+	tweet, err := domain.NewTweet("user1", "canción\r\nnueva\tlínea")
+	if err != nil {
+		t.Fatalf("Failed to create tweet: %v", err)
+	}
+	if tweet.Content != "canción\nnueva\tlínea" {
+		t.Errorf("Expected normalized content, got %q", tweet.Content)
+	}
+}
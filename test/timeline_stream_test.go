@@ -0,0 +1,419 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/events"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+// streamServer serves the API with timeline streams, relaying the outbox in
+// the background as the server does
+type streamServer struct {
+	*httptest.Server
+	repo    *memory.Repositories
+	streams *usecases.TimelineStreams
+	tweets  *usecases.TweetUseCase
+	follows *usecases.FollowUseCase
+}
+
+func newStreamServer(t *testing.T, cfg usecases.TimelineStreamsConfig) *streamServer {
+	t.Helper()
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	bus := events.NewBus(appLogger, events.BusConfig{})
+	streams := usecases.NewTimelineStreams(repo, appLogger, cfg)
+	bus.Subscribe("timeline-streams", streams.HandleEvent, domain.EventTweetCreated)
+	relay := usecases.NewOutboxRelay(repo, bus, appLogger, usecases.OutboxRelayConfig{PollInterval: time.Millisecond})
+	relay.Start()
+
+	s := &streamServer{
+		repo:    repo,
+		streams: streams,
+		tweets:  usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger),
+		follows: usecases.NewFollowUseCase(repo, repo, appLogger),
+	}
+	handlers := httpAdapters.NewHandlers(s.tweets, s.follows, usecases.NewUserUseCase(repo, appLogger), appLogger,
+		httpAdapters.WithTimelineStreams(streams))
+	s.Server = httptest.NewServer(httpAdapters.SetupRoutes(handlers))
+	t.Cleanup(func() {
+		streams.Close()
+		s.Server.Close()
+		relay.Stop(context.Background())
+		bus.Close(context.Background())
+	})
+	return s
+}
+
+// sseMessage is a message of an event stream: an event, or a comment
+type sseMessage struct {
+	id, event, data, comment, retry string
+}
+
+// sseClient reads the messages of an event stream in the background
+type sseClient struct {
+	resp     *http.Response
+	messages chan sseMessage
+}
+
+// openStream connects to the timeline stream of a user, and waits until
+// the server subscribed it
+func openStream(t *testing.T, server *streamServer, userID, lastEventID string) *sseClient {
+	t.Helper()
+	req, _ := http.NewRequest("GET", server.URL+"/users/"+userID+"/timeline/stream", nil)
+	req.Header.Set("X-User-ID", userID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	client := &sseClient{resp: resp, messages: make(chan sseMessage, 100)}
+	t.Cleanup(func() { resp.Body.Close() })
+	go func() {
+		defer close(client.messages)
+		scanner := bufio.NewScanner(resp.Body)
+		var message sseMessage
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				client.messages <- message
+				message = sseMessage{}
+			case strings.HasPrefix(line, ":"):
+				message.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				message.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				message.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				message.data = line[len("data: "):]
+			case strings.HasPrefix(line, "retry: "):
+				message.retry = line[len("retry: "):]
+			}
+		}
+	}()
+
+	if first := client.next(t); first.retry == "" {
+		t.Fatalf("Expected the stream to start with a retry hint, got %+v", first)
+	}
+	return client
+}
+
+// next returns the next message of the stream
+func (c *sseClient) next(t *testing.T) sseMessage {
+	t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			t.Fatal("Stream ended")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+	return sseMessage{}
+}
+
+// nextTweet returns the next tweet of the stream, skipping heartbeats
+func (c *sseClient) nextTweet(t *testing.T) (string, httpAdapters.TweetResponse) {
+	t.Helper()
+	for {
+		message := c.next(t)
+		if message.event != "tweet" {
+			continue
+		}
+		var tweet httpAdapters.TweetResponse
+		if err := json.Unmarshal([]byte(message.data), &tweet); err != nil {
+			t.Fatalf("Invalid tweet event %q: %v", message.data, err)
+		}
+		return message.id, tweet
+	}
+}
+
+// assertEnded checks that the server closed the stream
+func (c *sseClient) assertEnded(t *testing.T) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-c.messages:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected the stream to end")
+		}
+	}
+}
+
+func TestTimelineStreamPushesNewTweets(t *testing.T) {
+	server := newStreamServer(t, usecases.TimelineStreamsConfig{})
+	ctx := context.Background()
+	server.follows.FollowUser(ctx, "user1", "user2")
+
+	client := openStream(t, server, "user1", "")
+
+	// Only the tweets of the timeline are pushed, in order
+	server.tweets.CreateTweet(ctx, "user3", "not followed")
+	followed, _ := server.tweets.CreateTweet(ctx, "user2", "from a followed account")
+	own, _ := server.tweets.CreateTweet(ctx, "user1", "my own tweet")
+
+	for _, want := range []*domain.Tweet{followed, own} {
+		id, tweet := client.nextTweet(t)
+		if tweet.ID != want.ID || tweet.Content != want.Content || tweet.UserID != want.UserID {
+			t.Fatalf("Expected tweet %q, got %+v", want.Content, tweet)
+		}
+		if id != domain.CursorOf(want).Encode() {
+			t.Errorf("Expected the cursor of the tweet as event ID, got %q", id)
+		}
+	}
+
+	// Shutting down ends the open streams
+	server.streams.Close()
+	client.assertEnded(t)
+	if count := server.streams.Count(); count != 0 {
+		t.Errorf("Expected no open streams, got %d", count)
+	}
+}
+
+func TestTimelineStreamResumesFromLastEventID(t *testing.T) {
+	server := newStreamServer(t, usecases.TimelineStreamsConfig{})
+	ctx := context.Background()
+	server.follows.FollowUser(ctx, "user1", "user2")
+
+	var missed []*domain.Tweet
+	for _, content := range []string{"seen", "missed 1", "missed 2"} {
+		tweet, _ := server.tweets.CreateTweet(ctx, "user2", content)
+		missed = append(missed, tweet)
+	}
+	server.tweets.CreateTweet(ctx, "user3", "not followed")
+
+	// The tweets after the last event are sent first, oldest first
+	client := openStream(t, server, "user1", domain.CursorOf(missed[0]).Encode())
+	for _, want := range missed[1:] {
+		if _, tweet := client.nextTweet(t); tweet.ID != want.ID {
+			t.Fatalf("Expected missed tweet %q, got %q", want.Content, tweet.Content)
+		}
+	}
+
+	live, _ := server.tweets.CreateTweet(ctx, "user2", "live")
+	lastID, tweet := client.nextTweet(t)
+	if tweet.ID != live.ID {
+		t.Fatalf("Expected the live tweet, got %q", tweet.Content)
+	}
+
+	// Reconnecting with the last event ID sends nothing again
+	client.resp.Body.Close()
+	resumed := openStream(t, server, "user1", lastID)
+	next, _ := server.tweets.CreateTweet(ctx, "user1", "after resuming")
+	if _, tweet := resumed.nextTweet(t); tweet.ID != next.ID {
+		t.Errorf("Expected only the new tweet after resuming, got %q", tweet.Content)
+	}
+}
+
+func TestTimelineStreamReplaysPagesShortenedByRetweets(t *testing.T) {
+	server := newStreamServer(t, usecases.TimelineStreamsConfig{})
+	ctx := context.Background()
+	server.follows.FollowUser(ctx, "user1", "user2")
+
+	// Two retweets of the same tweet take one place in the first page, so
+	// it is short although more tweets follow
+	seen, _ := server.tweets.CreateTweet(ctx, "user2", "seen")
+	original, _ := server.tweets.CreateTweet(ctx, "user3", "retweeted twice")
+	server.tweets.Retweet(ctx, "user2", original.ID)
+	retweet, _ := server.tweets.Retweet(ctx, "user1", original.ID)
+	want := []string{retweet.ID}
+	for i := 0; i < domain.MaxTimelineLimit+10; i++ {
+		tweet, _ := server.tweets.CreateTweet(ctx, "user2", fmt.Sprintf("missed %d", i))
+		want = append(want, tweet.ID)
+	}
+
+	// The missed tweets are relayed before connecting, so they are only
+	// replayed
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if entries, _ := server.repo.PendingEvents(ctx, 1); len(entries) == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	client := openStream(t, server, "user1", domain.CursorOf(seen).Encode())
+	for i, wantID := range want {
+		if _, tweet := client.nextTweet(t); tweet.ID != wantID {
+			t.Fatalf("Expected missed tweet %d to be %s, got %s (%q)", i, wantID, tweet.ID, tweet.Content)
+		}
+	}
+
+	live, _ := server.tweets.CreateTweet(ctx, "user2", "live")
+	if _, tweet := client.nextTweet(t); tweet.ID != live.ID {
+		t.Errorf("Expected the live tweet after the replay, got %q", tweet.Content)
+	}
+}
+
+func TestTimelineStreamSendsHeartbeats(t *testing.T) {
+	server := newStreamServer(t, usecases.TimelineStreamsConfig{Heartbeat: 5 * time.Millisecond})
+	client := openStream(t, server, "user1", "")
+
+	for i := 0; i < 2; i++ {
+		if message := client.next(t); message.comment != "heartbeat" || message.event != "" {
+			t.Errorf("Expected a heartbeat comment, got %+v", message)
+		}
+	}
+}
+
+func TestTimelineStreamDropsSlowConsumers(t *testing.T) {
+	repo := memory.NewRepositories()
+	streams := usecases.NewTimelineStreams(repo, logger.NewLogger(), usecases.TimelineStreamsConfig{BufferSize: 2})
+	ctx := context.Background()
+	repo.Follow(ctx, "user1", "user2")
+
+	slow := streams.Subscribe("user1")
+	fast := streams.Subscribe("user1")
+	received := make(chan *domain.Tweet, 10)
+	go func() {
+		for tweet := range fast.Tweets() {
+			received <- tweet
+		}
+	}()
+
+	// Pushing never waits for a consumer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			tweet, _ := domain.NewTweet("user2", "tweet")
+			streams.HandleEvent(ctx, domain.NewTweetCreated(tweet, nil))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pushing tweets blocked on a slow consumer")
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("Expected the slow consumer to be dropped")
+	}
+	if len(slow.Tweets()) != 2 {
+		t.Errorf("Expected the slow consumer to keep its buffered tweets, got %d", len(slow.Tweets()))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the fast consumer to get every tweet, got %d", i)
+		}
+	}
+	select {
+	case <-fast.Done():
+		t.Error("Expected the fast consumer to stay connected")
+	default:
+	}
+	if count := streams.Count(); count != 1 {
+		t.Errorf("Expected only the fast consumer subscribed, got %d", count)
+	}
+}
+
+// lookupCountingFollowRepository counts the follow lookups and rejects
+// reading whole follower lists
+type lookupCountingFollowRepository struct {
+	*memory.Repositories
+	lookups atomic.Int32
+}
+
+func (r *lookupCountingFollowRepository) GetFollowers(ctx context.Context, userID string) ([]string, error) {
+	return nil, errors.New("unexpected follower list read")
+}
+
+func (r *lookupCountingFollowRepository) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	r.lookups.Add(1)
+	return r.Repositories.IsFollowing(ctx, followerID, followeeID)
+}
+
+func TestTimelineStreamLooksUpOnlyConnectedUsers(t *testing.T) {
+	repo := memory.NewRepositories()
+	followRepo := &lookupCountingFollowRepository{Repositories: repo}
+	streams := usecases.NewTimelineStreams(followRepo, logger.NewLogger(), usecases.TimelineStreamsConfig{})
+	ctx := context.Background()
+	repo.Follow(ctx, "user2", "user1")
+	repo.Follow(ctx, "user3", "user1")
+
+	// No lookup without connected users
+	tweet, _ := domain.NewTweet("user1", "nobody is listening")
+	if err := streams.HandleEvent(ctx, domain.NewTweetCreated(tweet, nil)); err != nil {
+		t.Fatalf("Error handling event: %v", err)
+	}
+
+	author := streams.Subscribe("user1")
+	follower := streams.Subscribe("user2")
+	other := streams.Subscribe("user2")
+	stranger := streams.Subscribe("user4")
+	tweet, _ = domain.NewTweet("user1", "hello followers")
+	if err := streams.HandleEvent(ctx, domain.NewTweetCreated(tweet, nil)); err != nil {
+		t.Fatalf("Error handling event: %v", err)
+	}
+
+	for _, stream := range []*usecases.TimelineStream{author, follower, other} {
+		if len(stream.Tweets()) != 1 {
+			t.Errorf("Expected the tweet in the stream of %s", stream.UserID)
+		}
+	}
+	if len(stranger.Tweets()) != 0 {
+		t.Error("Expected no tweet for a user not following the author")
+	}
+	// One lookup per connected user other than the author
+	if lookups := followRepo.lookups.Load(); lookups != 2 {
+		t.Errorf("Expected 2 follow lookups, got %d", lookups)
+	}
+}
+
+func TestTimelineStreamErrors(t *testing.T) {
+	server := newStreamServer(t, usecases.TimelineStreamsConfig{})
+
+	for _, c := range []struct {
+		name, userID, lastEventID string
+		status                    int
+		code                      string
+	}{
+		{"unknown user", "nobody", "", http.StatusNotFound, "user_not_found"},
+		{"bad last event ID", "user1", "garbage", http.StatusBadRequest, "invalid_cursor"},
+	} {
+		req, _ := http.NewRequest("GET", server.URL+"/users/"+c.userID+"/timeline/stream", nil)
+		req.Header.Set("Last-Event-ID", c.lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		var problem httpAdapters.ProblemResponse
+		if status := decodeResponse(resp, &problem); status != c.status || problem.Code != c.code {
+			t.Errorf("%s: expected %d %s, got %d %+v", c.name, c.status, c.code, status, problem)
+		}
+	}
+	if count := server.streams.Count(); count != 0 {
+		t.Errorf("Expected no open streams, got %d", count)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+func TestRegisterUserValidation(t *testing.T) {
+	userUseCase := usecases.NewUserUseCase(memory.NewRepositories(), logger.NewLogger())
+	ctx := context.Background()
+
+	invalid := map[string]domain.Profile{
+		"":                 {},
+		"with space":       {},
+		"tooLongUsername1": {},
+		"ñandú":            {},
+	}
+	for username, profile := range invalid {
+		if _, err := userUseCase.RegisterUser(ctx, username, "", profile); err != domain.ErrInvalidUsername {
+			t.Errorf("%q: expected ErrInvalidUsername, got %v", username, err)
+		}
+	}
+
+	if _, err := userUseCase.RegisterUser(ctx, "frank", "", domain.Profile{Bio: strings.Repeat("a", domain.MaxBioLength+1)}); err != domain.ErrProfileTooLong {
+		t.Errorf("Expected ErrProfileTooLong, got %v", err)
+	}
+	if _, err := userUseCase.RegisterUser(ctx, "frank", "", domain.Profile{AvatarURL: "javascript:alert(1)"}); err != domain.ErrInvalidAvatarURL {
+		t.Errorf("Expected ErrInvalidAvatarURL, got %v", err)
+	}
+
+	// Limits count characters, not bytes
+	user, err := userUseCase.RegisterUser(ctx, "Frank_1", "", domain.Profile{DisplayName: strings.Repeat("é", domain.MaxDisplayNameLength)})
+	if err != nil {
+		t.Fatalf("Error registering user: %v", err)
+	}
+	if user.ID == "" || user.Username != "Frank_1" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+
+	if _, err := userUseCase.RegisterUser(ctx, "frank_1", "", domain.Profile{}); err != domain.ErrUsernameTaken {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if found, err := userUseCase.GetUserByUsername(ctx, "FRANK_1"); err != nil || found.ID != user.ID {
+		t.Errorf("Expected lookup ignoring case, got %+v (err: %v)", found, err)
+	}
+}
+
+func TestUpdateProfileIsPartial(t *testing.T) {
+	userUseCase := usecases.NewUserUseCase(memory.NewRepositories(), logger.NewLogger())
+	ctx := context.Background()
+
+	user, _ := userUseCase.RegisterUser(ctx, "grace", "", domain.Profile{DisplayName: "Grace", Location: "NYC"})
+
+	bio := "Compilers"
+	updated, err := userUseCase.UpdateProfile(ctx, user.ID, domain.ProfileUpdate{Bio: &bio})
+	if err != nil {
+		t.Fatalf("Error updating profile: %v", err)
+	}
+	if updated.Bio != bio || updated.DisplayName != "Grace" || updated.Location != "NYC" {
+		t.Errorf("Unexpected profile: %+v", updated)
+	}
+
+	// Invalid edits leave the stored profile untouched
+	tooLong := strings.Repeat("x", domain.MaxLocationLength+1)
+	if _, err := userUseCase.UpdateProfile(ctx, user.ID, domain.ProfileUpdate{Location: &tooLong}); err != domain.ErrProfileTooLong {
+		t.Errorf("Expected ErrProfileTooLong, got %v", err)
+	}
+	if stored, _ := userUseCase.GetUser(ctx, user.ID); stored.Location != "NYC" || stored.Bio != bio {
+		t.Errorf("Unexpected stored profile: %+v", stored)
+	}
+
+	if _, err := userUseCase.UpdateProfile(ctx, "missing", domain.ProfileUpdate{Bio: &bio}); err != domain.ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,633 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"twitter-clone-backend/internal/adapters/events"
+	httpAdapters "twitter-clone-backend/internal/adapters/http"
+	"twitter-clone-backend/internal/adapters/memory"
+	"twitter-clone-backend/internal/adapters/webhooks"
+	"twitter-clone-backend/internal/domain"
+	"twitter-clone-backend/internal/usecases"
+	"twitter-clone-backend/pkg/logger"
+)
+
+const webhookSecret = "0123456789abcdef"
+
+// webhookRequest is a request received by a webhookReceiver
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver is an httptest endpoint recording the webhook requests it
+// gets and answering them with the statuses of respond, in order (the last
+// one is repeated)
+type webhookReceiver struct {
+	*httptest.Server
+	respond  []int
+	requests []webhookRequest
+	mu       sync.Mutex
+}
+
+func newWebhookReceiver(t *testing.T, respond ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{respond: respond}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, webhookRequest{header: r.Header.Clone(), body: body})
+		status := receiver.respond[len(receiver.respond)-1]
+		if len(receiver.requests) <= len(receiver.respond) {
+			status = receiver.respond[len(receiver.requests)-1]
+		}
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() []webhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookRequest(nil), r.requests...)
+}
+
+// newTestWebhooks creates a webhook use case with fast retries that
+// delivers to private addresses
+func newTestWebhooks(repo *memory.Repositories, cfg usecases.WebhookConfig) *usecases.WebhookUseCase {
+	cfg.RetryBackoff = time.Millisecond
+	cfg.MaxBackoff = 4 * time.Millisecond
+	// The receivers listen on localhost
+	cfg.AllowPrivateTargets = true
+	return usecases.NewWebhookUseCase(repo, webhooks.NewHTTPSender(time.Second, true), logger.NewLogger(), cfg)
+}
+
+// closeWebhooks waits for every queued delivery to be made
+func closeWebhooks(t *testing.T, webhookUseCase *usecases.WebhookUseCase) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := webhookUseCase.Close(ctx); err != nil {
+		t.Fatalf("Webhooks not delivered: %v", err)
+	}
+}
+
+func TestWebhooksDeliverSignedAccountEvents(t *testing.T) {
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	bus := newTestBus(t, events.BusConfig{})
+	relay := usecases.NewOutboxRelay(repo, bus, appLogger, usecases.OutboxRelayConfig{})
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{})
+	bus.Subscribe("webhooks", webhookUseCase.HandleEvent)
+	tweetUseCase := usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, appLogger)
+	likeUseCase := usecases.NewLikeUseCase(repo, repo, repo, appLogger)
+	ctx := context.Background()
+
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	webhook, err := webhookUseCase.CreateWebhook(ctx, "user1", receiver.URL,
+		[]string{domain.EventUserFollowed, domain.EventTweetCreated}, webhookSecret)
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	// Only the subscribed events about user1 are delivered
+	tweet, _ := tweetUseCase.CreateTweet(ctx, "user1", "hello partners")
+	followUseCase.FollowUser(ctx, "user2", "user1")
+	followUseCase.FollowUser(ctx, "user1", "user3")
+	tweetUseCase.CreateTweet(ctx, "user2", "not about user1")
+	likeUseCase.LikeTweet(ctx, "user2", tweet.ID)
+	followUseCase.UnfollowUser(ctx, "user2", "user1")
+	if _, err := relay.RelayPending(ctx); err != nil {
+		t.Fatalf("RelayPending failed: %v", err)
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	requests := receiver.received()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", len(requests))
+	}
+
+	for i, want := range []string{domain.EventTweetCreated, domain.EventUserFollowed} {
+		request := requests[i]
+		unix, _ := strconv.ParseInt(request.header.Get(webhooks.HeaderTimestamp), 10, 64)
+		signature := request.header.Get(webhooks.HeaderSignature)
+		if !domain.VerifyWebhook(webhookSecret, time.Unix(unix, 0), request.body, signature) {
+			t.Errorf("Delivery %d: invalid signature %q", i, signature)
+		}
+		if domain.VerifyWebhook("another secret!!", time.Unix(unix, 0), request.body, signature) {
+			t.Errorf("Delivery %d: signature verified with the wrong secret", i)
+		}
+		if request.header.Get(webhooks.HeaderEvent) != want || request.header.Get("Content-Type") != "application/json" {
+			t.Errorf("Delivery %d: unexpected headers %v", i, request.header)
+		}
+
+		var body struct {
+			ID   string          `json:"id"`
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(request.body, &body); err != nil || body.Type != want ||
+			body.ID == "" || body.ID != request.header.Get(webhooks.HeaderWebhookID) {
+			t.Errorf("Delivery %d: unexpected body %s (err: %v)", i, request.body, err)
+		}
+	}
+	if !strings.Contains(string(requests[0].body), tweet.ID) || !strings.Contains(string(requests[1].body), `"follower_id":"user2"`) {
+		t.Errorf("Expected the events in the bodies, got %s and %s", requests[0].body, requests[1].body)
+	}
+
+	deliveries, _ := repo.ListWebhookDeliveries(ctx, webhook.ID, 0)
+	if len(deliveries) != 2 || !deliveries[0].Succeeded() || deliveries[0].StatusCode != http.StatusNoContent ||
+		deliveries[0].EventType != domain.EventUserFollowed {
+		t.Errorf("Expected 2 successful deliveries in the log, got %+v", deliveries)
+	}
+}
+
+func TestWebhooksRetryWithBackoff(t *testing.T) {
+	repo := memory.NewRepositories()
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{MaxAttempts: 5})
+	ctx := context.Background()
+
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	webhook, _ := webhookUseCase.CreateWebhook(ctx, "user1", receiver.URL, []string{domain.EventUserFollowed}, webhookSecret)
+	repo.UpdateWebhook(ctx, webhook.RecordFailure(10, time.Now()))
+
+	event := domain.NewUserFollowed("user2", "user1")
+	if err := webhookUseCase.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("HandleEvent failed: %v", err)
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	// Each attempt is logged, newest first, and sends the same event
+	deliveries, _ := webhookUseCase.GetDeliveries(ctx, "user1", webhook.ID, 0)
+	if len(deliveries) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(deliveries))
+	}
+	for i, want := range []struct {
+		attempt, status int
+		succeeded       bool
+	}{{3, http.StatusOK, true}, {2, http.StatusBadGateway, false}, {1, http.StatusInternalServerError, false}} {
+		delivery := deliveries[i]
+		if delivery.Attempt != want.attempt || delivery.StatusCode != want.status ||
+			delivery.Succeeded() != want.succeeded || delivery.EventID != event.ID {
+			t.Errorf("Unexpected attempt %d: %+v", want.attempt, delivery)
+		}
+	}
+	for _, request := range receiver.received() {
+		if request.header.Get(webhooks.HeaderWebhookID) != event.ID {
+			t.Errorf("Expected every attempt to send event %s, got %s", event.ID, request.header.Get(webhooks.HeaderWebhookID))
+		}
+	}
+
+	// A successful delivery resets the failures
+	if webhook, _ := repo.GetWebhook(ctx, webhook.ID); webhook.ConsecutiveFailures != 0 || !webhook.IsEnabled() {
+		t.Errorf("Expected the failures reset, got %+v", webhook)
+	}
+}
+
+func TestWebhookRetriesDoNotHoldBackOtherWebhooks(t *testing.T) {
+	repo := memory.NewRepositories()
+	// One worker for every webhook, and a retry slower than a delivery
+	webhookUseCase := usecases.NewWebhookUseCase(repo, webhooks.NewHTTPSender(time.Second, true), logger.NewLogger(), usecases.WebhookConfig{
+		Workers:             1,
+		MaxAttempts:         2,
+		RetryBackoff:        300 * time.Millisecond,
+		AllowPrivateTargets: true,
+	})
+	ctx := context.Background()
+
+	failing := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusOK)
+	healthy := newWebhookReceiver(t, http.StatusOK)
+	webhookUseCase.CreateWebhook(ctx, "user1", failing.URL, []string{domain.EventUserFollowed}, webhookSecret)
+	webhookUseCase.CreateWebhook(ctx, "user1", healthy.URL, []string{domain.EventUserFollowed}, webhookSecret)
+
+	first := domain.NewUserFollowed("user2", "user1")
+	second := domain.NewUserFollowed("user3", "user1")
+	for _, event := range []domain.Event{first, second} {
+		if err := webhookUseCase.HandleEvent(ctx, event); err != nil {
+			t.Fatalf("HandleEvent failed: %v", err)
+		}
+	}
+
+	// The healthy webhook gets both events while the other one waits for
+	// its retry
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for len(healthy.received()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if received := len(healthy.received()); received != 2 {
+		t.Fatalf("Expected the healthy webhook to get 2 events during the retry backoff, got %d", received)
+	}
+	if received := len(failing.received()); received != 1 {
+		t.Fatalf("Expected only the first attempt of the failing webhook, got %d requests", received)
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	// The events held back behind the retry keep their order
+	var ids []string
+	for _, request := range failing.received() {
+		ids = append(ids, request.header.Get(webhooks.HeaderWebhookID))
+	}
+	if strings.Join(ids, ",") != strings.Join([]string{first.ID, first.ID, second.ID}, ",") {
+		t.Errorf("Expected the first event retried before the second one, got %v", ids)
+	}
+}
+
+func TestWebhooksSkipRedeliveriesOfQueuedEvents(t *testing.T) {
+	repo := memory.NewRepositories()
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{Workers: 1, QueueSize: 1})
+	ctx := context.Background()
+
+	// The receiver holds the first delivery until released
+	release := make(chan struct{})
+	inFlight := make(chan struct{}, 1)
+	var received []string
+	var mu sync.Mutex
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get(webhooks.HeaderWebhookID))
+		first := len(received) == 1
+		mu.Unlock()
+		if first {
+			inFlight <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer blocking.Close()
+	releaseHeld := sync.OnceFunc(func() { close(release) })
+	defer releaseHeld()
+	webhookUseCase.CreateWebhook(ctx, "user1", blocking.URL, []string{domain.EventUserFollowed}, webhookSecret)
+
+	held := domain.NewUserFollowed("user4", "user1")
+	webhookUseCase.HandleEvent(ctx, held)
+	<-inFlight
+
+	// With a delivery in flight the queue fits one of the two webhooks
+	other := newWebhookReceiver(t, http.StatusOK)
+	webhookUseCase.CreateWebhook(ctx, "user1", other.URL, []string{domain.EventUserFollowed}, webhookSecret)
+	event := domain.NewUserFollowed("user2", "user1")
+	if err := webhookUseCase.HandleEvent(ctx, event); err != usecases.ErrWebhookQueueFull {
+		t.Fatalf("Expected ErrWebhookQueueFull, got %v", err)
+	}
+
+	// Handling the event again only queues it for the webhook left out
+	releaseHeld()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := webhookUseCase.HandleEvent(ctx, event)
+		if err == nil {
+			break
+		}
+		if err != usecases.ErrWebhookQueueFull || time.Now().After(deadline) {
+			t.Fatalf("Expected the event to be queued again, got %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := webhookUseCase.HandleEvent(ctx, event); err != nil {
+		t.Fatalf("HandleEvent failed: %v", err)
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0] != held.ID || received[1] != event.ID {
+		t.Errorf("Expected the held event and the event once, got %v", received)
+	}
+	if requests := other.received(); len(requests) != 1 || requests[0].header.Get(webhooks.HeaderWebhookID) != event.ID {
+		t.Errorf("Expected the event once, got %d requests", len(requests))
+	}
+}
+
+func TestWebhooksKeepEventsWhenQueueIsFull(t *testing.T) {
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	bus := newTestBus(t, events.BusConfig{MaxAttempts: 1})
+	relay := usecases.NewOutboxRelay(repo, bus, appLogger, usecases.OutboxRelayConfig{RetryBackoff: time.Millisecond})
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{Workers: 1, QueueSize: 1})
+	bus.Subscribe("webhooks", webhookUseCase.HandleEvent)
+	followUseCase := usecases.NewFollowUseCase(repo, repo, appLogger)
+	ctx := context.Background()
+
+	// The receiver holds the first delivery until released
+	release := make(chan struct{})
+	var received []string
+	var mu sync.Mutex
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get(webhooks.HeaderWebhookID))
+		first := len(received) == 1
+		mu.Unlock()
+		if first {
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	releaseHeld := sync.OnceFunc(func() { close(release) })
+	defer releaseHeld()
+	if _, err := webhookUseCase.CreateWebhook(ctx, "user1", receiver.URL, []string{domain.EventUserFollowed}, webhookSecret); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	held := domain.NewUserFollowed("user4", "user1")
+	if err := webhookUseCase.HandleEvent(ctx, held); err != nil {
+		t.Fatalf("HandleEvent failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The first event fills the queue, the second does not fit and stays
+	// in the outbox
+	for _, followerID := range []string{"user2", "user3"} {
+		followUseCase.FollowUser(ctx, followerID, "user1")
+	}
+	if relayed, err := relay.RelayPending(ctx); err != nil || relayed != 1 {
+		t.Fatalf("Expected 1 event relayed, got %d (err: %v)", relayed, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	entries, _ := repo.PendingEvents(ctx, 10)
+	if len(entries) != 1 || entries[0].Attempts != 1 {
+		t.Fatalf("Expected the event that did not fit in the outbox, got %+v", entries)
+	}
+	postponed := entries[0].ID
+
+	// Once the queue drains it is delivered
+	releaseHeld()
+	for time.Now().Before(deadline) {
+		if entries, _ := repo.PendingEvents(ctx, 10); len(entries) == 0 {
+			break
+		}
+		relay.RelayPending(ctx)
+		time.Sleep(5 * time.Millisecond)
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 || received[0] != held.ID || received[2] != postponed {
+		t.Errorf("Expected the 3 events delivered, the postponed one last, got %v", received)
+	}
+	if err := webhookUseCase.HandleEvent(ctx, held); err != usecases.ErrWebhooksClosed {
+		t.Errorf("Expected ErrWebhooksClosed after closing, got %v", err)
+	}
+}
+
+func TestWebhooksDisabledAfterRepeatedFailures(t *testing.T) {
+	repo := memory.NewRepositories()
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{MaxAttempts: 2, DisableAfter: 2})
+	ctx := context.Background()
+
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	failing, _ := webhookUseCase.CreateWebhook(ctx, "user1", receiver.URL, []string{domain.EventUserFollowed}, webhookSecret)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	refused, _ := webhookUseCase.CreateWebhook(ctx, "user1", unreachable.URL, []string{domain.EventUserFollowed}, webhookSecret)
+
+	for _, followerID := range []string{"user2", "user3", "user4"} {
+		webhookUseCase.HandleEvent(ctx, domain.NewUserFollowed(followerID, "user1"))
+	}
+	closeWebhooks(t, webhookUseCase)
+
+	// Two deliveries failed every attempt, so the third one is not made
+	if requests := receiver.received(); len(requests) != 4 {
+		t.Errorf("Expected 2 attempts of 2 deliveries, got %d requests", len(requests))
+	}
+	for _, id := range []string{failing.ID, refused.ID} {
+		webhook, _ := repo.GetWebhook(ctx, id)
+		if webhook.IsEnabled() || webhook.ConsecutiveFailures != 2 {
+			t.Errorf("Expected webhook %s disabled after 2 failures, got %+v", id, webhook)
+		}
+		if deliveries, _ := repo.ListWebhookDeliveries(ctx, id, 0); len(deliveries) != 4 || deliveries[0].Succeeded() {
+			t.Errorf("Expected 4 failed attempts of webhook %s, got %+v", id, deliveries)
+		}
+	}
+
+	// Without a response there is no status
+	deliveries, _ := repo.ListWebhookDeliveries(ctx, refused.ID, 1)
+	if deliveries[0].StatusCode != 0 || deliveries[0].Error == "" {
+		t.Errorf("Expected a connection error without status, got %+v", deliveries[0])
+	}
+	deliveries, _ = repo.ListWebhookDeliveries(ctx, failing.ID, 1)
+	if deliveries[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the status of the failed attempt, got %+v", deliveries[0])
+	}
+
+	// The owner can enable it again
+	if _, err := webhookUseCase.EnableWebhook(ctx, "user2", failing.ID); err != domain.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound enabling another user's webhook, got %v", err)
+	}
+	enabled, err := webhookUseCase.EnableWebhook(ctx, "user1", failing.ID)
+	if err != nil || !enabled.IsEnabled() || enabled.ConsecutiveFailures != 0 {
+		t.Errorf("Expected the webhook enabled, got %+v (err: %v)", enabled, err)
+	}
+	if webhook, _ := repo.GetWebhook(ctx, failing.ID); !webhook.IsEnabled() {
+		t.Errorf("Expected the webhook enabled in the repository, got %+v", webhook)
+	}
+}
+
+func TestWebhookMessageSignature(t *testing.T) {
+	webhook, _ := domain.NewWebhook("user1", "https://example.com/hook", []string{domain.EventTweetCreated}, webhookSecret, false)
+	now := time.Unix(1700000000, 500)
+	message, err := domain.NewWebhookMessage(webhook, domain.NewUserFollowed("user2", "user1"), now)
+	if err != nil {
+		t.Fatalf("NewWebhookMessage failed: %v", err)
+	}
+
+	if !message.Timestamp.Equal(time.Unix(1700000000, 0)) || !strings.HasPrefix(message.Signature, "sha256=") {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+	if !domain.VerifyWebhook(webhookSecret, message.Timestamp, message.Body, message.Signature) {
+		t.Error("Expected the signature to verify")
+	}
+
+	// The timestamp and the body are both signed
+	if domain.VerifyWebhook(webhookSecret, message.Timestamp.Add(time.Second), message.Body, message.Signature) {
+		t.Error("Expected another timestamp not to verify")
+	}
+	tampered := append([]byte(nil), message.Body...)
+	tampered[len(tampered)-2] = 'X'
+	if domain.VerifyWebhook(webhookSecret, message.Timestamp, tampered, message.Signature) {
+		t.Error("Expected a tampered body not to verify")
+	}
+}
+
+func TestWebhooksRejectPrivateAddresses(t *testing.T) {
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://api.localhost./hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://224.0.0.1/hook",
+	} {
+		if _, err := domain.NewWebhook("user1", rawURL, []string{domain.EventTweetCreated}, webhookSecret, false); err != domain.ErrWebhookURLNotPublic {
+			t.Errorf("%s: expected ErrWebhookURLNotPublic, got %v", rawURL, err)
+		}
+		if _, err := domain.NewWebhook("user1", rawURL, []string{domain.EventTweetCreated}, webhookSecret, true); err != nil {
+			t.Errorf("%s: expected it allowed with private targets, got %v", rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{"https://example.com/hook", "http://93.184.216.34/hook", "http://[2606:2800:220:1::]/hook"} {
+		if _, err := domain.NewWebhook("user1", rawURL, []string{domain.EventTweetCreated}, webhookSecret, false); err != nil {
+			t.Errorf("%s: expected a public URL to be accepted, got %v", rawURL, err)
+		}
+	}
+
+	// Host names are checked once resolved, when connecting
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	port := receiver.URL[strings.LastIndex(receiver.URL, ":")+1:]
+	webhook, _ := domain.NewWebhook("user1", "https://example.com/hook", []string{domain.EventTweetCreated}, webhookSecret, false)
+	message, _ := domain.NewWebhookMessage(webhook, domain.NewUserFollowed("user2", "user1"), time.Now())
+	for _, rawURL := range []string{receiver.URL, "http://localhost:" + port} {
+		if _, err := webhooks.NewHTTPSender(time.Second, false).Send(context.Background(), rawURL, message); !errors.Is(err, webhooks.ErrAddressNotAllowed) {
+			t.Errorf("%s: expected ErrAddressNotAllowed, got %v", rawURL, err)
+		}
+	}
+	if len(receiver.received()) != 0 {
+		t.Fatalf("Expected no request to reach the receiver")
+	}
+	if status, err := webhooks.NewHTTPSender(time.Second, true).Send(context.Background(), receiver.URL, message); err != nil || status != http.StatusNoContent {
+		t.Errorf("Expected the delivery allowed with private targets, got %d (err: %v)", status, err)
+	}
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	repo := memory.NewRepositories()
+	appLogger := logger.NewLogger()
+	bus := newTestBus(t, events.BusConfig{})
+	relay := usecases.NewOutboxRelay(repo, bus, appLogger, usecases.OutboxRelayConfig{})
+	webhookUseCase := newTestWebhooks(repo, usecases.WebhookConfig{})
+	t.Cleanup(func() { webhookUseCase.Close(context.Background()) })
+	bus.Subscribe("webhooks", webhookUseCase.HandleEvent)
+	handlers := httpAdapters.NewHandlers(
+		usecases.NewTweetUseCase(repo, repo, repo, nil, appLogger),
+		usecases.NewFollowUseCase(repo, repo, appLogger),
+		usecases.NewUserUseCase(repo, appLogger),
+		appLogger,
+		httpAdapters.WithWebhooks(webhookUseCase),
+	)
+	router := httpAdapters.SetupRoutes(handlers)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(&relayingWriter{ResponseWriter: w, relay: relay}, r)
+	}))
+	defer server.Close()
+
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	create := func(userID string, body interface{}) (int, map[string]interface{}) {
+		t.Helper()
+		var decoded map[string]interface{}
+		status := decodeResponse(headerRequest(t, server, "POST", "/webhooks", userID, body), &decoded)
+		return status, decoded
+	}
+
+	status, created := create("user1", map[string]interface{}{
+		"url": receiver.URL, "event_types": []string{domain.EventTweetCreated, domain.EventTweetCreated}, "secret": webhookSecret,
+	})
+	if status != http.StatusCreated || created["enabled"] != true || len(created["event_types"].([]interface{})) != 1 {
+		t.Fatalf("Expected the webhook created, got %d %v", status, created)
+	}
+	if _, exposed := created["secret"]; exposed {
+		t.Error("Expected the secret not to be returned")
+	}
+	webhookID := created["id"].(string)
+
+	for _, c := range []struct {
+		name string
+		body map[string]interface{}
+		code string
+	}{
+		{"bad url", map[string]interface{}{"url": "ftp://example.com", "event_types": []string{domain.EventTweetCreated}, "secret": webhookSecret}, "invalid_webhook_url"},
+		{"no events", map[string]interface{}{"url": receiver.URL, "event_types": []string{}, "secret": webhookSecret}, "invalid_webhook_events"},
+		{"unknown event", map[string]interface{}{"url": receiver.URL, "event_types": []string{domain.EventTweetLiked}, "secret": webhookSecret}, "invalid_webhook_events"},
+		{"short secret", map[string]interface{}{"url": receiver.URL, "event_types": []string{domain.EventTweetCreated}, "secret": "short"}, "invalid_webhook_secret"},
+	} {
+		if status, problem := create("user1", c.body); status != http.StatusUnprocessableEntity || problem["code"] != c.code {
+			t.Errorf("%s: expected 422 %s, got %d %v", c.name, c.code, status, problem)
+		}
+	}
+	if status, _ := create("", map[string]interface{}{}); status != http.StatusUnauthorized {
+		t.Errorf("Expected anonymous requests to be rejected, got %d", status)
+	}
+
+	// Posting a tweet delivers it to the webhook of its author
+	if status := decodeResponse(headerRequest(t, server, "POST", "/tweets", "user1", map[string]string{"content": "hi"}), nil); status != http.StatusCreated {
+		t.Fatalf("Expected the tweet created, got %d", status)
+	}
+	var deliveries httpAdapters.WebhookDeliveriesResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for len(deliveries.Deliveries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		decodeResponse(headerRequest(t, server, "GET", "/webhooks/"+webhookID+"/deliveries?limit=10", "user1", nil), &deliveries)
+	}
+	if len(deliveries.Deliveries) != 1 || !deliveries.Deliveries[0].Succeeded ||
+		deliveries.Deliveries[0].StatusCode != http.StatusOK || deliveries.Deliveries[0].EventType != domain.EventTweetCreated {
+		t.Errorf("Expected the delivery of the tweet in the log, got %+v", deliveries)
+	}
+
+	// Other users can not see or change the webhook
+	for _, request := range []struct{ method, path string }{
+		{"GET", "/webhooks/" + webhookID + "/deliveries"},
+		{"POST", "/webhooks/" + webhookID + "/enable"},
+		{"DELETE", "/webhooks/" + webhookID},
+	} {
+		var problem httpAdapters.ProblemResponse
+		if status := decodeResponse(headerRequest(t, server, request.method, request.path, "user2", nil), &problem); status != http.StatusNotFound || problem.Code != "webhook_not_found" {
+			t.Errorf("%s %s: expected 404 webhook_not_found, got %d %+v", request.method, request.path, status, problem)
+		}
+	}
+
+	var enabled httpAdapters.WebhookResponse
+	if status := decodeResponse(headerRequest(t, server, "POST", "/webhooks/"+webhookID+"/enable", "user1", nil), &enabled); status != http.StatusOK || !enabled.Enabled {
+		t.Errorf("Expected the webhook enabled, got %d %+v", status, enabled)
+	}
+
+	// A user has a limited number of webhooks
+	for i := 1; i < domain.MaxWebhooksPerUser; i++ {
+		if status, _ := create("user1", map[string]interface{}{"url": receiver.URL, "event_types": []string{domain.EventUserFollowed}, "secret": webhookSecret}); status != http.StatusCreated {
+			t.Fatalf("Expected webhook %d created, got %d", i+1, status)
+		}
+	}
+	if status, problem := create("user1", map[string]interface{}{"url": receiver.URL, "event_types": []string{domain.EventUserFollowed}, "secret": webhookSecret}); status != http.StatusUnprocessableEntity || problem["code"] != "too_many_webhooks" {
+		t.Errorf("Expected 422 too_many_webhooks, got %d %v", status, problem)
+	}
+
+	var list httpAdapters.WebhooksResponse
+	if decodeResponse(headerRequest(t, server, "GET", "/webhooks", "user1", nil), &list); len(list.Webhooks) != domain.MaxWebhooksPerUser {
+		t.Errorf("Expected %d webhooks, got %d", domain.MaxWebhooksPerUser, len(list.Webhooks))
+	}
+	if decodeResponse(headerRequest(t, server, "GET", "/webhooks", "user2", nil), &list); len(list.Webhooks) != 0 {
+		t.Errorf("Expected no webhooks for user2, got %d", len(list.Webhooks))
+	}
+
+	if status := decodeResponse(headerRequest(t, server, "DELETE", "/webhooks/"+webhookID, "user1", nil), nil); status != http.StatusOK {
+		t.Errorf("Expected the webhook deleted, got %d", status)
+	}
+	if status := decodeResponse(headerRequest(t, server, "GET", "/webhooks/"+webhookID+"/deliveries", "user1", nil), nil); status != http.StatusNotFound {
+		t.Errorf("Expected the deleted webhook not found, got %d", status)
+	}
+}